@@ -0,0 +1,142 @@
+// Package cachedownloader downloads files that are kept at a fixed path
+// between runs, such as the packager's dependency cache, revalidating them
+// instead of downloading them again. The Installer does not use it: its
+// downloads are pinned by checksum, so a cached copy never needs asking the
+// server about.
+package cachedownloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// MetadataSuffix is appended to a downloaded file's name for the file
+// recording the validators of the response it came from.
+const MetadataSuffix = ".cache.json"
+
+// Downloader downloads files over HTTP, remembering the ETag and
+// Last-Modified of each response next to the file. Downloading the same uri
+// to the same file again asks the server whether it changed, with
+// If-None-Match and If-Modified-Since, and keeps the file if it did not.
+type Downloader struct {
+	client *http.Client
+}
+
+// New returns a Downloader using client, or http.DefaultClient if client is
+// nil.
+func New(client *http.Client) *Downloader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Downloader{client: client}
+}
+
+// StatusError is returned for responses that are neither successful nor
+// "304 Not Modified".
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("could not download: %d", e.StatusCode)
+}
+
+type metadata struct {
+	URI          string `json:"uri"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Fetch makes file a download of uri, revalidating an earlier download of
+// uri to file rather than fetching it again. It reports whether file was
+// downloaded, as opposed to found unchanged. file is only replaced once the
+// download is complete.
+func (d *Downloader) Fetch(ctx context.Context, uri, file string) (bool, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return false, err
+	}
+
+	cached, hasCached := readMetadata(file)
+	if hasCached && cached.URI == uri {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && hasCached && cached.URI == uri {
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, &StatusError{resp.StatusCode}
+	}
+
+	if err := writeFile(resp.Body, file); err != nil {
+		return false, err
+	}
+
+	m := metadata{URI: uri, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if m.ETag == "" && m.LastModified == "" {
+		os.Remove(file + MetadataSuffix)
+		return true, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return true, err
+	}
+	return true, ioutil.WriteFile(file+MetadataSuffix, data, 0644)
+}
+
+// readMetadata is the recorded metadata of file, if file is there to
+// revalidate.
+func readMetadata(file string) (metadata, bool) {
+	if _, err := os.Stat(file); err != nil {
+		return metadata{}, false
+	}
+	data, err := ioutil.ReadFile(file + MetadataSuffix)
+	if err != nil {
+		return metadata{}, false
+	}
+	var m metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return metadata{}, false
+	}
+	return m, true
+}
+
+func writeFile(source io.Reader, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	partialFile := file + ".partial"
+	output, err := os.Create(partialFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partialFile)
+	defer output.Close()
+
+	if _, err := io.Copy(output, source); err != nil {
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partialFile, file)
+}
@@ -0,0 +1,13 @@
+package cachedownloader_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCachedownloader(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "cachedownloader")
+}
@@ -0,0 +1,140 @@
+package cachedownloader_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack/cachedownloader"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Downloader", func() {
+	var (
+		server     *httptest.Server
+		content    string
+		etag       string
+		modified   string
+		requests   []*http.Request
+		dir        string
+		file       string
+		downloader *cachedownloader.Downloader
+		err        error
+	)
+
+	BeforeEach(func() {
+		content = "dependency"
+		etag = `"v1"`
+		modified = ""
+		requests = nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			if r.URL.Path == "/missing" {
+				http.NotFound(w, r)
+				return
+			}
+			if etag != "" {
+				w.Header().Set("ETag", etag)
+				if r.Header.Get("If-None-Match") == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+			if modified != "" {
+				w.Header().Set("Last-Modified", modified)
+				if r.Header.Get("If-Modified-Since") == modified {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+			w.Write([]byte(content))
+		}))
+
+		dir, err = ioutil.TempDir("", "cachedownloader")
+		Expect(err).To(BeNil())
+		file = filepath.Join(dir, "deps", "file.tgz")
+		downloader = cachedownloader.New(nil)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	fetch := func(uri string) bool {
+		downloaded, err := downloader.Fetch(context.Background(), uri, file)
+		Expect(err).To(BeNil())
+		return downloaded
+	}
+
+	It("downloads the file", func() {
+		Expect(fetch(server.URL + "/file.tgz")).To(BeTrue())
+		Expect(ioutil.ReadFile(file)).To(Equal([]byte("dependency")))
+		Expect(requests[0].Header.Get("If-None-Match")).To(BeEmpty())
+	})
+
+	It("revalidates with the ETag instead of downloading again", func() {
+		fetch(server.URL + "/file.tgz")
+
+		Expect(fetch(server.URL + "/file.tgz")).To(BeFalse())
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[1].Header.Get("If-None-Match")).To(Equal(`"v1"`))
+		Expect(ioutil.ReadFile(file)).To(Equal([]byte("dependency")))
+	})
+
+	It("revalidates with Last-Modified", func() {
+		etag = ""
+		modified = "Tue, 15 Oct 2019 10:00:00 GMT"
+		fetch(server.URL + "/file.tgz")
+
+		Expect(fetch(server.URL + "/file.tgz")).To(BeFalse())
+		Expect(requests[1].Header.Get("If-Modified-Since")).To(Equal(modified))
+	})
+
+	It("downloads again when the file changed", func() {
+		fetch(server.URL + "/file.tgz")
+		content, etag = "new dependency", `"v2"`
+
+		Expect(fetch(server.URL + "/file.tgz")).To(BeTrue())
+		Expect(ioutil.ReadFile(file)).To(Equal([]byte("new dependency")))
+		Expect(fetch(server.URL + "/file.tgz")).To(BeFalse())
+	})
+
+	It("does not revalidate a download of another uri", func() {
+		fetch(server.URL + "/file.tgz")
+
+		Expect(fetch(server.URL + "/other.tgz")).To(BeTrue())
+		Expect(requests[1].Header.Get("If-None-Match")).To(BeEmpty())
+	})
+
+	It("does not revalidate a file that is gone", func() {
+		fetch(server.URL + "/file.tgz")
+		Expect(os.Remove(file)).To(Succeed())
+
+		Expect(fetch(server.URL + "/file.tgz")).To(BeTrue())
+		Expect(file).To(BeAnExistingFile())
+	})
+
+	It("does not revalidate without validators", func() {
+		etag = ""
+		fetch(server.URL + "/file.tgz")
+		Expect(file + cachedownloader.MetadataSuffix).ToNot(BeAnExistingFile())
+
+		Expect(fetch(server.URL + "/file.tgz")).To(BeTrue())
+	})
+
+	It("reports failed downloads and keeps the file", func() {
+		fetch(server.URL + "/file.tgz")
+
+		_, err = downloader.Fetch(context.Background(), server.URL+"/missing", file)
+		Expect(err).To(Equal(&cachedownloader.StatusError{StatusCode: 404}))
+		Expect(err).To(MatchError("could not download: 404"))
+		Expect(ioutil.ReadFile(file)).To(Equal([]byte("dependency")))
+	})
+})
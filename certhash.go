@@ -0,0 +1,180 @@
+package libbuildpack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// rehashCerts does what OpenSSL's c_rehash does for dir: it adds a
+// <subject hash>.<n> file for every PEM certificate in dir, which is how
+// OpenSSL finds certificates in an SSL_CERT_DIR. It returns the names of the
+// files that hold no certificates.
+func rehashCerts(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []string
+	seen := map[string]bool{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				continue
+			}
+			found = true
+			if seen[string(cert.Raw)] {
+				continue
+			}
+			seen[string(cert.Raw)] = true
+
+			hash, err := subjectHash(cert.RawSubject)
+			if err != nil {
+				return nil, fmt.Errorf("could not hash the subject of %s: %v", file.Name(), err)
+			}
+			if err := writeHashedCert(dir, hash, block); err != nil {
+				return nil, err
+			}
+		}
+		if !found {
+			skipped = append(skipped, file.Name())
+		}
+	}
+	return skipped, nil
+}
+
+// writeHashedCert writes block to the first free <hash>.<n> in dir.
+func writeHashedCert(dir, hash string, block *pem.Block) error {
+	for n := 0; ; n++ {
+		path := filepath.Join(dir, fmt.Sprintf("%s.%d", hash, n))
+		if exists, err := FileExists(path); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+		return ioutil.WriteFile(path, pem.EncodeToMemory(block), 0644)
+	}
+}
+
+type attributeTypeAndValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// subjectHash is OpenSSL's X509_NAME_hash of a DER encoded name: the first
+// four bytes, little endian, of the SHA-1 of its canonical encoding. That is
+// the DER of each of its RDNs, with every string value converted to a
+// lowercase UTF8String without leading, trailing or repeated whitespace.
+func subjectHash(rawName []byte) (string, error) {
+	var name asn1.RawValue
+	if rest, err := asn1.Unmarshal(rawName, &name); err != nil {
+		return "", err
+	} else if len(rest) > 0 {
+		return "", fmt.Errorf("trailing data after name")
+	}
+
+	var canonical []byte
+	for rdns := name.Bytes; len(rdns) > 0; {
+		var rdn asn1.RawValue
+		var err error
+		if rdns, err = asn1.Unmarshal(rdns, &rdn); err != nil {
+			return "", err
+		}
+
+		var attributes [][]byte
+		for values := rdn.Bytes; len(values) > 0; {
+			var attribute attributeTypeAndValue
+			if values, err = asn1.Unmarshal(values, &attribute); err != nil {
+				return "", err
+			}
+			if value, ok := canonicalString(attribute.Value); ok {
+				attribute.Value = asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte(value)}
+			}
+			encoded, err := asn1.Marshal(attribute)
+			if err != nil {
+				return "", err
+			}
+			attributes = append(attributes, encoded)
+		}
+		// DER orders the members of a SET OF by their encoding
+		sort.Slice(attributes, func(i, j int) bool { return bytes.Compare(attributes[i], attributes[j]) < 0 })
+
+		encoded, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(attributes, nil)})
+		if err != nil {
+			return "", err
+		}
+		canonical = append(canonical, encoded...)
+	}
+
+	sum := sha1.Sum(canonical)
+	return fmt.Sprintf("%08x", binary.LittleEndian.Uint32(sum[:4])), nil
+}
+
+// canonicalString is the value of a string attribute as OpenSSL compares
+// it, or false for values that are not strings.
+func canonicalString(value asn1.RawValue) (string, bool) {
+	if value.Class != asn1.ClassUniversal {
+		return "", false
+	}
+
+	var s string
+	switch value.Tag {
+	case asn1.TagUTF8String, asn1.TagPrintableString, asn1.TagIA5String, 26: // VisibleString
+		s = string(value.Bytes)
+	case asn1.TagT61String:
+		runes := make([]rune, len(value.Bytes))
+		for i, b := range value.Bytes {
+			runes[i] = rune(b)
+		}
+		s = string(runes)
+	case 30: // BMPString
+		units := make([]uint16, len(value.Bytes)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(value.Bytes[2*i:])
+		}
+		s = string(utf16.Decode(units))
+	case 28: // UniversalString
+		runes := make([]rune, len(value.Bytes)/4)
+		for i := range runes {
+			runes[i] = rune(binary.BigEndian.Uint32(value.Bytes[4*i:]))
+		}
+		s = string(runes)
+	default:
+		return "", false
+	}
+
+	// only ASCII is lowercased and only ASCII whitespace collapsed
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\v' || r == '\f' || r == '\r'
+	})
+	lower := []byte(strings.Join(fields, " "))
+	for i, b := range lower {
+		if 'A' <= b && b <= 'Z' {
+			lower[i] = b + 'a' - 'A'
+		}
+	}
+	return string(lower), true
+}
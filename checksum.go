@@ -0,0 +1,108 @@
+package libbuildpack
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// Checksum verifies a file against an expected digest.
+type Checksum interface {
+	Algorithm() string
+	Verify(path string) error
+}
+
+// ChecksumAlgorithms are the hashes manifest checksums and every other
+// checksum of libbuildpack may use, keyed by the name used in the manifest.
+// Add entries to support other algorithms. md5 and sha1 are too weak to
+// verify a dependency on their own, so ParseChecksums only uses them, like
+// any other algorithm, in addition to a sha256 or sha512.
+var ChecksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	newHash, found := ChecksumAlgorithms[strings.ToLower(algorithm)]
+	if !found {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	return newHash(), nil
+}
+
+// HashChecksum is a Checksum for one of ChecksumAlgorithms with a hex
+// encoded expected digest.
+type HashChecksum struct {
+	Name     string
+	Expected string
+}
+
+func NewChecksum(algorithm, expected string) (Checksum, error) {
+	if _, found := ChecksumAlgorithms[strings.ToLower(algorithm)]; !found {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	return HashChecksum{Name: strings.ToLower(algorithm), Expected: expected}, nil
+}
+
+func (c HashChecksum) Algorithm() string {
+	return c.Name
+}
+
+func (c HashChecksum) Verify(path string) error {
+	actual, err := fileChecksum(path, c.Name)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, c.Expected) {
+		return fmt.Errorf("dependency %[1]s mismatch: expected %[1]s %[2]s, actual %[1]s %[3]s", c.Name, c.Expected, actual)
+	}
+	return nil
+}
+
+// ParseChecksums builds the Checksums for the sha256 and sha512 fields of a
+// dependency and its map of any other algorithms. The other algorithms are
+// only checked as well as a sha256 or sha512: with neither given it includes
+// an empty sha256, which nothing matches.
+func ParseChecksums(sha256, sha512 string, others map[string]string) ([]Checksum, error) {
+	var checksums []Checksum
+	if sha256 == "" && sha512 == "" {
+		checksums = append(checksums, HashChecksum{Name: "sha256"})
+	}
+	if sha256 != "" {
+		checksums = append(checksums, HashChecksum{Name: "sha256", Expected: sha256})
+	}
+	if sha512 != "" {
+		checksums = append(checksums, HashChecksum{Name: "sha512", Expected: sha512})
+	}
+
+	var algorithms []string
+	for algorithm := range others {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		checksum, err := NewChecksum(algorithm, others[algorithm])
+		if err != nil {
+			return nil, err
+		}
+		checksums = append(checksums, checksum)
+	}
+	return checksums, nil
+}
+
+// VerifyChecksums checks path against every one of checksums.
+func VerifyChecksums(path string, checksums []Checksum) error {
+	for _, checksum := range checksums {
+		if err := checksum.Verify(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
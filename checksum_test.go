@@ -0,0 +1,85 @@
+package libbuildpack_test
+
+import (
+	"hash"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checksums", func() {
+	const (
+		sha256Sum = "fdf72806b9bc1a1bc78be1bfc21978d03591dea5042304211b81235dbf87bd77"
+		sha512Sum = "7626c76ae888d50f1e479a8311380a326f28dfd1ee4d6e010404f45dc558ea1334bf18290ba23526a3fdc139f4f4ac0b8467c692d7e1a0b25040bcabfd6dea7d"
+	)
+
+	var file string
+
+	BeforeEach(func() {
+		dir, err := ioutil.TempDir("", "checksums")
+		Expect(err).To(BeNil())
+		file = filepath.Join(dir, "thing.tgz")
+		Expect(ioutil.WriteFile(file, []byte("exciting binary data"), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(filepath.Dir(file))
+		delete(libbuildpack.ChecksumAlgorithms, "fnv64a")
+	})
+
+	It("verifies sha256 and sha512 digests", func() {
+		checksums, err := libbuildpack.ParseChecksums(sha256Sum, "", nil)
+		Expect(err).To(BeNil())
+		Expect(libbuildpack.VerifyChecksums(file, checksums)).To(Succeed())
+
+		checksums, err = libbuildpack.ParseChecksums("", sha512Sum, nil)
+		Expect(err).To(BeNil())
+		Expect(libbuildpack.VerifyChecksums(file, checksums)).To(Succeed())
+	})
+
+	It("requires every declared checksum to match", func() {
+		checksums, err := libbuildpack.ParseChecksums(sha256Sum, "00", nil)
+		Expect(err).To(BeNil())
+		Expect(checksums).To(HaveLen(2))
+		Expect(libbuildpack.VerifyChecksums(file, checksums)).To(MatchError(MatchRegexp(`^dependency sha512 mismatch: expected sha512 00, actual sha512 [0-9a-f]{128}$`)))
+	})
+
+	It("treats a dependency without checksums as a sha256 mismatch", func() {
+		checksums, err := libbuildpack.ParseChecksums("", "", nil)
+		Expect(err).To(BeNil())
+		Expect(libbuildpack.VerifyChecksums(file, checksums)).To(MatchError("dependency sha256 mismatch: expected sha256 , actual sha256 " + sha256Sum))
+	})
+
+	It("supports registered algorithms", func() {
+		_, err := libbuildpack.ParseChecksums("", "", map[string]string{"fnv64a": "x"})
+		Expect(err).To(MatchError(`unsupported checksum algorithm "fnv64a"`))
+
+		libbuildpack.ChecksumAlgorithms["fnv64a"] = func() hash.Hash { return fnv.New64a() }
+		checksums, err := libbuildpack.ParseChecksums(sha256Sum, "", map[string]string{"fnv64a": "0000000000000000"})
+		Expect(err).To(BeNil())
+		Expect(checksums[1].Algorithm()).To(Equal("fnv64a"))
+		Expect(libbuildpack.VerifyChecksums(file, checksums)).To(MatchError(ContainSubstring("dependency fnv64a mismatch")))
+		Expect(libbuildpack.VerifyFileChecksum(file, "fnv64a", "0000000000000000")).To(MatchError(ContainSubstring("fnv64a mismatch")))
+	})
+
+	It("does not accept other checksums without a sha256 or sha512", func() {
+		checksums, err := libbuildpack.ParseChecksums("", "", map[string]string{"md5": "7712b658293ea4b2c8505843b0e15441", "sha1": "16e364dff8e2443ff70ad29d9ce28e2c27928b1c"})
+		Expect(err).To(BeNil())
+		Expect(libbuildpack.VerifyChecksums(file, checksums)).To(MatchError("dependency sha256 mismatch: expected sha256 , actual sha256 " + sha256Sum))
+
+		checksums, err = libbuildpack.ParseChecksums("", sha512Sum, map[string]string{"sha1": "00"})
+		Expect(err).To(BeNil())
+		Expect(libbuildpack.VerifyChecksums(file, checksums)).To(MatchError(ContainSubstring("dependency sha1 mismatch")))
+	})
+
+	It("supports md5 and sha1 for other checksums", func() {
+		Expect(libbuildpack.VerifyFileChecksum(file, "md5", "00")).To(MatchError(MatchRegexp(`md5 mismatch: expected 00, actual [0-9a-f]{32}$`)))
+		Expect(libbuildpack.VerifyFileChecksum(file, "sha1", "00")).To(MatchError(MatchRegexp(`sha1 mismatch: expected 00, actual [0-9a-f]{40}$`)))
+	})
+})
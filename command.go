@@ -1,21 +1,72 @@
 package libbuildpack
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Command runs programs for a buildpack. If Timeout is set, a program that
+// runs longer is killed, along with every process it started, and a
+// *CommandTimeoutError is returned. Env holds KEY=value pairs set for the
+// programs on top of the buildpack's own environment, overriding variables
+// of the same name. With TTY, programs run on a pseudo-terminal, for tools
+// that only report progress or behave correctly on one; their stdout and
+// stderr are both written to the stdout writer, so ExecuteTee streams them
+// through the Logger line by line. TTY is only supported on Linux.
 type Command struct {
+	Timeout time.Duration
+	Env     []string
+	TTY     bool
 }
 
+// WithEnv returns a copy of c that also sets the KEY=value pairs in env, for
+// a single invocation like
+//
+//	c.WithEnv("NODE_ENV=production").ExecuteTee(dir, tee, "npm", "install")
+func (c *Command) WithEnv(env ...string) *Command {
+	copied := *c
+	copied.Env = append(append([]string{}, c.Env...), env...)
+	return &copied
+}
+
+// CommandTimeoutError reports a program killed because it ran past its
+// timeout or the deadline of its context.
+type CommandTimeoutError struct {
+	Program string
+	Timeout time.Duration
+}
+
+func (e *CommandTimeoutError) Error() string {
+	if e.Timeout == 0 {
+		return fmt.Sprintf("%s timed out", e.Program)
+	}
+	return fmt.Sprintf("%s timed out after %s", e.Program, e.Timeout)
+}
+
+// Execute runs program in dir with its output written to stdout and stderr,
+// within c.Timeout if one is set.
 func (c *Command) Execute(dir string, stdout io.Writer, stderr io.Writer, program string, args ...string) error {
+	return c.ExecuteWithContext(context.Background(), dir, stdout, stderr, program, args...)
+}
+
+// ExecuteWithContext is Execute, killing the program and every process it
+// started if ctx is done before it exits. It then returns ctx.Err(), or a
+// *CommandTimeoutError if the deadline passed.
+func (c *Command) ExecuteWithContext(ctx context.Context, dir string, stdout io.Writer, stderr io.Writer, program string, args ...string) error {
 	cmd := exec.Command(program, args...)
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	cmd.Dir = dir
 
-	return cmd.Run()
+	return c.run(ctx, cmd)
 }
 
 func (c *Command) Output(dir string, program string, args ...string) (string, error) {
@@ -23,14 +74,245 @@ func (c *Command) Output(dir string, program string, args ...string) (string, er
 	cmd.Stderr = os.Stderr // TODO remove this line
 	cmd.Dir = dir
 
-	output, err := cmd.Output()
-	return string(output), err
+	output := new(bytes.Buffer)
+	cmd.Stdout = output
+	err := c.run(context.Background(), cmd)
+	return output.String(), err
+}
+
+// run runs cmd within c.Timeout, killing its process group once ctx is
+// done: killing only the program would leave children like the workers of
+// npm or bundler holding its output open, so Wait would not return. The
+// program only gets a process group of its own when it may be killed.
+func (c *Command) run(ctx context.Context, cmd *exec.Cmd) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err == context.DeadlineExceeded {
+		return &CommandTimeoutError{Program: filepath.Base(cmd.Path), Timeout: c.Timeout}
+	} else if err != nil {
+		return err
+	}
+
+	if len(c.Env) > 0 && cmd.Env == nil {
+		// later entries win, so these override the inherited ones
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	// a context that can never be done has nothing to kill, so the program
+	// stays in the caller's process group, as for Ctrl-C
+	killable := ctx.Done() != nil
+	finish := func() {}
+	if c.TTY {
+		var err error
+		if finish, err = startOnPTY(cmd); err != nil {
+			return err
+		}
+	} else {
+		if killable {
+			setProcessGroup(cmd)
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+	}
+
+	if killable {
+		exited := make(chan struct{})
+		defer close(exited)
+		go func() {
+			select {
+			case <-ctx.Done():
+				killProcessGroup(cmd)
+			case <-exited:
+			}
+		}()
+	}
+
+	stopHeartbeat := func() {}
+	if h, ok := cmd.Stdout.(heartbeater); ok {
+		stopHeartbeat = h.heartbeat(filepath.Base(cmd.Path))
+	}
+
+	err := cmd.Wait()
+	stopHeartbeat()
+	finish()
+	flushOutput(cmd.Stdout)
+	flushOutput(cmd.Stderr)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return &CommandTimeoutError{Program: filepath.Base(cmd.Path), Timeout: c.Timeout}
+	} else if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// heartbeater is implemented by the writers that log command output, so a
+// long quiet command still shows up in the staging log.
+type heartbeater interface {
+	heartbeat(what string) (stop func())
+}
+
+// flushOutput writes out what w, like Logger.Output or an OutputTee, holds
+// back waiting for the end of a line.
+func flushOutput(w io.Writer) {
+	if f, ok := w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
 }
 
+// Run runs cmd like cmd.Run, with c.Env and c.Timeout applied.
 func (c *Command) Run(cmd *exec.Cmd) error {
-	return cmd.Run()
+	return c.run(context.Background(), cmd)
 }
 
+// RunWithOutput runs cmd like cmd.Output, with c.Env and c.Timeout applied.
 func (c *Command) RunWithOutput(cmd *exec.Cmd) ([]byte, error) {
-	return cmd.Output()
+	output := new(bytes.Buffer)
+	cmd.Stdout = output
+	var stderr *bytes.Buffer
+	if cmd.Stderr == nil {
+		// as cmd.Output does, so callers can report why cmd failed
+		stderr = new(bytes.Buffer)
+		cmd.Stderr = stderr
+	}
+	err := c.run(context.Background(), cmd)
+	if exitErr, ok := err.(*exec.ExitError); ok && stderr != nil {
+		exitErr.Stderr = stderr.Bytes()
+	}
+	return output.Bytes(), err
+}
+
+// ExecuteTee runs program like Execute with both stdout and stderr written to
+// tee, flushing any unterminated last line once the command exits.
+func (c *Command) ExecuteTee(dir string, tee *OutputTee, program string, args ...string) error {
+	if tee.logger != nil {
+		tee.logger.Debug("Running %s in %s", tee.redacted(strings.Join(append([]string{program}, args...), " ")), dir)
+	}
+	defer tee.Flush()
+	return c.ExecuteWithContext(context.Background(), dir, tee, tee, program, args...)
+}
+
+// maxOutputLine is the longest line OutputTee holds back waiting for its
+// newline.
+const maxOutputLine = 64 * 1024
+
+// OutputTee streams command output line by line to a Logger while capturing
+// it for assertions and error messages. It is safe to use as both stdout and
+// stderr of one command. Every occurrence of a redacted string, credentials
+// in urls and matches of the logger's Redact patterns are replaced before
+// output is logged or captured, and only the last maxCapture bytes are kept
+// (all of it if maxCapture is 0). CaptureTo adds a writer, such as a log
+// file, that gets every redacted line.
+type OutputTee struct {
+	logger     *Logger
+	maxCapture int
+	redact     []string
+	capture    []io.Writer
+
+	mu        sync.Mutex
+	partial   []byte
+	captured  []byte
+	truncated bool
+}
+
+func NewOutputTee(logger *Logger, maxCapture int, redact ...string) *OutputTee {
+	return &OutputTee{logger: logger, maxCapture: maxCapture, redact: redact}
+}
+
+func (t *OutputTee) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+	for {
+		idx := bytes.IndexByte(t.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		t.writeLine(string(t.partial[:idx]))
+		t.partial = t.partial[idx+1:]
+	}
+	// output without newlines, like a progress bar, is written out in
+	// pieces rather than held on to until the command exits
+	for len(t.partial) >= maxOutputLine {
+		t.writeLine(string(t.partial[:maxOutputLine]))
+		t.partial = t.partial[maxOutputLine:]
+	}
+	return len(p), nil
+}
+
+// CaptureTo has every line from now on also written to w, redacted and
+// newline terminated. Errors writing to w are ignored so that they do not
+// fail the command.
+func (t *OutputTee) CaptureTo(w io.Writer) *OutputTee {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.capture = append(t.capture, w)
+	return t
+}
+
+func (t *OutputTee) heartbeat(what string) (stop func()) {
+	if t.logger == nil {
+		return func() {}
+	}
+	return t.logger.Heartbeat(what, DefaultHeartbeatInterval)
+}
+
+// Flush writes out a trailing line that was not terminated by a newline.
+func (t *OutputTee) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.partial) > 0 {
+		t.writeLine(string(t.partial))
+		t.partial = nil
+	}
+}
+
+// String returns the captured, redacted output.
+func (t *OutputTee) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.captured)
+}
+
+// Truncated reports whether output was dropped to stay within maxCapture.
+func (t *OutputTee) Truncated() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.truncated
+}
+
+// redacted is s with every redacted string, and whatever the logger
+// redacts, replaced.
+func (t *OutputTee) redacted(s string) string {
+	for _, secret := range t.redact {
+		if secret != "" {
+			s = strings.Replace(s, secret, "[REDACTED]", -1)
+		}
+	}
+	if t.logger == nil {
+		return RedactURIs(s)
+	}
+	return t.logger.redacted(s)
+}
+
+func (t *OutputTee) writeLine(line string) {
+	line = t.redacted(line)
+
+	if t.logger != nil {
+		t.logger.printRaw(msgPrefix + line)
+	}
+
+	for _, w := range t.capture {
+		io.WriteString(w, line+"\n")
+	}
+
+	t.captured = append(t.captured, line+"\n"...)
+	if t.maxCapture > 0 && len(t.captured) > t.maxCapture {
+		t.captured = t.captured[len(t.captured)-t.maxCapture:]
+		t.truncated = true
+	}
 }
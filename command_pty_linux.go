@@ -0,0 +1,152 @@
+// +build linux
+
+package libbuildpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ptyDrainTimeout is how long output may pause once the program has exited
+// before the rest is given up on: a process it daemonized can keep the
+// terminal open, and with it the copy, indefinitely.
+var ptyDrainTimeout = 250 * time.Millisecond
+
+// startOnPTY starts cmd in a session of its own with a pseudo-terminal as its
+// controlling terminal, stdout and stderr, and stdin unless cmd has one. What
+// the program writes to the terminal is copied to the stdout cmd had.
+// Calling the returned function once cmd has exited waits for the copy to
+// finish, or for output to pause for ptyDrainTimeout.
+func startOnPTY(cmd *exec.Cmd) (func(), error) {
+	master, tty, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+
+	output := cmd.Stdout
+	if output == nil {
+		output = ioutil.Discard
+	}
+	if cmd.Stdin == nil {
+		cmd.Stdin = tty
+	}
+	cmd.Stdout, cmd.Stderr = tty, tty
+	// a session leader heads its own process group, which killProcessGroup
+	// kills
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 1}
+
+	err = cmd.Start()
+	tty.Close()
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	var exited int32
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		out := &ptyOutput{w: output}
+		buf := make([]byte, 32*1024)
+		for {
+			// reading fails with EIO once every process has closed the
+			// terminal, or once output pauses after the program exited
+			n, err := master.Read(buf)
+			if n > 0 {
+				out.Write(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+			if atomic.LoadInt32(&exited) == 1 {
+				master.SetReadDeadline(time.Now().Add(ptyDrainTimeout))
+			}
+		}
+	}()
+	return func() {
+		atomic.StoreInt32(&exited, 1)
+		if err := master.SetReadDeadline(time.Now().Add(ptyDrainTimeout)); err != nil {
+			master.Close()
+		}
+		<-copied
+		master.Close()
+	}, nil
+}
+
+// openPTY opens a pseudo-terminal of 120 columns by 40 rows, returning its
+// master side and the terminal itself.
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open pseudo-terminal: %v", err)
+	}
+
+	var unlock int32
+	var number uint32
+	size := struct{ rows, cols, x, y uint16 }{rows: 40, cols: 120}
+	// master.Fd would put the master into blocking mode, where reads ignore
+	// the deadlines startOnPTY drains it with
+	conn, err := master.SyscallConn()
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("could not set up pseudo-terminal: %v", err)
+	}
+	var errno syscall.Errno
+	conn.Control(func(fd uintptr) {
+		for _, ioctl := range []struct {
+			request uintptr
+			arg     unsafe.Pointer
+		}{
+			{syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)},
+			{syscall.TIOCGPTN, unsafe.Pointer(&number)},
+			{syscall.TIOCSWINSZ, unsafe.Pointer(&size)},
+		} {
+			if _, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, ioctl.request, uintptr(ioctl.arg)); errno != 0 {
+				return
+			}
+		}
+	})
+	if errno != 0 {
+		master.Close()
+		return nil, nil, fmt.Errorf("could not set up pseudo-terminal: %v", errno)
+	}
+
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("could not open pseudo-terminal: %v", err)
+	}
+	return master, tty, nil
+}
+
+// ptyOutput turns the CRLF line endings of a terminal back into newlines.
+type ptyOutput struct {
+	w  io.Writer
+	cr bool
+}
+
+func (p *ptyOutput) Write(b []byte) (int, error) {
+	out := b
+	if p.cr {
+		if len(b) == 0 || b[0] != '\n' {
+			out = append([]byte{'\r'}, b...)
+		}
+		p.cr = false
+	}
+	if bytes.HasSuffix(out, []byte{'\r'}) {
+		out = out[:len(out)-1]
+		p.cr = true
+	}
+	if _, err := p.w.Write(bytes.Replace(out, []byte("\r\n"), []byte("\n"), -1)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
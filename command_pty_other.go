@@ -0,0 +1,12 @@
+// +build !linux
+
+package libbuildpack
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func startOnPTY(cmd *exec.Cmd) (func(), error) {
+	return nil, errors.New("running commands on a pseudo-terminal is only supported on linux")
+}
@@ -0,0 +1,14 @@
+// +build windows
+
+package libbuildpack
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd; Windows does not kill the processes it started.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
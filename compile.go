@@ -0,0 +1,97 @@
+package libbuildpack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CompilePhase is a supply or finalize phase run by Compile.
+type CompilePhase func(*Stager) error
+
+const compileDepsIdx = "0"
+
+// Compile runs supply and then finalize for the legacy single
+// "bin/compile BUILD_DIR CACHE_DIR" entrypoint, so that a buildpack still
+// shipping bin/compile can be built on the supply and finalize API while it
+// migrates. It lays out the deps dir under BUILD_DIR/.cloudfoundry, as the
+// platform's multi-buildpack lifecycle would, warns that bin/compile is
+// deprecated, writes the launch environment finalize would otherwise have
+// had to, removes the files registered as sensitive and logs how long each
+// step took.
+func Compile(args []string, logger *Logger, manifest *Manifest, supply, finalize CompilePhase) error {
+	if len(args) < 2 {
+		return errors.New("compile needs BUILD_DIR and CACHE_DIR arguments")
+	}
+	logger.Warning(compileDeprecationWarning)
+
+	buildDir := args[0]
+	depsDir := filepath.Join(buildDir, ".cloudfoundry")
+	profileDir := filepath.Join(buildDir, ".profile.d")
+
+	if err := os.MkdirAll(filepath.Join(depsDir, compileDepsIdx), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(profileDir, 0755); err != nil {
+		return err
+	}
+	// the launch environment refers to $DEPS_DIR, which only the
+	// multi-buildpack lifecycle sets
+	depsDirScript := `export DEPS_DIR="$HOME/.cloudfoundry"` + "\n"
+	if err := writeToFile(strings.NewReader(depsDirScript), filepath.Join(profileDir, "0000_set-deps-dir.sh"), 0755); err != nil {
+		return err
+	}
+	if err := os.Setenv("DEPS_DIR", depsDir); err != nil {
+		return err
+	}
+
+	stager := NewStager([]string{buildDir, args[1], depsDir, compileDepsIdx, profileDir}, logger, manifest)
+	unlockDepDir, err := stager.LockDepDir()
+	if err != nil {
+		return err
+	}
+	defer unlockDepDir()
+
+	tracer, metrics := NewTracer(), NewMetrics()
+	defer metrics.Close()
+	if supply != nil {
+		if err := runCompilePhase(tracer, metrics, "supply", supply, stager); err != nil {
+			return err
+		}
+	} else {
+		logger.Warning(compileMissingPhaseWarning("supply"))
+	}
+
+	if err := stager.SetStagingEnvironment(); err != nil {
+		return err
+	}
+
+	if finalize != nil {
+		if err := runCompilePhase(tracer, metrics, "finalize", finalize, stager); err != nil {
+			return err
+		}
+	} else {
+		logger.Warning(compileMissingPhaseWarning("finalize"))
+	}
+
+	if err := stager.SetLaunchEnvironment(); err != nil {
+		return err
+	}
+	if err := stager.ScrubSensitiveFiles(); err != nil {
+		return err
+	}
+	logger.StepSummary()
+	return nil
+}
+
+// runCompilePhase runs phase as the root span of its trace, reporting how
+// long it took.
+func runCompilePhase(tracer *Tracer, metrics *Metrics, name string, phase CompilePhase, stager *Stager) error {
+	span := tracer.StartPhase(name, nil)
+	done := metrics.Phase(name)
+	err := phase(stager)
+	done()
+	span.End(err)
+	return err
+}
@@ -0,0 +1,135 @@
+package libbuildpack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compile", func() {
+	var (
+		buildDir   string
+		cacheDir   string
+		buffer     *bytes.Buffer
+		logger     *libbuildpack.Logger
+		manifest   *libbuildpack.Manifest
+		oldDepsDir string
+		phases     []string
+		err        error
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip("bin/compile is not used on Windows")
+		}
+
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		cacheDir, err = ioutil.TempDir("", "cache")
+		Expect(err).To(BeNil())
+
+		buffer = new(bytes.Buffer)
+		logger = libbuildpack.NewLogger(buffer)
+		manifest, err = libbuildpack.NewManifest(filepath.Join("fixtures", "manifest", "standard"), logger, time.Now())
+		Expect(err).To(BeNil())
+
+		oldDepsDir = os.Getenv("DEPS_DIR")
+		phases = nil
+	})
+
+	AfterEach(func() {
+		os.Setenv("DEPS_DIR", oldDepsDir)
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(cacheDir)).To(Succeed())
+	})
+
+	supply := func(s *libbuildpack.Stager) error {
+		phases = append(phases, "supply")
+		Expect(os.MkdirAll(filepath.Join(s.DepDir(), "bin"), 0755)).To(Succeed())
+		return nil
+	}
+	finalize := func(s *libbuildpack.Stager) error {
+		phases = append(phases, "finalize")
+		return nil
+	}
+
+	It("runs supply and then finalize with a deps dir in the build dir", func() {
+		var depsDir string
+		err = libbuildpack.Compile([]string{buildDir, cacheDir}, logger, manifest, func(s *libbuildpack.Stager) error {
+			depsDir = s.DepsDir()
+			Expect(s.BuildDir()).To(Equal(buildDir))
+			Expect(s.CacheDir()).To(Equal(cacheDir))
+			Expect(s.DepsIdx()).To(Equal("0"))
+			return supply(s)
+		}, func(s *libbuildpack.Stager) error {
+			Expect(os.Getenv("PATH")).To(HavePrefix(filepath.Join(buildDir, ".cloudfoundry", "0", "bin") + ":"))
+			return finalize(s)
+		})
+		Expect(err).To(BeNil())
+
+		Expect(phases).To(Equal([]string{"supply", "finalize"}))
+		Expect(depsDir).To(Equal(filepath.Join(buildDir, ".cloudfoundry")))
+		Expect(os.Getenv("DEPS_DIR")).To(Equal(depsDir))
+	})
+
+	It("warns that bin/compile is deprecated", func() {
+		Expect(libbuildpack.Compile([]string{buildDir, cacheDir}, logger, manifest, supply, finalize)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring("This buildpack was run through the deprecated bin/compile entrypoint."))
+		Expect(buffer.String()).To(ContainSubstring("provide bin/supply and bin/finalize instead"))
+	})
+
+	It("writes the launch environment", func() {
+		Expect(libbuildpack.Compile([]string{buildDir, cacheDir}, logger, manifest, supply, finalize)).To(Succeed())
+
+		Expect(ioutil.ReadFile(filepath.Join(buildDir, ".profile.d", "0000_set-deps-dir.sh"))).To(Equal([]byte(`export DEPS_DIR="$HOME/.cloudfoundry"` + "\n")))
+		Expect(ioutil.ReadFile(filepath.Join(buildDir, ".profile.d", "000_multi-supply.sh"))).To(ContainSubstring(`export PATH=$DEPS_DIR/0/bin`))
+	})
+
+	It("reports how long each phase took", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		defer conn.Close()
+		os.Setenv("BP_METRICS_STATSD_ADDR", conn.LocalAddr().String())
+		defer os.Unsetenv("BP_METRICS_STATSD_ADDR")
+
+		Expect(libbuildpack.Compile([]string{buildDir, cacheDir}, logger, manifest, supply, finalize)).To(Succeed())
+
+		var lines []string
+		for i := 0; i < 2; i++ {
+			buf := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err).To(BeNil())
+			lines = append(lines, string(buf[:n]))
+		}
+		Expect(lines[0]).To(MatchRegexp(`^libbuildpack\.phase\.duration:[0-9.e+-]+\|ms\|#phase:supply$`))
+		Expect(lines[1]).To(MatchRegexp(`^libbuildpack\.phase\.duration:[0-9.e+-]+\|ms\|#phase:finalize$`))
+	})
+
+	It("warns about a missing phase", func() {
+		Expect(libbuildpack.Compile([]string{buildDir, cacheDir}, logger, manifest, nil, finalize)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring("This buildpack has no supply phase."))
+		Expect(phases).To(Equal([]string{"finalize"}))
+	})
+
+	It("stops at a failing phase", func() {
+		err = libbuildpack.Compile([]string{buildDir, cacheDir}, logger, manifest, func(*libbuildpack.Stager) error {
+			return os.ErrPermission
+		}, finalize)
+		Expect(err).To(Equal(os.ErrPermission))
+		Expect(phases).To(BeEmpty())
+	})
+
+	It("needs the build and cache dirs", func() {
+		err = libbuildpack.Compile([]string{buildDir}, logger, manifest, supply, finalize)
+		Expect(err).To(MatchError("compile needs BUILD_DIR and CACHE_DIR arguments"))
+	})
+})
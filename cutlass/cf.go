@@ -18,8 +18,13 @@ import (
 )
 
 type cfConfig struct {
+	OrganizationFields struct {
+		GUID string
+		Name string
+	}
 	SpaceFields struct {
 		GUID string
+		Name string
 	}
 }
 type cfApps struct {
@@ -30,27 +35,30 @@ type cfApps struct {
 	} `json:"resources"`
 }
 type cfInstance struct {
-	State string `json:"state"`
+	State string  `json:"state"`
+	Since float64 `json:"since"`
 }
 
 type App struct {
-	Name         string
-	Path         string
-	Stack        string
-	Buildpacks   []string
-	Memory       string
-	Disk         string
-	StartCommand string
-	Stdout       *Buffer
-	appGUID      string
-	env          map[string]string
-	logCmd       *exec.Cmd
-	HealthCheck  string
+	Name          string
+	Path          string
+	Stack         string
+	Buildpacks    []string
+	Memory        string
+	Disk          string
+	StartCommand  string
+	Stdout        *Buffer
+	appGUID       string
+	env           map[string]string
+	logCmd        *exec.Cmd
+	previousStack string
+	HealthCheck   string
+	Labels        map[string]string
 }
 
 func New(fixture string) *App {
 	return &App{
-		Name:         filepath.Base(fixture) + "-" + RandStringRunes(20),
+		Name:         ResourceName(filepath.Base(fixture)),
 		Path:         fixture,
 		Stack:        os.Getenv("CF_STACK"),
 		Buildpacks:   []string{},
@@ -61,13 +69,14 @@ func New(fixture string) *App {
 		env:          map[string]string{},
 		logCmd:       nil,
 		HealthCheck:  "",
+		Labels:       ResourceLabels(),
 	}
 }
 
 func ApiVersion() (string, error) {
 	cmd := exec.Command("cf", "curl", "/v2/info")
 	cmd.Stderr = DefaultStdoutStderr
-	bytes, err := cmd.Output()
+	bytes, err := cfOutput(cmd)
 	if err != nil {
 		return "", err
 	}
@@ -99,7 +108,7 @@ func ApiGreaterThan(version string) (bool, error) {
 func Stacks() ([]string, error) {
 	cmd := exec.Command("cf", "curl", "/v2/stacks")
 	cmd.Stderr = DefaultStdoutStderr
-	bytes, err := cmd.Output()
+	bytes, err := cfOutput(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +133,7 @@ func DeleteOrphanedRoutes() error {
 	command := exec.Command("cf", "delete-orphaned-routes", "-f")
 	command.Stdout = DefaultStdoutStderr
 	command.Stderr = DefaultStdoutStderr
-	if err := command.Run(); err != nil {
+	if err := cfRun(command); err != nil {
 		return err
 	}
 	return nil
@@ -132,7 +141,7 @@ func DeleteOrphanedRoutes() error {
 
 func DeleteBuildpack(language string) error {
 	command := exec.Command("cf", "delete-buildpack", "-f", fmt.Sprintf("%s_buildpack", language))
-	if data, err := command.CombinedOutput(); err != nil {
+	if data, err := cfCombinedOutput(command); err != nil {
 		fmt.Println(string(data))
 		return err
 	}
@@ -152,7 +161,7 @@ func UpdateBuildpack(language, file, stack string) error {
 	}
 
 	command := exec.Command("cf", updateBuildpackArgs...)
-	if data, err := command.CombinedOutput(); err != nil {
+	if data, err := cfCombinedOutput(command); err != nil {
 		return fmt.Errorf("Failed to update buildpack by running '%s':\n%s\n%v", strings.Join(command.Args, " "), string(data), err)
 	}
 	return nil
@@ -160,17 +169,30 @@ func UpdateBuildpack(language, file, stack string) error {
 
 func createBuildpack(language, file string) error {
 	command := exec.Command("cf", "create-buildpack", fmt.Sprintf("%s_buildpack", language), file, "100", "--enable")
-	if data, err := command.CombinedOutput(); err != nil {
+	if data, err := cfCombinedOutput(command); err != nil {
 		return fmt.Errorf("Failed to create buildpack by running '%s':\n%s\n%v", strings.Join(command.Args, " "), string(data), err)
 	}
+	labelBuildpack(fmt.Sprintf("%s_buildpack", language))
 	return nil
 }
 
+// CreateService creates an instance of a marketplace service, named by
+// ResourceName and labelled like apps, and returns its name.
+func CreateService(service, plan, base string) (string, error) {
+	name := ResourceName(base)
+	command := exec.Command("cf", "create-service", service, plan, name)
+	if data, err := cfCombinedOutput(command); err != nil {
+		return "", fmt.Errorf("Failed to create service by running '%s':\n%s\n%v", strings.Join(command.Args, " "), string(data), err)
+	}
+	labelService(name)
+	return name, nil
+}
+
 func CountBuildpack(language string) (int, error) {
 	command := exec.Command("cf", "buildpacks")
 	targetBpname := fmt.Sprintf("%s_buildpack", language)
 	matches := 0
-	lines, err := command.CombinedOutput()
+	lines, err := cfCombinedOutput(command)
 	if err != nil {
 		return -1, err
 	}
@@ -196,7 +218,7 @@ func (a *App) ConfirmBuildpack(version string) error {
 				versionLine = line
 			}
 		}
-		return fmt.Errorf("Wrong buildpack version. Expected '%s', but this was logged: %s", version, versionLine)
+		return a.withDiagnostics(fmt.Errorf("Wrong buildpack version. Expected '%s', but this was logged: %s", version, versionLine))
 	}
 	return nil
 }
@@ -204,7 +226,7 @@ func (a *App) ConfirmBuildpack(version string) error {
 func (a *App) RunTask(command string) ([]byte, error) {
 	cmd := exec.Command("cf", "run-task", a.Name, command)
 	cmd.Stderr = DefaultStdoutStderr
-	bytes, err := cmd.Output()
+	bytes, err := cfOutput(cmd)
 	if err != nil {
 		return bytes, err
 	}
@@ -215,7 +237,7 @@ func (a *App) Stop() error {
 	command := exec.Command("cf", "stop", a.Name)
 	command.Stdout = DefaultStdoutStderr
 	command.Stderr = DefaultStdoutStderr
-	if err := command.Run(); err != nil {
+	if err := cfRun(command); err != nil {
 		return err
 	}
 	return nil
@@ -225,7 +247,7 @@ func (a *App) Restart() error {
 	command := exec.Command("cf", "restart", a.Name)
 	command.Stdout = DefaultStdoutStderr
 	command.Stderr = DefaultStdoutStderr
-	if err := command.Run(); err != nil {
+	if err := cfRun(command); err != nil {
 		return err
 	}
 	return nil
@@ -236,19 +258,25 @@ func (a *App) SetEnv(key, value string) {
 }
 
 func (a *App) SpaceGUID() (string, error) {
+	config, err := readCFConfig()
+	if err != nil {
+		return "", err
+	}
+	return config.SpaceFields.GUID, nil
+}
+
+func readCFConfig() (cfConfig, error) {
+	var config cfConfig
 	cfHome := os.Getenv("CF_HOME")
 	if cfHome == "" {
 		cfHome = os.Getenv("HOME")
 	}
 	bytes, err := ioutil.ReadFile(filepath.Join(cfHome, ".cf", "config.json"))
 	if err != nil {
-		return "", err
-	}
-	var config cfConfig
-	if err := json.Unmarshal(bytes, &config); err != nil {
-		return "", err
+		return config, err
 	}
-	return config.SpaceFields.GUID, nil
+	err = json.Unmarshal(bytes, &config)
+	return config, err
 }
 
 func (a *App) AppGUID() (string, error) {
@@ -261,7 +289,7 @@ func (a *App) AppGUID() (string, error) {
 	}
 	cmd := exec.Command("cf", "curl", "/v2/apps?q=space_guid:"+guid+"&q=name:"+a.Name)
 	cmd.Stderr = DefaultStdoutStderr
-	bytes, err := cmd.Output()
+	bytes, err := cfOutput(cmd)
 	if err != nil {
 		return "", err
 	}
@@ -283,7 +311,7 @@ func (a *App) InstanceStates() ([]string, error) {
 	}
 	cmd := exec.Command("cf", "curl", "/v2/apps/"+guid+"/instances")
 	cmd.Stderr = DefaultStdoutStderr
-	bytes, err := cmd.Output()
+	bytes, err := cfOutput(cmd)
 	if err != nil {
 		return []string{}, err
 	}
@@ -299,7 +327,7 @@ func (a *App) InstanceStates() ([]string, error) {
 }
 
 func (a *App) PushNoStart() error {
-	args := []string{"push", a.Name, "--no-start", "-p", a.Path}
+	args := []string{"-p", a.Path}
 	if a.Stack != "" {
 		args = append(args, "-s", a.Stack)
 	}
@@ -309,6 +337,13 @@ func (a *App) PushNoStart() error {
 	if _, err := os.Stat(filepath.Join(a.Path, "manifest.yml")); err == nil {
 		args = append(args, "-f", filepath.Join(a.Path, "manifest.yml"))
 	}
+	return a.pushNoStart(args)
+}
+
+// pushNoStart creates the app from sourceArgs, the cf push flags naming
+// what to push, without starting it.
+func (a *App) pushNoStart(sourceArgs []string) error {
+	args := append([]string{"push", a.Name, "--no-start"}, sourceArgs...)
 	if a.Memory != "" {
 		args = append(args, "-m", a.Memory)
 	}
@@ -325,15 +360,21 @@ func (a *App) PushNoStart() error {
 	command := exec.Command("cf", args...)
 	command.Stdout = DefaultStdoutStderr
 	command.Stderr = DefaultStdoutStderr
-	if err := command.Run(); err != nil {
+	if err := cfRun(command); err != nil {
 		return err
 	}
 
+	if len(a.Labels) > 0 {
+		if guid, err := a.AppGUID(); err == nil {
+			labelResource("apps", guid, a.Labels)
+		}
+	}
+
 	for k, v := range a.env {
 		command := exec.Command("cf", "set-env", a.Name, k, v)
 		command.Stdout = DefaultStdoutStderr
 		command.Stderr = DefaultStdoutStderr
-		if err := command.Run(); err != nil {
+		if err := cfRun(command); err != nil {
 			return err
 		}
 	}
@@ -365,7 +406,7 @@ func (a *App) V3Push() error {
 	command := exec.Command("cf", args...)
 	command.Stdout = DefaultStdoutStderr
 	command.Stderr = DefaultStdoutStderr
-	if err := command.Run(); err != nil {
+	if err := cfRun(command); err != nil {
 		return err
 	}
 	return nil
@@ -375,13 +416,34 @@ func (a *App) Push() error {
 	if err := a.PushNoStart(); err != nil {
 		return err
 	}
+	return a.start()
+}
+
+// PushDroplet creates the app from a droplet saved with DownloadDroplet and
+// starts it without staging, so launch time behaviour such as profile.d
+// scripts can be checked apart from staging. Path, Stack and Buildpacks are
+// not used.
+func (a *App) PushDroplet(dropletPath string) error {
+	if err := a.pushNoStart([]string{"--droplet", dropletPath}); err != nil {
+		return err
+	}
+	return a.start()
+}
 
+func (a *App) start() error {
 	command := exec.Command("cf", "start", a.Name)
 	buf := &bytes.Buffer{}
 	command.Stdout = buf
 	command.Stderr = buf
-	if err := command.Run(); err != nil {
-		return fmt.Errorf("err: %s\n\nlogs: %s", err, buf)
+	if err := cfRun(command); err != nil {
+		err = fmt.Errorf("err: %s\n\nlogs: %s", err, buf)
+		// tests expect staging to fail often enough, and its logs are
+		// already in buf, so only an app that staged but did not start
+		// is worth diagnosing
+		if failure, stagingErr := a.StagingFailure(); stagingErr == nil && failure.Failed {
+			return err
+		}
+		return a.withDiagnostics(err)
 	}
 	return nil
 }
@@ -393,7 +455,7 @@ func (a *App) GetUrl(path string) (string, error) {
 	}
 	cmd := exec.Command("cf", "curl", "/v2/apps/"+guid+"/summary")
 	cmd.Stderr = DefaultStdoutStderr
-	data, err := cmd.Output()
+	data, err := cfOutput(cmd)
 	if err != nil {
 		return "", err
 	}
@@ -472,7 +534,7 @@ func (a *App) DownloadDroplet(path string) error {
 
 	cmd := exec.Command("cf", "curl", "/v2/apps/"+guid+"/droplet/download", "--output", path)
 	cmd.Stderr = DefaultStdoutStderr
-	_, err = cmd.Output()
+	_, err = cfOutput(cmd)
 	return err
 }
 
@@ -486,5 +548,12 @@ func (a *App) Destroy() error {
 	command := exec.Command("cf", "delete", "-f", a.Name)
 	command.Stdout = DefaultStdoutStderr
 	command.Stderr = DefaultStdoutStderr
-	return command.Run()
+	err := cfRun(command)
+
+	// coverage is recorded once the app is gone, so failing to record it
+	// never leaves the app behind
+	if recordErr := a.recordInstalledDependencies(); err == nil {
+		err = recordErr
+	}
+	return err
 }
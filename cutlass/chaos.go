@@ -0,0 +1,115 @@
+package cutlass
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// KillInstance stops the app instance at index without stopping the app, so
+// the platform has to bring a fresh instance back up.
+func (a *App) KillInstance(index int) error {
+	command := exec.Command("cf", "restart-app-instance", a.Name, strconv.Itoa(index))
+	command.Stdout = DefaultStdoutStderr
+	command.Stderr = DefaultStdoutStderr
+	return cfRun(command)
+}
+
+// CrashTimeout is how long CrashInstance waits for the platform to notice the
+// crashed instance.
+var CrashTimeout = time.Minute
+
+// CrashInstance kills every process in the app instance at index over cf
+// ssh, as an out of memory kill or segfault would, and waits until the
+// platform has noticed the crash, so a later recovery check proves something.
+func (a *App) CrashInstance(index int) error {
+	before, err := a.instance(index)
+	if err != nil {
+		return a.withDiagnostics(fmt.Errorf("could not read instance %d of %s before crashing it: %v", index, a.Name, err))
+	}
+
+	command := exec.Command("cf", "ssh", a.Name, "-i", strconv.Itoa(index), "-c", "kill -9 -1")
+	command.Stdout = DefaultStdoutStderr
+	command.Stderr = DefaultStdoutStderr
+	// The ssh session is killed along with the app, so cf ssh exits with the
+	// status of a shell killed by SIGKILL. Any other failure means ssh is
+	// disabled, the instance does not exist or cf could not log in.
+	if err := cfRun(command); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok || exitErr.ExitCode() != 128+9 {
+			return a.withDiagnostics(fmt.Errorf("could not crash instance %d of %s over cf ssh: %v", index, a.Name, err))
+		}
+	}
+
+	deadline := time.Now().Add(CrashTimeout)
+	for {
+		after, err := a.instance(index)
+		if err == nil && (after.State != "RUNNING" || after.Since != before.Since) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return a.withDiagnostics(fmt.Errorf("instance %d of %s was still running since %v %s after crashing it (err: %v)", index, a.Name, before.Since, CrashTimeout, err))
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Restage restages the app, failing with diagnostics if staging fails.
+func (a *App) Restage() error {
+	command := exec.Command("cf", "restage", a.Name)
+	command.Stdout = DefaultStdoutStderr
+	command.Stderr = DefaultStdoutStderr
+	if err := cfRun(command); err != nil {
+		return a.withDiagnostics(fmt.Errorf("restage of %s failed: %v", a.Name, err))
+	}
+	return nil
+}
+
+// instance returns the state of the app instance at index.
+func (a *App) instance(index int) (cfInstance, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return cfInstance{}, err
+	}
+	var instances map[string]cfInstance
+	if err := cfCurl("/v2/apps/"+guid+"/instances", &instances); err != nil {
+		return cfInstance{}, err
+	}
+	instance, found := instances[strconv.Itoa(index)]
+	if !found {
+		return cfInstance{}, fmt.Errorf("%s has no instance %d", a.Name, index)
+	}
+	return instance, nil
+}
+
+// WaitForInstances polls until count instances are RUNNING or timeout passes.
+func (a *App) WaitForInstances(count int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		states, err := a.InstanceStates()
+		running := 0
+		for _, s := range states {
+			if s == "RUNNING" {
+				running++
+			}
+		}
+		if err == nil && running >= count {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return a.withDiagnostics(fmt.Errorf("expected %d running instances of %s within %s, last saw %v (err: %v)", count, a.Name, timeout, states, err))
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// ConfirmRecovery runs a disruptive action, such as CrashInstance or
+// Restage, then waits for the app to pass probe again, e.g.
+// app.ConfirmRecovery(func() error { return app.CrashInstance(0) }, Probe{Path: "/health"}).
+func (a *App) ConfirmRecovery(action func() error, probe Probe) error {
+	if err := action(); err != nil {
+		return err
+	}
+	return a.WaitUntilReady(probe)
+}
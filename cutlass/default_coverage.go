@@ -0,0 +1,121 @@
+package cutlass
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// DependencyCoverageFile, if set, is where RecordDependency also writes what
+// it records, and where DefaultVersionsCovered reads it back from, so that
+// the parallel nodes of a Ginkgo suite share one record.
+var DependencyCoverageFile string
+
+var (
+	coverageMu sync.Mutex
+	exercised  = map[string]bool{}
+
+	installingDependency = regexp.MustCompile(`-----> Installing (\S+) (\S+)`)
+)
+
+// RecordDependency notes that a test exercised version of the dependency
+// name. Destroy records every dependency the app's staging logs show being
+// installed, so calling this is only needed for versions tests exercise
+// some other way.
+func RecordDependency(name, version string) error {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+
+	exercised[name+" "+version] = true
+	if DependencyCoverageFile == "" {
+		return nil
+	}
+	fh, err := os.OpenFile(DependencyCoverageFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = fmt.Fprintf(fh, "%s %s\n", name, version)
+	return err
+}
+
+// recordInstalledDependencies records the dependencies staging installed.
+func (a *App) recordInstalledDependencies() error {
+	if a.Stdout == nil {
+		return nil
+	}
+	for _, match := range installingDependency.FindAllStringSubmatch(a.Stdout.ANSIStrippedString(), -1) {
+		if err := RecordDependency(match[1], match[2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultVersionsCovered checks that a test exercised the version every
+// default_versions entry in the manifest of the buildpack at bpDir resolves
+// to, listing those none did. Calling it after all tests have run, e.g. in
+// the second function of SynchronizedAfterSuite, keeps tests honest as
+// defaults change.
+func DefaultVersionsCovered(bpDir string) error {
+	manifest, err := libbuildpack.NewManifest(bpDir, libbuildpack.NewLogger(ioutil.Discard), time.Now())
+	if err != nil {
+		return err
+	}
+
+	covered, err := recordedDependencies()
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, defaultVersion := range manifest.DefaultVersions {
+		dep, err := manifest.DefaultVersion(defaultVersion.Name)
+		if err != nil {
+			return err
+		}
+		if !covered[dep.Name+" "+dep.Version] {
+			missing = append(missing, dep.Name+" "+dep.Version)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no test exercised the default versions %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func recordedDependencies() (map[string]bool, error) {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+
+	covered := map[string]bool{}
+	for dep := range exercised {
+		covered[dep] = true
+	}
+	if DependencyCoverageFile == "" {
+		return covered, nil
+	}
+
+	fh, err := os.Open(DependencyCoverageFile)
+	if os.IsNotExist(err) {
+		return covered, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		covered[scanner.Text()] = true
+	}
+	return covered, scanner.Err()
+}
@@ -0,0 +1,197 @@
+package cutlass
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// DiagnosticsDir is where App assertions that fail write what they could
+// gather about the app, one directory per app. It defaults to
+// $CUTLASS_DIAGNOSTICS_DIR, falling back to a directory under the system tmp.
+var DiagnosticsDir = diagnosticsDir()
+
+func diagnosticsDir() string {
+	if dir := os.Getenv("CUTLASS_DIAGNOSTICS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "cutlass-diagnostics")
+}
+
+// CollectDiagnostics writes the app's streamed logs, recent logs, audit events,
+// environment and droplet checksum into dir. Each piece is collected on a best
+// effort basis; failures are written in place of the missing output. Service
+// credentials and user provided variables are redacted from the environment
+// and recent logs, which are left out if they cannot be found.
+func (a *App) CollectDiagnostics(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	write := func(name string, contents []byte, err error) {
+		if err != nil {
+			contents = append(contents, []byte(fmt.Sprintf("\nerror collecting %s: %v\n", name, err))...)
+		}
+		ioutil.WriteFile(filepath.Join(dir, name), contents, 0644)
+	}
+
+	if a.Stdout != nil {
+		write("logs.txt", []byte(a.Stdout.String()), nil)
+	}
+
+	secrets, secretsErr := a.envSecrets()
+	if secretsErr != nil {
+		secretsErr = fmt.Errorf("could not find the credentials to redact: %v", secretsErr)
+		write("recent-logs.txt", nil, secretsErr)
+		write("env.txt", nil, secretsErr)
+	} else {
+		recentLogs, err := exec.Command("cf", "logs", a.Name, "--recent").CombinedOutput()
+		write("recent-logs.txt", redactSecrets(recentLogs, secrets), err)
+
+		env, err := exec.Command("cf", "env", a.Name).CombinedOutput()
+		write("env.txt", redactSecrets(env, secrets), err)
+	}
+
+	var eventsJSON []byte
+	events, err := a.AuditEvents()
+	if err == nil {
+		eventsJSON, err = json.MarshalIndent(events, "", "  ")
+	}
+	write("events.json", eventsJSON, err)
+
+	sum, err := a.dropletChecksum()
+	write("droplet.sha256", []byte(sum), err)
+
+	return nil
+}
+
+// minSecretLength is the length below which a credential value, like a
+// port or "true", is too common to redact without mangling the output.
+const minSecretLength = 6
+
+// redactSecrets replaces every secret in output, as written and as encoded
+// in JSON like VCAP_SERVICES, so multi-line values such as PEM keys are
+// caught, then redacts the credentials of urls line by line.
+func redactSecrets(output []byte, secrets []string) []byte {
+	var forms []string
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength {
+			continue
+		}
+		forms = append(forms, secret)
+		for _, escapeHTML := range []bool{true, false} {
+			var b strings.Builder
+			enc := json.NewEncoder(&b)
+			enc.SetEscapeHTML(escapeHTML)
+			if enc.Encode(secret) == nil {
+				forms = append(forms, strings.TrimSuffix(strings.TrimSuffix(b.String(), "\n"), `"`)[1:])
+			}
+		}
+	}
+	// longest first, so a secret containing another is replaced whole
+	sort.Slice(forms, func(i, j int) bool { return len(forms[i]) > len(forms[j]) })
+
+	text := string(output)
+	for _, form := range forms {
+		text = strings.Replace(text, form, "[REDACTED]", -1)
+	}
+
+	tee := libbuildpack.NewOutputTee(nil, 0)
+	tee.Write([]byte(text))
+	tee.Flush()
+	return []byte(tee.String())
+}
+
+// envSecrets lists the string values of the app's service credentials and
+// user provided environment variables.
+func (a *App) envSecrets() ([]string, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return nil, err
+	}
+
+	var env struct {
+		SystemEnv struct {
+			Services map[string][]struct {
+				Credentials interface{} `json:"credentials"`
+			} `json:"VCAP_SERVICES"`
+		} `json:"system_env_json"`
+		Variables map[string]interface{} `json:"environment_variables"`
+	}
+	if err := cfCurl("/v3/apps/"+guid+"/env", &env); err != nil {
+		return nil, err
+	}
+
+	var secrets []string
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			secrets = append(secrets, v)
+		case map[string]interface{}:
+			for _, child := range v {
+				collect(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				collect(child)
+			}
+		}
+	}
+	for _, instances := range env.SystemEnv.Services {
+		for _, instance := range instances {
+			collect(instance.Credentials)
+		}
+	}
+	collect(env.Variables)
+	return secrets, nil
+}
+
+func (a *App) dropletChecksum() (string, error) {
+	tmpDir, err := ioutil.TempDir("", "cutlass-droplet")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "droplet.tgz")
+	if err := a.DownloadDroplet(path); err != nil {
+		return "", err
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, fh); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// withDiagnostics collects diagnostics for an unexpected failure, wrapping
+// err so that callers can still unwrap it, e.g. to a *ProbeError.
+func (a *App) withDiagnostics(err error) error {
+	if err == nil || DiagnosticsDir == "" {
+		return err
+	}
+
+	dir := filepath.Join(DiagnosticsDir, a.Name)
+	if collectErr := a.CollectDiagnostics(dir); collectErr != nil {
+		return fmt.Errorf("%w\n\nfailed to collect diagnostics: %v", err, collectErr)
+	}
+	return fmt.Errorf("%w\n\ndiagnostics: %s", err, dir)
+}
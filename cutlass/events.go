@@ -0,0 +1,94 @@
+package cutlass
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"time"
+)
+
+const (
+	EventAppCreate        = "audit.app.create"
+	EventAppStart         = "audit.app.start"
+	EventAppRestage       = "audit.app.restage"
+	EventAppDropletMapped = "audit.app.droplet.mapped"
+	EventAppCrash         = "app.crash"
+)
+
+type AuditEvent struct {
+	Type      string                 `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+type cfEvents struct {
+	NextURL   string `json:"next_url"`
+	Resources []struct {
+		Entity AuditEvent `json:"entity"`
+	} `json:"resources"`
+}
+
+// AuditEvents returns every audit event recorded against the app, oldest first.
+func (a *App) AuditEvents() ([]AuditEvent, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []AuditEvent
+	next := "/v2/events?order-direction=asc&results-per-page=100&q=actee:" + url.QueryEscape(guid)
+	for next != "" {
+		cmd := exec.Command("cf", "curl", next)
+		cmd.Stderr = DefaultStdoutStderr
+		bytes, err := cfOutput(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		var page cfEvents
+		if err := json.Unmarshal(bytes, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Resources {
+			events = append(events, r.Entity)
+		}
+		next = page.NextURL
+	}
+
+	return events, nil
+}
+
+func (a *App) AuditEventsOfType(eventType string) ([]AuditEvent, error) {
+	events, err := a.AuditEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []AuditEvent
+	for _, e := range events {
+		if e.Type == eventType {
+			matching = append(matching, e)
+		}
+	}
+	return matching, nil
+}
+
+// ConfirmAuditEventCount errors unless exactly count events of eventType were
+// recorded, e.g. ConfirmAuditEventCount(EventAppDropletMapped, 1) after a push
+// or ConfirmAuditEventCount(EventAppCrash, 0) to catch silent restarts.
+func (a *App) ConfirmAuditEventCount(eventType string, count int) error {
+	events, err := a.AuditEventsOfType(eventType)
+	if err != nil {
+		return err
+	}
+
+	if len(events) != count {
+		var timestamps []string
+		for _, e := range events {
+			timestamps = append(timestamps, e.Timestamp.Format(time.RFC3339))
+		}
+		return a.withDiagnostics(fmt.Errorf("Expected %d %s events for %s, found %d at %v", count, eventType, a.Name, len(events), timestamps))
+	}
+	return nil
+}
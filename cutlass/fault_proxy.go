@@ -0,0 +1,167 @@
+package cutlass
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elazarl/goproxy"
+)
+
+// Fault describes how FaultProxy misbehaves for requests whose URL contains
+// Match. Delay is applied first; then StatusCode, if set, is returned instead
+// of the real response, or with Corrupt the real response body has its bytes
+// flipped. Times limits the fault to that many requests, 0 meaning every one.
+//
+// HTTPS downloads are tunnelled, so for them only Delay applies and a
+// StatusCode or Corrupt fault refuses the tunnel; Match is checked against
+// the host and port.
+type Fault struct {
+	Match      string
+	Delay      time.Duration
+	StatusCode int
+	Corrupt    bool
+	Times      int
+}
+
+// FaultProxy is an HTTP proxy that injects Faults into the dependency
+// downloads of staging apps. Point staging at it with SetStagingProxy.
+type FaultProxy struct {
+	*httptest.Server
+
+	mutex  sync.Mutex
+	faults []*Fault
+	hits   map[string]int
+}
+
+func NewFaultProxy() (*FaultProxy, error) {
+	p := &FaultProxy{hits: map[string]int{}}
+
+	proxy := goproxy.NewProxyHttpServer()
+	proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		if f := p.apply(host); f != nil && (f.StatusCode != 0 || f.Corrupt) {
+			return goproxy.RejectConnect, host
+		}
+		return goproxy.OkConnect, host
+	})
+	proxy.OnRequest().DoFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+		f := p.apply(req.URL.String())
+		if f == nil {
+			return req, nil
+		}
+		if f.StatusCode != 0 {
+			return req, goproxy.NewResponse(req, goproxy.ContentTypeText, f.StatusCode, "injected by cutlass FaultProxy")
+		}
+		ctx.UserData = f
+		return req, nil
+	})
+	proxy.OnResponse().DoFunc(func(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
+		if f, ok := ctx.UserData.(*Fault); ok && f.Corrupt && resp != nil {
+			resp.Body = &corruptReader{resp.Body}
+		}
+		return resp
+	})
+
+	server, err := listenProxy(proxy, false)
+	if err != nil {
+		return nil, err
+	}
+	p.Server = server
+	return p, nil
+}
+
+func (p *FaultProxy) AddFault(f Fault) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.faults = append(p.faults, &f)
+}
+
+func (p *FaultProxy) ClearFaults() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.faults = nil
+}
+
+// Hits returns how many requests a fault with the given Match has been
+// applied to.
+func (p *FaultProxy) Hits(match string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.hits[match]
+}
+
+// apply finds the first fault matching target, counts it, sleeps for its
+// delay and returns it.
+func (p *FaultProxy) apply(target string) *Fault {
+	p.mutex.Lock()
+	var fault *Fault
+	for _, f := range p.faults {
+		if strings.Contains(target, f.Match) && (f.Times == 0 || p.hits[f.Match] < f.Times) {
+			fault = f
+			p.hits[f.Match]++
+			break
+		}
+	}
+	p.mutex.Unlock()
+
+	if fault != nil && fault.Delay > 0 {
+		time.Sleep(fault.Delay)
+	}
+	return fault
+}
+
+type corruptReader struct {
+	io.ReadCloser
+}
+
+func (r *corruptReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= 0xff
+	}
+	return n, err
+}
+
+// SetStagingProxy adds http_proxy and https_proxy for proxyURL to the
+// staging environment variable group, keeping its other variables, and
+// returns a func that puts the group back as it was. proxyURL must be
+// reachable from the cells, so use the host's address rather than the
+// listener's. Changing the group needs admin rights and affects every app
+// staged on the foundation.
+func SetStagingProxy(proxyURL string) (restore func() error, err error) {
+	var group map[string]interface{}
+	if err := cfCurl("/v2/config/environment_variable_groups/staging", &group); err != nil {
+		return nil, err
+	}
+	original, err := json.Marshal(group)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"} {
+		group[name] = proxyURL
+	}
+	updated, err := json.Marshal(group)
+	if err != nil {
+		return nil, err
+	}
+	if err := setStagingEnvironmentGroup(string(updated)); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return setStagingEnvironmentGroup(string(original))
+	}, nil
+}
+
+func setStagingEnvironmentGroup(env string) error {
+	command := exec.Command("cf", "set-staging-environment-variable-group", env)
+	command.Stdout = DefaultStdoutStderr
+	command.Stderr = DefaultStdoutStderr
+	return cfRun(command)
+}
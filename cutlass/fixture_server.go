@@ -0,0 +1,98 @@
+package cutlass
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type FixtureRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	At     time.Time
+}
+
+// FixtureServer serves files from a directory over HTTP for tests that need
+// a dependency host, recording every request. Latency and failures can be
+// injected per path to exercise retries, proxies and mirrors.
+type FixtureServer struct {
+	*httptest.Server
+
+	dir      string
+	mutex    sync.Mutex
+	requests []FixtureRequest
+	latency  map[string]time.Duration
+	failures map[string][]int
+}
+
+func NewFixtureServer(dir string) *FixtureServer {
+	s := &FixtureServer{
+		dir:      dir,
+		latency:  map[string]time.Duration{},
+		failures: map[string][]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// SetLatency delays every response for path by d.
+func (s *FixtureServer) SetLatency(path string, d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latency[path] = d
+}
+
+// FailNext makes the next requests for path respond with the given status
+// codes, one per request, before the file is served normally again.
+func (s *FixtureServer) FailNext(path string, statusCodes ...int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures[path] = append(s.failures[path], statusCodes...)
+}
+
+func (s *FixtureServer) Requests() []FixtureRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]FixtureRequest{}, s.requests...)
+}
+
+func (s *FixtureServer) RequestCount(path string) int {
+	count := 0
+	for _, r := range s.Requests() {
+		if r.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *FixtureServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests = append(s.requests, FixtureRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, At: time.Now()})
+	latency := s.latency[r.URL.Path]
+	status := 0
+	if failures := s.failures[r.URL.Path]; len(failures) > 0 {
+		status = failures[0]
+		s.failures[r.URL.Path] = failures[1:]
+	}
+	s.mutex.Unlock()
+
+	time.Sleep(latency)
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	file := filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, file)
+}
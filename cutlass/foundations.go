@@ -0,0 +1,130 @@
+package cutlass
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// Foundation is a CF target a suite can be run against. Stack, when set, is
+// passed to the suite as CF_STACK.
+type Foundation struct {
+	Name              string `yaml:"name"`
+	API               string `yaml:"api"`
+	Username          string `yaml:"username"`
+	Password          string `yaml:"password"`
+	Org               string `yaml:"org"`
+	Space             string `yaml:"space"`
+	Stack             string `yaml:"stack"`
+	SkipSSLValidation bool   `yaml:"skip_ssl_validation"`
+}
+
+type FoundationResult struct {
+	Foundation Foundation
+	Output     string
+	Duration   time.Duration
+	Err        error
+}
+
+// LoadFoundations reads a list of foundations from the "foundations" key of
+// a YAML file. ${VAR} references are expanded so credentials can be kept in
+// the environment.
+func LoadFoundations(file string) ([]Foundation, error) {
+	var config struct {
+		Foundations []Foundation `yaml:"foundations"`
+	}
+	if err := (&libbuildpack.YAML{InterpolateEnv: true}).Load(file, &config); err != nil {
+		return nil, err
+	}
+	return config.Foundations, nil
+}
+
+// Login targets the foundation's org and space using the cf config in
+// cfHome, leaving the current CF_HOME untouched.
+func (f Foundation) Login(cfHome string) error {
+	apiArgs := []string{"api", f.API}
+	if f.SkipSSLValidation {
+		apiArgs = append(apiArgs, "--skip-ssl-validation")
+	}
+
+	for _, args := range [][]string{apiArgs, {"auth"}, {"target", "-o", f.Org, "-s", f.Space}} {
+		cmd := exec.Command("cf", args...)
+		cmd.Env = append(os.Environ(), "CF_HOME="+cfHome, "CF_USERNAME="+f.Username, "CF_PASSWORD="+f.Password)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("could not log in to %s with cf %s: %v\n%s", f.Name, args[0], err, out)
+		}
+	}
+	return nil
+}
+
+// RunOnFoundations runs a suite command (e.g. "ginkgo", "-r", "integration")
+// once per foundation, each logged in with its own CF_HOME and with
+// CUTLASS_FOUNDATION set to the foundation's name. Foundations are run one
+// after another unless parallel is set. Results are in the order given.
+func RunOnFoundations(foundations []Foundation, parallel bool, program string, args ...string) []FoundationResult {
+	results := make([]FoundationResult, len(foundations))
+
+	var wg sync.WaitGroup
+	for i, f := range foundations {
+		run := func(i int, f Foundation) {
+			defer wg.Done()
+			start := time.Now()
+			output, err := runOnFoundation(f, program, args...)
+			results[i] = FoundationResult{Foundation: f, Output: output, Duration: time.Since(start), Err: err}
+		}
+		wg.Add(1)
+		if parallel {
+			go run(i, f)
+		} else {
+			run(i, f)
+		}
+	}
+	wg.Wait()
+
+	return results
+}
+
+func runOnFoundation(f Foundation, program string, args ...string) (string, error) {
+	cfHome, err := ioutil.TempDir("", "cutlass-cf-home")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(cfHome)
+
+	if err := f.Login(cfHome); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(program, args...)
+	cmd.Env = append(os.Environ(), "CF_HOME="+cfHome, "CUTLASS_FOUNDATION="+f.Name)
+	if f.Stack != "" {
+		cmd.Env = append(cmd.Env, "CF_STACK="+f.Stack)
+	}
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	return output.String(), err
+}
+
+// FoundationsReport summarizes results, one line per foundation, followed by
+// the output of every failed run.
+func FoundationsReport(results []FoundationResult) string {
+	var lines, failures []string
+	for _, r := range results {
+		status := "PASSED"
+		if r.Err != nil {
+			status = "FAILED"
+			failures = append(failures, fmt.Sprintf("--- %s: %v\n%s", r.Foundation.Name, r.Err, r.Output))
+		}
+		lines = append(lines, fmt.Sprintf("%-6s %s (%s) in %s", status, r.Foundation.Name, r.Foundation.API, r.Duration.Round(time.Second)))
+	}
+	return strings.Join(append(lines, failures...), "\n")
+}
@@ -0,0 +1,185 @@
+package cutlass
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+type cfRelationship struct {
+	Data *struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+// PlaceOrgInIsolationSegment entitles the targeted org to segment and makes it
+// the org's default, so every space without its own segment runs there.
+func PlaceOrgInIsolationSegment(segment string) error {
+	config, err := readCFConfig()
+	if err != nil {
+		return err
+	}
+	org := config.OrganizationFields.Name
+	if org == "" {
+		return fmt.Errorf("no org targeted, run cf target")
+	}
+	if err := runCF("enable-org-isolation", org, segment); err != nil {
+		return err
+	}
+	return runCF("set-org-default-isolation-segment", org, segment)
+}
+
+// PlaceSpaceInIsolationSegment entitles the targeted org to segment and
+// assigns it to the targeted space. Apps already running in the space only
+// move once they are restarted.
+func PlaceSpaceInIsolationSegment(segment string) error {
+	config, err := readCFConfig()
+	if err != nil {
+		return err
+	}
+	org, space := config.OrganizationFields.Name, config.SpaceFields.Name
+	if org == "" || space == "" {
+		return fmt.Errorf("no org and space targeted, run cf target")
+	}
+	if err := runCF("enable-org-isolation", org, segment); err != nil {
+		return err
+	}
+	return runCF("set-space-isolation-segment", space, segment)
+}
+
+// ResetSpaceIsolationSegment returns the targeted space to its org's default
+// isolation segment.
+func ResetSpaceIsolationSegment() error {
+	config, err := readCFConfig()
+	if err != nil {
+		return err
+	}
+	return runCF("reset-space-isolation-segment", config.SpaceFields.Name)
+}
+
+// IsolationSegment returns the name of the isolation segment the app's own
+// space places it in, falling back to the default of the space's org,
+// whatever space is targeted. It is empty for the shared segment.
+func (a *App) IsolationSegment() (string, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return "", err
+	}
+
+	var app struct {
+		Relationships struct {
+			Space cfRelationship `json:"space"`
+		} `json:"relationships"`
+	}
+	if err := cfCurl("/v3/apps/"+guid, &app); err != nil {
+		return "", err
+	}
+	if app.Relationships.Space.Data == nil {
+		return "", fmt.Errorf("%s has no space", a.Name)
+	}
+	spaceGUID := app.Relationships.Space.Data.GUID
+
+	var rel cfRelationship
+	if err := cfCurl("/v3/spaces/"+spaceGUID+"/relationships/isolation_segment", &rel); err != nil {
+		return "", err
+	}
+	if rel.Data == nil || rel.Data.GUID == "" {
+		var space struct {
+			Relationships struct {
+				Organization cfRelationship `json:"organization"`
+			} `json:"relationships"`
+		}
+		if err := cfCurl("/v3/spaces/"+spaceGUID, &space); err != nil {
+			return "", err
+		}
+		if space.Relationships.Organization.Data == nil {
+			return "", fmt.Errorf("space %s of %s has no org", spaceGUID, a.Name)
+		}
+		if err := cfCurl("/v3/organizations/"+space.Relationships.Organization.Data.GUID+"/relationships/default_isolation_segment", &rel); err != nil {
+			return "", err
+		}
+	}
+	if rel.Data == nil || rel.Data.GUID == "" {
+		return "", nil
+	}
+
+	var segment struct {
+		Name string `json:"name"`
+	}
+	if err := cfCurl("/v3/isolation_segments/"+rel.Data.GUID, &segment); err != nil {
+		return "", err
+	}
+	return segment.Name, nil
+}
+
+// RunningIsolationSegments returns the isolation segment each running
+// instance of the app's web process reports it runs in, "" for the shared
+// segment. An app is only moved to the segment of its space when restarted.
+func (a *App) RunningIsolationSegments() ([]string, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return nil, err
+	}
+
+	var stats struct {
+		Resources []struct {
+			State            string  `json:"state"`
+			IsolationSegment *string `json:"isolation_segment"`
+		} `json:"resources"`
+	}
+	if err := cfCurl("/v3/apps/"+guid+"/processes/web/stats", &stats); err != nil {
+		return nil, err
+	}
+	var segments []string
+	for _, instance := range stats.Resources {
+		if instance.State != "RUNNING" {
+			continue
+		}
+		segment := ""
+		if instance.IsolationSegment != nil {
+			segment = *instance.IsolationSegment
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// ConfirmIsolationSegment returns an error unless the app is placed in
+// segment and every running instance runs there. Pass "" to confirm the app
+// runs in the shared segment.
+func (a *App) ConfirmIsolationSegment(segment string) error {
+	placed, err := a.IsolationSegment()
+	if err != nil {
+		return a.withDiagnostics(fmt.Errorf("could not read the isolation segment of %s: %v", a.Name, err))
+	}
+	if placed != segment {
+		return a.withDiagnostics(fmt.Errorf("expected %s to run in %s, its space places it in %s", a.Name, describeSegment(segment), describeSegment(placed)))
+	}
+
+	running, err := a.RunningIsolationSegments()
+	if err != nil {
+		return a.withDiagnostics(fmt.Errorf("could not read the instances of %s: %v", a.Name, err))
+	}
+	if len(running) == 0 {
+		return a.withDiagnostics(fmt.Errorf("expected %s to run in %s, it has no running instances", a.Name, describeSegment(segment)))
+	}
+	for _, actual := range running {
+		if actual != segment {
+			return a.withDiagnostics(fmt.Errorf("expected %s to run in %s, a running instance is in %s; restart the app after changing its segment", a.Name, describeSegment(segment), describeSegment(actual)))
+		}
+	}
+	return nil
+}
+
+func describeSegment(segment string) string {
+	if segment == "" {
+		return "the shared segment"
+	}
+	return fmt.Sprintf("isolation segment %q", segment)
+}
+
+func runCF(args ...string) error {
+	command := exec.Command("cf", args...)
+	command.Stdout = DefaultStdoutStderr
+	command.Stderr = DefaultStdoutStderr
+	return cfRun(command)
+}
@@ -0,0 +1,104 @@
+package cutlass
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogLine is one message from cf logs output, e.g.
+// "2019-06-05T10:11:12.34+0000 [APP/PROC/WEB/0] OUT Listening on 8080".
+type LogLine struct {
+	Time    time.Time
+	Source  string
+	Stream  string
+	Message string
+}
+
+func (l LogLine) String() string {
+	return fmt.Sprintf("%s [%s] %s %s", l.Time.Format(time.RFC3339Nano), l.Source, l.Stream, l.Message)
+}
+
+// IsStaging reports whether the line was logged by the staging container.
+func (l LogLine) IsStaging() bool {
+	return strings.HasPrefix(l.Source, "STG")
+}
+
+var logLineRegexp = regexp.MustCompile(`^\s*(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?[-+]\d{4})\s+\[([^\]]+)\]\s+(OUT|ERR)\s?(.*)$`)
+
+const cfLogTimeFormat = "2006-01-02T15:04:05.999999999-0700"
+
+// ParseLogs parses cf logs output into lines ordered by timestamp, in UTC.
+// Lines logged at the same time keep their original order, and lines without
+// a header (e.g. continued stack traces) are attached to the line before.
+func ParseLogs(output string) []LogLine {
+	var lines []LogLine
+	for _, raw := range strings.Split(output, "\n") {
+		match := logLineRegexp.FindStringSubmatch(raw)
+		if match == nil {
+			if len(lines) > 0 && strings.TrimSpace(raw) != "" {
+				lines[len(lines)-1].Message += "\n" + strings.TrimRight(raw, "\r")
+			}
+			continue
+		}
+
+		t, err := time.Parse(cfLogTimeFormat, match[1])
+		if err != nil {
+			continue
+		}
+		lines = append(lines, LogLine{
+			Time:    t.UTC(),
+			Source:  match[2],
+			Stream:  match[3],
+			Message: strings.TrimRight(match[4], "\r"),
+		})
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time.Before(lines[j].Time) })
+	return lines
+}
+
+// MergedLogs combines the logs streamed since the app was pushed with its
+// recent logs into a single ordered stream without duplicates, covering both
+// staging and runtime even when one transport dropped or reordered lines.
+func (a *App) MergedLogs() ([]LogLine, error) {
+	recent, err := exec.Command("cf", "logs", a.Name, "--recent").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	streamed := ""
+	if a.Stdout != nil {
+		streamed = a.Stdout.String()
+	}
+
+	var merged []LogLine
+	seen := map[string]bool{}
+	for _, line := range ParseLogs(streamed + "\n" + string(recent)) {
+		if key := line.String(); !seen[key] {
+			seen[key] = true
+			merged = append(merged, line)
+		}
+	}
+	return merged, nil
+}
+
+// ConfirmLogOrder errors unless a line containing each of substrings is found
+// after the line matching the one before it, e.g.
+// ConfirmLogOrder(lines, "Installing node", "Listening on").
+func ConfirmLogOrder(lines []LogLine, substrings ...string) error {
+	idx := 0
+	for _, substring := range substrings {
+		for idx < len(lines) && !strings.Contains(lines[idx].Message, substring) {
+			idx++
+		}
+		if idx == len(lines) {
+			return fmt.Errorf("expected %q to be logged in order %q", substring, substrings)
+		}
+		idx++
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package cutlass
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+type VersionFilter int
+
+const (
+	// AllVersions selects every version of the dependency in the manifest.
+	AllVersions VersionFilter = iota
+	// DefaultAndLatestPerLine selects the default version and the newest
+	// version of each major.minor line.
+	DefaultAndLatestPerLine
+)
+
+// DependencyVersions lists the versions of depName in the manifest of the
+// buildpack at bpDir that are available for $CF_STACK, oldest first.
+func DependencyVersions(bpDir, depName string, filter VersionFilter) ([]string, error) {
+	manifest, err := libbuildpack.NewManifest(bpDir, libbuildpack.NewLogger(ioutil.Discard), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := libbuildpack.FindMatchingVersions("*", manifest.AllDependencyVersions(depName))
+	if err != nil {
+		return nil, fmt.Errorf("no versions of %s found in %s: %v", depName, bpDir, err)
+	}
+	if filter == AllVersions {
+		return all, nil
+	}
+
+	selected := map[string]bool{}
+	if dep, err := manifest.DefaultVersion(depName); err == nil {
+		selected[dep.Version] = true
+	}
+
+	latest := map[string]string{}
+	for _, v := range all {
+		line := v
+		if sv, err := semver.NewVersion(v); err == nil {
+			line = fmt.Sprintf("%d.%d", sv.Major(), sv.Minor())
+		}
+		latest[line] = v
+	}
+	for _, v := range latest {
+		selected[v] = true
+	}
+
+	var versions []string
+	for _, v := range all {
+		if selected[v] {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// ForEachDependencyVersion calls fn with each selected version of depName in
+// the buildpack found by FindRoot. Calling It from fn inside a Describe
+// generates one test per version.
+func ForEachDependencyVersion(depName string, filter VersionFilter, fn func(version string)) error {
+	bpDir, err := FindRoot()
+	if err != nil {
+		return err
+	}
+
+	versions, err := DependencyVersions(bpDir, depName, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		fn(v)
+	}
+	return nil
+}
+
+// TemplateFixture copies the fixture at srcDir to a new tmp dir, rendering
+// every file ending in .tmpl with data and dropping the suffix, e.g. a
+// package.json.tmpl containing {{.Version}}.
+func TemplateFixture(srcDir string, data interface{}) (string, error) {
+	dir, err := CopyFixture(srcDir)
+	if err != nil {
+		return "", err
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".tmpl") {
+			return nil
+		}
+
+		tmpl, err := template.ParseFiles(path)
+		if err != nil {
+			return err
+		}
+
+		fh, err := os.OpenFile(strings.TrimSuffix(path, ".tmpl"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, info.Mode())
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+
+		if err := tmpl.Execute(fh, data); err != nil {
+			return fmt.Errorf("could not render %s: %v", path, err)
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
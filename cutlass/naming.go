@@ -0,0 +1,150 @@
+package cutlass
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+)
+
+const (
+	LabelSuite   = "cutlass-suite"
+	LabelNode    = "cutlass-node"
+	LabelCreated = "cutlass-created"
+)
+
+// SuiteID identifies this test run in the names and labels of everything
+// cutlass creates. It is taken from CUTLASS_SUITE_ID, so parallel nodes and
+// CI jobs can share one, and is random otherwise.
+var SuiteID = suiteID()
+
+func suiteID() string {
+	if id := os.Getenv("CUTLASS_SUITE_ID"); id != "" {
+		return id
+	}
+	return RandStringRunes(8)
+}
+
+// maxResourceName is the DNS label limit, as app names become route hosts.
+const maxResourceName = 63
+
+// ResourceName returns a unique name for a resource created from base that
+// can be traced back to the suite, parallel node and time that created it,
+// e.g. "go_app-abcdefgh-n2-pz8q1c-xyzwvu". Names are cut to 63 characters by
+// shortening base, then the suite ID, so the unique suffix is always kept.
+func ResourceName(base string) string {
+	unique := fmt.Sprintf("-n%d-%s-%s", config.GinkgoConfig.ParallelNode, strconv.FormatInt(time.Now().Unix(), 36), RandStringRunes(6))
+	suite := "-" + SuiteID
+
+	if over := len(base) + len(suite) + len(unique) - maxResourceName; over > 0 {
+		keep := len(base) - over
+		if keep < 1 {
+			keep = 1
+		}
+		base = strings.TrimRight(base[:keep], "-.")
+	}
+	if over := len(base) + len(suite) + len(unique) - maxResourceName; over > 0 && over < len(suite) {
+		suite = suite[:len(suite)-over]
+	}
+	return base + suite + unique
+}
+
+// ResourceLabels are the metadata labels applied to created apps and
+// buildpacks, see FindByLabel.
+func ResourceLabels() map[string]string {
+	return map[string]string{
+		LabelSuite:   SuiteID,
+		LabelNode:    strconv.Itoa(config.GinkgoConfig.ParallelNode),
+		LabelCreated: strconv.FormatInt(time.Now().Unix(), 10),
+	}
+}
+
+// SuiteSelector selects every resource labelled by this suite.
+func SuiteSelector() string {
+	return LabelSuite + "=" + SuiteID
+}
+
+type LabeledResource struct {
+	GUID     string `json:"guid"`
+	Name     string `json:"name"`
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+}
+
+// FindByLabel lists resources of a v3 kind ("apps", "buildpacks",
+// "service_instances", ...) matching a label selector such as
+// SuiteSelector(), to find what a suite leaked or which test created what.
+func FindByLabel(kind, selector string) ([]LabeledResource, error) {
+	var resources []LabeledResource
+	next := fmt.Sprintf("/v3/%s?per_page=100&label_selector=%s", kind, url.QueryEscape(selector))
+	for next != "" {
+		var page struct {
+			Pagination struct {
+				Next *struct {
+					Href string `json:"href"`
+				} `json:"next"`
+			} `json:"pagination"`
+			Resources []LabeledResource `json:"resources"`
+		}
+		if err := cfCurl(next, &page); err != nil {
+			return nil, err
+		}
+		resources = append(resources, page.Resources...)
+
+		next = ""
+		if page.Pagination.Next != nil {
+			u, err := url.Parse(page.Pagination.Next.Href)
+			if err != nil {
+				return nil, err
+			}
+			next = u.RequestURI()
+		}
+	}
+	return resources, nil
+}
+
+// labelResource applies labels to a v3 resource. Labels only help debugging,
+// so failures (e.g. on foundations without metadata support) are reported
+// rather than returned.
+func labelResource(kind, guid string, labels map[string]string) {
+	body, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}})
+	if err == nil {
+		var out []byte
+		cmd := exec.Command("cf", "curl", "-X", "PATCH", fmt.Sprintf("/v3/%s/%s", kind, guid), "-d", string(body))
+		if out, err = cfCombinedOutput(cmd); err == nil && strings.Contains(string(out), `"errors"`) {
+			err = fmt.Errorf("%s", out)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(DefaultStdoutStderr, "cutlass: could not label %s %s: %v\n", kind, guid, err)
+	}
+}
+
+func labelBuildpack(name string) {
+	labelByName("buildpacks", name)
+}
+
+func labelService(name string) {
+	labelByName("service_instances", name)
+}
+
+// labelByName applies ResourceLabels to the v3 resources of kind called name.
+func labelByName(kind, name string) {
+	var found struct {
+		Resources []LabeledResource `json:"resources"`
+	}
+	if err := cfCurl(fmt.Sprintf("/v3/%s?names=%s", kind, url.QueryEscape(name)), &found); err != nil {
+		fmt.Fprintf(DefaultStdoutStderr, "cutlass: could not label %s %s: %v\n", kind, name, err)
+		return
+	}
+	for _, r := range found.Resources {
+		labelResource(kind, r.GUID, ResourceLabels())
+	}
+}
@@ -0,0 +1,88 @@
+package cutlass
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+)
+
+// PackageOptions configures PackageBuildpack. Dir defaults to the buildpack
+// found by FindRoot, Version to the contents of its VERSION file and CacheDir
+// to packager.CacheDir. An empty Stack packages for any stack.
+type PackageOptions struct {
+	Dir      string
+	Version  string
+	Stack    string
+	Cached   bool
+	CacheDir string
+}
+
+type BuildpackArtifact struct {
+	File    string
+	Version string
+	SHA256  string
+}
+
+// PackageBuildpack packages a buildpack with the packager API, so suites do
+// not need a script that builds the buildpack before they run.
+func PackageBuildpack(opts PackageOptions) (BuildpackArtifact, error) {
+	if opts.Dir == "" {
+		dir, err := FindRoot()
+		if err != nil {
+			return BuildpackArtifact{}, fmt.Errorf("Failed to find root: %v", err)
+		}
+		opts.Dir = dir
+	}
+	if opts.Version == "" {
+		data, err := ioutil.ReadFile(filepath.Join(opts.Dir, "VERSION"))
+		if err != nil {
+			return BuildpackArtifact{}, fmt.Errorf("Failed to read VERSION file: %v", err)
+		}
+		opts.Version = strings.TrimSpace(string(data))
+	}
+	if opts.CacheDir == "" {
+		opts.CacheDir = packager.CacheDir
+	}
+
+	var file string
+	if compileExtension, err := isCompileExtensionBuildpack(opts.Dir); err != nil {
+		return BuildpackArtifact{}, fmt.Errorf("Failed to decide if this is a compile extension buildpack: %v", err)
+	} else if compileExtension {
+		file, err = packager.CompileExtensionPackage(opts.Dir, opts.Version, opts.Cached, opts.Stack)
+		if err != nil {
+			return BuildpackArtifact{}, fmt.Errorf("Failed to package as a compile extension buildpack: %v", err)
+		}
+	} else {
+		file, err = packager.Package(opts.Dir, opts.CacheDir, opts.Version, opts.Stack, opts.Cached)
+		if err != nil {
+			return BuildpackArtifact{}, fmt.Errorf("Failed to package buildpack: %v", err)
+		}
+	}
+
+	sha, err := sha256File(file)
+	if err != nil {
+		return BuildpackArtifact{}, err
+	}
+	return BuildpackArtifact{File: file, Version: opts.Version, SHA256: sha}, nil
+}
+
+func sha256File(path string) (string, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, fh); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
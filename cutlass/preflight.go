@@ -0,0 +1,271 @@
+package cutlass
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Requirements describes what a test suite needs from the targeted org and
+// space. Zero values are not checked.
+type Requirements struct {
+	MemoryMB       int
+	Routes         int
+	Services       int
+	SecurityGroups []string
+	Stacks         []string
+}
+
+type PreflightError struct {
+	Mismatches []string
+}
+
+func (e *PreflightError) Error() string {
+	return "the targeted CF environment cannot run this suite:\n  " + strings.Join(e.Mismatches, "\n  ")
+}
+
+type cfQuota struct {
+	MemoryLimit   int `json:"memory_limit"`
+	TotalRoutes   int `json:"total_routes"`
+	TotalServices int `json:"total_services"`
+}
+
+// Preflight checks the targeted org and space quotas, security groups and
+// stacks against req, returning a *PreflightError listing every mismatch.
+// Call it from BeforeSuite so a misconfigured environment fails fast instead
+// of surfacing as unrelated push errors.
+func Preflight(req Requirements) error {
+	config, err := readCFConfig()
+	if err != nil {
+		return err
+	}
+	orgGUID, spaceGUID := config.OrganizationFields.GUID, config.SpaceFields.GUID
+	if orgGUID == "" || spaceGUID == "" {
+		return &PreflightError{Mismatches: []string{"no org and space targeted, run cf target"}}
+	}
+
+	var mismatches []string
+
+	if req.MemoryMB > 0 || req.Routes > 0 || req.Services > 0 {
+		quota, err := orgQuota(orgGUID)
+		if err != nil {
+			return err
+		}
+		found, err := quotaMismatches("org", quota, req, quotaUsage{
+			memoryMB: func() (int, error) {
+				var usage struct {
+					MemoryUsageInMB int `json:"memory_usage_in_mb"`
+				}
+				err := cfCurl("/v2/organizations/"+orgGUID+"/memory_usage", &usage)
+				return usage.MemoryUsageInMB, err
+			},
+			routes:   func() (int, error) { return cfTotalResults("/v2/routes?q=organization_guid:" + orgGUID) },
+			services: func() (int, error) { return cfTotalResults("/v2/service_instances?q=organization_guid:" + orgGUID) },
+		})
+		if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, found...)
+
+		quota, hasSpaceQuota, err := spaceQuota(spaceGUID)
+		if err != nil {
+			return err
+		}
+		if hasSpaceQuota {
+			found, err := quotaMismatches("space", quota, req, quotaUsage{
+				memoryMB: func() (int, error) { return spaceMemoryUsage(spaceGUID) },
+				routes:   func() (int, error) { return cfTotalResults("/v2/spaces/" + spaceGUID + "/routes") },
+				services: func() (int, error) { return cfTotalResults("/v2/spaces/" + spaceGUID + "/service_instances") },
+			})
+			if err != nil {
+				return err
+			}
+			mismatches = append(mismatches, found...)
+		}
+	}
+
+	if len(req.SecurityGroups) > 0 {
+		groups, err := securityGroups(spaceGUID)
+		if err != nil {
+			return err
+		}
+		for _, name := range req.SecurityGroups {
+			if !groups[name] {
+				mismatches = append(mismatches, fmt.Sprintf("security group %s is not bound to the space or running by default", name))
+			}
+		}
+	}
+
+	if len(req.Stacks) > 0 {
+		stacks, err := Stacks()
+		if err != nil {
+			return err
+		}
+		for _, name := range req.Stacks {
+			if !contains(stacks, name) {
+				mismatches = append(mismatches, fmt.Sprintf("stack %s is not available, found %v", name, stacks))
+			}
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return &PreflightError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// quotaUsage looks up how much of each quota limit is in use.
+type quotaUsage struct {
+	memoryMB func() (int, error)
+	routes   func() (int, error)
+	services func() (int, error)
+}
+
+// quotaMismatches checks an org or space quota against req. Unlimited
+// limits, which are -1, are not checked.
+func quotaMismatches(scope string, quota cfQuota, req Requirements, usage quotaUsage) ([]string, error) {
+	var mismatches []string
+
+	if req.MemoryMB > 0 && quota.MemoryLimit >= 0 {
+		used, err := usage.memoryMB()
+		if err != nil {
+			return nil, err
+		}
+		if available := quota.MemoryLimit - used; available < req.MemoryMB {
+			mismatches = append(mismatches, fmt.Sprintf("memory: need %dMB, %dMB of the %dMB %s quota is available", req.MemoryMB, available, quota.MemoryLimit, scope))
+		}
+	}
+
+	if req.Routes > 0 && quota.TotalRoutes >= 0 {
+		used, err := usage.routes()
+		if err != nil {
+			return nil, err
+		}
+		if available := quota.TotalRoutes - used; available < req.Routes {
+			mismatches = append(mismatches, fmt.Sprintf("routes: need %d, %d of the %d %s quota are available", req.Routes, available, quota.TotalRoutes, scope))
+		}
+	}
+
+	if req.Services > 0 && quota.TotalServices >= 0 {
+		used, err := usage.services()
+		if err != nil {
+			return nil, err
+		}
+		if available := quota.TotalServices - used; available < req.Services {
+			mismatches = append(mismatches, fmt.Sprintf("services: need %d, %d of the %d %s quota are available", req.Services, available, quota.TotalServices, scope))
+		}
+	}
+	return mismatches, nil
+}
+
+func orgQuota(orgGUID string) (cfQuota, error) {
+	var org struct {
+		Entity struct {
+			QuotaDefinitionURL string `json:"quota_definition_url"`
+		} `json:"entity"`
+	}
+	if err := cfCurl("/v2/organizations/"+orgGUID, &org); err != nil {
+		return cfQuota{}, err
+	}
+
+	var quota struct {
+		Entity cfQuota `json:"entity"`
+	}
+	err := cfCurl(org.Entity.QuotaDefinitionURL, &quota)
+	return quota.Entity, err
+}
+
+// spaceQuota returns the quota of the space, and false if it has none.
+func spaceQuota(spaceGUID string) (cfQuota, bool, error) {
+	var space struct {
+		Entity struct {
+			SpaceQuotaDefinitionGUID string `json:"space_quota_definition_guid"`
+		} `json:"entity"`
+	}
+	if err := cfCurl("/v2/spaces/"+spaceGUID, &space); err != nil {
+		return cfQuota{}, false, err
+	}
+	if space.Entity.SpaceQuotaDefinitionGUID == "" {
+		return cfQuota{}, false, nil
+	}
+
+	var quota struct {
+		Entity cfQuota `json:"entity"`
+	}
+	err := cfCurl("/v2/space_quota_definitions/"+space.Entity.SpaceQuotaDefinitionGUID, &quota)
+	return quota.Entity, true, err
+}
+
+// spaceMemoryUsage is the memory of the started app instances in the space,
+// which is what its quota limits.
+func spaceMemoryUsage(spaceGUID string) (int, error) {
+	var summary struct {
+		Apps []struct {
+			Memory    int    `json:"memory"`
+			Instances int    `json:"instances"`
+			State     string `json:"state"`
+		} `json:"apps"`
+	}
+	if err := cfCurl("/v2/spaces/"+spaceGUID+"/summary", &summary); err != nil {
+		return 0, err
+	}
+	used := 0
+	for _, app := range summary.Apps {
+		if app.State == "STARTED" {
+			used += app.Memory * app.Instances
+		}
+	}
+	return used, nil
+}
+
+func securityGroups(spaceGUID string) (map[string]bool, error) {
+	groups := map[string]bool{}
+	for _, path := range []string{"/v2/spaces/" + spaceGUID + "/security_groups", "/v2/config/running_security_groups"} {
+		for path != "" {
+			var page struct {
+				NextURL   string `json:"next_url"`
+				Resources []struct {
+					Entity struct {
+						Name string `json:"name"`
+					} `json:"entity"`
+				} `json:"resources"`
+			}
+			if err := cfCurl(path, &page); err != nil {
+				return nil, err
+			}
+			for _, r := range page.Resources {
+				groups[r.Entity.Name] = true
+			}
+			path = page.NextURL
+		}
+	}
+	return groups, nil
+}
+
+func cfTotalResults(path string) (int, error) {
+	var page struct {
+		TotalResults int `json:"total_results"`
+	}
+	err := cfCurl(path, &page)
+	return page.TotalResults, err
+}
+
+func cfCurl(path string, obj interface{}) error {
+	cmd := exec.Command("cf", "curl", path)
+	cmd.Stderr = DefaultStdoutStderr
+	bytes, err := cfOutput(cmd)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, obj)
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
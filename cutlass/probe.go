@@ -0,0 +1,122 @@
+package cutlass
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultProbeInterval    = 1 * time.Second
+	DefaultProbeMaxInterval = 10 * time.Second
+	DefaultProbeTimeout     = 2 * time.Minute
+)
+
+// Probe describes what a ready app looks like and how long to wait for it.
+// Zero values fall back to the defaults above, a "/" path and a 200 status.
+type Probe struct {
+	Path           string
+	Headers        map[string]string
+	ExpectedStatus int
+	ExpectedBody   string
+	Interval       time.Duration
+	MaxInterval    time.Duration
+	Timeout        time.Duration
+}
+
+type ProbeAttempt struct {
+	At     time.Duration
+	Status int
+	Err    error
+}
+
+func (p ProbeAttempt) String() string {
+	if p.Err != nil {
+		return fmt.Sprintf("+%s error: %v", p.At.Round(time.Millisecond), p.Err)
+	}
+	return fmt.Sprintf("+%s status: %d", p.At.Round(time.Millisecond), p.Status)
+}
+
+type ProbeError struct {
+	Probe    Probe
+	Attempts []ProbeAttempt
+}
+
+func (e *ProbeError) Error() string {
+	lines := []string{fmt.Sprintf("app did not become ready at %s within %s (expected status %d", e.Probe.Path, e.Probe.Timeout, e.Probe.ExpectedStatus)}
+	if e.Probe.ExpectedBody != "" {
+		lines[0] += fmt.Sprintf(" and body containing %q", e.Probe.ExpectedBody)
+	}
+	lines[0] += "), attempts:"
+	for _, attempt := range e.Attempts {
+		lines = append(lines, "  "+attempt.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WaitUntilReady polls the app with an exponential backoff until the probe
+// succeeds or its timeout is spent. On failure the error lists every attempt
+// made and where diagnostics for the app were written.
+func (a *App) WaitUntilReady(probe Probe) error {
+	probe = probe.withDefaults()
+
+	var attempts []ProbeAttempt
+	start := time.Now()
+	interval := probe.Interval
+
+	for {
+		attempt := ProbeAttempt{At: time.Since(start)}
+
+		headers := map[string]string{}
+		for k, v := range probe.Headers {
+			headers[k] = v
+		}
+
+		body, respHeaders, err := a.Get(probe.Path, headers)
+		if err != nil {
+			attempt.Err = err
+		} else if len(respHeaders["StatusCode"]) == 1 {
+			attempt.Status, _ = strconv.Atoi(respHeaders["StatusCode"][0])
+			if attempt.Status != probe.ExpectedStatus {
+				attempt.Err = fmt.Errorf("unexpected status %d", attempt.Status)
+			} else if !strings.Contains(body, probe.ExpectedBody) {
+				attempt.Err = fmt.Errorf("status %d but body did not contain %q", attempt.Status, probe.ExpectedBody)
+			}
+		}
+		attempts = append(attempts, attempt)
+
+		if attempt.Err == nil {
+			return nil
+		}
+
+		if time.Since(start)+interval > probe.Timeout {
+			return a.withDiagnostics(&ProbeError{Probe: probe, Attempts: attempts})
+		}
+
+		time.Sleep(interval)
+		interval *= 2
+		if interval > probe.MaxInterval {
+			interval = probe.MaxInterval
+		}
+	}
+}
+
+func (p Probe) withDefaults() Probe {
+	if p.Path == "" {
+		p.Path = "/"
+	}
+	if p.ExpectedStatus == 0 {
+		p.ExpectedStatus = 200
+	}
+	if p.Interval == 0 {
+		p.Interval = DefaultProbeInterval
+	}
+	if p.MaxInterval == 0 {
+		p.MaxInterval = DefaultProbeMaxInterval
+	}
+	if p.Timeout == 0 {
+		p.Timeout = DefaultProbeTimeout
+	}
+	return p
+}
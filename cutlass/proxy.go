@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 
@@ -52,8 +53,14 @@ func NewProxy() (*httptest.Server, error) {
 }
 
 func newProxy(tls bool) (*httptest.Server, error) {
+	return listenProxy(goproxy.NewProxyHttpServer(), tls)
+}
+
+// listenProxy serves handler on every interface, so that app containers can
+// reach it through the host's address.
+func listenProxy(handler http.Handler, tls bool) (*httptest.Server, error) {
 	var err error
-	ts := httptest.NewUnstartedServer(goproxy.NewProxyHttpServer())
+	ts := httptest.NewUnstartedServer(handler)
 	ts.Listener.Close()
 	ts.Listener, err = net.Listen("tcp", "0.0.0.0:0")
 	if err != nil {
@@ -0,0 +1,183 @@
+package cutlass
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// RateLimitRetries is how many times a cf command the platform rate
+	// limited is run again before its failure is returned.
+	RateLimitRetries = 5
+	// RateLimitBackoff is the wait before the first retry; it doubles for
+	// every further retry, up to RateLimitMaxBackoff.
+	RateLimitBackoff    = 5 * time.Second
+	RateLimitMaxBackoff = time.Minute
+)
+
+// RateLimitDelay records a wait for the platform to stop rate limiting a cf
+// command.
+type RateLimitDelay struct {
+	Command string
+	Attempt int
+	Delay   time.Duration
+}
+
+var (
+	rateLimitMu     sync.Mutex
+	rateLimitDelays []RateLimitDelay
+
+	// the API's CF-RateLimitExceeded error code, or the 429 status the cf
+	// CLI reports when there is no error body
+	rateLimitPattern = regexp.MustCompile(`CF-RateLimitExceeded|(?i:status code:? 429\b|\b429 Too Many Requests)`)
+)
+
+// RateLimitDelays returns every wait cutlass made because of rate limiting.
+func RateLimitDelays() []RateLimitDelay {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	return append([]RateLimitDelay{}, rateLimitDelays...)
+}
+
+// TotalRateLimitDelay is how long cutlass has waited on rate limiting.
+func TotalRateLimitDelay() time.Duration {
+	var total time.Duration
+	for _, d := range RateLimitDelays() {
+		total += d.Delay
+	}
+	return total
+}
+
+// RateLimitReport summarizes the waits, one line per command, so suites can
+// tell slow runs on a busy foundation from slow buildpacks. It is empty when
+// nothing was rate limited.
+func RateLimitReport() string {
+	delays := RateLimitDelays()
+	if len(delays) == 0 {
+		return ""
+	}
+
+	var commands []string
+	retries := map[string]int{}
+	waited := map[string]time.Duration{}
+	for _, d := range delays {
+		if _, found := retries[d.Command]; !found {
+			commands = append(commands, d.Command)
+		}
+		retries[d.Command]++
+		waited[d.Command] += d.Delay
+	}
+
+	lines := []string{fmt.Sprintf("Rate limited %d times, waited %s", len(delays), TotalRateLimitDelay())}
+	for _, c := range commands {
+		lines = append(lines, fmt.Sprintf("  %s: %d retries, waited %s", c, retries[c], waited[c]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cfOutput runs cmd like cmd.Output, retrying while it is rate limited.
+func cfOutput(cmd *exec.Cmd) ([]byte, error) {
+	return retryRateLimited(cmd, (*exec.Cmd).Output)
+}
+
+// cfCombinedOutput runs cmd like cmd.CombinedOutput, retrying while it is
+// rate limited.
+func cfCombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return retryRateLimited(cmd, (*exec.Cmd).CombinedOutput)
+}
+
+// cfRun runs cmd like cmd.Run, retrying while it is rate limited.
+func cfRun(cmd *exec.Cmd) error {
+	_, err := retryRateLimited(cmd, func(c *exec.Cmd) ([]byte, error) {
+		return nil, c.Run()
+	})
+	return err
+}
+
+// retryRateLimited runs a fresh copy of cmd for every attempt, since a
+// command can only be run once. Each attempt's output is buffered and only
+// the final attempt's is written to cmd's Stdout and Stderr, and cmd's Stdin
+// is read up front so every attempt gets all of it.
+func retryRateLimited(cmd *exec.Cmd, run func(*exec.Cmd) ([]byte, error)) ([]byte, error) {
+	var stdin []byte
+	if cmd.Stdin != nil {
+		var err error
+		if stdin, err = ioutil.ReadAll(cmd.Stdin); err != nil {
+			return nil, err
+		}
+	}
+
+	delay := RateLimitBackoff
+	for attempt := 1; ; attempt++ {
+		c := exec.Command(cmd.Path, cmd.Args[1:]...)
+		c.Args, c.Env, c.Dir = cmd.Args, cmd.Env, cmd.Dir
+		if cmd.Stdin != nil {
+			c.Stdin = bytes.NewReader(stdin)
+		}
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if cmd.Stdout != nil {
+			c.Stdout = stdout
+		}
+		if cmd.Stderr != nil {
+			c.Stderr = stderr
+			if sameWriter(cmd.Stdout, cmd.Stderr) {
+				// keep the two interleaved as they were written
+				c.Stderr = stdout
+			}
+		}
+
+		output, err := run(c)
+		seen := append(append(append([]byte{}, stdout.Bytes()...), stderr.Bytes()...), output...)
+		if attempt > RateLimitRetries || !rateLimited(seen, err) {
+			if cmd.Stdout != nil {
+				if _, werr := cmd.Stdout.Write(stdout.Bytes()); werr != nil && err == nil {
+					err = werr
+				}
+			}
+			if cmd.Stderr != nil && stderr.Len() > 0 {
+				if _, werr := cmd.Stderr.Write(stderr.Bytes()); werr != nil && err == nil {
+					err = werr
+				}
+			}
+			return output, err
+		}
+
+		command := strings.Join(cmd.Args, " ")
+		fmt.Fprintf(DefaultStdoutStderr, "cutlass: %s was rate limited, retrying in %s\n", command, delay)
+		rateLimitMu.Lock()
+		rateLimitDelays = append(rateLimitDelays, RateLimitDelay{Command: command, Attempt: attempt, Delay: delay})
+		rateLimitMu.Unlock()
+
+		time.Sleep(delay)
+		if delay *= 2; delay > RateLimitMaxBackoff {
+			delay = RateLimitMaxBackoff
+		}
+	}
+}
+
+// sameWriter reports whether a and b are the same writer, like os/exec does
+// for a command's Stdout and Stderr. Writers that can't be compared never are.
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// rateLimited reports whether a cf command failed because of rate limiting.
+// The output of a successful command is never checked, as it may mention
+// anything, from an app's environment to the staging logs of a package
+// named express-rate-limit.
+func rateLimited(output []byte, err error) bool {
+	return err != nil && rateLimitPattern.Match(output)
+}
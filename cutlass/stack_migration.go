@@ -0,0 +1,131 @@
+package cutlass
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+
+	"github.com/onsi/gomega/types"
+)
+
+// CurrentStack asks the API which stack the app stages and runs on.
+func (a *App) CurrentStack() (string, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return "", err
+	}
+
+	var app struct {
+		Lifecycle struct {
+			Data struct {
+				Stack string `json:"stack"`
+			} `json:"data"`
+		} `json:"lifecycle"`
+	}
+	if err := cfCurl("/v3/apps/"+guid, &app); err != nil {
+		return "", err
+	}
+	return app.Lifecycle.Data.Stack, nil
+}
+
+// ChangeStack moves the app to stack for its next staging, without
+// restaging it.
+func (a *App) ChangeStack(stack string) error {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"lifecycle": map[string]interface{}{
+			"type": "buildpack",
+			"data": map[string]interface{}{"stack": stack},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("cf", "curl", "-X", "PATCH", "/v3/apps/"+guid, "-d", string(body))
+	cmd.Stderr = DefaultStdoutStderr
+	out, err := cfOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Errors []struct {
+			Detail string `json:"detail"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(out, &response); err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("could not move %s to stack %s: %s", a.Name, stack, response.Errors[0].Detail)
+	}
+	a.Stack = stack
+	return nil
+}
+
+// RestageOnStack moves the app to stack and restages it there, as an
+// operator migrating apps off an old stack (e.g. cflinuxfs3 to cflinuxfs4)
+// would. The stack the app was on is kept for RollbackStack. A failed
+// restage leaves the app on stack, so its staging logs can be checked with
+// FailStagingWith.
+func (a *App) RestageOnStack(stack string) error {
+	previous, err := a.CurrentStack()
+	if err != nil {
+		return err
+	}
+	if err := a.ChangeStack(stack); err != nil {
+		return err
+	}
+	if a.previousStack == "" {
+		a.previousStack = previous
+	}
+	return a.Restage()
+}
+
+// RollbackStack moves the app back to the stack it was on before the first
+// RestageOnStack and restages it there.
+func (a *App) RollbackStack() error {
+	if a.previousStack == "" {
+		return fmt.Errorf("%s has not been restaged on another stack", a.Name)
+	}
+	if err := a.ChangeStack(a.previousStack); err != nil {
+		return err
+	}
+	a.previousStack = ""
+	return a.Restage()
+}
+
+// BeOnStack succeeds for an *App the API reports is on stack, e.g.
+//
+//	Expect(app.RestageOnStack("cflinuxfs4")).To(Succeed())
+//	Expect(app).To(cutlass.BeOnStack("cflinuxfs4"))
+func BeOnStack(stack string) types.GomegaMatcher {
+	return &stackMatcher{stack: stack}
+}
+
+type stackMatcher struct {
+	stack  string
+	actual string
+}
+
+func (m *stackMatcher) Match(actual interface{}) (bool, error) {
+	app, ok := actual.(*App)
+	if !ok {
+		return false, fmt.Errorf("BeOnStack expects a *cutlass.App, got %T", actual)
+	}
+	var err error
+	m.actual, err = app.CurrentStack()
+	return m.actual == m.stack, err
+}
+
+func (m *stackMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s to be on stack %s, but it is on %s", actual.(*App).Name, m.stack, m.actual)
+}
+
+func (m *stackMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s not to be on stack %s", actual.(*App).Name, m.stack)
+}
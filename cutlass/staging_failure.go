@@ -0,0 +1,146 @@
+package cutlass
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/onsi/gomega/types"
+)
+
+// StagingContextLines is how many staging log lines before and after a
+// matched error message FailStagingWith captures.
+var StagingContextLines = 5
+
+// StagingFailure describes why an app's package failed to stage.
+type StagingFailure struct {
+	Failed      bool
+	Reason      string
+	Description string
+}
+
+// StagingFailure asks the API whether the app's last staging failed.
+func (a *App) StagingFailure() (StagingFailure, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return StagingFailure{}, err
+	}
+
+	var app struct {
+		Entity struct {
+			PackageState             string `json:"package_state"`
+			StagingFailedReason      string `json:"staging_failed_reason"`
+			StagingFailedDescription string `json:"staging_failed_description"`
+		} `json:"entity"`
+	}
+	if err := cfCurl("/v2/apps/"+guid, &app); err != nil {
+		return StagingFailure{}, err
+	}
+	return StagingFailure{
+		Failed:      app.Entity.PackageState == "FAILED",
+		Reason:      app.Entity.StagingFailedReason,
+		Description: app.Entity.StagingFailedDescription,
+	}, nil
+}
+
+// StagingLogContext finds the first staging log line matching pattern and
+// returns it with up to lines lines of staging output on either side.
+func (a *App) StagingLogContext(pattern *regexp.Regexp, lines int) (string, bool) {
+	if a.Stdout == nil {
+		return "", false
+	}
+
+	var staging []string
+	for _, line := range ParseLogs(StripColor(a.Stdout.String())) {
+		if line.IsStaging() {
+			staging = append(staging, strings.Split(line.Message, "\n")...)
+		}
+	}
+
+	for i, line := range staging {
+		if !pattern.MatchString(line) {
+			continue
+		}
+		start, end := i-lines, i+lines+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(staging) {
+			end = len(staging)
+		}
+		return strings.Join(staging[start:end], "\n"), true
+	}
+	return "", false
+}
+
+func (a *App) stagingLogTail(lines int) string {
+	var staging []string
+	if a.Stdout != nil {
+		for _, line := range ParseLogs(StripColor(a.Stdout.String())) {
+			if line.IsStaging() {
+				staging = append(staging, line.Message)
+			}
+		}
+	}
+	if len(staging) > lines {
+		staging = staging[len(staging)-lines:]
+	}
+	return strings.Join(staging, "\n")
+}
+
+// FailStagingWith succeeds for an *App whose staging failed and whose
+// staging logs contain message, e.g.
+//
+//	Expect(app.Push()).ToNot(Succeed())
+//	Expect(app).To(cutlass.FailStagingWith("Unsupported ruby version"))
+//
+// The log lines around the message are kept for failure output and are
+// available from the matcher's Context.
+func FailStagingWith(message string) *StagingFailureMatcher {
+	return &StagingFailureMatcher{pattern: regexp.MustCompile(regexp.QuoteMeta(message)), description: fmt.Sprintf("%q", message)}
+}
+
+// FailStagingMatching is FailStagingWith for a regular expression.
+func FailStagingMatching(pattern string) *StagingFailureMatcher {
+	return &StagingFailureMatcher{pattern: regexp.MustCompile(pattern), description: fmt.Sprintf("/%s/", pattern)}
+}
+
+type StagingFailureMatcher struct {
+	pattern     *regexp.Regexp
+	description string
+
+	app     *App
+	failure StagingFailure
+	found   bool
+	Context string
+}
+
+var _ types.GomegaMatcher = &StagingFailureMatcher{}
+
+func (m *StagingFailureMatcher) Match(actual interface{}) (bool, error) {
+	app, ok := actual.(*App)
+	if !ok {
+		return false, fmt.Errorf("FailStagingWith expects a *cutlass.App, got %T", actual)
+	}
+	m.app = app
+
+	failure, err := app.StagingFailure()
+	if err != nil {
+		return false, err
+	}
+	m.failure = failure
+	m.Context, m.found = app.StagingLogContext(m.pattern, StagingContextLines)
+
+	return failure.Failed && m.found, nil
+}
+
+func (m *StagingFailureMatcher) FailureMessage(actual interface{}) string {
+	if !m.failure.Failed {
+		return fmt.Sprintf("Expected %s to fail staging with %s, but staging did not fail\n\nLast staging logs:\n%s", m.app.Name, m.description, m.app.stagingLogTail(20))
+	}
+	return fmt.Sprintf("Expected %s to fail staging with %s\nIt failed with %s: %s\n\nLast staging logs:\n%s", m.app.Name, m.description, m.failure.Reason, m.failure.Description, m.app.stagingLogTail(20))
+}
+
+func (m *StagingFailureMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s not to fail staging with %s, but it did:\n%s", m.app.Name, m.description, m.Context)
+}
@@ -0,0 +1,102 @@
+package cutlass
+
+import (
+	"fmt"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+)
+
+// DropletProcessTypes returns the process types, and their commands, that
+// the buildpacks detected for the app's current droplet.
+func (a *App) DropletProcessTypes() (map[string]string, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return nil, err
+	}
+
+	var droplet struct {
+		ProcessTypes map[string]string `json:"process_types"`
+	}
+	if err := cfCurl("/v3/apps/"+guid+"/droplets/current", &droplet); err != nil {
+		return nil, err
+	}
+	return droplet.ProcessTypes, nil
+}
+
+// DetectedStartCommand is the web command the buildpacks detected for the
+// app's current droplet.
+func (a *App) DetectedStartCommand() (string, error) {
+	processTypes, err := a.DropletProcessTypes()
+	if err != nil {
+		return "", err
+	}
+	return processTypes["web"], nil
+}
+
+// WebProcessCommand is the command the app's web process runs with, which
+// is the detected start command unless the app overrides it.
+func (a *App) WebProcessCommand() (string, error) {
+	guid, err := a.AppGUID()
+	if err != nil {
+		return "", err
+	}
+
+	var process struct {
+		Command string `json:"command"`
+	}
+	if err := cfCurl("/v3/apps/"+guid+"/processes/web", &process); err != nil {
+		return "", err
+	}
+	return process.Command, nil
+}
+
+// HaveDetectedStartCommand succeeds for an *App whose droplet's detected web
+// command equals expected, or satisfies it if expected is a matcher, e.g.
+//
+//	Expect(app).To(cutlass.HaveDetectedStartCommand(ContainSubstring("bundle exec rackup")))
+func HaveDetectedStartCommand(expected interface{}) types.GomegaMatcher {
+	return &startCommandMatcher{kind: "detected start command", get: (*App).DetectedStartCommand, expected: expected}
+}
+
+// HaveWebProcessCommand is HaveDetectedStartCommand for the command the web
+// process runs with.
+func HaveWebProcessCommand(expected interface{}) types.GomegaMatcher {
+	return &startCommandMatcher{kind: "web process command", get: (*App).WebProcessCommand, expected: expected}
+}
+
+type startCommandMatcher struct {
+	kind     string
+	get      func(*App) (string, error)
+	expected interface{}
+
+	matcher types.GomegaMatcher
+	command string
+}
+
+func (m *startCommandMatcher) Match(actual interface{}) (bool, error) {
+	app, ok := actual.(*App)
+	if !ok {
+		return false, fmt.Errorf("expected a *cutlass.App, got %T", actual)
+	}
+
+	command, err := m.get(app)
+	if err != nil {
+		return false, fmt.Errorf("could not get the %s of %s: %v", m.kind, app.Name, err)
+	}
+	m.command = command
+
+	m.matcher, ok = m.expected.(types.GomegaMatcher)
+	if !ok {
+		m.matcher = gomega.Equal(m.expected)
+	}
+	return m.matcher.Match(command)
+}
+
+func (m *startCommandMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Unexpected %s:\n%s", m.kind, m.matcher.FailureMessage(m.command))
+}
+
+func (m *startCommandMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Unexpected %s:\n%s", m.kind, m.matcher.NegatedFailureMessage(m.command))
+}
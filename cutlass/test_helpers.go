@@ -15,7 +15,6 @@ import (
 
 	"github.com/cloudfoundry/libbuildpack"
 	"github.com/cloudfoundry/libbuildpack/cutlass/glow"
-	"github.com/cloudfoundry/libbuildpack/packager"
 	"github.com/cloudfoundry/packit"
 	"gopkg.in/yaml.v2"
 )
@@ -58,19 +57,11 @@ func PackageUniquelyVersionedBuildpackExtra(name, version, stack string, cached,
 
 	var file string
 	if os.Getenv("BUILDPACK_FILE") == "" {
-		if compileExtension, err := isCompileExtensionBuildpack(bpDir); err != nil {
-			return VersionedBuildpackPackage{}, fmt.Errorf("Failed to decide if this is a compile extension buildpack: %v", err)
-		} else if compileExtension {
-			file, err = packager.CompileExtensionPackage(bpDir, version, cached, stack)
-			if err != nil {
-				return VersionedBuildpackPackage{}, fmt.Errorf("Failed to package as a compile extension buildpack: %v", err)
-			}
-		} else {
-			file, err = packager.Package(bpDir, packager.CacheDir, version, stack, cached)
-			if err != nil {
-				return VersionedBuildpackPackage{}, fmt.Errorf("Failed to package buildpack: %v", err)
-			}
+		artifact, err := PackageBuildpack(PackageOptions{Dir: bpDir, Version: version, Stack: stack, Cached: cached})
+		if err != nil {
+			return VersionedBuildpackPackage{}, err
 		}
+		file = artifact.File
 	} else {
 		file = os.Getenv("BUILDPACK_FILE")
 		version, err = readVersionFromZip(file)
@@ -0,0 +1,119 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DiskUsageEnv, when "true", makes Stager.ReportDiskUsage log where staging
+// disk space went, to help diagnose disk quota failures.
+const DiskUsageEnv = "BP_REPORT_DISK_USAGE"
+
+// DiskUsageTopN is how many of the largest directories DiskUsage lists.
+var DiskUsageTopN = 5
+
+// DirUsage is the size of the files under Path.
+type DirUsage struct {
+	Path    string
+	Bytes   int64
+	Largest []DirUsage
+}
+
+// DiskUsage sums the sizes of the files under dir, listing the topN largest
+// directories directly inside it. Symlinks are not followed.
+func DiskUsage(dir string, topN int) (DirUsage, error) {
+	usage := DirUsage{Path: dir}
+	children := map[string]int64{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		usage.Bytes += info.Size()
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			if child := filepath.Join(dir, firstPathElement(rel)); child != path {
+				children[child] += info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return DirUsage{}, err
+	}
+
+	for path, bytes := range children {
+		usage.Largest = append(usage.Largest, DirUsage{Path: path, Bytes: bytes})
+	}
+	sort.Slice(usage.Largest, func(i, j int) bool {
+		if usage.Largest[i].Bytes == usage.Largest[j].Bytes {
+			return usage.Largest[i].Path < usage.Largest[j].Path
+		}
+		return usage.Largest[i].Bytes > usage.Largest[j].Bytes
+	})
+	if len(usage.Largest) > topN {
+		usage.Largest = usage.Largest[:topN]
+	}
+	return usage, nil
+}
+
+func firstPathElement(rel string) string {
+	for i := 0; i < len(rel); i++ {
+		if os.IsPathSeparator(rel[i]) {
+			return rel[:i]
+		}
+	}
+	return rel
+}
+
+// ReportDiskUsage logs the size of the deps, cache and build dirs and their
+// largest directories when DiskUsageEnv is "true". Buildpacks call it at the
+// end of finalize.
+func (s *Stager) ReportDiskUsage() {
+	if os.Getenv(DiskUsageEnv) != "true" {
+		return
+	}
+
+	s.log.BeginStep("Disk usage")
+	for _, dir := range []struct{ name, path string }{
+		{"Deps dir", s.depsDir},
+		{"Cache dir", s.cacheDir},
+		{"App dir", s.buildDir},
+	} {
+		if dir.path == "" {
+			continue
+		}
+		usage, err := DiskUsage(dir.path, DiskUsageTopN)
+		if err != nil {
+			s.log.Warning("Could not measure %s: %v", dir.path, err)
+			continue
+		}
+		s.log.Info("%s %s: %s", dir.name, dir.path, formatBytes(usage.Bytes))
+		for _, child := range usage.Largest {
+			s.log.Info("  %8s  %s", formatBytes(child.Bytes), child.Path)
+		}
+	}
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	value, suffix := float64(bytes)/unit, "KMGT"
+	for i := 0; i < len(suffix); i++ {
+		if value < unit || i == len(suffix)-1 {
+			return fmt.Sprintf("%.1f%ciB", value, suffix[i])
+		}
+		value /= unit
+	}
+	return ""
+}
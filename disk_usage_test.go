@@ -0,0 +1,93 @@
+package libbuildpack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DiskUsage", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "usage")
+		Expect(err).To(BeNil())
+
+		for path, size := range map[string]int{
+			"top.txt":           10,
+			"big/a":             3000,
+			"big/nested/b":      2000,
+			"medium/c":          1000,
+			"small/d":           100,
+			"small/nested/e/ff": 50,
+		} {
+			Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, path), []byte(strings.Repeat("x", size)), 0644)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("sums the files and lists the largest directories", func() {
+		usage, err := libbuildpack.DiskUsage(dir, 2)
+		Expect(err).To(BeNil())
+
+		Expect(usage.Path).To(Equal(dir))
+		Expect(usage.Bytes).To(Equal(int64(6160)))
+		Expect(usage.Largest).To(Equal([]libbuildpack.DirUsage{
+			{Path: filepath.Join(dir, "big"), Bytes: 5000},
+			{Path: filepath.Join(dir, "medium"), Bytes: 1000},
+		}))
+	})
+
+	It("does not follow symlinks", func() {
+		Expect(os.Symlink(filepath.Join(dir, "big"), filepath.Join(dir, "link"))).To(Succeed())
+
+		usage, err := libbuildpack.DiskUsage(dir, 10)
+		Expect(err).To(BeNil())
+		Expect(usage.Bytes).To(Equal(int64(6160)))
+		Expect(usage.Largest).To(HaveLen(3))
+	})
+
+	Describe("Stager.ReportDiskUsage", func() {
+		var (
+			buffer *bytes.Buffer
+			stager *libbuildpack.Stager
+		)
+
+		BeforeEach(func() {
+			buffer = new(bytes.Buffer)
+			stager = libbuildpack.NewStager([]string{filepath.Join(dir, "big"), filepath.Join(dir, "medium"), filepath.Join(dir, "small"), "0"}, libbuildpack.NewLogger(buffer), nil)
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("BP_REPORT_DISK_USAGE")
+		})
+
+		It("logs nothing by default", func() {
+			stager.ReportDiskUsage()
+			Expect(buffer.String()).To(BeEmpty())
+		})
+
+		It("logs the size of each staging dir", func() {
+			os.Setenv("BP_REPORT_DISK_USAGE", "true")
+			stager.ReportDiskUsage()
+
+			Expect(buffer.String()).To(ContainSubstring("-----> Disk usage"))
+			Expect(buffer.String()).To(ContainSubstring("Deps dir " + filepath.Join(dir, "small") + ": 150B"))
+			Expect(buffer.String()).To(ContainSubstring("Cache dir " + filepath.Join(dir, "medium") + ": 1000B"))
+			Expect(buffer.String()).To(ContainSubstring("App dir " + filepath.Join(dir, "big") + ": 4.9KiB"))
+			Expect(buffer.String()).To(ContainSubstring("2.0KiB  " + filepath.Join(dir, "big", "nested")))
+		})
+	})
+})
@@ -0,0 +1,235 @@
+package libbuildpack
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DownloadOptions controls how dependency downloads deal with transient
+// failures: network errors and 408, 429 and 5xx responses are retried up to
+// Attempts times in total, waiting InitialBackoff and then twice as long each
+// time up to MaxBackoff. A retry resumes a partial download with an HTTP
+// range request when the server supports it. Timeout, if set, bounds each
+// attempt. Uris other than http and https are retrieved with their Fetcher.
+//
+// Downloads go through the proxy named by HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY. CACertsFile is a PEM bundle of certificates to trust on top of
+// the system roots, as are any PEM certificates in BUILDPACK_CA_CERTS.
+type DownloadOptions struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	CACertsFile    string
+
+	// progress, if set, is told how many of the total bytes of a download
+	// have arrived; total is -1 when the server does not say.
+	progress func(downloaded, total int64)
+}
+
+var DefaultDownloadOptions = DownloadOptions{
+	Attempts:       3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+type downloadStatusError struct {
+	statusCode int
+}
+
+func (e *downloadStatusError) Error() string {
+	return fmt.Sprintf("could not download: %d", e.statusCode)
+}
+
+func (e *downloadStatusError) retryable() bool {
+	return e.statusCode == http.StatusRequestTimeout || e.statusCode == http.StatusTooManyRequests || e.statusCode >= 500
+}
+
+func downloadFile(ctx context.Context, url, destFile string, opts DownloadOptions, logger *Logger) error {
+	client, err := downloadClient(opts)
+	if err != nil {
+		return err
+	}
+	fetcher, err := fetcherFor(url, client)
+	if err != nil {
+		return err
+	}
+	fetch := func(resume bool) error {
+		return fetchAttempt(ctx, fetcher, url, destFile, opts)
+	}
+	if httpFetcher, ok := fetcher.(*HTTPFetcher); ok {
+		// plain http downloads can resume where a failed attempt left off
+		if httpFetcher.Client != nil {
+			client = httpFetcher.Client
+		}
+		fetch = func(resume bool) error {
+			return downloadAttempt(ctx, client, url, destFile, resume, opts)
+		}
+	}
+
+	backoff := opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err = fetch(attempt > 1); err == nil {
+			return nil
+		}
+		if statusErr, ok := err.(*downloadStatusError); ok && !statusErr.retryable() {
+			break
+		}
+		if ctx.Err() != nil || attempt >= opts.Attempts {
+			break
+		}
+
+		logger.Warning("Download failed: %v, retrying in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; opts.MaxBackoff > 0 && backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// downloadAttempt fetches url into destFile. With resume, bytes already in
+// destFile are kept if the server honours a range request for the rest.
+func downloadAttempt(ctx context.Context, client *http.Client, url, destFile string, resume bool, opts DownloadOptions) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	if resume {
+		if info, err := os.Stat(destFile); err == nil && info.Size() > 0 {
+			offset = info.Size()
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if opts.progress != nil {
+		start := int64(0)
+		if offset > 0 && resp.StatusCode == http.StatusPartialContent {
+			start = offset
+		}
+		total := int64(-1)
+		if resp.ContentLength > 0 {
+			total = start + resp.ContentLength
+		}
+		body = &progressReader{r: resp.Body, downloaded: start, total: total, report: opts.progress}
+	}
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		return appendToFile(body, destFile)
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is stale, start over on the next attempt
+		os.Remove(destFile)
+		return &downloadStatusError{resp.StatusCode}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return &downloadStatusError{resp.StatusCode}
+	}
+
+	return writeToFile(body, destFile, 0666)
+}
+
+type progressReader struct {
+	r          io.Reader
+	downloaded int64
+	total      int64
+	report     func(downloaded, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.downloaded += int64(n)
+		p.report(p.downloaded, p.total)
+	}
+	return n, err
+}
+
+// downloadClient is http.DefaultClient, or a client that also trusts the
+// extra CA certificates from opts and BUILDPACK_CA_CERTS.
+func downloadClient(opts DownloadOptions) (*http.Client, error) {
+	var extraCerts [][]byte
+	if opts.CACertsFile != "" {
+		pem, err := ioutil.ReadFile(opts.CACertsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificates: %v", err)
+		}
+		extraCerts = append(extraCerts, pem)
+	}
+	if pem := os.Getenv("BUILDPACK_CA_CERTS"); pem != "" {
+		extraCerts = append(extraCerts, []byte(pem))
+	}
+	if len(extraCerts) == 0 {
+		return http.DefaultClient, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, pem := range extraCerts {
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("could not read CA certificates: no PEM certificates found")
+		}
+	}
+
+	var transport *http.Transport
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	} else {
+		// http.DefaultTransport has been replaced with one the CA
+		// certificates cannot be given to, so start from its defaults
+		transport = &http.Transport{
+			DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		}
+	}
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport}, nil
+}
+
+func appendToFile(source io.Reader, destFile string) error {
+	fh, err := os.OpenFile(filepath.Clean(destFile), os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	_, err = io.Copy(fh, source)
+	return err
+}
@@ -0,0 +1,152 @@
+package libbuildpack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// downloadCache keeps verified downloads on disk across runs, e.g. for local
+// development where the same dependencies are installed again and again.
+// Files are keyed on the entry's uri and checksums, and the least recently
+// used are evicted once the cache grows past maxBytes.
+type downloadCache struct {
+	dir      string
+	maxBytes int64
+}
+
+// SetDownloadCache makes the installer keep downloaded dependencies in dir
+// and reuse them instead of downloading them again. Once the files in dir
+// take up more than maxBytes, the least recently used are removed; a
+// maxBytes of 0 or less means no limit.
+func (i *Installer) SetDownloadCache(dir string, maxBytes int64) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	i.downloadCache = &downloadCache{dir: dir, maxBytes: maxBytes}
+	return nil
+}
+
+func (c *downloadCache) path(entry *ManifestEntry) string {
+	h := sha256.New()
+	h.Write([]byte(entry.URI))
+	for _, sum := range []string{entry.SHA256, entry.SHA512} {
+		h.Write([]byte("\n" + sum))
+	}
+	var algorithms []string
+	for algorithm := range entry.Checksums {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		h.Write([]byte("\n" + algorithm + ":" + entry.Checksums[algorithm]))
+	}
+	return filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil)))
+}
+
+// fetch copies the cached download of entry to outputFile, reporting
+// whether there was a good one.
+func (c *downloadCache) fetch(entry *ManifestEntry, outputFile string) bool {
+	cacheFile := c.path(entry)
+	if exists, err := FileExists(cacheFile); err != nil || !exists {
+		return false
+	}
+	if err := CopyFile(cacheFile, outputFile); err != nil {
+		return false
+	}
+	if err := deleteBadFile(entry, outputFile); err != nil {
+		os.Remove(cacheFile)
+		return false
+	}
+
+	now := time.Now()
+	os.Chtimes(cacheFile, now, now)
+	return true
+}
+
+// store adds the verified download in file to the cache and evicts the
+// least recently used files beyond the size limit.
+func (c *downloadCache) store(entry *ManifestEntry, file string) error {
+	if err := replaceFile(file, c.path(entry)); err != nil {
+		return err
+	}
+	return c.evict()
+}
+
+func (c *downloadCache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(a, b int) bool { return files[a].ModTime().After(files[b].ModTime()) })
+
+	var total int64
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != "" {
+			// skip the lock and partial files of other processes
+			continue
+		}
+		total += file.Size()
+		if total > c.maxBytes {
+			if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// download is fetch, handing over the prefetched file for entry if there is
+// one. It reports whether the download cache had the file.
+func (i *Installer) download(ctx context.Context, entry *ManifestEntry, outputFile string) (bool, error) {
+	if found, err := i.usePrefetched(entry, outputFile); found {
+		return false, err
+	}
+	return i.fetch(ctx, entry, outputFile)
+}
+
+// fetch is downloadDependency going through the installer's download cache,
+// if it has one. It reports whether the cache had the file.
+func (i *Installer) fetch(ctx context.Context, entry *ManifestEntry, outputFile string) (bool, error) {
+	opts := i.downloadOptions
+	if progress := i.progress; progress != nil {
+		opts.progress = func(downloaded, total int64) { progress.Downloading(entry.Dependency, downloaded, total) }
+	}
+	if i.downloadCache == nil {
+		return false, downloadDependency(ctx, entry, outputFile, i.manifest.log, opts)
+	}
+
+	// the cache may be shared with other processes installing the same file
+	unlock, err := lockFile(ctx, i.downloadCache.path(entry)+".lock", i.manifest.log)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if i.downloadCache.fetch(entry, outputFile) {
+		i.manifest.log.Info("Copy [%s]", i.downloadCache.path(entry))
+		return true, nil
+	}
+	i.manifest.log.Debug("Download cache miss: %s", i.downloadCache.path(entry))
+
+	if err := downloadDependency(ctx, entry, outputFile, i.manifest.log, opts); err != nil {
+		return false, err
+	}
+	if err := i.downloadCache.store(entry, outputFile); err != nil {
+		i.manifest.log.Warning("Could not add %s %s to the download cache: %v", entry.Dependency.Name, entry.Dependency.Version, err)
+	}
+	return false, nil
+}
@@ -6,8 +6,9 @@ const defaultVersionsError = "The buildpack manifest is misconfigured for 'defau
 	"Contact your Cloud Foundry operator/admin. For more information, see " +
 	"https://docs.cloudfoundry.org/buildpacks/custom.html#specifying-default-versions"
 
-func dependencyMissingError(m *Manifest, dep Dependency) string {
+func dependencyMissingError(m *Manifest, err *DependencyResolutionError) string {
 	var msg string
+	dep := err.Dependency
 	otherVersions := m.AllDependencyVersions(dep.Name)
 
 	msg += fmt.Sprintf("DEPENDENCY MISSING IN MANIFEST:\n\n")
@@ -23,6 +24,13 @@ func dependencyMissingError(m *Manifest, dep Dependency) string {
 		}
 	}
 
+	if suggestions := err.Suggestions(); len(suggestions) > 0 {
+		msg += "\nYou could:\n"
+		for _, s := range suggestions {
+			msg += fmt.Sprintf("\t- %s\n", s)
+		}
+	}
+
 	return msg
 }
 
@@ -43,3 +51,12 @@ func endOfLifeWarning(depName, versionLine, eolDate, link string) string {
 
 	return fmt.Sprintf(warning, depName, versionLine, eolDate)
 }
+
+const compileDeprecationWarning = "This buildpack was run through the deprecated bin/compile entrypoint. " +
+	"Buildpacks should provide bin/supply and bin/finalize instead, " +
+	"so that they can be used with multiple buildpacks. For more information, see " +
+	"https://docs.cloudfoundry.org/buildpacks/understand-buildpacks.html"
+
+func compileMissingPhaseWarning(phase string) string {
+	return fmt.Sprintf("This buildpack has no %[1]s phase. Move the %[1]s work out of bin/compile into a %[1]s phase before removing bin/compile.", phase)
+}
@@ -0,0 +1,97 @@
+package libbuildpack
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ExtensionsDir is where platform operators can drop files into a buildpack
+// to customize it without patching Go code. During supply, files in
+// extensions/profile.d are added to the dep dir's profile.d and PEM
+// certificates in extensions/certs are trusted at runtime via SSL_CERT_DIR.
+// Executables at extensions/hooks/before_compile and
+// extensions/hooks/after_compile run after the buildpack's own hooks, with
+// the build, cache and deps dirs and the deps index as arguments.
+const ExtensionsDir = "extensions"
+
+func runExtensions(stager *Stager, phase string) error {
+	if stager.manifest == nil {
+		return nil
+	}
+	dir := filepath.Join(stager.manifest.RootDir(), ExtensionsDir)
+	if exists, err := FileExists(dir); err != nil || !exists {
+		return err
+	}
+
+	if phase == "before_compile" {
+		if err := applyExtensionProfileD(stager, dir); err != nil {
+			return err
+		}
+		if err := applyExtensionCerts(stager, dir); err != nil {
+			return err
+		}
+	}
+
+	hook := filepath.Join(dir, "hooks", phase)
+	if exists, err := FileExists(hook); err != nil || !exists {
+		return err
+	}
+
+	stager.log.BeginStep("Running extension hook %s", phase)
+	command := &Command{}
+	if err := command.ExecuteWithContext(context.Background(), stager.BuildDir(), stager.log.Output(), stager.log.Output(), hook, stager.BuildDir(), stager.CacheDir(), stager.DepsDir(), stager.DepsIdx()); err != nil {
+		return fmt.Errorf("extension hook %s failed: %v", phase, err)
+	}
+	return nil
+}
+
+func applyExtensionProfileD(stager *Stager, dir string) error {
+	scripts, err := ioutil.ReadDir(filepath.Join(dir, "profile.d"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, script := range scripts {
+		if script.IsDir() {
+			continue
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "profile.d", script.Name()))
+		if err != nil {
+			return err
+		}
+		stager.log.Info("Adding extension profile.d script %s", script.Name())
+		if err := stager.WriteProfileD(script.Name(), string(contents)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyExtensionCerts(stager *Stager, dir string) error {
+	certsDir := filepath.Join(dir, "certs")
+	if exists, err := FileExists(certsDir); err != nil || !exists {
+		return err
+	}
+
+	stager.log.Info("Adding extension certificates")
+	destDir := filepath.Join(stager.DepDir(), "extension_certs")
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	if err := CopyDirectory(certsDir, destDir); err != nil {
+		return err
+	}
+	skipped, err := rehashCerts(destDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range skipped {
+		stager.log.Warning("Extension certificate file %s holds no PEM certificates, skipping it", name)
+	}
+	return stager.WriteProfileD("extension_certs.sh", fmt.Sprintf("export SSL_CERT_DIR=\"$DEPS_DIR/%s/extension_certs:${SSL_CERT_DIR:-/etc/ssl/certs}\"\n", stager.DepsIdx()))
+}
@@ -0,0 +1,121 @@
+package libbuildpack_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	bp "github.com/cloudfoundry/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Extensions", func() {
+	var (
+		bpDir    string
+		buildDir string
+		cacheDir string
+		depsDir  string
+		buffer   *bytes.Buffer
+		stager   *bp.Stager
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip("extension hooks are shell scripts")
+		}
+
+		var err error
+		for _, dir := range []*string{&bpDir, &buildDir, &cacheDir, &depsDir} {
+			*dir, err = ioutil.TempDir("", "extensions")
+			Expect(err).To(BeNil())
+		}
+		Expect(os.MkdirAll(filepath.Join(depsDir, "3"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte("language: extended\n"), 0644)).To(Succeed())
+
+		buffer = new(bytes.Buffer)
+		logger := bp.NewLogger(buffer)
+		manifest, err := bp.NewManifest(bpDir, logger, time.Now())
+		Expect(err).To(BeNil())
+		stager = bp.NewStager([]string{buildDir, cacheDir, depsDir, "3"}, logger, manifest)
+	})
+
+	AfterEach(func() {
+		for _, dir := range []string{bpDir, buildDir, cacheDir, depsDir} {
+			os.RemoveAll(dir)
+		}
+	})
+
+	writeExtension := func(path, contents string, mode os.FileMode) {
+		path = filepath.Join(bpDir, "extensions", path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(contents), mode)).To(Succeed())
+	}
+
+	It("does nothing without an extensions dir", func() {
+		Expect(bp.RunBeforeCompile(stager)).To(Succeed())
+		Expect(bp.RunAfterCompile(stager)).To(Succeed())
+		Expect(buffer.String()).To(BeEmpty())
+	})
+
+	It("adds profile.d scripts and certificates before compile", func() {
+		writeExtension("profile.d/proxy.sh", "export HTTP_PROXY=http://proxy\n", 0644)
+		writeExtension("certs/corp.pem", "-----BEGIN CERTIFICATE-----\n", 0644)
+
+		Expect(bp.RunBeforeCompile(stager)).To(Succeed())
+
+		Expect(ioutil.ReadFile(filepath.Join(depsDir, "3", "profile.d", "proxy.sh"))).To(Equal([]byte("export HTTP_PROXY=http://proxy\n")))
+		Expect(filepath.Join(depsDir, "3", "extension_certs", "corp.pem")).To(BeARegularFile())
+		Expect(ioutil.ReadFile(filepath.Join(depsDir, "3", "profile.d", "extension_certs.sh"))).To(ContainSubstring(`SSL_CERT_DIR="$DEPS_DIR/3/extension_certs:`))
+	})
+
+	It("adds the subject hash links OpenSSL looks certificates up by", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).To(BeNil())
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "Corp  Root CA", Organization: []string{"Example Corp"}},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).To(BeNil())
+		cert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		writeExtension("certs/corp.pem", cert, 0644)
+		writeExtension("certs/README", "corporate certificates\n", 0644)
+
+		Expect(bp.RunBeforeCompile(stager)).To(Succeed())
+
+		// as computed by openssl x509 -hash
+		Expect(ioutil.ReadFile(filepath.Join(depsDir, "3", "extension_certs", "da4d32c9.0"))).To(Equal([]byte(cert)))
+		Expect(buffer.String()).To(ContainSubstring("Extension certificate file README holds no PEM certificates"))
+	})
+
+	It("runs hooks for each phase with the staging dirs", func() {
+		writeExtension("hooks/before_compile", "#!/bin/sh\necho before \"$@\"\n", 0755)
+		writeExtension("hooks/after_compile", "#!/bin/sh\necho after \"$4\"\n", 0755)
+
+		Expect(bp.RunBeforeCompile(stager)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring("before " + buildDir + " " + cacheDir + " " + depsDir + " 3"))
+		Expect(buffer.String()).ToNot(ContainSubstring("after 3"))
+
+		Expect(bp.RunAfterCompile(stager)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring("after 3"))
+	})
+
+	It("returns hook failures", func() {
+		writeExtension("hooks/after_compile", "#!/bin/sh\nexit 1\n", 0755)
+
+		Expect(bp.RunAfterCompile(stager)).To(MatchError(ContainSubstring("extension hook after_compile failed")))
+	})
+})
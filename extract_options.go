@@ -0,0 +1,152 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ExtractOptions adjusts what ExtractArchiveWithOptions and
+// Installer.InstallDependencyWithOptions write.
+//
+// StripComponents drops that many leading elements from every path in the
+// archive, like tar --strip-components; entries left with no path are
+// skipped. Include and Exclude are path.Match globs checked against the
+// stripped path and against each of its parent directories, so "bin"
+// selects everything under bin/. An entry is extracted if it matches an
+// Include glob, or there are none, and matches no Exclude glob.
+//
+// FileMode and DirMode, when not zero, replace the permission bits of
+// extracted files and directories. Owner, when set, is given ownership of
+// everything extracted.
+//
+// Entries with absolute paths or ".." elements, symlinks and hard links
+// leading outside the destination, and device nodes and fifos are refused
+// with an error. AllowUnsafePaths confines such paths to the destination,
+// keeps such links and skips such entries instead, which is only safe for
+// archives that are trusted.
+type ExtractOptions struct {
+	StripComponents int
+	Include         []string
+	Exclude         []string
+	FileMode        os.FileMode
+	DirMode         os.FileMode
+	Owner           *FileOwner
+
+	AllowUnsafePaths bool
+}
+
+type FileOwner struct {
+	UID int
+	GID int
+}
+
+func (o ExtractOptions) isZero() bool {
+	return o.StripComponents == 0 && len(o.Include) == 0 && len(o.Exclude) == 0 && o.FileMode == 0 && o.DirMode == 0 && o.Owner == nil && !o.AllowUnsafePaths
+}
+
+// target is the path an archive entry called name is extracted to, relative
+// to the destination, and whether it is extracted at all.
+func (o ExtractOptions) target(name string) (string, bool, error) {
+	slashed := strings.Replace(name, "\\", "/", -1)
+	if !o.AllowUnsafePaths && unsafePath(slashed) {
+		return "", false, fmt.Errorf("cannot extract %s outside of the destination directory", name)
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+slashed), "/")
+	if o.StripComponents > 0 {
+		parts := strings.Split(name, "/")
+		if len(parts) <= o.StripComponents {
+			return "", false, nil
+		}
+		name = strings.Join(parts[o.StripComponents:], "/")
+	}
+	if name == "" {
+		return "", len(o.Include) == 0, nil
+	}
+
+	if len(o.Include) > 0 && !matchesAnyParent(o.Include, name) {
+		return "", false, nil
+	}
+	if matchesAnyParent(o.Exclude, name) {
+		return "", false, nil
+	}
+	return name, true, nil
+}
+
+// unsafePath is whether a slash separated archive path is absolute, has a
+// volume name or climbs out of its directory.
+func unsafePath(name string) bool {
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return true
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// irregular is what to do with an entry that is neither a file, directory
+// nor link, such as a device node or fifo.
+func (o ExtractOptions) irregular(name string) error {
+	if o.AllowUnsafePaths {
+		return nil
+	}
+	return fmt.Errorf("cannot extract %s, which is not a regular file", name)
+}
+
+func matchesAnyParent(patterns []string, name string) bool {
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, prefix); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o ExtractOptions) fileMode(mode os.FileMode) os.FileMode {
+	if o.FileMode != 0 {
+		return o.FileMode
+	}
+	return mode
+}
+
+func (o ExtractOptions) dirMode(mode os.FileMode) os.FileMode {
+	if o.DirMode != 0 {
+		return o.DirMode
+	}
+	return mode
+}
+
+// apply sets the mode and owner opts asks for on an extracted path.
+func (o ExtractOptions) apply(path string, isDir bool) error {
+	if o.FileMode == 0 && o.DirMode == 0 && o.Owner == nil {
+		return nil
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		if isDir && o.DirMode != 0 {
+			err = os.Chmod(path, o.DirMode)
+		} else if !isDir && o.FileMode != 0 {
+			err = os.Chmod(path, o.FileMode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if o.Owner != nil {
+		return os.Lchown(path, o.Owner.UID, o.Owner.GID)
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package libbuildpack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fetcher retrieves artifacts, such as dependencies, from a store.
+type Fetcher interface {
+	// Get opens the artifact at uri. The caller closes it.
+	Get(ctx context.Context, uri string) (io.ReadCloser, FetchMetadata, error)
+}
+
+// FetchMetadata describes a fetched artifact. Size is -1 and the other
+// fields are empty when the store does not say.
+type FetchMetadata struct {
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified string
+}
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = map[string]Fetcher{}
+)
+
+// RegisterFetcher makes fetcher retrieve every uri with the scheme, in place
+// of the built in one if there is one, so platforms can install dependencies
+// from their own artifact stores.
+func RegisterFetcher(scheme string, fetcher Fetcher) {
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	fetchers[strings.ToLower(scheme)] = fetcher
+}
+
+// FetcherFor returns the Fetcher for the scheme of uri: one registered with
+// RegisterFetcher, or else the built in one for http, https, file, s3 or oci.
+func FetcherFor(uri string) (Fetcher, error) {
+	return fetcherFor(uri, http.DefaultClient)
+}
+
+// fetcherFor is FetcherFor, with the built in fetchers using client.
+func fetcherFor(uri string, client *http.Client) (Fetcher, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	scheme := strings.ToLower(u.Scheme)
+
+	fetchersMu.RLock()
+	fetcher, found := fetchers[scheme]
+	fetchersMu.RUnlock()
+	if found {
+		return fetcher, nil
+	}
+
+	switch scheme {
+	case "http", "https":
+		return &HTTPFetcher{Client: client}, nil
+	case "file":
+		return FileFetcher{}, nil
+	case "s3":
+		credentials := S3CredentialsFromEnv()
+		if os.Getenv(S3CredentialsEnv) != "true" {
+			credentials = S3Credentials{Region: credentials.Region}
+		}
+		return &S3Fetcher{Credentials: credentials, Endpoint: strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"), Client: client}, nil
+	case "oci":
+		return &OCIFetcher{Client: client}, nil
+	}
+	return nil, fmt.Errorf("no fetcher for %s", uri)
+}
+
+// fetchAttempt fetches uri into destFile with fetcher.
+func fetchAttempt(ctx context.Context, fetcher Fetcher, uri, destFile string, opts DownloadOptions) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	body, metadata, err := fetcher.Get(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var source io.Reader = body
+	if opts.progress != nil {
+		source = &progressReader{r: body, total: metadata.Size, report: opts.progress}
+	}
+	return writeToFile(source, destFile, 0666)
+}
+
+// HTTPFetcher fetches http and https uris. Client defaults to
+// http.DefaultClient.
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+func (f *HTTPFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, FetchMetadata, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, FetchMetadata{}, err
+	}
+	return doFetch(f.Client, req.WithContext(ctx))
+}
+
+// doFetch sends req, turning a response other than 2xx into an error.
+func doFetch(client *http.Client, req *http.Request) (io.ReadCloser, FetchMetadata, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, FetchMetadata{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, FetchMetadata{}, &downloadStatusError{resp.StatusCode}
+	}
+
+	size := resp.ContentLength
+	if size == 0 {
+		size = -1
+	}
+	return resp.Body, FetchMetadata{
+		Size:         size,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// FileFetcher fetches file uris from the local filesystem.
+type FileFetcher struct{}
+
+func (FileFetcher) Get(_ context.Context, uri string) (io.ReadCloser, FetchMetadata, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, FetchMetadata{}, err
+	}
+	fh, err := os.Open(filepath.FromSlash(u.Path))
+	if err != nil {
+		return nil, FetchMetadata{}, err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, FetchMetadata{}, err
+	}
+	return fh, FetchMetadata{Size: info.Size(), LastModified: info.ModTime().UTC().Format(http.TimeFormat)}, nil
+}
+
+// S3Credentials sign requests to S3 with AWS Signature Version 4. Requests
+// are sent unsigned, as for public buckets, without an access key.
+type S3Credentials struct {
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// S3CredentialsEnv has the built in s3 fetcher sign dependency downloads with
+// the AWS credentials of S3CredentialsFromEnv when set to "true". Otherwise
+// it fetches them anonymously, so that credentials staging happens to have
+// are not sent along with every dependency.
+const S3CredentialsEnv = "BP_S3_CREDENTIALS"
+
+// S3CredentialsFromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN and AWS_REGION or AWS_DEFAULT_REGION, which defaults to
+// us-east-1.
+func S3CredentialsFromEnv() S3Credentials {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return S3Credentials{
+		Region:       region,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Sign adds the X-Amz headers and an Authorization header to req, whose body
+// has the hex encoded payloadHash.
+func (c S3Credentials) Sign(req *http.Request, payloadHash string, now time.Time) {
+	if c.AccessKey == "" {
+		return
+	}
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, c.Region)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.RawQuery),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	for _, part := range []string{c.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery is rawQuery as Signature Version 4 signs it: every name and
+// value URI encoded, sorted by name and then value.
+func canonicalQuery(rawQuery string) string {
+	var params [][2]string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		name, value := param, ""
+		if i := strings.Index(param, "="); i >= 0 {
+			name, value = param[:i], param[i+1:]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		params = append(params, [2]string{AWSURIEncode(name), AWSURIEncode(value)})
+	}
+	sort.Slice(params, func(a, b int) bool {
+		if params[a][0] != params[b][0] {
+			return params[a][0] < params[b][0]
+		}
+		return params[a][1] < params[b][1]
+	})
+
+	encoded := make([]string, len(params))
+	for i, param := range params {
+		encoded[i] = param[0] + "=" + param[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// AWSURIEncode percent encodes everything but the unreserved characters of
+// RFC 3986, in upper case, as AWS requires.
+func AWSURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// emptySHA256 is the payload hash of a request without a body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Fetcher fetches s3://<bucket>/<key> uris. Endpoint, if set, is an S3
+// compatible store addressed path style, as in AWS_ENDPOINT_URL.
+type S3Fetcher struct {
+	Credentials S3Credentials
+	Endpoint    string
+	Client      *http.Client
+}
+
+func (f *S3Fetcher) Get(ctx context.Context, uri string) (io.ReadCloser, FetchMetadata, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, FetchMetadata{}, err
+	}
+	if u.Host == "" {
+		return nil, FetchMetadata{}, fmt.Errorf("invalid s3 uri %q: missing bucket", uri)
+	}
+
+	objectURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com%s", u.Host, f.Credentials.Region, u.EscapedPath())
+	if f.Endpoint != "" {
+		objectURL = fmt.Sprintf("%s/%s%s", f.Endpoint, u.Host, u.EscapedPath())
+	}
+	req, err := http.NewRequest("GET", objectURL, nil)
+	if err != nil {
+		return nil, FetchMetadata{}, err
+	}
+	f.Credentials.Sign(req, emptySHA256, time.Now().UTC())
+	return doFetch(f.Client, req.WithContext(ctx))
+}
+
+// OCIFetcher fetches oci://<registry>/<repository>:<tag> and
+// oci://<registry>/<repository>@sha256:<digest> uris, which must name an
+// image or artifact with a single layer: the layer is the artifact. Docker
+// config credentials are used as by Installer.InstallDependencyFromImage.
+type OCIFetcher struct {
+	Client *http.Client
+}
+
+func (f *OCIFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, FetchMetadata, error) {
+	image, err := parseImageRef(strings.TrimPrefix(uri, "oci://"))
+	if err != nil {
+		return nil, FetchMetadata{}, err
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	registry := &registryClient{client: client, image: image}
+	if registry.username, registry.password, err = registryCredentials(image.registry); err != nil {
+		return nil, FetchMetadata{}, err
+	}
+
+	layers, err := registry.layers(ctx)
+	if err != nil {
+		return nil, FetchMetadata{}, fmt.Errorf("could not pull %s: %v", uri, err)
+	}
+	if len(layers) != 1 {
+		return nil, FetchMetadata{}, fmt.Errorf("could not pull %s: it has %d layers, not one", uri, len(layers))
+	}
+	layer := layers[0]
+	expected := strings.TrimPrefix(layer.Digest, "sha256:")
+	if expected == layer.Digest {
+		return nil, FetchMetadata{}, fmt.Errorf("unsupported layer digest %s", layer.Digest)
+	}
+
+	resp, err := registry.get(ctx, "blobs/"+layer.Digest)
+	if err != nil {
+		return nil, FetchMetadata{}, fmt.Errorf("could not pull %s: %v", uri, err)
+	}
+	size := layer.Size
+	if size <= 0 {
+		size = -1
+	}
+	return &digestReader{ReadCloser: resp.Body, hash: sha256.New(), expected: expected}, FetchMetadata{Size: size, ContentType: layer.MediaType, ETag: strconv.Quote(layer.Digest)}, nil
+}
+
+// digestReader fails the read that reaches the end of a blob whose sha256
+// is not the expected one.
+type digestReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (d *digestReader) Read(b []byte) (int, error) {
+	n, err := d.ReadCloser.Read(b)
+	d.hash.Write(b[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(d.hash.Sum(nil)); actual != d.expected {
+			return n, fmt.Errorf("dependency sha256 mismatch: expected sha256 %s, actual sha256 %s", d.expected, actual)
+		}
+	}
+	return n, err
+}
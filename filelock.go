@@ -0,0 +1,60 @@
+package libbuildpack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileLockRetryInterval is how often a process waiting for a cache entry
+// locked by another process checks the lock again.
+var FileLockRetryInterval = 100 * time.Millisecond
+
+// FileLockTimeout is the longest a process waits for another to release a
+// cache entry before giving up.
+var FileLockTimeout = 15 * time.Minute
+
+// lockFile takes an exclusive lock on the file at path, shared with other
+// processes, waiting while another process holds it until ctx is done or
+// FileLockTimeout has passed. Calling the returned function releases the lock.
+func lockFile(ctx context.Context, path string, logger *Logger) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(FileLockTimeout)
+	defer timeout.Stop()
+
+	waiting := false
+	for {
+		unlock, locked, err := tryLockFile(path)
+		if err != nil || locked {
+			return unlock, err
+		}
+		if !waiting {
+			logger.Info("Waiting for another process to release %s", path)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, fmt.Errorf("timed out after %s waiting for another process to release %s", FileLockTimeout, path)
+		case <-time.After(FileLockRetryInterval):
+		}
+	}
+}
+
+// replaceFile copies source to destFile through a temporary file, so that
+// readers never see a partly written destFile.
+func replaceFile(source, destFile string) error {
+	tmpFile := destFile + ".partial"
+	if err := CopyFile(source, tmpFile); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, destFile)
+}
@@ -0,0 +1,34 @@
+// +build windows
+
+package libbuildpack
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLockFile locks the file with LockFileEx rather than creating it
+// exclusively, so that Windows releases the lock when its holder dies and a
+// crashed staging does not leave the cache locked for every later one.
+func tryLockFile(path string) (func(), bool, error) {
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ol := new(windows.Overlapped)
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err := windows.LockFileEx(windows.Handle(fh.Fd()), flags, 0, 1, 0, ol); err != nil {
+		fh.Close()
+		if err == windows.ERROR_LOCK_VIOLATION {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return func() {
+		windows.UnlockFileEx(windows.Handle(fh.Fd()), 0, 1, 0, ol)
+		fh.Close()
+	}, true, nil
+}
@@ -29,7 +29,7 @@ func RunBeforeCompile(stager *Stager) error {
 			return err
 		}
 	}
-	return nil
+	return runExtensions(stager, "before_compile")
 }
 
 func RunAfterCompile(stager *Stager) error {
@@ -38,7 +38,7 @@ func RunAfterCompile(stager *Stager) error {
 			return err
 		}
 	}
-	return nil
+	return runExtensions(stager, "after_compile")
 }
 
 type DefaultHook struct{}
@@ -0,0 +1,120 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// SetInstallCache turns on keeping extracted dependencies in the app cache
+// dir, keyed by their checksum, so that later stagings copy them into place
+// instead of downloading and extracting them again. It needs SetAppCacheDir,
+// and CleanupAppCache removes installs that were not used by this staging.
+func (i *Installer) SetInstallCache(enabled bool) {
+	i.installCache = enabled
+}
+
+// installCacheDir is where entry's extracted files are kept, or "" if they
+// are not cached.
+func (i *Installer) installCacheDir(entry *ManifestEntry) string {
+	dir := i.installCachePath(entry)
+	if dir != "" {
+		i.installsInAppCache[dir] = true
+	}
+	return dir
+}
+
+// installCachePath is installCacheDir without keeping the dir from being
+// cleaned up.
+func (i *Installer) installCachePath(entry *ManifestEntry) string {
+	if !i.installCache || i.appCacheDir == "" {
+		return ""
+	}
+	key := entry.SHA256
+	if key == "" {
+		key = entry.SHA512
+	}
+	if key == "" {
+		return ""
+	}
+
+	return filepath.Join(i.installCacheRoot(), key)
+}
+
+func (i *Installer) installCacheRoot() string {
+	return filepath.Join(filepath.Dir(i.appCacheDir), "installed")
+}
+
+// restoreInstall copies a cached install of entry into outputDir, reporting
+// whether there was one.
+func (i *Installer) restoreInstall(entry *ManifestEntry, outputDir string) (bool, error) {
+	cacheDir := i.installCacheDir(entry)
+	if cacheDir == "" {
+		return false, nil
+	}
+	if exists, err := FileExists(cacheDir); err != nil || !exists {
+		i.manifest.log.Debug("Install cache miss: %s", cacheDir)
+		return false, err
+	}
+
+	i.manifest.log.Info("Copy [%s]", cacheDir)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return true, err
+	}
+	return true, CopyDirectory(cacheDir, outputDir)
+}
+
+// storeInstall extracts archive into the install cache and returns the dir
+// holding it, or "" if entry is not cached. The archive is extracted into a
+// fresh dir, so that nothing else already in the install's outputDir is
+// cached with it, and renamed into place so that an interrupted staging
+// never leaves a partial install behind.
+func (i *Installer) storeInstall(entry *ManifestEntry, archive string) (string, error) {
+	cacheDir := i.installCacheDir(entry)
+	if cacheDir == "" {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(i.installCacheRoot(), 0755); err != nil {
+		return "", err
+	}
+	tmpDir, err := ioutil.TempDir(i.installCacheRoot(), ".partial")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := ExtractArchive(archive, entry.URI, tmpDir); err != nil {
+		return "", err
+	}
+	// TempDir creates it private to this user
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return "", err
+	}
+	os.RemoveAll(cacheDir)
+	if err := os.Rename(tmpDir, cacheDir); err != nil {
+		return "", err
+	}
+	return cacheDir, nil
+}
+
+func (i *Installer) cleanupInstallCache() error {
+	dirs, err := ioutil.ReadDir(i.installCacheRoot())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(i.installCacheRoot(), dir.Name())
+		if !i.installsInAppCache[path] {
+			i.manifest.log.Debug("Deleting cached install: %s", path)
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("Failed while cleaning up app cache; couldn't delete %s because: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
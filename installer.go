@@ -1,6 +1,7 @@
 package libbuildpack
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -18,10 +19,50 @@ type Installer struct {
 	appCacheDir     string
 	filesInAppCache map[string]interface{}
 	versionLine     *map[string]string
+	metrics         *Metrics
+	tracer          *Tracer
+	downloadOptions DownloadOptions
+
+	installCache       bool
+	installsInAppCache map[string]bool
+	signatureOptions   *SignatureOptions
+	downloadCache      *downloadCache
+	progress           Progress
+	prefetch           *prefetchCache
 }
 
 func NewInstaller(manifest *Manifest) *Installer {
-	return &Installer{manifest, "", make(map[string]interface{}), &map[string]string{}}
+	installer := &Installer{
+		manifest:           manifest,
+		filesInAppCache:    make(map[string]interface{}),
+		versionLine:        &map[string]string{},
+		metrics:            NewMetrics(),
+		tracer:             NewTracer(),
+		downloadOptions:    DefaultDownloadOptions,
+		installsInAppCache: make(map[string]bool),
+	}
+	if manifest != nil && manifest.log != nil {
+		installer.progress = manifest.log.ProgressReporter(DefaultProgressInterval)
+	}
+	return installer
+}
+
+func (i *Installer) SetMetrics(metrics *Metrics) {
+	i.metrics = metrics
+}
+
+func (i *Installer) SetTracer(tracer *Tracer) {
+	i.tracer = tracer
+}
+
+func (i *Installer) SetDownloadOptions(opts DownloadOptions) {
+	i.downloadOptions = opts
+}
+
+// SetProgress has the progress of dependency installs reported to progress
+// instead of the manifest's logger, or to nothing if progress is nil.
+func (i *Installer) SetProgress(progress Progress) {
+	i.progress = progress
 }
 
 func (i *Installer) SetAppCacheDir(appCacheDir string) (err error) {
@@ -29,9 +70,34 @@ func (i *Installer) SetAppCacheDir(appCacheDir string) (err error) {
 	return
 }
 
+// Deprecated: use InstallDependencyCtx.
 func (i *Installer) InstallDependency(dep Dependency, outputDir string) error {
+	return i.InstallDependencyCtx(context.Background(), dep, outputDir)
+}
+
+// InstallDependencyCtx is InstallDependency, giving up on the download when
+// ctx is done.
+func (i *Installer) InstallDependencyCtx(ctx context.Context, dep Dependency, outputDir string) error {
+	return i.InstallDependencyWithOptionsCtx(ctx, dep, outputDir, ExtractOptions{})
+}
+
+// InstallDependencyWithOptions is InstallDependency, extracting the
+// dependency as opts asks.
+func (i *Installer) InstallDependencyWithOptions(dep Dependency, outputDir string, opts ExtractOptions) error {
+	return i.InstallDependencyWithOptionsCtx(context.Background(), dep, outputDir, opts)
+}
+
+// InstallDependencyWithOptionsCtx is InstallDependencyWithOptions, giving up
+// on the download when ctx is done.
+func (i *Installer) InstallDependencyWithOptionsCtx(ctx context.Context, dep Dependency, outputDir string, opts ExtractOptions) (err error) {
+	ctx, span := i.tracer.Start(ctx, "dependency.install", map[string]string{"dependency": dep.Name, "version": dep.Version})
+	defer func() { span.End(err) }()
+
 	i.manifest.log.BeginStep("Installing %s %s", dep.Name, dep.Version)
 
+	stopHeartbeat := i.manifest.log.Heartbeat(fmt.Sprintf("%s %s", dep.Name, dep.Version), DefaultHeartbeatInterval)
+	defer stopHeartbeat()
+
 	tmpDir, err := ioutil.TempDir("", "downloads")
 	if err != nil {
 		return err
@@ -45,22 +111,33 @@ func (i *Installer) InstallDependency(dep Dependency, outputDir string) error {
 		return err
 	}
 
-	err = i.FetchDependency(dep, tmpFile)
+	isScript := strings.HasSuffix(entry.URI, ".sh")
+	// installs extracted with options are not cached, as they differ
+	useInstallCache := !isScript && opts.isZero()
+	if useInstallCache {
+		if restored, err := i.restoreInstall(entry, outputDir); err != nil {
+			return err
+		} else if restored {
+			return i.warn(dep)
+		}
+	}
+
+	err = i.FetchDependencyCtx(ctx, dep, tmpFile)
 	if err != nil {
 		return err
 	}
 
-	err = i.warnNewerPatch(dep)
+	err = i.verifySignature(ctx, entry, tmpFile)
 	if err != nil {
 		return err
 	}
 
-	err = i.warnEndOfLife(dep)
+	err = i.warn(dep)
 	if err != nil {
 		return err
 	}
 
-	if strings.HasSuffix(entry.URI, ".sh") {
+	if isScript {
 		return os.Rename(tmpFile, outputDir)
 	}
 
@@ -69,15 +146,26 @@ func (i *Installer) InstallDependency(dep Dependency, outputDir string) error {
 		return err
 	}
 
-	if strings.HasSuffix(entry.URI, ".zip") {
-		return ExtractZip(tmpFile, outputDir)
+	if i.progress != nil {
+		i.progress.Extracting(dep)
 	}
-
-	if strings.HasSuffix(entry.URI, ".tar.xz") {
-		return ExtractTarXz(tmpFile, outputDir)
+	if useInstallCache {
+		if cacheDir, err := i.storeInstall(entry, tmpFile); err != nil {
+			i.manifest.log.Warning("Could not cache %s %s for later stagings: %v", dep.Name, dep.Version, err)
+		} else if cacheDir != "" {
+			return CopyDirectory(cacheDir, outputDir)
+		}
 	}
+	return ExtractArchiveWithOptions(tmpFile, entry.URI, outputDir, opts)
+}
 
-	return ExtractTarGz(tmpFile, outputDir)
+// warn prints the warnings for installing dep: a newer patch or the end of
+// its life.
+func (i *Installer) warn(dep Dependency) error {
+	if err := i.warnNewerPatch(dep); err != nil {
+		return err
+	}
+	return i.warnEndOfLife(dep)
 }
 
 func (i *Installer) warnNewerPatch(dep Dependency) error {
@@ -150,21 +238,62 @@ func (i *Installer) warnEndOfLife(dep Dependency) error {
 	return nil
 }
 
+// Deprecated: use FetchDependencyCtx.
 func (i *Installer) FetchDependency(dep Dependency, outputFile string) error {
+	return i.FetchDependencyCtx(context.Background(), dep, outputFile)
+}
+
+// FetchDependencyCtx is FetchDependency, giving up on the download when ctx
+// is done.
+func (i *Installer) FetchDependencyCtx(ctx context.Context, dep Dependency, outputFile string) (err error) {
+	ctx, span := i.tracer.Start(ctx, "dependency.fetch", map[string]string{"dependency": dep.Name, "version": dep.Version})
+	defer func() { span.End(err) }()
+
 	entry, err := i.manifest.GetEntry(dep)
 	if err != nil {
 		return err
 	}
 
+	start := time.Now()
+	source := "download"
 	if entry.File != "" { // this file is cached by the buildpack
-		return fetchCachedBuildpackDependency(entry, outputFile, i.manifest.manifestRootDir, i.manifest.log)
+		source = "buildpack_cache"
+		err = fetchCachedBuildpackDependency(entry, outputFile, i.manifest.manifestRootDir, i.manifest.log)
+	} else if i.appCacheDir != "" { // this buildpack caches dependencies in the app cache
+		var cacheHit bool
+		if cacheHit, err = i.fetchAppCachedBuildpackDependency(ctx, entry, outputFile); cacheHit {
+			source = "app_cache"
+		}
+	} else {
+		var cacheHit bool
+		if cacheHit, err = i.download(ctx, entry, outputFile); cacheHit {
+			source = "download_cache"
+		}
+	}
+	span.SetAttribute("source", source)
+	if err != nil {
+		return err
 	}
 
-	if i.appCacheDir != "" { // this buildpack caches dependencies in the app cache
-		return i.fetchAppCachedBuildpackDependency(entry, outputFile)
+	i.reportFetch(dep, source, time.Since(start), outputFile)
+	return nil
+}
+
+func (i *Installer) reportFetch(dep Dependency, source string, duration time.Duration, outputFile string) {
+	if !i.metrics.Enabled() {
+		return
 	}
 
-	return downloadDependency(entry, outputFile, i.manifest.log)
+	tags := map[string]string{"dependency": dep.Name, "version": dep.Version, "source": source}
+	i.metrics.Timing("dependency.fetch", duration, tags)
+	if info, err := os.Stat(outputFile); err == nil {
+		i.metrics.Gauge("dependency.size_bytes", float64(info.Size()), tags)
+	}
+	if source == "download" {
+		i.metrics.Increment("dependency.cache_miss", tags)
+	} else {
+		i.metrics.Increment("dependency.cache_hit", tags)
+	}
 }
 
 func (i *Installer) CleanupAppCache() error {
@@ -195,6 +324,12 @@ func (i *Installer) CleanupAppCache() error {
 		}
 	}
 
+	if err := i.RemovePrefetched(); err != nil {
+		return err
+	}
+	if i.installCache {
+		return i.cleanupInstallCache()
+	}
 	return nil
 }
 
@@ -208,37 +343,47 @@ func (i *Installer) InstallOnlyVersion(depName string, installDir string) error
 	}
 
 	dep := Dependency{Name: depName, Version: depVersions[0]}
-	return i.InstallDependency(dep, installDir)
+	return i.InstallDependencyCtx(context.Background(), dep, installDir)
 }
 
-func (i *Installer) fetchAppCachedBuildpackDependency(entry *ManifestEntry, outputFile string) error {
-	shaURI := sha256.Sum256([]byte(entry.URI))
-	cacheFile := filepath.Join(i.appCacheDir, hex.EncodeToString(shaURI[:]), filepath.Base(entry.URI))
+func (i *Installer) fetchAppCachedBuildpackDependency(ctx context.Context, entry *ManifestEntry, outputFile string) (bool, error) {
+	cacheFile := i.appCacheFile(entry)
 
 	i.filesInAppCache[cacheFile] = true
+	i.filesInAppCache[cacheFile+".lock"] = true
 	i.filesInAppCache[filepath.Dir(cacheFile)] = true
 
+	// other buildpacks staging with the same app cache may fetch this too
+	unlock, err := lockFile(ctx, cacheFile+".lock", i.manifest.log)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
 	foundCacheFile, err := FileExists(cacheFile)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if foundCacheFile {
 		i.manifest.log.Info("Copy [%s]", cacheFile)
 		if err := CopyFile(cacheFile, outputFile); err != nil {
-			return err
+			return true, err
 		}
-		return deleteBadFile(entry, outputFile)
+		return true, deleteBadFile(entry, outputFile)
 	}
+	i.manifest.log.Debug("App cache miss: %s", cacheFile)
 
-	if err := downloadDependency(entry, outputFile, i.manifest.log); err != nil {
-		return err
-	}
-	if err := CopyFile(outputFile, cacheFile); err != nil {
-		return err
+	if _, err := i.download(ctx, entry, outputFile); err != nil {
+		return false, err
 	}
+	return false, replaceFile(outputFile, cacheFile)
+}
 
-	return nil
+// appCacheFile is where the app cache keeps entry's download.
+func (i *Installer) appCacheFile(entry *ManifestEntry) string {
+	shaURI := sha256.Sum256([]byte(entry.URI))
+	return filepath.Join(i.appCacheDir, hex.EncodeToString(shaURI[:]), filepath.Base(entry.URI))
 }
 
 func (i *Installer) SetVersionLine(depName string, line string) {
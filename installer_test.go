@@ -2,11 +2,19 @@ package libbuildpack_test
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"testing/iotest"
 	"time"
 
 	"github.com/cloudfoundry/libbuildpack"
@@ -257,6 +265,413 @@ var _ = Describe("Installer", func() {
 
 		})
 
+		Context("uncached with mirrors", func() {
+			const mirrorURI = "https://mirror.example.com/dependencies/thing-1-linux-x64.tgz"
+
+			BeforeEach(func() {
+				allEntries[0].File = ""
+				allEntries[0].Mirrors = []string{mirrorURI}
+				manifestForTest := libbuildpack.Manifest{
+					LanguageString:  "sample",
+					ManifestEntries: allEntries,
+				}
+				Expect(libbuildpack.NewYAML().Write(filepath.Join(manifestDir, "manifest.yml"), manifestForTest)).To(Succeed())
+
+				httpmock.RegisterResponder("GET", mirrorURI,
+					httpmock.NewStringResponder(200, string(entryToFetch.content)))
+			})
+
+			It("downloads from the mirror when the uri fails", func() {
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI,
+					httpmock.NewStringResponder(404, ""))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+				Expect(buffer.String()).To(ContainSubstring("trying mirror"))
+				Expect(buffer.String()).To(ContainSubstring("Download [https://mirror.example.com/dependencies/thing-1-linux-x64.tgz]"))
+			})
+
+			It("downloads from the mirror when the uri does not match the checksum", func() {
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI,
+					httpmock.NewStringResponder(200, "other data"))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+			})
+
+			It("stops when the context is done", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				err = installer.FetchDependencyCtx(ctx, entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(MatchError(context.Canceled))
+				Expect(outputFile).ToNot(BeAnExistingFile())
+			})
+
+			It("does not try the mirror when the uri succeeds", func() {
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI,
+					httpmock.NewStringResponder(200, string(entryToFetch.content)))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(buffer.String()).ToNot(ContainSubstring("mirror.example.com"))
+			})
+		})
+
+		Context("uncached from a registered fetcher", func() {
+			var fetched []string
+
+			BeforeEach(func() {
+				fetched = nil
+				libbuildpack.RegisterFetcher("artifacts", fetcherFunc(func(uri string) (io.ReadCloser, libbuildpack.FetchMetadata, error) {
+					fetched = append(fetched, uri)
+					return ioutil.NopCloser(bytes.NewReader(entryToFetch.content)), libbuildpack.FetchMetadata{Size: -1}, nil
+				}))
+
+				allEntries[0].File = ""
+				allEntries[0].URI = "artifacts://store/thing-1-linux-x64.tgz"
+				manifestForTest := libbuildpack.Manifest{
+					LanguageString:  "sample",
+					ManifestEntries: allEntries,
+				}
+				Expect(libbuildpack.NewYAML().Write(filepath.Join(manifestDir, "manifest.yml"), manifestForTest)).To(Succeed())
+			})
+
+			It("downloads the dependency with the fetcher", func() {
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(fetched).To(Equal([]string{"artifacts://store/thing-1-linux-x64.tgz"}))
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+			})
+		})
+
+		Context("uncached with a download cache", func() {
+			var (
+				downloadCacheDir string
+				otherEntry       libbuildpack.ManifestEntry
+			)
+
+			BeforeEach(func() {
+				downloadCacheDir = filepath.Join(tmpdir, "download-cache")
+				otherEntry = libbuildpack.ManifestEntry{
+					Dependency: libbuildpack.Dependency{Name: "thing", Version: "2"},
+					URI:        "https://example.com/dependencies/thing-2-linux-x64.tgz",
+					SHA256:     "ab3b6898659bcb5023fbe54d46b1a26f9a3f8df891167d3bee2e797e518c2175",
+					CFStacks:   []string{"cflinuxfs2"},
+				}
+				manifestForTest := libbuildpack.Manifest{
+					LanguageString:  "sample",
+					ManifestEntries: []libbuildpack.ManifestEntry{entryToFetch.entry, otherEntry},
+				}
+				Expect(libbuildpack.NewYAML().Write(filepath.Join(manifestDir, "manifest.yml"), manifestForTest)).To(Succeed())
+
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI,
+					httpmock.NewBytesResponder(200, entryToFetch.content))
+				httpmock.RegisterResponder("GET", otherEntry.URI,
+					httpmock.NewStringResponder(200, "other binary data"))
+			})
+
+			cachedFiles := func() []string {
+				var files []string
+				paths, err := filepath.Glob(filepath.Join(downloadCacheDir, "*"))
+				Expect(err).To(BeNil())
+				for _, path := range paths {
+					if filepath.Ext(path) == "" {
+						files = append(files, path)
+					}
+				}
+				return files
+			}
+
+			newInstaller := func(maxBytes int64) *libbuildpack.Installer {
+				manifest, err := libbuildpack.NewManifest(manifestDir, logger, currentTime)
+				Expect(err).To(BeNil())
+				installer := libbuildpack.NewInstaller(manifest)
+				Expect(installer.SetDownloadCache(downloadCacheDir, maxBytes)).To(Succeed())
+				return installer
+			}
+
+			It("reuses downloads across installers", func() {
+				Expect(newInstaller(0).FetchDependency(entryToFetch.entry.Dependency, outputFile)).To(Succeed())
+				Expect(os.Remove(outputFile)).To(Succeed())
+
+				Expect(newInstaller(0).FetchDependency(entryToFetch.entry.Dependency, outputFile)).To(Succeed())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+				Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+			})
+
+			It("downloads again when the cached file is corrupt", func() {
+				Expect(newInstaller(0).FetchDependency(entryToFetch.entry.Dependency, outputFile)).To(Succeed())
+				cached := cachedFiles()
+				Expect(cached).To(HaveLen(1))
+				Expect(ioutil.WriteFile(cached[0], []byte("corrupt"), 0644)).To(Succeed())
+
+				Expect(newInstaller(0).FetchDependency(entryToFetch.entry.Dependency, outputFile)).To(Succeed())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+				Expect(httpmock.GetTotalCallCount()).To(Equal(2))
+			})
+
+			It("downloads once when installers share the cache concurrently", func() {
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI, func(req *http.Request) (*http.Response, error) {
+					time.Sleep(300 * time.Millisecond)
+					return httpmock.NewBytesResponse(200, entryToFetch.content), nil
+				})
+
+				installers := []*libbuildpack.Installer{newInstaller(0), newInstaller(0)}
+				errs := make(chan error, len(installers))
+				for n, installer := range installers {
+					go func(installer *libbuildpack.Installer, outputFile string) {
+						defer GinkgoRecover()
+						errs <- installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+					}(installer, fmt.Sprintf("%s.%d", outputFile, n))
+				}
+				for range installers {
+					Expect(<-errs).To(Succeed())
+				}
+
+				Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+				Expect(buffer.String()).To(ContainSubstring("Waiting for another process to release"))
+				for n := range installers {
+					Expect(ioutil.ReadFile(fmt.Sprintf("%s.%d", outputFile, n))).To(Equal(entryToFetch.content))
+				}
+			})
+
+			It("gives up waiting for another installer after FileLockTimeout", func() {
+				defer func(timeout time.Duration) { libbuildpack.FileLockTimeout = timeout }(libbuildpack.FileLockTimeout)
+				libbuildpack.FileLockTimeout = 100 * time.Millisecond
+
+				release := make(chan struct{})
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI, func(req *http.Request) (*http.Response, error) {
+					<-release
+					return httpmock.NewBytesResponse(200, entryToFetch.content), nil
+				})
+
+				done := make(chan error, 1)
+				go func() {
+					defer GinkgoRecover()
+					done <- newInstaller(0).FetchDependency(entryToFetch.entry.Dependency, outputFile+".0")
+				}()
+				Eventually(httpmock.GetTotalCallCount).Should(Equal(1))
+
+				err := newInstaller(0).FetchDependency(entryToFetch.entry.Dependency, outputFile+".1")
+				Expect(err).To(MatchError(ContainSubstring("timed out after 100ms waiting for another process to release")))
+
+				close(release)
+				Expect(<-done).To(Succeed())
+			})
+
+			It("evicts the least recently used downloads beyond the size limit", func() {
+				installer := newInstaller(30)
+				Expect(installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)).To(Succeed())
+				Expect(installer.FetchDependency(otherEntry.Dependency, outputFile)).To(Succeed())
+
+				cached := cachedFiles()
+				Expect(cached).To(HaveLen(1))
+				Expect(ioutil.ReadFile(cached[0])).To(Equal([]byte("other binary data")))
+
+				Expect(installer.FetchDependency(otherEntry.Dependency, outputFile)).To(Succeed())
+				Expect(httpmock.GetTotalCallCount()).To(Equal(2))
+				Expect(installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)).To(Succeed())
+				Expect(httpmock.GetTotalCallCount()).To(Equal(3))
+			})
+		})
+
+		Context("uncached with BUILDPACK_DEPENDENCY_MIRROR", func() {
+			BeforeEach(func() {
+				allEntries[0].File = ""
+				manifestForTest := libbuildpack.Manifest{
+					LanguageString:  "sample",
+					ManifestEntries: allEntries,
+				}
+				Expect(libbuildpack.NewYAML().Write(filepath.Join(manifestDir, "manifest.yml"), manifestForTest)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				os.Unsetenv("BUILDPACK_DEPENDENCY_MIRROR")
+			})
+
+			It("downloads from the rewritten uri", func() {
+				os.Setenv("BUILDPACK_DEPENDENCY_MIRROR", "https://example.com=https://artifactory.example.com/bp/{path}")
+				httpmock.RegisterResponder("GET", "https://artifactory.example.com/bp/dependencies/thing-1-linux-x64.tgz",
+					httpmock.NewStringResponder(200, string(entryToFetch.content)))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+				Expect(buffer.String()).To(ContainSubstring("Download [https://artifactory.example.com/bp/dependencies/thing-1-linux-x64.tgz]"))
+			})
+
+			It("fills in the filename, name and version", func() {
+				os.Setenv("BUILDPACK_DEPENDENCY_MIRROR", "https://other.example.com=https://unused.example.com/{path}, https://mirror.example.com/{name}/{version}/{filename}")
+				httpmock.RegisterResponder("GET", "https://mirror.example.com/thing/1/thing-1-linux-x64.tgz",
+					httpmock.NewStringResponder(200, string(entryToFetch.content)))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+			})
+
+			It("matches prefixes on the whole host and path segments", func() {
+				os.Setenv("BUILDPACK_DEPENDENCY_MIRROR", "https://example.co=https://unused.example.com/{path}, https://example.com/dep=https://unused.example.com/{path}, https://example.com/dependencies/=https://mirror.example.com/{filename}")
+				httpmock.RegisterResponder("GET", "https://mirror.example.com/thing-1-linux-x64.tgz",
+					httpmock.NewStringResponder(200, string(entryToFetch.content)))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+			})
+
+			It("leaves uris no rule matches alone", func() {
+				os.Setenv("BUILDPACK_DEPENDENCY_MIRROR", "https://other.example.com=https://unused.example.com/{path}")
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI,
+					httpmock.NewStringResponder(200, string(entryToFetch.content)))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Context("uncached with retries", func() {
+			BeforeEach(func() {
+				allEntries[0].File = ""
+				manifestForTest := libbuildpack.Manifest{
+					LanguageString:  "sample",
+					ManifestEntries: allEntries,
+				}
+				Expect(libbuildpack.NewYAML().Write(filepath.Join(manifestDir, "manifest.yml"), manifestForTest)).To(Succeed())
+			})
+
+			JustBeforeEach(func() {
+				installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 3, InitialBackoff: time.Millisecond})
+			})
+
+			It("retries transient failures", func() {
+				responses := []int{503, 429, 200}
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI, func(req *http.Request) (*http.Response, error) {
+					status := responses[0]
+					responses = responses[1:]
+					return httpmock.NewStringResponse(status, string(entryToFetch.content)), nil
+				})
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+				Expect(buffer.String()).To(ContainSubstring("Download failed: could not download: 503, retrying"))
+			})
+
+			It("gives up after the configured attempts", func() {
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI, httpmock.NewStringResponder(503, ""))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(MatchError("could not download: 503"))
+				Expect(httpmock.GetTotalCallCount()).To(Equal(3))
+			})
+
+			It("does not retry client errors", func() {
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI, httpmock.NewStringResponder(404, ""))
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(MatchError("could not download: 404"))
+				Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+			})
+
+			It("resumes a partial download with a range request", func() {
+				content := entryToFetch.content
+				var ranges []string
+				httpmock.RegisterResponder("GET", entryToFetch.entry.URI, func(req *http.Request) (*http.Response, error) {
+					ranges = append(ranges, req.Header.Get("Range"))
+					if len(ranges) == 1 {
+						resp := httpmock.NewStringResponse(200, "")
+						resp.Body = ioutil.NopCloser(iotest.TimeoutReader(bytes.NewReader(content[:8])))
+						return resp, nil
+					}
+					return httpmock.NewBytesResponse(206, content[8:]), nil
+				})
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ranges).To(Equal([]string{"", "bytes=8-"}))
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(content))
+			})
+		})
+
+		Context("uncached from a server with a private CA", func() {
+			var (
+				server  *httptest.Server
+				caCerts string
+			)
+
+			BeforeEach(func() {
+				httpmock.Deactivate()
+				server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write(entryToFetch.content)
+				}))
+
+				caCerts = filepath.Join(tmpdir, "ca.pem")
+				certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+				Expect(ioutil.WriteFile(caCerts, certPEM, 0644)).To(Succeed())
+
+				entryToFetch.entry.File = ""
+				entryToFetch.entry.URI = server.URL + "/thing-1-linux-x64.tgz"
+				manifestForTest := libbuildpack.Manifest{
+					LanguageString:  "sample",
+					ManifestEntries: []libbuildpack.ManifestEntry{entryToFetch.entry},
+				}
+				Expect(libbuildpack.NewYAML().Write(filepath.Join(manifestDir, "manifest.yml"), manifestForTest)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				server.Close()
+				httpmock.Activate()
+				os.Unsetenv("BUILDPACK_CA_CERTS")
+			})
+
+			It("does not trust the server by default", func() {
+				installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 1})
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(MatchError(ContainSubstring("certificate")))
+			})
+
+			It("trusts the certificates in the configured CA bundle", func() {
+				installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 1, CACertsFile: caCerts})
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+			})
+
+			It("trusts the CA bundle when the default transport has been replaced", func() {
+				httpmock.Activate()
+				installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 1, CACertsFile: caCerts})
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+			})
+
+			It("trusts the certificates in BUILDPACK_CA_CERTS", func() {
+				certPEM, err := ioutil.ReadFile(caCerts)
+				Expect(err).To(BeNil())
+				os.Setenv("BUILDPACK_CA_CERTS", string(certPEM))
+				installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 1})
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(outputFile)).To(Equal(entryToFetch.content))
+			})
+
+			It("fails when the CA bundle has no certificates", func() {
+				Expect(ioutil.WriteFile(caCerts, []byte("not a certificate"), 0644)).To(Succeed())
+				installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 1, CACertsFile: caCerts})
+
+				err = installer.FetchDependency(entryToFetch.entry.Dependency, outputFile)
+				Expect(err).To(MatchError("could not read CA certificates: no PEM certificates found"))
+			})
+		})
+
 		Context("app cached", func() {
 			var (
 				manifestForTest libbuildpack.Manifest
@@ -502,6 +917,15 @@ var _ = Describe("Installer", func() {
 					Expect(ioutil.ReadFile(filepath.Join(outputDir, "thing", "bin", "file2.exe"))).To(Equal([]byte("progam2\n")))
 				})
 
+				It("extracts with options", func() {
+					err = installer.InstallDependencyWithOptions(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir, libbuildpack.ExtractOptions{StripComponents: 1, Include: []string{"bin"}})
+					Expect(err).To(BeNil())
+
+					Expect(ioutil.ReadFile(filepath.Join(outputDir, "bin", "file2.exe"))).To(Equal([]byte("progam2\n")))
+					Expect(filepath.Join(outputDir, "file1.txt")).ToNot(BeAnExistingFile())
+					Expect(filepath.Join(outputDir, "root.txt")).ToNot(BeAnExistingFile())
+				})
+
 				It("makes intermediate directories", func() {
 					outputDir = filepath.Join(outputDir, "notexist")
 					err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
@@ -527,6 +951,17 @@ var _ = Describe("Installer", func() {
 						err = installer.InstallDependency(libbuildpack.Dependency{Name: "thing", Version: "6.2.2"}, outputDir)
 						Expect(err).To(BeNil())
 						Expect(buffer.String()).To(ContainSubstring(patchWarning))
+						Expect(strings.Index(buffer.String(), "Download [")).To(BeNumerically("<", strings.Index(buffer.String(), patchWarning)))
+					})
+
+					It("does not warn when the download fails", func() {
+						installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 1})
+						httpmock.RegisterResponder("GET", "https://example.com/dependencies/thing-6.2.2-linux-x64.tgz",
+							httpmock.NewStringResponder(404, ""))
+
+						err = installer.InstallDependency(libbuildpack.Dependency{Name: "thing", Version: "6.2.2"}, outputDir)
+						Expect(err).NotTo(BeNil())
+						Expect(buffer.String()).NotTo(ContainSubstring("newer version"))
 					})
 				})
 
@@ -856,6 +1291,81 @@ var _ = Describe("Installer", func() {
 				})
 			})
 		})
+
+		Context("with the install cache", func() {
+			var cacheDir string
+
+			BeforeEach(func() {
+				manifestDir = "fixtures/manifest/fetch"
+				cacheDir, err = ioutil.TempDir("", "cache")
+				Expect(err).To(BeNil())
+
+				tgzContents, err := ioutil.ReadFile("fixtures/thing.tgz")
+				Expect(err).To(BeNil())
+				httpmock.RegisterResponder("GET", "https://example.com/dependencies/real_tar_file-3-linux-x64.tgz",
+					httpmock.NewStringResponder(200, string(tgzContents)))
+			})
+
+			JustBeforeEach(func() {
+				Expect(installer.SetAppCacheDir(cacheDir)).To(Succeed())
+				installer.SetInstallCache(true)
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(cacheDir)).To(Succeed())
+			})
+
+			It("restores the extracted dependency in a later staging without downloading it", func() {
+				err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
+				Expect(err).To(BeNil())
+				Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+
+				manifest, err := libbuildpack.NewManifest(manifestDir, logger, currentTime)
+				Expect(err).To(BeNil())
+				installer = libbuildpack.NewInstaller(manifest)
+				Expect(installer.SetAppCacheDir(cacheDir)).To(Succeed())
+				installer.SetInstallCache(true)
+
+				secondDir := filepath.Join(outputDir, "second")
+				err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, secondDir)
+				Expect(err).To(BeNil())
+				Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+
+				Expect(ioutil.ReadFile(filepath.Join(secondDir, "root.txt"))).To(Equal([]byte("root\n")))
+				Expect(ioutil.ReadFile(filepath.Join(secondDir, "thing", "bin", "file2.exe"))).To(Equal([]byte("progam2\n")))
+			})
+
+			It("only caches the files of the dependency", func() {
+				Expect(os.MkdirAll(outputDir, 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(outputDir, "unrelated.txt"), []byte("other"), 0644)).To(Succeed())
+
+				err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(filepath.Join(outputDir, "root.txt"))).To(Equal([]byte("root\n")))
+				Expect(ioutil.ReadFile(filepath.Join(outputDir, "unrelated.txt"))).To(Equal([]byte("other")))
+
+				secondDir := filepath.Join(outputDir, "second")
+				err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, secondDir)
+				Expect(err).To(BeNil())
+				Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+				Expect(filepath.Join(secondDir, "root.txt")).To(BeAnExistingFile())
+				Expect(filepath.Join(secondDir, "unrelated.txt")).NotTo(BeAnExistingFile())
+			})
+
+			It("removes cached installs that were not used during cleanup", func() {
+				stale := filepath.Join(cacheDir, "installed", "stale")
+				Expect(os.MkdirAll(stale, 0755)).To(Succeed())
+
+				err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
+				Expect(err).To(BeNil())
+				Expect(installer.CleanupAppCache()).To(Succeed())
+
+				Expect(stale).ToNot(BeADirectory())
+				entries, err := ioutil.ReadDir(filepath.Join(cacheDir, "installed"))
+				Expect(err).To(BeNil())
+				Expect(entries).To(HaveLen(1))
+			})
+		})
 	})
 
 	Describe("InstallOnlyVersion", func() {
@@ -0,0 +1,74 @@
+package libbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ConfigInterpolationEnv opts override.yml into environment variable
+// interpolation when set to "true".
+const ConfigInterpolationEnv = "BP_CONFIG_INTERPOLATION"
+
+var interpolationRegexp = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// InterpolateEnv replaces ${VAR} in data with the value of the environment
+// variable VAR, or with default for ${VAR:-default} when VAR is unset or
+// empty. $$ is an escaped $, so $${VAR} is left as ${VAR}. Every referenced
+// variable that is unset and has no default is reported in the error.
+func InterpolateEnv(data []byte) ([]byte, error) {
+	return interpolateEnv(data, func(s string) string { return s })
+}
+
+func interpolateEnv(data []byte, escape func(string) string) ([]byte, error) {
+	var missing []string
+	result := interpolationRegexp.ReplaceAllFunc(data, func(match []byte) []byte {
+		value, ok := lookupReference(match)
+		if !ok {
+			missing = append(missing, referenceName(match))
+			return match
+		}
+		if string(match) == "$$" {
+			return []byte(value)
+		}
+		return []byte(escape(value))
+	})
+
+	if len(missing) > 0 {
+		return nil, missingVariablesError(missing)
+	}
+	return result, nil
+}
+
+// lookupReference returns the value a match of interpolationRegexp expands
+// to, or false if it names a variable that is unset and has no default.
+func lookupReference(match []byte) (string, bool) {
+	if string(match) == "$$" {
+		return "$", true
+	}
+	groups := interpolationRegexp.FindSubmatch(match)
+	value, found := os.LookupEnv(string(groups[1]))
+	if value == "" && len(groups[2]) > 0 {
+		value, found = string(groups[3]), true
+	}
+	return value, found
+}
+
+func referenceName(match []byte) string {
+	return string(interpolationRegexp.FindSubmatch(match)[1])
+}
+
+func missingVariablesError(missing []string) error {
+	return fmt.Errorf("required environment variables are not set: %s", strings.Join(missing, ", "))
+}
+
+func escapeJSONString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+func configInterpolationEnabled() bool {
+	return os.Getenv(ConfigInterpolationEnv) == "true"
+}
@@ -0,0 +1,234 @@
+package libbuildpack
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	referenceAtStart   = regexp.MustCompile(`^(?:` + interpolationRegexp.String() + `)`)
+	blockScalarHeader  = regexp.MustCompile(`(^[ \t]*|[:?-][ \t]+)[|>][-+0-9]*[ \t]*(#.*)?$`)
+	yamlFlowIndicators = ",[]{}"
+)
+
+// interpolateYAML is InterpolateEnv for YAML documents. Comments are left as
+// they are, and every value is written so that it reads back as the same
+// string: escaped inside quoted strings, quoted when it is a whole unquoted
+// value that YAML would otherwise read as something else, and indented to
+// match inside block scalars. A value that can't be written where it is
+// referenced is an error rather than a change to the document's structure.
+func interpolateYAML(data []byte) ([]byte, error) {
+	in := &yamlInterpolator{block: -1}
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if err := in.line(line); err != nil {
+			return nil, err
+		}
+	}
+	if len(in.missing) > 0 {
+		return nil, missingVariablesError(in.missing)
+	}
+	return in.out.Bytes(), nil
+}
+
+type yamlInterpolator struct {
+	out     bytes.Buffer
+	missing []string
+
+	inDouble  bool
+	inSingle  bool
+	flowDepth int
+	// block is the indentation of the line that started the block scalar
+	// (| or >) being read, or -1 outside of one
+	block int
+}
+
+func (in *yamlInterpolator) line(line []byte) error {
+	content := bytes.TrimRight(line, "\r\n")
+	eol := line[len(content):]
+	indent := len(content) - len(bytes.TrimLeft(content, " "))
+
+	if in.block >= 0 {
+		if len(bytes.TrimSpace(content)) == 0 || indent > in.block {
+			in.blockLine(content, indent)
+			in.out.Write(eol)
+			return nil
+		}
+		in.block = -1
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		if c == '$' {
+			if loc := referenceAtStart.FindIndex(content[i:]); loc != nil {
+				text, err := in.expand(content, i, content[i:i+loc[1]])
+				if err != nil {
+					return err
+				}
+				in.out.WriteString(text)
+				i += loc[1]
+				continue
+			}
+		}
+
+		switch {
+		case in.inDouble:
+			if c == '\\' && i+1 < len(content) {
+				in.out.Write(content[i : i+2])
+				i += 2
+				continue
+			}
+			in.inDouble = c != '"'
+		case in.inSingle:
+			if c == '\'' && i+1 < len(content) && content[i+1] == '\'' {
+				in.out.Write(content[i : i+2])
+				i += 2
+				continue
+			}
+			in.inSingle = c != '\''
+		case c == '#' && (i == 0 || isYAMLSpace(content[i-1])):
+			// the rest of the line is a comment
+			in.out.Write(content[i:])
+			i = len(content)
+			continue
+		case (c == '"' || c == '\'') && in.scalarStart(content, i):
+			in.inDouble, in.inSingle = c == '"', c == '\''
+		case (c == '[' || c == '{') && in.scalarStart(content, i):
+			in.flowDepth++
+		case (c == ']' || c == '}') && in.flowDepth > 0:
+			in.flowDepth--
+		}
+		in.out.WriteByte(c)
+		i++
+	}
+	in.out.Write(eol)
+
+	if !in.inDouble && !in.inSingle && in.flowDepth == 0 && blockScalarHeader.Match(content) {
+		in.block = indent
+	}
+	return nil
+}
+
+// blockLine expands the references in a line of a block scalar, where
+// everything but the indentation is taken as it is.
+func (in *yamlInterpolator) blockLine(content []byte, indent int) {
+	in.out.Write(interpolationRegexp.ReplaceAllFunc(content, func(match []byte) []byte {
+		value, ok := in.lookup(match)
+		if !ok {
+			return match
+		}
+		return []byte(strings.Replace(value, "\n", "\n"+strings.Repeat(" ", indent), -1))
+	}))
+}
+
+func (in *yamlInterpolator) expand(content []byte, i int, match []byte) (string, error) {
+	value, ok := in.lookup(match)
+	if !ok || string(match) == "$$" {
+		return value, nil
+	}
+
+	name := referenceName(match)
+	switch {
+	case in.inDouble:
+		return escapeJSONString(value), nil
+	case in.inSingle:
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("the value of ${%s} has a line break, which a single-quoted string can't hold; use double quotes", name)
+		}
+		return strings.Replace(value, "'", "''", -1), nil
+	case in.scalarStart(content, i) && in.scalarEnd(content, i+len(match)):
+		return yamlScalar(value, in.flowDepth > 0), nil
+	case plainSafe(value, in.flowDepth > 0):
+		return value, nil
+	}
+	return "", fmt.Errorf("the value of ${%s} can't be written into an unquoted value; put the value in double quotes", name)
+}
+
+// lookup returns what a reference expands to, or the reference itself when
+// its variable is unset, noting it as missing.
+func (in *yamlInterpolator) lookup(match []byte) (string, bool) {
+	value, ok := lookupReference(match)
+	if !ok {
+		in.missing = append(in.missing, referenceName(match))
+		return string(match), false
+	}
+	return value, true
+}
+
+// scalarStart reports whether a value starting at content[i] would be the
+// start of a scalar, rather than part of one.
+func (in *yamlInterpolator) scalarStart(content []byte, i int) bool {
+	j := i
+	for j > 0 && isYAMLSpace(content[j-1]) {
+		j--
+	}
+	if j == 0 {
+		return true
+	}
+	switch content[j-1] {
+	case ':', '-', '?':
+		// these are only indicators when followed by a space
+		return j < i
+	case '[', '{', ',':
+		return in.flowDepth > 0
+	}
+	return false
+}
+
+// scalarEnd reports whether the scalar ends at content[k].
+func (in *yamlInterpolator) scalarEnd(content []byte, k int) bool {
+	j := k
+	for j < len(content) && isYAMLSpace(content[j]) {
+		j++
+	}
+	switch {
+	case j == len(content):
+		return true
+	case content[j] == '#':
+		return j > k
+	case in.flowDepth > 0:
+		return strings.IndexByte(yamlFlowIndicators, content[j]) >= 0
+	}
+	return false
+}
+
+// yamlScalar is value written as a whole scalar: as it is if YAML reads it
+// back as a single value without losing any of it, so that numbers and
+// booleans keep their type, and as a double-quoted string otherwise.
+func yamlScalar(value string, flow bool) string {
+	var v interface{}
+	if !strings.ContainsAny(value, "\r\n") && !containsComment(value) && !(flow && strings.ContainsAny(value, yamlFlowIndicators)) {
+		if err := yaml.Unmarshal([]byte(value), &v); err == nil {
+			switch v := v.(type) {
+			case map[interface{}]interface{}, []interface{}:
+			case string:
+				if v == value {
+					return value
+				}
+			default:
+				return value
+			}
+		}
+	}
+	return `"` + escapeJSONString(value) + `"`
+}
+
+// plainSafe reports whether value can be written into the middle of an
+// unquoted scalar without ending it.
+func plainSafe(value string, flow bool) bool {
+	if strings.ContainsAny(value, "\r\n#") || strings.Contains(value, ": ") || strings.HasSuffix(value, ":") {
+		return false
+	}
+	return !flow || !strings.ContainsAny(value, yamlFlowIndicators)
+}
+
+func containsComment(s string) bool {
+	return strings.HasPrefix(s, "#") || strings.Contains(s, " #") || strings.Contains(s, "\t#")
+}
+
+func isYAMLSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
@@ -3,10 +3,17 @@ package libbuildpack
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 )
 
 type JSON struct {
+	// InterpolateEnv expands ${VAR} references when loading, see InterpolateEnv.
+	// Values are escaped so they can be used inside JSON strings.
+	InterpolateEnv bool
+	// Strict makes Load fail on keys that obj has no field for, to catch
+	// misspelt keys.
+	Strict bool
 }
 
 func NewJSON() *JSON {
@@ -35,6 +42,18 @@ func (j *JSON) Load(file string, obj interface{}) error {
 		return err
 	}
 
+	if j.InterpolateEnv {
+		if data, err = interpolateEnv(data, escapeJSONString); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	if j.Strict {
+		decoder := json.NewDecoder(bytes.NewReader(removeBOM(data)))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(obj)
+	}
+
 	err = json.Unmarshal(removeBOM(data), obj)
 	if err != nil {
 		return err
@@ -75,6 +75,46 @@ var _ = Describe("JSON", func() {
 				Expect(err).ToNot(BeNil())
 			})
 		})
+
+		Context("with Strict", func() {
+			type config struct {
+				Name string `json:"name"`
+			}
+
+			It("fails on unknown keys", func() {
+				json.Strict = true
+				ioutil.WriteFile(filepath.Join(tmpDir, "typo.json"), []byte("\uFEFF"+`{"name": "app", "nmae": "typo"}`), 0666)
+
+				var obj config
+				err = json.Load(filepath.Join(tmpDir, "typo.json"), &obj)
+				Expect(err).To(MatchError(ContainSubstring(`unknown field "nmae"`)))
+			})
+
+			It("loads known keys", func() {
+				json.Strict = true
+				ioutil.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(`{"name": "app"}`), 0666)
+
+				var obj config
+				Expect(json.Load(filepath.Join(tmpDir, "config.json"), &obj)).To(Succeed())
+				Expect(obj.Name).To(Equal("app"))
+			})
+		})
+
+		Context("with InterpolateEnv", func() {
+			BeforeEach(func() {
+				json.InterpolateEnv = true
+				os.Setenv("JSON_TEST_PASSWORD", `p"ss\word`)
+			})
+			AfterEach(func() { os.Unsetenv("JSON_TEST_PASSWORD") })
+
+			It("expands variables escaped for json strings", func() {
+				ioutil.WriteFile(filepath.Join(tmpDir, "env.json"), []byte(`{"password": "${JSON_TEST_PASSWORD}"}`), 0666)
+
+				obj := make(map[string]string)
+				Expect(json.Load(filepath.Join(tmpDir, "env.json"), &obj)).To(Succeed())
+				Expect(obj["password"]).To(Equal(`p"ss\word`))
+			})
+		})
 	})
 
 	Describe("Write", func() {
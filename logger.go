@@ -1,14 +1,87 @@
 package libbuildpack
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Logger struct {
-	w io.Writer
+	w         io.Writer
+	mu        sync.Mutex
+	lastWrite time.Time
+	level     *LogLevel
+
+	steps     []StepTiming
+	step      string
+	stepStart time.Time
+
+	redactions []*regexp.Regexp
+	partial    []byte
+}
+
+// StepTiming is how long a step begun with BeginStep took.
+type StepTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// LogLevel is the least severe kind of message a Logger prints.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarning
+	LogLevelError
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogLevelDebug:   "debug",
+	LogLevelInfo:    "info",
+	LogLevelWarning: "warning",
+	LogLevelError:   "error",
+}
+
+func (l LogLevel) String() string {
+	if name, found := logLevelNames[l]; found {
+		return name
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// ParseLogLevel parses "debug", "info", "warning" (or "warn") or "error",
+// ignoring case.
+func ParseLogLevel(s string) (LogLevel, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "warn" {
+		return LogLevelWarning, nil
+	}
+	for level, levelName := range logLevelNames {
+		if name == levelName {
+			return level, nil
+		}
+	}
+	return LogLevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// LogLevelFromEnv is the level named by BP_LOG_LEVEL, or debug when BP_DEBUG
+// is set. Otherwise, or when BP_LOG_LEVEL cannot be parsed, it is info.
+func LogLevelFromEnv() LogLevel {
+	if value := os.Getenv("BP_LOG_LEVEL"); value != "" {
+		if level, err := ParseLogLevel(value); err == nil {
+			return level
+		}
+	}
+	if os.Getenv("BP_DEBUG") != "" {
+		return LogLevelDebug
+	}
+	return LogLevelInfo
 }
 
 const (
@@ -22,44 +95,245 @@ const (
 	msgDebug    = msgPrefix + bluePrefix + "DEBUG:" + colorSuffix
 )
 
+// DefaultHeartbeatInterval is how long dependency installs may stay silent
+// before a heartbeat line is logged.
+var DefaultHeartbeatInterval = time.Minute
+
 func NewLogger(w io.Writer) *Logger {
 	return &Logger{w: w}
 }
 
+// SetLevel makes the logger drop messages less severe than level, in place
+// of following LogLevelFromEnv.
+func (l *Logger) SetLevel(level LogLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = &level
+}
+
+// Level is the level set with SetLevel, or else LogLevelFromEnv.
+func (l *Logger) Level() LogLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.level != nil {
+		return *l.level
+	}
+	return LogLevelFromEnv()
+}
+
+// Enabled reports whether messages at level are printed, so callers can skip
+// work that only feeds debug output.
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level >= l.Level()
+}
+
 func (l *Logger) Info(format string, args ...interface{}) {
-	l.printWithHeader("      ", format, args...)
+	if l.Enabled(LogLevelInfo) {
+		l.printWithHeader("      ", format, args...)
+	}
 }
 
 func (l *Logger) Warning(format string, args ...interface{}) {
-	l.printWithHeader(msgWarning, format, args...)
-
+	if l.Enabled(LogLevelWarning) {
+		l.printWithHeader(msgWarning, format, args...)
+	}
 }
+
 func (l *Logger) Error(format string, args ...interface{}) {
 	l.printWithHeader(msgError, format, args...)
 }
 
 func (l *Logger) Debug(format string, args ...interface{}) {
-	if os.Getenv("BP_DEBUG") != "" {
+	if l.Enabled(LogLevelDebug) {
 		l.printWithHeader(msgDebug, format, args...)
 	}
 }
 
+// BeginStep logs the start of a step and times it until EndStep, or until
+// the next step begins.
 func (l *Logger) BeginStep(format string, args ...interface{}) {
-	l.printWithHeader("----->", format, args...)
+	now := time.Now()
+	l.mu.Lock()
+	l.endStep(now)
+	l.step, l.stepStart = fmt.Sprintf(format, args...), now
+	l.mu.Unlock()
+
+	if l.Enabled(LogLevelInfo) {
+		l.printWithHeader("----->", format, args...)
+	}
+}
+
+// EndStep ends the step begun last, returning how long it took, or 0 if
+// there is none.
+func (l *Logger) EndStep() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.endStep(time.Now())
+}
+
+func (l *Logger) endStep(now time.Time) time.Duration {
+	if l.step == "" {
+		return 0
+	}
+	duration := now.Sub(l.stepStart)
+	l.steps = append(l.steps, StepTiming{Name: l.step, Duration: duration})
+	l.step = ""
+	return duration
+}
+
+// StepTimings are the steps that have ended, in order.
+func (l *Logger) StepTimings() []StepTiming {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]StepTiming{}, l.steps...)
+}
+
+// StepSummary ends the current step and logs a table of how long each step
+// took, so users can see where staging time goes.
+func (l *Logger) StepSummary() {
+	l.EndStep()
+	steps := l.StepTimings()
+	if len(steps) == 0 || !l.Enabled(LogLevelInfo) {
+		return
+	}
+
+	width := len("Total")
+	var total time.Duration
+	for _, step := range steps {
+		if len(step.Name) > width {
+			width = len(step.Name)
+		}
+		total += step.Duration
+	}
+
+	l.printWithHeader("----->", "Staging time")
+	for _, step := range steps {
+		l.printWithHeader("      ", "%-*s %8s", width, step.Name, formatStepDuration(step.Duration))
+	}
+	l.printWithHeader("      ", "%-*s %8s", width, "Total", formatStepDuration(total))
+}
+
+func formatStepDuration(d time.Duration) string {
+	if d < time.Second {
+		return "<1s"
+	}
+	return d.Round(time.Second).String()
 }
 
 func (l *Logger) Protip(tip string, helpURL string) {
-	l.printWithHeader(msgProtip, "%s", tip)
-	l.printWithHeader(msgPrefix+"Visit", "%s", helpURL)
+	if l.Enabled(LogLevelInfo) {
+		l.printWithHeader(msgProtip, "%s", tip)
+		l.printWithHeader(msgPrefix+"Visit", "%s", helpURL)
+	}
 }
 
 func (l *Logger) printWithHeader(header string, format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 
 	msg = strings.Replace(msg, "\n", "\n       ", -1)
-	fmt.Fprintf(l.w, "%s %s\n", header, msg)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushOutput()
+	fmt.Fprintf(l.w, "%s %s\n", header, l.redact(msg))
+	l.lastWrite = time.Now()
+}
+
+func (l *Logger) printRaw(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushOutput()
+	fmt.Fprintln(l.w, l.redact(line))
+	l.lastWrite = time.Now()
 }
 
+// Heartbeat prints "still working on <what> (<elapsed> elapsed)" whenever
+// nothing has been logged for interval, so that long quiet operations are not
+// mistaken for a hung staging process. Call the returned func to stop it.
+func (l *Logger) Heartbeat(what string, interval time.Duration) (stop func()) {
+	start := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				l.mu.Lock()
+				silent := now.Sub(l.lastWrite) >= interval
+				l.mu.Unlock()
+
+				if silent {
+					l.Info("still working on %s (%s elapsed)", what, now.Sub(start).Round(time.Second))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Output is a writer for command output that writes it to the logger line
+// by line, redacted as the logger's messages are. A last line without a
+// newline is written when the logger next logs, or when the writer is
+// flushed, as Command does once the command exits. A command run by Command
+// with its stdout going to Output gets a Heartbeat while it is quiet.
 func (l *Logger) Output() io.Writer {
-	return l.w
+	return &loggerOutput{l: l}
+}
+
+type loggerOutput struct {
+	l *Logger
+}
+
+func (o *loggerOutput) Write(p []byte) (int, error) {
+	l := o.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.partial = append(l.partial, p...)
+	for {
+		idx := bytes.IndexByte(l.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		fmt.Fprintln(l.w, l.redact(string(l.partial[:idx])))
+		l.partial = l.partial[idx+1:]
+	}
+	for len(l.partial) >= maxOutputLine {
+		fmt.Fprintln(l.w, l.redact(string(l.partial[:maxOutputLine])))
+		l.partial = l.partial[maxOutputLine:]
+	}
+	// any output, even a partial line, shows the command is still working
+	l.lastWrite = time.Now()
+	return len(p), nil
+}
+
+// Flush writes out a last line that was not terminated by a newline.
+func (o *loggerOutput) Flush() {
+	o.l.mu.Lock()
+	defer o.l.mu.Unlock()
+	o.l.flushOutput()
+}
+
+func (o *loggerOutput) heartbeat(what string) (stop func()) {
+	return o.l.Heartbeat(what, DefaultHeartbeatInterval)
+}
+
+// flushOutput writes out a partial line of command output. The caller holds
+// l.mu.
+func (l *Logger) flushOutput() {
+	if len(l.partial) > 0 {
+		fmt.Fprintln(l.w, l.redact(string(l.partial)))
+		l.partial = nil
+	}
 }
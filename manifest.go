@@ -1,6 +1,7 @@
 package libbuildpack
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -33,11 +34,15 @@ type DeprecationDate struct {
 }
 
 type ManifestEntry struct {
-	Dependency Dependency `yaml:",inline"`
-	URI        string     `yaml:"uri"`
-	File       string     `yaml:"file"`
-	SHA256     string     `yaml:"sha256"`
-	CFStacks   []string   `yaml:"cf_stacks"`
+	Dependency Dependency           `yaml:",inline"`
+	URI        string               `yaml:"uri"`
+	Mirrors    []string             `yaml:"mirrors"`
+	File       string               `yaml:"file"`
+	SHA256     string               `yaml:"sha256"`
+	SHA512     string               `yaml:"sha512"`
+	Checksums  map[string]string    `yaml:"checksums"`
+	Signature  *DependencySignature `yaml:"signature"`
+	CFStacks   []string             `yaml:"cf_stacks"`
 }
 
 type Manifest struct {
@@ -46,11 +51,44 @@ type Manifest struct {
 	ManifestEntries []ManifestEntry   `yaml:"dependencies"`
 	Deprecations    []DeprecationDate `yaml:"dependency_deprecation_dates"`
 	Stack           string            `yaml:"stack"`
+	MinVersion      string            `yaml:"min_packager_version"`
 	manifestRootDir string
 	currentTime     time.Time //move into installer?
 	log             *Logger
 }
 
+// StrictManifestEnv makes NewManifest fail on keys of manifest.yml that no
+// buildpack tooling knows, e.g. a misspelt "dependecies", when set to "true".
+const StrictManifestEnv = "BP_STRICT_MANIFEST"
+
+// manifestSchema has every key a manifest.yml may have, including those only
+// the packager reads, for strict loading.
+type manifestSchema struct {
+	Language        string            `yaml:"language"`
+	DefaultVersions []Dependency      `yaml:"default_versions"`
+	Deprecations    []DeprecationDate `yaml:"dependency_deprecation_dates"`
+	Stack           string            `yaml:"stack"`
+	MinVersion      string            `yaml:"min_packager_version"`
+	IncludeFiles    []string          `yaml:"include_files"`
+	ExcludeFiles    []string          `yaml:"exclude_files"`
+	PrePackage      string            `yaml:"pre_package"`
+	URLToDependency []struct {
+		Match   string `yaml:"match"`
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+	} `yaml:"url_to_dependency_map"`
+	Dependencies []manifestSchemaEntry `yaml:"dependencies"`
+}
+
+type manifestSchemaEntry struct {
+	ManifestEntry `yaml:",inline"`
+	MD5           string   `yaml:"md5"`
+	Source        string   `yaml:"source"`
+	SourceSHA256  string   `yaml:"source_sha256"`
+	Modules       []string `yaml:"modules"`
+	LicenseFiles  []string `yaml:"license_files"`
+}
+
 type BuildpackMetadata struct {
 	Language string `yaml:"language"`
 	Version  string `yaml:"version"`
@@ -60,11 +98,22 @@ func NewManifest(bpDir string, logger *Logger, currentTime time.Time) (*Manifest
 	var m Manifest
 	y := &YAML{}
 
+	if os.Getenv(StrictManifestEnv) == "true" {
+		strict := &YAML{Strict: true}
+		if err := strict.Load(filepath.Join(bpDir, "manifest.yml"), &manifestSchema{}); err != nil {
+			return nil, fmt.Errorf("manifest.yml: %v", err)
+		}
+	}
+
 	err := y.Load(filepath.Join(bpDir, "manifest.yml"), &m)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := CheckMinVersion(m.MinVersion); err != nil {
+		return nil, err
+	}
+
 	m.manifestRootDir, err = filepath.Abs(bpDir)
 	if err != nil {
 		return nil, err
@@ -111,7 +160,7 @@ func (m *Manifest) ApplyOverride(depsDir string) error {
 
 	for _, file := range files {
 		var overrideYml map[string]Manifest
-		y := &YAML{}
+		y := &YAML{InterpolateEnv: configInterpolationEnabled()}
 		if err := y.Load(file, &overrideYml); err != nil {
 			return err
 		}
@@ -259,6 +308,23 @@ func (m *Manifest) DefaultVersion(depName string) (Dependency, error) {
 	return Dependency{Name: depName, Version: highestVersion}, nil
 }
 
+// DefaultVersionConstraint returns the highest version of depName on the
+// current stack satisfying constraint, which may be a semver range such as
+// ">=14.0.0 <16" as well as an x-style pattern like "14.x". Pre-release
+// versions only match constraints that themselves name a pre-release.
+func (m *Manifest) DefaultVersionConstraint(depName, constraint string) (Dependency, error) {
+	depVersions := m.AllDependencyVersions(depName)
+	if len(depVersions) == 0 {
+		return Dependency{}, fmt.Errorf("no versions of %s found", depName)
+	}
+
+	versions, err := matchSemver2(normalizeConstraint(constraint), depVersions)
+	if err != nil {
+		return Dependency{}, err
+	}
+	return Dependency{Name: depName, Version: versions[len(versions)-1]}, nil
+}
+
 func fetchCachedBuildpackDependency(entry *ManifestEntry, outputFile, manifestRootDir string, manifestLog *Logger) error {
 	source := entry.File
 	if !filepath.IsAbs(source) {
@@ -271,26 +337,59 @@ func fetchCachedBuildpackDependency(entry *ManifestEntry, outputFile, manifestRo
 	return deleteBadFile(entry, outputFile)
 }
 
+// VerifyChecksums checks file against every checksum the entry declares.
+func (e *ManifestEntry) VerifyChecksums(file string) error {
+	checksums, err := ParseChecksums(e.SHA256, e.SHA512, e.Checksums)
+	if err != nil {
+		return err
+	}
+	return VerifyChecksums(file, checksums)
+}
+
 func deleteBadFile(entry *ManifestEntry, outputFile string) error {
-	if err := CheckSha256(outputFile, entry.SHA256); err != nil {
+	if err := entry.VerifyChecksums(outputFile); err != nil {
 		os.Remove(outputFile)
 		return err
 	}
 	return nil
 }
 
-func downloadDependency(entry *ManifestEntry, outputFile string, logger *Logger) error {
-	filteredURI, err := filterURI(entry.URI)
+// downloadDependency tries the entry's uri and then each of its mirrors in
+// order, stopping at the first download that matches the checksums.
+func downloadDependency(ctx context.Context, entry *ManifestEntry, outputFile string, logger *Logger, opts DownloadOptions) error {
+	uris, err := dependencyURIs(entry)
 	if err != nil {
 		return err
 	}
-	logger.Info("Download [%s]", filteredURI)
-	err = downloadFile(entry.URI, outputFile)
-	if err != nil {
-		return err
+	if logger.Enabled(LogLevelDebug) {
+		var filtered []string
+		for _, uri := range uris {
+			if filteredURI, err := filterURI(uri); err == nil {
+				filtered = append(filtered, filteredURI)
+			}
+		}
+		logger.Debug("Resolved %s %s to %s", entry.Dependency.Name, entry.Dependency.Version, strings.Join(filtered, ", "))
 	}
+	for i, uri := range uris {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		filteredURI, filterErr := filterURI(uri)
+		if filterErr != nil {
+			return filterErr
+		}
+		if i > 0 {
+			logger.Warning("Download failed: %v, trying mirror", err)
+		}
+		logger.Info("Download [%s]", filteredURI)
 
-	return deleteBadFile(entry, outputFile)
+		if err = downloadFile(ctx, uri, outputFile, opts, logger); err == nil {
+			if err = deleteBadFile(entry, outputFile); err == nil {
+				return nil
+			}
+		}
+	}
+	return err
 }
 
 func (m *Manifest) entrySupportsStack(entry *ManifestEntry, stack string) bool {
@@ -330,8 +429,32 @@ func (m *Manifest) GetEntry(dep Dependency) (*ManifestEntry, error) {
 		}
 	}
 
-	m.log.Error(dependencyMissingError(m, dep))
-	return nil, fmt.Errorf("dependency %s %s not found", dep.Name, dep.Version)
+	err := m.resolutionError(dep, currentStack)
+	m.log.Error(dependencyMissingError(m, err))
+	return nil, err
+}
+
+func (m *Manifest) resolutionError(dep Dependency, stack string) *DependencyResolutionError {
+	err := &DependencyResolutionError{Dependency: dep, Stack: stack}
+	err.NearestVersions = nearestVersions(dep.Version, m.AllDependencyVersions(dep.Name), 3)
+
+	seen := map[string]bool{}
+	for _, e := range m.ManifestEntries {
+		if e.Dependency != dep {
+			continue
+		}
+		stacks := e.CFStacks
+		if m.Stack != "" {
+			stacks = []string{m.Stack}
+		}
+		for _, s := range stacks {
+			if s != stack && !seen[s] {
+				seen[s] = true
+				err.OtherStacks = append(err.OtherStacks, s)
+			}
+		}
+	}
+	return err
 }
 
 func (m *Manifest) IsCached() bool {
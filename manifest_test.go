@@ -49,6 +49,82 @@ var _ = Describe("Manifest", func() {
 		It("has a language", func() {
 			Expect(manifest.Language()).To(Equal("dotnet-core"))
 		})
+
+		Context("with a min_packager_version", func() {
+			var bpDir string
+
+			BeforeEach(func() {
+				bpDir, err = ioutil.TempDir("", "min_version")
+				Expect(err).To(BeNil())
+			})
+			AfterEach(func() {
+				Expect(os.RemoveAll(bpDir)).To(Succeed())
+			})
+
+			It("loads manifests this version supports", func() {
+				Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte("language: sample\nmin_packager_version: 1.0.0\n"), 0644)).To(Succeed())
+
+				_, err := libbuildpack.NewManifest(bpDir, logger, time.Now())
+				Expect(err).To(BeNil())
+			})
+
+			It("refuses manifests needing a newer version", func() {
+				Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte("language: sample\nmin_packager_version: 99.0.0\n"), 0644)).To(Succeed())
+
+				_, err := libbuildpack.NewManifest(bpDir, logger, time.Now())
+				Expect(err).To(MatchError(ContainSubstring("manifest requires libbuildpack 99.0.0 or newer")))
+			})
+		})
+
+		Context("with BP_STRICT_MANIFEST", func() {
+			var bpDir string
+
+			BeforeEach(func() {
+				bpDir, err = ioutil.TempDir("", "strict")
+				Expect(err).To(BeNil())
+				os.Setenv("BP_STRICT_MANIFEST", "true")
+			})
+			AfterEach(func() {
+				os.Unsetenv("BP_STRICT_MANIFEST")
+				Expect(os.RemoveAll(bpDir)).To(Succeed())
+			})
+
+			It("refuses misspelt keys", func() {
+				Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte("language: sample\ndependecies:\n- name: node\n"), 0644)).To(Succeed())
+
+				_, err := libbuildpack.NewManifest(bpDir, logger, time.Now())
+				Expect(err).To(MatchError(ContainSubstring("field dependecies not found")))
+			})
+
+			It("loads the keys the packager reads", func() {
+				data := `language: sample
+pre_package: scripts/build.sh
+include_files: [manifest.yml]
+exclude_files: [.git]
+url_to_dependency_map:
+- match: node-v(\d+\.\d+\.\d+)
+  name: node
+  version: $1
+dependencies:
+- name: node
+  version: 6.9.4
+  uri: https://example.com/node.tgz
+  sha256: abc
+  cf_stacks: [cflinuxfs3]
+  source: https://example.com/node-src.tgz
+  source_sha256: def
+`
+				Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte(data), 0644)).To(Succeed())
+
+				_, err := libbuildpack.NewManifest(bpDir, logger, time.Now())
+				Expect(err).To(BeNil())
+			})
+
+			It("loads the fixture manifests", func() {
+				_, err := libbuildpack.NewManifest(filepath.Join("fixtures", "manifest", "standard"), logger, time.Now())
+				Expect(err).To(BeNil())
+			})
+		})
 	})
 
 	Describe("ApplyOverride", func() {
@@ -111,6 +187,35 @@ ruby:
 
 			Expect(manifest.DefaultVersion("thing")).To(Equal(libbuildpack.Dependency{Name: "thing", Version: "9.3.6"}))
 		})
+
+		Context("with BP_CONFIG_INTERPOLATION", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(depsDir, "2", "override.yml"), []byte(`---
+dotnet-core:
+  default_versions:
+  - name: ruby
+    version: ${OVERRIDE_RUBY_VERSION}
+`), 0644)).To(Succeed())
+				os.Setenv("OVERRIDE_RUBY_VERSION", "2.4.x")
+			})
+			AfterEach(func() {
+				os.Unsetenv("OVERRIDE_RUBY_VERSION")
+				os.Unsetenv("BP_CONFIG_INTERPOLATION")
+			})
+
+			It("expands environment variables when enabled", func() {
+				os.Setenv("BP_CONFIG_INTERPOLATION", "true")
+				Expect(manifest.ApplyOverride(depsDir)).To(Succeed())
+
+				Expect(manifest.DefaultVersions).To(ContainElement(libbuildpack.Dependency{Name: "ruby", Version: "2.4.x"}))
+			})
+
+			It("does not expand them otherwise", func() {
+				Expect(manifest.ApplyOverride(depsDir)).To(Succeed())
+
+				Expect(manifest.DefaultVersions).To(ContainElement(libbuildpack.Dependency{Name: "ruby", Version: "${OVERRIDE_RUBY_VERSION}"}))
+			})
+		})
 	})
 
 	Describe("CheckStackSupport", func() {
@@ -325,6 +430,50 @@ ruby:
 		})
 	})
 
+	Describe("DefaultVersionConstraint", func() {
+		BeforeEach(func() { manifestDir = "fixtures/manifest/constraints" })
+
+		It("resolves semver ranges", func() {
+			dep, err := manifest.DefaultVersionConstraint("node", ">=14.0.0 <16")
+			Expect(err).To(BeNil())
+			Expect(dep).To(Equal(libbuildpack.Dependency{Name: "node", Version: "15.14.0"}))
+		})
+
+		It("accepts comma separated and || alternatives", func() {
+			dep, err := manifest.DefaultVersionConstraint("node", ">= 14.0.0, < 14.18 || 13.x")
+			Expect(err).To(BeNil())
+			Expect(dep.Version).To(Equal("14.17.0"))
+		})
+
+		It("resolves x-style patterns", func() {
+			dep, err := manifest.DefaultVersionConstraint("node", "14.x")
+			Expect(err).To(BeNil())
+			Expect(dep).To(Equal(libbuildpack.Dependency{Name: "node", Version: "14.18.1"}))
+		})
+
+		It("skips pre-releases unless the constraint asks for one", func() {
+			dep, err := manifest.DefaultVersionConstraint("node", ">=16")
+			Expect(err).To(BeNil())
+			Expect(dep.Version).To(Equal("16.1.0"))
+
+			dep, err = manifest.DefaultVersionConstraint("node", ">=16.0.0-0")
+			Expect(err).To(BeNil())
+			Expect(dep.Version).To(Equal("17.0.0-rc.2"))
+
+			dep, err = manifest.DefaultVersionConstraint("node", "~16.0.0-0")
+			Expect(err).To(BeNil())
+			Expect(dep.Version).To(Equal("16.0.0-rc.1"))
+		})
+
+		It("returns an error when nothing matches", func() {
+			_, err := manifest.DefaultVersionConstraint("node", ">=18")
+			Expect(err).To(HaveOccurred())
+
+			_, err = manifest.DefaultVersionConstraint("notexist", ">=1")
+			Expect(err).To(MatchError("no versions of notexist found"))
+		})
+	})
+
 	Describe("DefaultVersion", func() {
 		Context("requested name exists and default version is locked to the patch", func() {
 			It("returns the default", func() {
@@ -571,6 +720,35 @@ ruby:
 						_, err := manifest.GetEntry(depToFind)
 						Expect(err).To(HaveOccurred())
 					})
+
+					It("suggests the stacks that provide the version", func() {
+						_, err := manifest.GetEntry(depToFind)
+						resolutionErr, ok := err.(*libbuildpack.DependencyResolutionError)
+						Expect(ok).To(BeTrue())
+						Expect(resolutionErr.Stack).To(Equal("inanestack"))
+						Expect(resolutionErr.OtherStacks).To(Equal([]string{"cflinuxfs2"}))
+						Expect(buffer.String()).To(ContainSubstring("push with stack cflinuxfs2, which provides jruby 9.3.5"))
+					})
+				})
+			})
+
+			Context("version does not match", func() {
+				BeforeEach(func() {
+					manifestDir = "fixtures/manifest/standard"
+					os.Setenv("CF_STACK", "cflinuxfs2")
+					depToFind = libbuildpack.Dependency{"jruby", "9.3.9"}
+				})
+
+				It("returns the nearest available versions", func() {
+					_, err := manifest.GetEntry(depToFind)
+					Expect(err).To(MatchError("dependency jruby 9.3.9 not found"))
+
+					resolutionErr, ok := err.(*libbuildpack.DependencyResolutionError)
+					Expect(ok).To(BeTrue())
+					Expect(resolutionErr.NearestVersions).To(Equal([]string{"9.3.5", "9.3.4", "9.4.4"}))
+					Expect(resolutionErr.OtherStacks).To(BeEmpty())
+					Expect(buffer.String()).To(ContainSubstring("You could:\n"))
+					Expect(buffer.String()).To(ContainSubstring("use jruby 9.3.5 instead"))
 				})
 			})
 
@@ -0,0 +1,154 @@
+package libbuildpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics reports staging measurements for fleet-wide monitoring. It sends
+// them to a statsd server when BP_METRICS_STATSD_ADDR (host:port) is set, or
+// POSTs them as JSON to BP_METRICS_URL. With neither set every call is a no-op.
+// Metric names are prefixed with BP_METRICS_PREFIX, "libbuildpack" by default.
+// Failures to send are ignored so metrics can never break staging. Metrics
+// for statsd share one UDP socket, released by Close.
+type Metrics struct {
+	statsdAddr string
+	url        string
+	prefix     string
+	client     *http.Client
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+type Metric struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+func NewMetrics() *Metrics {
+	prefix := os.Getenv("BP_METRICS_PREFIX")
+	if prefix == "" {
+		prefix = "libbuildpack"
+	}
+	return &Metrics{
+		statsdAddr: os.Getenv("BP_METRICS_STATSD_ADDR"),
+		url:        os.Getenv("BP_METRICS_URL"),
+		prefix:     prefix,
+		client:     &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func (m *Metrics) Enabled() bool {
+	return m != nil && (m.statsdAddr != "" || m.url != "")
+}
+
+func (m *Metrics) Timing(name string, d time.Duration, tags map[string]string) {
+	m.send(Metric{Name: name, Type: "timing", Value: float64(d) / float64(time.Millisecond), Tags: tags})
+}
+
+func (m *Metrics) Gauge(name string, value float64, tags map[string]string) {
+	m.send(Metric{Name: name, Type: "gauge", Value: value, Tags: tags})
+}
+
+func (m *Metrics) Increment(name string, tags map[string]string) {
+	m.send(Metric{Name: name, Type: "count", Value: 1, Tags: tags})
+}
+
+// Phase starts timing a staging phase, e.g. defer metrics.Phase("supply")(),
+// and reports it as phase.duration when the returned func is called.
+func (m *Metrics) Phase(name string) (done func()) {
+	start := time.Now()
+	return func() {
+		m.Timing("phase.duration", time.Since(start), map[string]string{"phase": name})
+	}
+}
+
+// Close releases the statsd socket. Metrics sent afterwards open a new one.
+func (m *Metrics) Close() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conn == nil {
+		return nil
+	}
+	err := m.conn.Close()
+	m.conn = nil
+	return err
+}
+
+func (m *Metrics) send(metric Metric) {
+	if !m.Enabled() {
+		return
+	}
+	metric.Name = m.prefix + "." + metric.Name
+	metric.Timestamp = time.Now()
+
+	if m.statsdAddr != "" {
+		m.sendStatsd(metric)
+	}
+	if m.url != "" {
+		m.sendHTTP(metric)
+	}
+}
+
+func (m *Metrics) sendStatsd(metric Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conn == nil {
+		conn, err := net.Dial("udp", m.statsdAddr)
+		if err != nil {
+			return
+		}
+		m.conn = conn
+	}
+	if _, err := fmt.Fprint(m.conn, statsdLine(metric)); err != nil {
+		// dial again for the next metric
+		m.conn.Close()
+		m.conn = nil
+	}
+}
+
+func (m *Metrics) sendHTTP(metric Metric) {
+	body, err := json.Marshal(metric)
+	if err != nil {
+		return
+	}
+	resp, err := m.client.Post(m.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// statsdLine formats metric in the statsd line protocol, with tags in the
+// widely supported DogStatsD "|#key:value" extension.
+func statsdLine(metric Metric) string {
+	types := map[string]string{"timing": "ms", "gauge": "g", "count": "c"}
+	line := fmt.Sprintf("%s:%g|%s", metric.Name, metric.Value, types[metric.Type])
+
+	if len(metric.Tags) > 0 {
+		var tags []string
+		for k, v := range metric.Tags {
+			tags = append(tags, k+":"+v)
+		}
+		sort.Strings(tags)
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
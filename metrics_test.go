@@ -0,0 +1,135 @@
+package libbuildpack_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	httpmock "github.com/jarcoal/httpmock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var oldEnv map[string]string
+
+	BeforeEach(func() {
+		oldEnv = map[string]string{}
+		for _, name := range []string{"BP_METRICS_STATSD_ADDR", "BP_METRICS_URL", "BP_METRICS_PREFIX"} {
+			oldEnv[name] = os.Getenv(name)
+			os.Unsetenv(name)
+		}
+	})
+
+	AfterEach(func() {
+		for name, value := range oldEnv {
+			os.Setenv(name, value)
+		}
+	})
+
+	Context("not configured", func() {
+		It("is disabled", func() {
+			metrics := libbuildpack.NewMetrics()
+			Expect(metrics.Enabled()).To(BeFalse())
+			metrics.Increment("anything", nil)
+		})
+	})
+
+	Context("with BP_METRICS_URL", func() {
+		var received []libbuildpack.Metric
+
+		BeforeEach(func() {
+			received = nil
+			httpmock.Reset()
+			httpmock.RegisterResponder("POST", "https://metrics.example.com/staging",
+				func(req *http.Request) (*http.Response, error) {
+					var metric libbuildpack.Metric
+					Expect(json.NewDecoder(req.Body).Decode(&metric)).To(Succeed())
+					received = append(received, metric)
+					return httpmock.NewStringResponse(204, ""), nil
+				})
+			os.Setenv("BP_METRICS_URL", "https://metrics.example.com/staging")
+			os.Setenv("BP_METRICS_PREFIX", "test_buildpack")
+		})
+
+		It("posts each metric as JSON", func() {
+			metrics := libbuildpack.NewMetrics()
+			Expect(metrics.Enabled()).To(BeTrue())
+
+			metrics.Gauge("dependency.size_bytes", 1024, map[string]string{"dependency": "thing"})
+			metrics.Phase("supply")()
+
+			Expect(received).To(HaveLen(2))
+			Expect(received[0].Name).To(Equal("test_buildpack.dependency.size_bytes"))
+			Expect(received[0].Type).To(Equal("gauge"))
+			Expect(received[0].Value).To(Equal(1024.0))
+			Expect(received[0].Tags).To(Equal(map[string]string{"dependency": "thing"}))
+			Expect(received[1].Name).To(Equal("test_buildpack.phase.duration"))
+			Expect(received[1].Type).To(Equal("timing"))
+			Expect(received[1].Tags).To(Equal(map[string]string{"phase": "supply"}))
+		})
+	})
+
+	Context("with BP_METRICS_STATSD_ADDR", func() {
+		var conn net.PacketConn
+
+		BeforeEach(func() {
+			var err error
+			conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			os.Setenv("BP_METRICS_STATSD_ADDR", conn.LocalAddr().String())
+		})
+
+		AfterEach(func() { conn.Close() })
+
+		readLine := func() string {
+			buf := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err).To(BeNil())
+			return string(buf[:n])
+		}
+
+		It("sends statsd lines with tags", func() {
+			metrics := libbuildpack.NewMetrics()
+
+			metrics.Timing("dependency.fetch", 1500*time.Millisecond, map[string]string{"source": "download", "dependency": "thing"})
+			Expect(readLine()).To(Equal("libbuildpack.dependency.fetch:1500|ms|#dependency:thing,source:download"))
+
+			metrics.Increment("dependency.cache_hit", nil)
+			Expect(readLine()).To(Equal("libbuildpack.dependency.cache_hit:1|c"))
+		})
+
+		It("sends every metric over one socket", func() {
+			metrics := libbuildpack.NewMetrics()
+			readFrom := func() net.Addr {
+				buf := make([]byte, 1024)
+				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				_, addr, err := conn.ReadFrom(buf)
+				Expect(err).To(BeNil())
+				return addr
+			}
+
+			metrics.Increment("one", nil)
+			first := readFrom()
+			metrics.Increment("two", nil)
+			Expect(readFrom().String()).To(Equal(first.String()))
+		})
+
+		It("opens a new socket for metrics sent after Close", func() {
+			metrics := libbuildpack.NewMetrics()
+			metrics.Increment("one", nil)
+			Expect(readLine()).To(Equal("libbuildpack.one:1|c"))
+
+			Expect(metrics.Close()).To(Succeed())
+			Expect(metrics.Close()).To(Succeed())
+
+			metrics.Increment("two", nil)
+			Expect(readLine()).To(Equal("libbuildpack.two:1|c"))
+		})
+	})
+})
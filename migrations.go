@@ -0,0 +1,118 @@
+package libbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const schemaVersionKey = "schema_version"
+
+// Migration upgrades config data from one schema version to the next.
+type Migration func(data map[string]interface{}) error
+
+// ConfigMigrator loads and writes buildpack owned config files (for example
+// metadata persisted in the cache dir) carrying a schema_version field.
+// Files written by an older buildpack are brought up to date by running the
+// registered migrations in order. Files ending in .json are read and written
+// as JSON, everything else as YAML.
+type ConfigMigrator struct {
+	currentVersion int
+	migrations     map[int]Migration
+}
+
+func NewConfigMigrator(currentVersion int) *ConfigMigrator {
+	return &ConfigMigrator{currentVersion: currentVersion, migrations: map[int]Migration{}}
+}
+
+// Register adds the migration from fromVersion to fromVersion+1.
+func (c *ConfigMigrator) Register(fromVersion int, migration Migration) {
+	c.migrations[fromVersion] = migration
+}
+
+func (c *ConfigMigrator) Load(file string, obj interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	raw := map[string]interface{}{}
+	if isJSON(file) {
+		err = json.Unmarshal(removeBOM(data), &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := schemaVersion(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	if version > c.currentVersion {
+		return fmt.Errorf("%s has schema version %d, newer than the supported version %d", file, version, c.currentVersion)
+	}
+
+	for ; version < c.currentVersion; version++ {
+		migration, found := c.migrations[version]
+		if !found {
+			return fmt.Errorf("no migration registered from schema version %d for %s", version, file)
+		}
+		if err := migration(raw); err != nil {
+			return fmt.Errorf("could not migrate %s from schema version %d: %v", file, version, err)
+		}
+	}
+	raw[schemaVersionKey] = c.currentVersion
+
+	return convert(file, raw, obj)
+}
+
+func (c *ConfigMigrator) Write(dest string, obj interface{}) error {
+	raw := map[string]interface{}{}
+	if err := convert(dest, obj, &raw); err != nil {
+		return err
+	}
+	raw[schemaVersionKey] = c.currentVersion
+
+	if isJSON(dest) {
+		return NewJSON().Write(dest, raw)
+	}
+	return NewYAML().Write(dest, raw)
+}
+
+func schemaVersion(raw map[string]interface{}) (int, error) {
+	switch v := raw[schemaVersionKey].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("invalid %s %v", schemaVersionKey, v)
+	}
+}
+
+func convert(file string, from, to interface{}) error {
+	if isJSON(file) {
+		data, err := json.Marshal(from)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, to)
+	}
+
+	data, err := yaml.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, to)
+}
+
+func isJSON(file string) bool {
+	return filepath.Ext(file) == ".json"
+}
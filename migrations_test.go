@@ -0,0 +1,107 @@
+package libbuildpack_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConfigMigrator", func() {
+	type metadata struct {
+		SchemaVersion int    `yaml:"schema_version" json:"schema_version"`
+		Runtime       string `yaml:"runtime" json:"runtime"`
+		Version       string `yaml:"version" json:"version"`
+	}
+
+	var (
+		tmpDir   string
+		migrator *libbuildpack.ConfigMigrator
+		err      error
+	)
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "migrations")
+		Expect(err).To(BeNil())
+
+		migrator = libbuildpack.NewConfigMigrator(2)
+		migrator.Register(0, func(data map[string]interface{}) error {
+			data["runtime"] = data["name"]
+			delete(data, "name")
+			return nil
+		})
+		migrator.Register(1, func(data map[string]interface{}) error {
+			if data["version"] == nil {
+				data["version"] = "unknown"
+			}
+			return nil
+		})
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	for _, ext := range []string{"yml", "json"} {
+		ext := ext
+
+		Context("with a ."+ext+" file", func() {
+			var file string
+
+			BeforeEach(func() {
+				file = filepath.Join(tmpDir, "metadata."+ext)
+			})
+
+			It("migrates files without a schema version through every migration", func() {
+				contents := "name: ruby\n"
+				if ext == "json" {
+					contents = `{"name": "ruby"}`
+				}
+				Expect(ioutil.WriteFile(file, []byte(contents), 0644)).To(Succeed())
+
+				var m metadata
+				Expect(migrator.Load(file, &m)).To(Succeed())
+				Expect(m).To(Equal(metadata{SchemaVersion: 2, Runtime: "ruby", Version: "unknown"}))
+			})
+
+			It("round trips current files untouched", func() {
+				Expect(migrator.Write(file, metadata{Runtime: "ruby", Version: "2.6.5"})).To(Succeed())
+
+				var m metadata
+				Expect(migrator.Load(file, &m)).To(Succeed())
+				Expect(m).To(Equal(metadata{SchemaVersion: 2, Runtime: "ruby", Version: "2.6.5"}))
+			})
+		})
+	}
+
+	It("refuses files from a newer schema", func() {
+		file := filepath.Join(tmpDir, "metadata.yml")
+		Expect(ioutil.WriteFile(file, []byte("schema_version: 3\n"), 0644)).To(Succeed())
+
+		var m metadata
+		Expect(migrator.Load(file, &m)).To(MatchError(ContainSubstring("has schema version 3, newer than the supported version 2")))
+	})
+
+	It("errors when a migration is missing", func() {
+		migrator = libbuildpack.NewConfigMigrator(1)
+		file := filepath.Join(tmpDir, "metadata.yml")
+		Expect(ioutil.WriteFile(file, []byte("name: ruby\n"), 0644)).To(Succeed())
+
+		var m metadata
+		Expect(migrator.Load(file, &m)).To(MatchError(ContainSubstring("no migration registered from schema version 0")))
+	})
+
+	It("reports failing migrations", func() {
+		migrator.Register(1, func(data map[string]interface{}) error { return errors.New("bad data") })
+		file := filepath.Join(tmpDir, "metadata.yml")
+		Expect(ioutil.WriteFile(file, []byte("schema_version: 1\n"), 0644)).To(Succeed())
+
+		var m metadata
+		Expect(migrator.Load(file, &m)).To(MatchError(ContainSubstring("from schema version 1: bad data")))
+	})
+})
@@ -0,0 +1,101 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// DependencyMirrorEnv names the environment variable holding rules that
+// rewrite dependency URIs before they are downloaded, e.g. to fetch from an
+// internal artifact repository instead of the public internet. Rules are
+// separated by commas. A rule is either "<prefix>=<template>", applied to
+// URIs with the scheme and host of prefix and a path within its path, or a
+// bare template applied to every URI; the first matching rule wins.
+// Templates may use {path}, {filename}, {name} and {version}, for example
+//
+//	https://buildpacks.cloudfoundry.org=https://artifactory.example.com/buildpacks/{path}
+const DependencyMirrorEnv = "BUILDPACK_DEPENDENCY_MIRROR"
+
+type mirrorRule struct {
+	prefix   string
+	template string
+}
+
+func parseMirrorRules(value string) ([]mirrorRule, error) {
+	var rules []mirrorRule
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		var r mirrorRule
+		if idx := strings.Index(rule, "="); idx > 0 && !strings.ContainsAny(rule[:idx], "{?") {
+			r = mirrorRule{prefix: rule[:idx], template: rule[idx+1:]}
+		} else {
+			r = mirrorRule{template: rule}
+		}
+		if _, err := url.Parse(r.template); err != nil || r.template == "" {
+			return nil, fmt.Errorf("invalid %s rule %q", DependencyMirrorEnv, rule)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// mirrorURI returns uri rewritten by the first of rules that matches it.
+func mirrorURI(uri string, dep Dependency, rules []mirrorRule) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	for _, rule := range rules {
+		if !rule.matches(u) {
+			continue
+		}
+		return strings.NewReplacer(
+			"{path}", strings.TrimPrefix(u.Path, "/"),
+			"{filename}", path.Base(u.Path),
+			"{name}", dep.Name,
+			"{version}", dep.Version,
+		).Replace(rule.template), nil
+	}
+	return uri, nil
+}
+
+// matches reports whether u starts with the rule's prefix: the same scheme
+// and host, and a path starting with the prefix's whole path segments, so
+// that https://example.com/deps matches neither https://example.com.evil.com
+// nor https://example.com/deps-old.
+func (r mirrorRule) matches(u *url.URL) bool {
+	if r.prefix == "" {
+		return true
+	}
+	prefix, err := url.Parse(r.prefix)
+	if err != nil || !strings.EqualFold(prefix.Scheme, u.Scheme) || !strings.EqualFold(prefix.Host, u.Host) {
+		return false
+	}
+	dir := strings.TrimSuffix(prefix.Path, "/")
+	return dir == "" || u.Path == dir || strings.HasPrefix(u.Path, dir+"/")
+}
+
+// dependencyURIs is the entry's uri followed by its mirrors, rewritten by
+// the rules in DependencyMirrorEnv.
+func dependencyURIs(entry *ManifestEntry) ([]string, error) {
+	uris := append([]string{entry.URI}, entry.Mirrors...)
+
+	rules, err := parseMirrorRules(os.Getenv(DependencyMirrorEnv))
+	if err != nil || len(rules) == 0 {
+		return uris, err
+	}
+	for i, uri := range uris {
+		if uris[i], err = mirrorURI(uri, entry.Dependency, rules); err != nil {
+			return nil, err
+		}
+	}
+	return uris, nil
+}
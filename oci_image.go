@@ -0,0 +1,427 @@
+package libbuildpack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexMediaType       = "application/vnd.oci.image.index.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerListMediaType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	ociTitleAnnotation = "org.opencontainers.image.title"
+)
+
+// InstallDependencyFromImage installs a dependency published to an OCI
+// registry as an image or artifact, such as "registry.example.com/deps/ruby:2.6.5"
+// or a reference pinned by "@sha256:..." digest. Each layer that is an archive
+// is extracted into outputDir in order; any other layer is written there
+// under the name in its org.opencontainers.image.title annotation. For an
+// image index the manifest for linux on this architecture is used.
+//
+// Credentials for the registry are read from the "auths" of the Docker config
+// in $DOCKER_CONFIG or ~/.docker; credential helpers are not supported.
+func (i *Installer) InstallDependencyFromImage(ref, outputDir string) error {
+	return i.InstallDependencyFromImageCtx(context.Background(), ref, outputDir)
+}
+
+// InstallDependencyFromImageCtx is InstallDependencyFromImage, giving up on
+// the pull when ctx is done.
+func (i *Installer) InstallDependencyFromImageCtx(ctx context.Context, ref, outputDir string) (err error) {
+	ctx, span := i.tracer.Start(ctx, "dependency.install_image", map[string]string{"image": ref})
+	defer func() { span.End(err) }()
+
+	i.manifest.log.BeginStep("Installing %s", ref)
+
+	image, err := parseImageRef(ref)
+	if err != nil {
+		return err
+	}
+
+	client, err := downloadClient(i.downloadOptions)
+	if err != nil {
+		return err
+	}
+	registry := &registryClient{client: client, image: image}
+	if registry.username, registry.password, err = registryCredentials(image.registry); err != nil {
+		return err
+	}
+
+	layers, err := registry.layers(ctx)
+	if err != nil {
+		return fmt.Errorf("could not pull %s: %v", ref, err)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "image")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	for n, layer := range layers {
+		tmpFile := filepath.Join(tmpDir, fmt.Sprintf("layer%d", n))
+		if err := registry.blob(ctx, layer.Digest, tmpFile); err != nil {
+			return fmt.Errorf("could not pull %s: %v", ref, err)
+		}
+
+		title := layer.Annotations[ociTitleAnnotation]
+		if title != "" && !strings.Contains(layer.MediaType, "tar") && !strings.Contains(layer.MediaType, "zip") {
+			if err := CopyFile(tmpFile, filepath.Join(outputDir, filepath.Base(title))); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ExtractArchive(tmpFile, title, outputDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type imageRef struct {
+	registry   string
+	repository string
+	reference  string
+}
+
+// parseImageRef splits an image reference the way docker does: the first
+// path element is the registry if it looks like a host, otherwise the image
+// is on Docker Hub, and a missing tag means "latest".
+func parseImageRef(ref string) (imageRef, error) {
+	image := imageRef{registry: dockerHubRegistry}
+
+	name := ref
+	if at := strings.Index(name, "@"); at >= 0 {
+		name, image.reference = name[:at], name[at+1:]
+	} else if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		name, image.reference = name[:colon], name[colon+1:]
+	} else {
+		image.reference = "latest"
+	}
+
+	if slash := strings.Index(name, "/"); slash >= 0 {
+		if host := name[:slash]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			image.registry, name = host, name[slash+1:]
+		}
+	}
+	if image.registry == "docker.io" || image.registry == "index.docker.io" {
+		image.registry = dockerHubRegistry
+	}
+	if image.registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	image.repository = name
+
+	if image.repository == "" || image.reference == "" {
+		return imageRef{}, fmt.Errorf("invalid image reference %q", ref)
+	}
+	return image, nil
+}
+
+// registryCredentials are the username and password the Docker config has
+// for registry, if any.
+func registryCredentials(registry string) (string, string, error) {
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil
+		}
+		configDir = filepath.Join(home, ".docker")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(configDir, "config.json"))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", "", fmt.Errorf("could not read Docker config: %v", err)
+	}
+
+	for key, auth := range config.Auths {
+		if registryHost(key) != registry {
+			continue
+		}
+		if auth.Auth == "" {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("could not read Docker config auth for %s: %v", key, err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("could not read Docker config auth for %s: not username:password", key)
+		}
+		return parts[0], parts[1], nil
+	}
+	return "", "", nil
+}
+
+// registryHost is the registry a Docker config auths key, such as
+// "https://index.docker.io/v1/", is for.
+func registryHost(key string) string {
+	if u, err := url.Parse(key); err == nil && u.Host != "" {
+		key = u.Host
+	}
+	key = strings.SplitN(key, "/", 2)[0]
+	if key == "docker.io" || key == "index.docker.io" {
+		return dockerHubRegistry
+	}
+	return key
+}
+
+type registryClient struct {
+	client   *http.Client
+	image    imageRef
+	username string
+	password string
+	token    string
+}
+
+type imageDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform"`
+}
+
+// layers are the layers of the image's manifest, resolving an image index
+// to the manifest for this platform.
+func (r *registryClient) layers(ctx context.Context) ([]imageDescriptor, error) {
+	reference := r.image.reference
+	for depth := 0; ; depth++ {
+		var manifest struct {
+			MediaType string            `json:"mediaType"`
+			Manifests []imageDescriptor `json:"manifests"`
+			Layers    []imageDescriptor `json:"layers"`
+		}
+		if err := r.manifest(ctx, reference, &manifest); err != nil {
+			return nil, err
+		}
+
+		if len(manifest.Manifests) == 0 {
+			if len(manifest.Layers) == 0 {
+				return nil, fmt.Errorf("manifest %s has no layers", reference)
+			}
+			return manifest.Layers, nil
+		}
+		if depth > 0 {
+			return nil, fmt.Errorf("image index %s refers to another index", reference)
+		}
+
+		platform, ok := matchingPlatform(manifest.Manifests)
+		if !ok {
+			return nil, fmt.Errorf("no manifest for linux/%s", runtime.GOARCH)
+		}
+		reference = platform.Digest
+	}
+}
+
+func matchingPlatform(manifests []imageDescriptor) (imageDescriptor, bool) {
+	for _, m := range manifests {
+		if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+			return m, true
+		}
+	}
+	// artifacts need not say which platform they are for
+	if len(manifests) == 1 && manifests[0].Platform == nil {
+		return manifests[0], true
+	}
+	return imageDescriptor{}, false
+}
+
+func (r *registryClient) manifest(ctx context.Context, reference string, obj interface{}) error {
+	resp, err := r.get(ctx, "manifests/"+reference, ociManifestMediaType, ociIndexMediaType, dockerManifestMediaType, dockerListMediaType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(reference, "sha256:") {
+		sum := sha256.Sum256(data)
+		if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != reference {
+			return fmt.Errorf("manifest digest mismatch: expected: %s actual: %s", reference, actual)
+		}
+	}
+	return json.Unmarshal(data, obj)
+}
+
+func (r *registryClient) blob(ctx context.Context, digest, destFile string) error {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] != "sha256" {
+		return fmt.Errorf("unsupported layer digest %s", digest)
+	}
+
+	resp, err := r.get(ctx, "blobs/"+digest)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := writeToFile(resp.Body, destFile, 0666); err != nil {
+		return err
+	}
+	return CheckSha256(destFile, parts[1])
+}
+
+// get requests path under the image's repository, authenticating with a
+// bearer token from the registry's token service when it asks for one.
+func (r *registryClient) get(ctx context.Context, path string, accept ...string) (*http.Response, error) {
+	scheme := "https"
+	if host := strings.Split(r.image.registry, ":")[0]; loopbackHost(host) {
+		scheme = "http"
+	}
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", scheme, r.image.registry, r.image.repository, path)
+
+	resp, err := r.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && r.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return nil, fmt.Errorf("unauthorized to pull from %s", r.image.registry)
+		}
+		if r.token, err = r.fetchToken(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = r.do(ctx, u, accept); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not get %s: %d", path, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (r *registryClient) do(ctx context.Context, u string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	} else if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	return r.client.Do(req.WithContext(ctx))
+}
+
+// loopbackHost is whether host is this machine, which registries and token
+// services may be reached on without TLS.
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// fetchToken gets a pull token from the token service named by a
+// "Bearer realm=..." challenge.
+func (r *registryClient) fetchToken(ctx context.Context, challenge string) (string, error) {
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("registry %s asked for a token without a realm", r.image.registry)
+	}
+
+	// the registry's credentials must not travel in the clear to wherever
+	// the challenge points
+	realm, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	if realm.Scheme != "https" && !(realm.Scheme == "http" && loopbackHost(realm.Hostname())) {
+		return "", fmt.Errorf("registry %s asked for a token from %s, which is not https", r.image.registry, RedactURIs(params["realm"]))
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", r.image.repository)
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequest("GET", params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("could not get a token for %s: %d", r.image.registry, resp.StatusCode)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
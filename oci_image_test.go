@@ -0,0 +1,218 @@
+package libbuildpack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	"github.com/cloudfoundry/libbuildpack/ansicleaner"
+	httpmock "github.com/jarcoal/httpmock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstallDependencyFromImage", func() {
+	const registry = "https://registry.example.com/v2/deps/ruby"
+
+	var (
+		installer    *libbuildpack.Installer
+		outputDir    string
+		configDir    string
+		oldConfigDir string
+		layer        []byte
+		manifest     []byte
+		err          error
+	)
+
+	digest := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	tgz := func(name, content string) []byte {
+		buf := new(bytes.Buffer)
+		gw := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gw)
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(content))})).To(Succeed())
+		_, err := tw.Write([]byte(content))
+		Expect(err).To(BeNil())
+		Expect(tw.Close()).To(Succeed())
+		Expect(gw.Close()).To(Succeed())
+		return buf.Bytes()
+	}
+
+	imageManifest := func(layers ...map[string]interface{}) []byte {
+		data, err := json.Marshal(map[string]interface{}{
+			"schemaVersion": 2,
+			"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+			"layers":        layers,
+		})
+		Expect(err).To(BeNil())
+		return data
+	}
+
+	BeforeEach(func() {
+		httpmock.Reset()
+
+		outputDir, err = ioutil.TempDir("", "image")
+		Expect(err).To(BeNil())
+		configDir, err = ioutil.TempDir("", "docker")
+		Expect(err).To(BeNil())
+		oldConfigDir = os.Getenv("DOCKER_CONFIG")
+		os.Setenv("DOCKER_CONFIG", configDir)
+
+		layer = tgz("bin/ruby", "ruby binary")
+		manifest = imageManifest(map[string]interface{}{
+			"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			"digest":    digest(layer),
+		})
+		httpmock.RegisterResponder("GET", registry+"/blobs/"+digest(layer), httpmock.NewBytesResponder(200, layer))
+
+		logger := libbuildpack.NewLogger(ansicleaner.New(new(bytes.Buffer)))
+		m, err := libbuildpack.NewManifest("fixtures/manifest/standard", logger, time.Now())
+		Expect(err).To(BeNil())
+		installer = libbuildpack.NewInstaller(m)
+	})
+
+	AfterEach(func() {
+		os.Setenv("DOCKER_CONFIG", oldConfigDir)
+		Expect(os.RemoveAll(outputDir)).To(Succeed())
+		Expect(os.RemoveAll(configDir)).To(Succeed())
+	})
+
+	It("extracts the layers of a tagged image", func() {
+		httpmock.RegisterResponder("GET", registry+"/manifests/2.6.5", func(req *http.Request) (*http.Response, error) {
+			Expect(req.Header.Get("Accept")).To(ContainSubstring("application/vnd.oci.image.manifest.v1+json"))
+			return httpmock.NewBytesResponse(200, manifest), nil
+		})
+
+		Expect(installer.InstallDependencyFromImage("registry.example.com/deps/ruby:2.6.5", outputDir)).To(Succeed())
+		Expect(ioutil.ReadFile(filepath.Join(outputDir, "bin", "ruby"))).To(Equal([]byte("ruby binary")))
+	})
+
+	It("pulls images pinned by digest", func() {
+		httpmock.RegisterResponder("GET", registry+"/manifests/"+digest(manifest), httpmock.NewBytesResponder(200, manifest))
+
+		Expect(installer.InstallDependencyFromImage("registry.example.com/deps/ruby@"+digest(manifest), outputDir)).To(Succeed())
+		Expect(filepath.Join(outputDir, "bin", "ruby")).To(BeAnExistingFile())
+	})
+
+	It("rejects a manifest that does not match its digest", func() {
+		pinned := digest([]byte("other manifest"))
+		httpmock.RegisterResponder("GET", registry+"/manifests/"+pinned, httpmock.NewBytesResponder(200, manifest))
+
+		err = installer.InstallDependencyFromImage("registry.example.com/deps/ruby@"+pinned, outputDir)
+		Expect(err).To(MatchError(ContainSubstring("manifest digest mismatch")))
+	})
+
+	It("rejects a layer that does not match its digest", func() {
+		httpmock.RegisterResponder("GET", registry+"/manifests/latest", httpmock.NewBytesResponder(200, manifest))
+		httpmock.RegisterResponder("GET", registry+"/blobs/"+digest(layer), httpmock.NewBytesResponder(200, tgz("bin/ruby", "tampered")))
+
+		err = installer.InstallDependencyFromImage("registry.example.com/deps/ruby", outputDir)
+		Expect(err).To(MatchError(ContainSubstring("dependency sha256 mismatch")))
+	})
+
+	It("writes artifact layers under their title", func() {
+		binary := []byte("#!/bin/sh\necho hi\n")
+		artifact := imageManifest(map[string]interface{}{
+			"mediaType":   "application/octet-stream",
+			"digest":      digest(binary),
+			"annotations": map[string]string{"org.opencontainers.image.title": "hello.sh"},
+		})
+		httpmock.RegisterResponder("GET", registry+"/manifests/1.0.0", httpmock.NewBytesResponder(200, artifact))
+		httpmock.RegisterResponder("GET", registry+"/blobs/"+digest(binary), httpmock.NewBytesResponder(200, binary))
+
+		Expect(installer.InstallDependencyFromImage("registry.example.com/deps/ruby:1.0.0", outputDir)).To(Succeed())
+		Expect(ioutil.ReadFile(filepath.Join(outputDir, "hello.sh"))).To(Equal(binary))
+	})
+
+	It("uses the manifest for this platform from an image index", func() {
+		index, err := json.Marshal(map[string]interface{}{
+			"schemaVersion": 2,
+			"mediaType":     "application/vnd.oci.image.index.v1+json",
+			"manifests": []map[string]interface{}{
+				{"digest": "sha256:" + hex.EncodeToString(make([]byte, 32)), "platform": map[string]string{"os": "windows", "architecture": "amd64"}},
+				{"digest": digest(manifest), "platform": map[string]string{"os": "linux", "architecture": runtime.GOARCH}},
+			},
+		})
+		Expect(err).To(BeNil())
+		httpmock.RegisterResponder("GET", registry+"/manifests/2.6.5", httpmock.NewBytesResponder(200, index))
+		httpmock.RegisterResponder("GET", registry+"/manifests/"+digest(manifest), httpmock.NewBytesResponder(200, manifest))
+
+		Expect(installer.InstallDependencyFromImage("registry.example.com/deps/ruby:2.6.5", outputDir)).To(Succeed())
+		Expect(filepath.Join(outputDir, "bin", "ruby")).To(BeAnExistingFile())
+	})
+
+	Context("when the registry needs a token", func() {
+		BeforeEach(func() {
+			config := fmt.Sprintf(`{"auths": {"registry.example.com": {"auth": %q}}}`, "dXNlcjpwYXNz") // user:pass
+			Expect(ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0600)).To(Succeed())
+
+			httpmock.RegisterResponder("GET", "https://auth.example.com/token", func(req *http.Request) (*http.Response, error) {
+				user, pass, ok := req.BasicAuth()
+				if !ok || user != "user" || pass != "pass" {
+					return httpmock.NewStringResponse(401, ""), nil
+				}
+				Expect(req.URL.Query().Get("service")).To(Equal("registry.example.com"))
+				Expect(req.URL.Query().Get("scope")).To(Equal("repository:deps/ruby:pull"))
+				return httpmock.NewStringResponse(200, `{"token": "pull-token"}`), nil
+			})
+			httpmock.RegisterResponder("GET", registry+"/manifests/2.6.5", func(req *http.Request) (*http.Response, error) {
+				if req.Header.Get("Authorization") != "Bearer pull-token" {
+					resp := httpmock.NewStringResponse(401, "")
+					resp.Header.Set("WWW-Authenticate", `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:deps/ruby:pull"`)
+					return resp, nil
+				}
+				return httpmock.NewBytesResponse(200, manifest), nil
+			})
+		})
+
+		It("gets one with the Docker config credentials", func() {
+			Expect(installer.InstallDependencyFromImage("registry.example.com/deps/ruby:2.6.5", outputDir)).To(Succeed())
+			Expect(filepath.Join(outputDir, "bin", "ruby")).To(BeAnExistingFile())
+		})
+
+		It("fails without credentials", func() {
+			Expect(os.Remove(filepath.Join(configDir, "config.json"))).To(Succeed())
+
+			err = installer.InstallDependencyFromImage("registry.example.com/deps/ruby:2.6.5", outputDir)
+			Expect(err).To(MatchError("could not pull registry.example.com/deps/ruby:2.6.5: could not get a token for registry.example.com: 401"))
+		})
+
+		It("refuses to send the credentials to a token service without https", func() {
+			httpmock.RegisterResponder("GET", "http://auth.example.com/token", func(req *http.Request) (*http.Response, error) {
+				defer GinkgoRecover()
+				Fail("the credentials were sent over http")
+				return nil, nil
+			})
+			httpmock.RegisterResponder("GET", registry+"/manifests/2.6.5", func(req *http.Request) (*http.Response, error) {
+				resp := httpmock.NewStringResponse(401, "")
+				resp.Header.Set("WWW-Authenticate", `Bearer realm="http://auth.example.com/token",service="registry.example.com"`)
+				return resp, nil
+			})
+
+			err = installer.InstallDependencyFromImage("registry.example.com/deps/ruby:2.6.5", outputDir)
+			Expect(err).To(MatchError("could not pull registry.example.com/deps/ruby:2.6.5: registry registry.example.com asked for a token from http://auth.example.com/token, which is not https"))
+		})
+	})
+
+	It("reports missing images", func() {
+		httpmock.RegisterResponder("GET", registry+"/manifests/9.9.9", httpmock.NewStringResponder(404, ""))
+
+		err = installer.InstallDependencyFromImage("registry.example.com/deps/ruby:9.9.9", outputDir)
+		Expect(err).To(MatchError("could not pull registry.example.com/deps/ruby:9.9.9: could not get manifests/9.9.9: 404"))
+	})
+})
@@ -0,0 +1,110 @@
+package packager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// walkArtifact calls fn with the name, size and contents of every regular
+// file in a packaged buildpack, which may be a zip or a tar.zst.
+func walkArtifact(artifact string, fn func(name string, size int64, r io.Reader) error) error {
+	isTarZst, err := hasTarZstMagic(artifact)
+	if err != nil {
+		return err
+	}
+	if isTarZst {
+		return walkTarZst(artifact, fn)
+	}
+
+	r, err := zip.OpenReader(artifact)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(f.Name, int64(f.UncompressedSize64), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTarZst(artifact string, fn func(name string, size int64, r io.Reader) error) error {
+	fh, err := os.Open(artifact)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("zstd", "--decompress", "--stdout")
+	cmd.Stdin = fh
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not run zstd, is it installed? %v", err)
+	}
+
+	walkErr := walkTar(stdout, fn)
+	// drain whatever fn did not read so zstd can exit
+	io.Copy(ioutil.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("zstd failed: %v %s", err, stderr.String())
+	}
+	return walkErr
+}
+
+func walkTar(src io.Reader, fn func(name string, size int64, r io.Reader) error) error {
+	tr := tar.NewReader(src)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header.Name, header.Size, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func hasTarZstMagic(artifact string) (bool, error) {
+	fh, err := os.Open(artifact)
+	if err != nil {
+		return false, err
+	}
+	defer fh.Close()
+
+	header := make([]byte, len(zstdMagic))
+	if _, err := io.ReadFull(fh, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return bytes.Equal(header, zstdMagic), nil
+}
@@ -308,7 +308,7 @@ func binFinalize() (*asset, error) {
 	return a, nil
 }
 
-var _binRelease = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x2c\x8f\x31\x6b\xfb\x30\x10\x47\x77\x7f\x8a\xdf\x3f\x06\x67\x32\xe6\xdf\x31\x94\x2c\x85\xce\x1d\x3a\x06\xc2\x49\x77\xae\x44\x6d\x49\xe8\x4e\x49\xfc\xed\x8b\xd3\x8e\x6f\x78\xf0\x5e\xff\x6f\x6a\x5a\x27\x17\xd3\x24\xe9\x06\x47\x1a\xba\x1e\x3b\x56\x59\x84\x54\xf0\xea\x5a\x5c\x78\xe4\x58\xcf\x5d\x8f\xcf\x10\x15\xea\x6b\x2c\x86\x52\xf3\x2d\xb2\x28\x66\x11\x76\xe4\xbf\xb1\x8a\x11\x93\x11\x2c\xe3\x6d\xc9\x8d\xf1\x9e\x5b\xe2\xba\x21\x26\x8e\x9e\x2c\xa6\x2f\x84\x7c\x87\x05\x01\x95\x02\x0d\xb9\x2d\x0c\x27\x90\x87\xf8\x66\xc2\x5d\x27\x3e\x64\x8c\x82\xc3\x38\x8e\x97\xc4\x32\x53\x5b\xec\x5a\x6a\xf6\xa2\x7a\xb5\xad\x88\x9e\x2e\x09\xb8\x8b\x3b\xe1\x78\x1e\x5e\xf0\x34\x3e\x7e\x73\xb5\x88\x8f\xf3\x06\xc2\x9f\x09\x35\xaa\x06\x9f\xd7\x95\x12\x63\xce\x15\xb6\x4f\x3c\xb7\xca\x5e\x3d\x0c\x90\x47\x34\xfc\x3f\x1e\xba\x9f\x00\x00\x00\xff\xff\x03\x76\xa7\xd0\x10\x01\x00\x00")
+var _binRelease = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x5c\x90\x41\xab\x1a\x31\x14\x85\xf7\xf9\x15\xa7\xa3\xe8\x2a\x1d\xec\xd2\xb6\x82\x94\xd6\x4d\x29\x5d\xb4\xab\x5a\x24\x93\xdc\xbc\x09\x66\x92\x90\xdc\xa8\x83\xf8\xdf\x1f\xe3\x93\xc7\xe3\x2d\x2f\xf7\x7c\xf7\x7e\x9c\xd9\x87\xb6\x96\xdc\x76\x2e\xb4\x14\x4e\xe8\x54\xe9\xc5\x0c\xd3\x98\xc9\x93\x2a\x84\x2f\x5d\x75\xde\x48\xe3\xf2\x46\xcc\xf0\xa7\x77\x05\x45\x67\x97\x18\x29\xc7\x93\x33\x54\x60\x89\x4c\xa7\xf4\x11\x03\xb1\x32\x8a\x15\x38\xe2\x9b\x8f\xd5\xe0\x47\xac\xc1\xe4\x11\x2e\x18\xa7\x15\xbb\xf0\x84\x3e\x9e\xc1\x3d\x41\xa5\x84\xd2\xc7\xea\x0d\x3a\x02\x5d\x48\x57\x26\x23\x44\x21\x86\xa4\x1a\x91\x5c\x22\xab\x9c\x17\xe2\xe1\x72\x28\x4c\xe9\x6b\x33\x5f\xb5\x3c\xa4\xf6\x7a\xfd\xb9\xfd\xb5\xfb\xbb\xdd\x7d\xbf\xdd\xe4\x5d\x32\x29\x7d\x94\x8f\xac\x9c\xb2\x1f\xc7\xc1\x37\xc2\x59\xfc\x83\xb4\x68\xe6\x6f\xef\x34\xf8\xff\x79\xf2\x08\x02\xd0\x8a\xdf\x6f\x05\xf9\x42\x02\x20\xdd\x47\x48\x42\x23\xa5\xdc\x07\x43\x56\x55\xcf\x87\x94\xa3\xa6\x52\x0e\x3c\x26\x2a\xeb\x7d\x00\xce\xd4\xad\xb1\xdc\x2c\x3e\xbd\x10\xbf\xef\x8f\x50\x12\x69\x67\x47\x28\x3c\x48\x14\x56\x99\xa1\xe3\x30\xa8\x60\x60\x63\x06\x4f\x95\xbe\xfa\x63\xb1\x00\x5d\x1c\x63\xb5\x6c\x84\x75\xe2\x79\x00\xd9\xf9\xa7\x3a\xa1\x01\x00\x00")
 
 func binReleaseBytes() ([]byte, error) {
 	return bindataRead(
@@ -323,7 +323,7 @@ func binRelease() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "bin/release", size: 272, mode: os.FileMode(493), modTime: time.Unix(1526054508, 0)}
+	info := bindataFileInfo{name: "bin/release", size: 417, mode: os.FileMode(509), modTime: time.Unix(1792085753, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -1888,7 +1888,7 @@ func srcLanguageFinalizeCli_cli_suite_testGo() (*asset, error) {
 	return a, nil
 }
 
-var _srcLanguageFinalizeCli_mainGo = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x93\xd1\x6b\xdb\x30\x10\xc6\x9f\xa5\xbf\xe2\x66\xd8\xb0\xa1\xa8\xcb\x36\x18\xf3\xc8\x43\xd6\x66\x79\x49\x33\x68\xe8\xd3\x18\x43\xb1\xcf\x8e\xa8\x2c\x19\x49\x4e\x96\x85\xfc\xef\x43\x8e\xe2\xc4\x6b\xd7\x04\xf6\x12\x82\x74\x77\xdf\xef\xbb\xcf\xaa\x79\xf6\xc8\x4b\x84\x8a\x0b\x45\xa9\xa8\x6a\x6d\x1c\xc4\x94\x44\xdb\xed\x74\x34\x9b\x3c\x8c\x26\xe3\xdd\xee\xba\x10\x8a\x4b\xf1\x1b\x23\x4a\x7e\x42\xff\x6a\xa9\xf5\xa3\x8d\x28\x89\x74\xfb\xeb\x44\x85\x11\xa5\x24\x2a\x85\x5b\x36\x0b\x96\xe9\xea\x3a\x93\xba\xc9\x0b\xdd\xa8\xdc\x6c\xae\xa5\x58\x2c\x1a\x21\x73\x2f\x1c\xd1\x84\xd2\xa2\x51\x59\x2b\x1f\x27\xb0\xa5\x44\xea\xb2\x44\x03\xe9\x10\x4e\x2b\xd9\x0c\xd7\xd3\xf6\x26\xd6\x96\xcd\x5d\xae\x1b\x97\x50\x4a\xba\x82\x5b\x61\xae\x00\xcd\xd3\xc6\x09\xba\x2f\x27\x45\x71\x42\x89\x28\xda\xca\x57\x43\x50\x42\x7a\xcd\x20\xca\xc6\xc6\x68\x13\x47\x0f\x8a\x2f\x24\x82\xd3\x90\xa3\x43\x53\x09\x85\xd0\x0d\x84\x5c\x18\xcc\x9c\x36\x9b\x14\x5e\xdb\xa8\x15\x0d\x8d\x49\x42\x09\xd1\x96\x8d\x7f\x09\x17\x7f\x4a\x28\xd9\x51\x4a\x2a\xae\x44\x81\xd6\x3d\x4f\x37\xc3\xf5\x5d\x28\x88\xfb\x5e\xf6\x48\x57\xe0\x17\xca\x66\x7a\xdd\x4e\xbf\x9c\x5c\x6a\x9e\x9f\x40\x1f\x28\x5e\x66\x1e\xbc\x0d\xd0\xd6\xf1\x7f\x84\x30\x6f\x6f\x7c\x08\x23\x53\xda\xef\x83\xf4\xc7\x11\xf5\x20\xe2\x83\x09\xa4\xc3\xee\x90\x8d\xea\x5a\x6e\xbe\xad\xd0\x18\x91\x63\xbc\x57\x60\xb7\x58\xdb\x36\x95\xe4\xf3\xa5\xce\xb8\x9f\x03\x3a\x0c\x62\x9b\x4a\x42\x21\x24\xda\xa3\xb5\x9e\xa5\x8f\xc1\x52\x00\x4a\x87\x10\xa4\xe7\xe8\xbc\x19\xa1\xca\xb1\x5a\x09\xa3\x55\x85\xca\xc5\x97\x73\x58\x74\x4d\x0d\x78\xec\x85\x15\x37\xc2\xdf\xda\x33\x5b\x1e\x04\xa4\xc2\xd3\x1c\x9e\x16\xfb\x1a\xfe\x18\x2f\x7a\xd7\xe5\xd5\x7d\x3f\x94\x90\xfd\xf2\x53\x80\xe0\xc1\x9f\xdd\xe8\xaa\xe2\x2a\x4f\x01\xde\xf4\xb2\x0a\xe7\xdb\x9d\x2f\x9a\xea\xd2\x77\x01\x1c\xb2\xfa\x7b\x27\x05\xbb\x6f\xd4\x33\xe6\x3b\xe6\x77\x4f\xd6\xd8\x53\xbb\x6f\xd4\xa8\x70\x68\x6e\x74\x55\x0b\x79\x88\xf7\xdc\x32\xdb\x16\x08\x3d\x67\x96\xf6\xfe\x85\x1c\xa7\xbc\x51\xd9\xf2\x7f\x62\x94\xed\x84\xd3\x34\xcf\xe0\x7c\xe8\xbd\x14\x16\xbe\x24\x6f\x45\xa2\xc3\x38\xa1\x3b\xfa\x27\x00\x00\xff\xff\xca\x83\xa0\x7f\x5b\x05\x00\x00")
+var _srcLanguageFinalizeCli_mainGo = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x95\x4d\x6f\xe3\x36\x10\x86\xcf\xe4\xaf\x98\x15\xb0\x85\x54\x18\x4c\xd2\x4f\xd4\x85\x0f\x6e\xe2\xcd\xc5\x9b\x16\xeb\xee\xa9\x28\x0a\x5a\x1a\xd9\x44\xf8\x21\x0c\x29\x67\xd3\xc0\xff\xbd\x20\x25\xd9\x4e\x56\x6e\x37\xe8\x21\x39\x70\x66\xde\x79\xe6\x9d\x51\xd2\xc8\xf2\x5e\x6e\x10\x8c\x54\x96\x73\x65\x1a\x47\x01\x72\xce\xb2\xda\x84\x8c\xb3\xec\xe9\x69\x39\xbf\xbb\xfd\x38\xbf\x5d\xec\xf7\x17\xb5\xb2\x52\xab\xbf\x31\xe3\xec\x2f\x78\x1e\xda\x3a\x77\xef\x63\x81\x4b\xbf\x83\x32\x98\x71\xce\xb2\x8d\x0a\xdb\x76\x2d\x4a\x67\x2e\x4a\xed\xda\xaa\x76\xad\xad\xe8\xf1\x42\xab\xf5\xba\x55\xba\x8a\xfd\x33\x5e\x70\x5e\xb7\xb6\x4c\x14\x79\x01\x4f\x9c\x69\xb7\xd9\x20\xc1\x74\x06\xa7\x99\xe2\x0e\x1f\x96\x29\x92\x3b\x2f\x56\xa1\x72\x6d\x28\x38\x67\xcd\x56\x7a\x1c\x4b\xfe\x9d\x64\x89\x94\x17\x62\x15\x24\x85\xdf\x62\x5a\x9e\x1d\xc6\x98\x80\x55\xba\xe0\xcc\x60\x20\x55\xfa\x31\x81\xf7\x5d\x28\x2f\xfa\x26\x37\xce\xa6\x46\x7d\x89\x78\x29\x59\x70\x56\xba\x2a\xa5\x50\x6b\xdf\xf5\xcf\x79\x37\xce\xa9\x48\x7e\xec\x2b\xae\xb5\xf3\x98\xc7\x41\x76\x92\x00\x29\xfd\x38\xe2\x4c\xd5\x90\xd4\xde\xcc\xe0\x32\xba\xc2\x62\x6c\x06\xb5\x09\x62\x41\xe4\xa8\x3e\x76\x06\xfc\xa4\x02\x56\xf0\xa0\xc2\x16\x7c\x90\xa1\xf5\xf0\xb6\xca\x26\x49\xa0\xe0\x6c\xdf\xf7\x16\x0b\x5b\xe5\x48\x54\x70\xe6\xbc\x58\x7c\x52\x21\xef\x32\xf6\xfd\x0e\x3e\xc7\x86\xaf\x9f\xb9\xd2\x2d\xa0\x00\x65\x43\x64\x3a\x04\x6e\x14\x4d\x22\xf8\x67\x36\xde\x62\xf8\xe5\x24\x29\x4e\xae\xea\x94\xf9\x66\x16\x37\x10\x55\xfa\x85\x77\x63\xe5\xd9\x47\x2b\xd7\x1a\x21\x38\xa8\x30\x20\x19\x65\x11\x0e\x82\x50\x29\xc2\x32\x38\x7a\x9c\xc2\x5b\x9f\xa5\xa6\x05\x67\x8c\x30\xb4\x64\xe1\xa7\x38\x2c\x67\x46\x5a\x55\xa3\x0f\xe3\x4c\x77\xf8\xf0\xbe\x4f\xc8\x0f\xaf\x69\x82\x0e\x64\x02\xf1\x84\xc5\x9d\x7b\xc8\x8b\x57\xf1\x6a\x27\xab\x13\xd4\x81\x62\x94\xf4\xea\xb2\x43\xf5\x41\x9e\x39\xf6\x55\x8a\xc4\x63\x9f\xd3\xc6\xff\x71\x35\xfd\xf3\x08\x38\x48\x17\x9c\xb5\x56\xbb\xf2\xfe\x06\x9b\xd3\x1d\x74\xaa\x62\x79\x88\xbc\xce\xf8\x52\x4b\x65\xa0\xc2\xc6\x47\xbf\xc7\xf9\xbf\x8f\xfc\xac\xc2\x1a\x09\x4e\x19\xd2\x2d\xf7\xad\x66\x07\x50\x31\x6f\x1a\xfd\xf8\xeb\x0e\x89\x54\x85\x79\xcf\x77\x83\x8d\x4f\x25\xc5\xcf\x5f\x8a\x26\xa3\x0e\xb8\x5e\x48\x3c\x1a\x0d\xb5\xd2\xe8\xc7\x21\x7f\x8c\x90\x07\x9c\xa3\x31\x2b\x0c\xd1\x5e\x65\x37\x0b\xbb\x53\xe4\xac\x41\x1b\xf2\x2f\xa7\xf0\x18\xda\x06\xf0\x58\x0b\x3b\x49\x4a\xae\xcf\x82\x5c\x75\x20\x75\x5c\xce\xf0\xd9\x8a\xe1\x5b\xa3\xd8\x6a\x38\xc9\xe9\xc1\xb3\x09\x67\x2c\x52\x22\x4d\x01\x7a\xf2\xf8\x76\xed\x8c\x91\xb6\x9a\x02\x7c\xf5\xec\x66\xfa\xf7\xa7\x7d\x4c\x5a\xba\x4d\xac\x02\x18\x6e\xe6\xa5\x13\xb5\xf8\xd0\xda\x91\x91\x07\xe2\x6f\x5e\x16\x3c\xeb\xf5\xa1\xb5\xf3\x3a\x20\x5d\x3b\xd3\x28\x3d\x2c\xf4\xbf\x0c\x4c\x25\xd0\xd7\x8c\x1b\xf5\xed\xf9\x8d\x2d\x65\x6b\xcb\xed\xff\x59\x98\x4e\x0a\xa7\x7b\x1b\x87\xf8\xee\x1c\x44\x49\xed\x7a\x85\xd6\xab\xa0\x76\xf8\x2e\xde\xdd\x2b\x18\x08\x8d\xdb\x21\xf8\xa1\xfe\xdf\x0e\xf7\x87\xd3\xbf\x0e\xa2\xbf\xd5\x68\x9c\xc6\x10\xff\x5d\x0c\x89\x97\x7c\xcf\xff\x19\x00\xa6\xf8\x2b\x58\xc8\x07\x00\x00")
 
 func srcLanguageFinalizeCli_mainGoBytes() ([]byte, error) {
 	return bindataRead(
@@ -1903,7 +1903,7 @@ func srcLanguageFinalizeCli_mainGo() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "src/LANGUAGE/finalize/cli/_main.go", size: 1371, mode: os.FileMode(420), modTime: time.Unix(1526054508, 0)}
+	info := bindataFileInfo{name: "src/LANGUAGE/finalize/cli/_main.go", size: 1992, mode: os.FileMode(436), modTime: time.Unix(1792098762, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -2068,7 +2068,7 @@ func srcLanguageSupplyCli_cli_suite_testGo() (*asset, error) {
 	return a, nil
 }
 
-var _srcLanguageSupplyCli_mainGo = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x55\x61\x6f\xe2\x46\x10\xfd\xec\xfd\x15\x53\x4b\xad\x6c\x09\x99\xd0\x36\x4d\x4b\xc5\x07\x42\x50\xd4\x2a\xe1\xa4\x43\xb9\xd3\xe9\x74\x3a\x2d\xf6\x60\x46\xac\x77\xad\xf5\x3a\x1c\x42\xfc\xf7\xd3\x9a\xb5\x0d\x09\x1c\xe4\x3e\x39\xd9\x9d\x37\xfb\x66\xe6\xcd\x23\xe7\xf1\x92\xa7\x08\x19\x27\xc9\x18\x65\xb9\xd2\x06\x02\xe6\x7d\x05\x7f\xb3\x79\x18\x4e\xee\x9f\x86\xf7\xe3\xed\xb6\xbb\x50\x6a\x59\xf8\xcc\x3b\x3c\x2d\xca\x3c\x17\x6b\x7b\xac\xaa\xcb\x9c\x9b\x45\x77\x4e\x02\xed\x1f\xf6\xc0\x50\x86\x3e\x63\x9e\x9f\x92\x59\x94\xb3\x28\x56\x59\x37\x16\xaa\x4c\xe6\xaa\x94\x89\x5e\x77\x05\xcd\x66\x25\x89\xc4\xf2\xf0\x59\xc8\xd8\xbc\x94\x71\xc5\x26\x08\x61\xc3\x3c\xa1\xd2\x14\x35\xf4\x07\xb0\x1f\x19\x4d\x70\xf5\x50\xdd\x04\xaa\x88\xa6\x26\x51\xa5\x09\x19\xf3\x9a\x80\x3b\xd2\x1d\x40\xfd\x1a\x78\x8f\xe6\x76\x2f\x28\x08\x99\x47\xf3\x2a\xf2\x97\x01\x48\x12\xf6\x4d\xf7\x68\x34\xd6\x5a\xe9\xc0\x7f\x92\x7c\x26\x10\x8c\x82\x04\x0d\xea\x8c\x24\x42\x93\x10\x12\xd2\x18\x1b\xa5\xd7\x7d\xf8\xb5\xf0\xab\x47\x1d\x30\x0c\x99\xe7\xa9\x22\x1a\x7f\x23\x13\xfc\x13\x32\x6f\xcb\x98\x97\x71\x49\x73\x2c\xcc\x71\x76\x13\x5c\x3d\xba\x80\xe0\xb0\x96\x1d\xa5\x0e\xd8\x86\x46\x13\xb5\xaa\xb2\x5f\xce\x5c\x28\x9e\xec\x91\xae\x59\xfc\x98\x73\xef\xaa\x22\xed\x91\x2c\x0c\x17\xe2\xf8\x18\xfe\xab\x2f\x83\x3a\xa9\x1d\x44\x61\xf8\x89\xb1\x4d\xab\x1b\x3b\xb6\xa1\x4e\x8b\xcf\xbd\xfe\x97\xb6\xb8\x36\x43\x5d\x5a\x7f\x00\xbb\x54\xd1\x68\x81\xf1\xb2\x19\xdd\x07\x2e\x28\x09\xc2\x7f\x5f\xd6\xdf\x50\xef\xb9\x7e\xab\x22\x7a\x5c\x26\xa4\x87\x42\x04\xb5\x30\xa3\xff\x15\xc9\xc0\xe5\xbd\xc3\xbc\xd2\x41\x07\xfc\x19\x49\x3f\xec\xc0\xd5\xcd\xf5\x75\xf8\x36\xa4\xa0\x59\x8b\x6c\xc8\x0f\xa0\xe9\x5c\x34\x45\x33\xcc\xf3\x11\x8f\x17\x68\x41\x75\x51\xf5\xff\xe1\xeb\x52\x4e\x8d\xb2\x40\x53\xe6\xc0\xf3\x1c\x62\x8b\xb6\x02\x6c\xc7\x78\x30\xbe\xbf\xdd\xf8\x6a\x36\x75\x7b\xa3\xa1\xdd\xda\x77\xcf\xa8\x35\x25\xb8\x57\x4e\xf1\x46\x2a\xdc\xe6\x01\xe5\x12\x45\xeb\x4c\x80\xed\x54\x71\x82\xcf\x8d\x9b\xc9\x8e\xcd\x81\x30\xde\x97\xf2\x16\xe7\x4a\xe3\x48\x65\x39\x89\x9a\xd4\x05\x1a\xdf\xc1\xc0\xe1\xce\x08\xfa\x77\xc7\xa0\x55\xd7\xcf\xea\xe3\xe2\x1e\xc5\x1a\xb9\x41\x98\x91\xbc\xd4\x2a\x7a\x7f\x1c\xf4\xc9\xf1\x98\xa2\xb1\xab\x43\x32\x1d\xcb\x67\xd2\x4a\x66\x28\xcd\xdb\xec\x6b\xa7\x1c\x6c\xe1\xf0\xcc\x35\xd9\xdb\xe2\x0c\xa3\x3f\x1d\xa3\xa2\x5a\xc8\xca\xf4\xa3\xa9\xfd\x10\x6a\xfb\xe2\x63\xe3\x27\x8d\xbf\x31\xcf\x6b\xac\xa1\xdf\x2e\x82\x3d\xdf\x59\x40\x1f\xc0\xd5\x66\xcf\x46\x2a\xcb\xb8\x4c\xfa\x00\xbf\x1d\x08\xc3\x9d\x6f\xb6\x36\xe8\x41\xa5\x16\x05\x50\x3b\xc6\x41\x9f\xac\x88\x2e\x69\x48\xf5\x39\x21\xd1\xeb\x57\x02\x71\xed\xff\xa8\xc9\xe0\x48\xc9\x39\xa5\x9f\x32\x11\x48\x12\xe7\x24\x50\x7d\x60\xa5\xc9\x90\x4c\x21\xae\xa0\x76\x45\xce\xb4\xfa\xaf\x17\x4b\xdb\x5a\xc8\x48\x20\x97\x65\x5e\xdb\xc8\x11\xf7\x3b\x3a\xf8\xd8\xc2\x60\xdf\x32\x4e\xd4\xbe\xfb\x89\xda\xb2\xef\x01\x00\x00\xff\xff\x4a\x45\x49\xf7\x13\x08\x00\x00")
+var _srcLanguageSupplyCli_mainGo = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x55\x6d\x6f\xdb\x36\x10\xfe\x2c\xfe\x8a\xab\x80\x0e\xd4\x60\x28\x71\xb7\xac\x9b\x0b\x7f\x70\x1d\x23\xd8\x90\x64\x43\xbd\x6e\x18\x86\x61\xa0\xa5\x93\x4d\x98\x22\x05\x8a\x8a\x6b\x04\xfe\xef\xc3\x51\x2f\x96\x33\x05\x89\xfb\xc1\xb0\xcd\x7b\x79\x9e\x7b\xee\x78\x2c\x44\xb2\x15\x6b\x84\x5c\x48\xcd\x98\xcc\x0b\x63\x1d\x70\x16\x84\x59\xee\x42\x16\xfc\x0b\xe1\xe3\xe3\xed\xec\xfe\xe6\xf3\xec\x66\x71\x38\x5c\x6c\x8c\xd9\x96\x21\x0b\x4e\x4f\xcb\xaa\x28\xd4\x9e\x8e\x8d\x37\x16\xc2\x6d\x2e\x32\xa9\x90\x7e\xd0\x81\x93\x39\x86\x8c\x05\xe1\x5a\xba\x4d\xb5\x8a\x13\x93\x5f\x24\xca\x54\x69\x66\x2a\x9d\xda\xfd\x85\x92\xab\x55\x25\x55\x4a\x74\x42\x16\x31\x96\x55\x3a\xf1\xa4\x78\x04\x8f\x2c\x50\x66\xbd\x46\x0b\x93\x29\xf4\x3d\xe3\x7b\xdc\xdd\x7a\x0b\x37\x65\xbc\x74\xa9\xa9\x5c\xc4\x58\x50\x6c\x44\x89\x43\xce\xbf\x5b\x91\xa0\xe5\x51\xbc\x74\xc2\xba\xdf\xc8\x8d\x87\x0d\xfb\x11\x68\xa9\x22\x16\xe4\xe8\xac\x4c\xca\xa1\xf0\xbb\xda\xc4\xa3\x06\xe2\xda\x68\x0f\xd3\x84\xc4\xa7\x09\x23\x16\x24\x26\xf5\x0e\xb6\xd2\x4b\x7f\xc8\xeb\x42\xfa\x09\xf8\x11\x33\x9e\x2b\x53\x22\xa7\x12\x1e\x84\x05\xb4\xfe\x63\x2c\x0b\x64\x06\x3e\xd7\x9b\x29\x5c\x92\x1e\x01\xd9\xa6\x90\xe5\x2e\x5e\x58\x6b\x6c\xd6\xa2\x02\x7e\x91\x0e\x53\xd8\x49\xb7\x81\xd2\x09\x57\x95\xf0\x36\x0d\x47\x3e\x3c\x62\xc1\xa1\x41\x8e\x17\x3a\xe5\x68\x6d\xc4\x02\x53\xc6\x8b\x2f\xd2\xf1\xda\xe3\xd0\x68\xff\x94\x32\x7c\x7b\xa2\x46\x2d\x7b\x04\x52\x3b\xe2\xd3\x19\xae\xa5\x1d\x11\xe9\xff\xc9\x77\x83\xee\x63\xcf\x89\xaa\x96\x99\xf7\x7c\x33\x25\xe5\x29\x4b\xd3\xe6\xba\x24\x1e\x7e\xd6\x62\xa5\x10\x9c\x81\x14\x1d\xda\x5c\x6a\x84\x2e\x21\xa4\xd2\x62\xe2\x8c\xdd\x4f\xe0\x6d\x19\x7a\xd0\x88\x05\x81\x45\x57\x59\x0d\x3f\x51\xa9\x2c\xc8\x85\x96\x19\x96\x6e\x98\xd3\x3d\xee\xee\x1a\x07\xde\x9d\xfa\x0a\x6a\x22\x23\xa0\xc1\x8d\xef\xcd\x8e\x47\x67\xf1\x55\x46\xa4\x3d\xaa\x2d\x8b\x41\xa6\xe3\x4b\xa2\x1a\x48\x5d\x3a\xa1\xd4\xf0\x90\xff\xdc\x1a\x79\x9b\x8a\x66\xa4\x74\xe2\x99\x4b\xb1\xf4\x16\xba\x14\x33\xbb\x2e\xff\x1e\x4f\xfe\x39\x96\x74\xcc\x10\x54\x5a\x99\x64\x7b\x8d\x45\xbf\x6b\x75\xd6\xf8\xb6\xb3\x9c\xd7\xaa\x44\x09\x99\x43\x8a\x45\x49\x1d\x1a\xac\xf8\x5d\x5d\x71\x8a\x19\x5a\xe8\x73\xe8\x21\x1d\x89\xcc\x37\x98\x6c\xbb\xd1\xf9\x43\x28\x99\xf2\xe8\xc3\x53\x3a\xad\x98\x63\x4a\xed\x67\xfa\x6e\x9b\x4a\x3b\x53\x8a\xb7\x8b\x28\xfe\xc5\x48\xcd\x9b\xac\x2d\xe0\x08\xc2\x95\xd4\x61\x34\x82\xcb\xf7\x57\x57\xd1\x79\x91\x4a\xae\x8e\x91\x1d\xf5\x29\x74\xbd\x8c\x97\xe8\x66\x45\x31\x17\xc9\x06\x49\xc9\x26\x45\xf7\x3f\x8a\x3e\xbc\x56\xd7\x12\x5d\x55\x80\x28\x0a\x48\x28\xfa\x59\x71\xc7\x3f\x92\x02\x47\x2e\x6d\xbb\xe3\x19\xad\x87\x5f\x1f\xd0\x5a\x99\x62\xaf\x98\xf2\x4c\x22\x82\xf2\x80\x69\x12\xc5\xfb\x5c\x01\x29\x5c\x0e\xb3\x79\x4f\x6c\x58\xb3\xae\x4e\xc6\xf4\x53\xa5\x3f\x62\x66\x2c\xce\x4d\x5e\x48\xd5\x52\x7a\xc5\xb0\xd5\x61\xd0\xc4\x0d\xe3\xbe\xab\x71\x9b\x54\x93\x29\x7c\xed\x44\xbc\x5a\x97\xc4\xa2\x70\x08\x2b\xa9\x5f\x58\x4d\xe3\xef\xfa\x9a\x34\xe8\x4b\x74\x74\x69\xa5\x5e\x2f\xf4\x83\xb4\x46\xe7\xa8\xdd\x79\x37\xaf\x9e\x10\x3c\x86\xc3\x83\xb0\x52\xac\x9e\xed\xcd\xf7\x35\x0f\xff\xd4\xd5\xaf\x47\xec\x5f\x29\x89\x96\x70\xda\xd5\x38\xe9\x66\x68\xc4\x82\xa0\x5b\x45\x93\xe3\x98\xd3\x39\xb1\x47\x3b\x01\x68\x2a\xa2\xb3\xb9\xc9\x73\xa1\xd3\x09\xc0\x37\x27\xad\x6f\xce\x1f\x0f\xe4\x74\x6b\xd6\x14\x05\xd0\x6e\xa8\x13\x75\xe2\x4f\x95\x7e\x8d\x0c\xbe\x1d\xc3\x65\x5e\x3d\x1d\x85\x46\xf2\x3f\xad\x74\x38\x37\x3a\x93\xeb\xbf\x72\xc5\xe9\xf5\x7f\xa1\xd9\xfe\x0b\x76\x56\x3a\xa9\xd7\x90\xf8\x50\xba\x00\xc3\xb8\x3f\x9c\x5e\xc4\xe3\x52\x98\x2b\x14\xba\x2a\xda\xc5\xc0\x5f\x82\x6d\x5a\x9c\x50\x18\xf4\x97\xc0\x30\xae\x7f\xfa\xda\x14\x4b\x87\xc5\xb2\xca\x73\x61\xf7\x24\xa3\x45\x57\x59\x0d\x97\xec\xc0\xfe\x1b\x00\xf0\x1f\x24\x2e\xfa\x09\x00\x00")
 
 func srcLanguageSupplyCli_mainGoBytes() ([]byte, error) {
 	return bindataRead(
@@ -2083,7 +2083,7 @@ func srcLanguageSupplyCli_mainGo() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "src/LANGUAGE/supply/cli/_main.go", size: 2067, mode: os.FileMode(420), modTime: time.Unix(1527781218, 0)}
+	info := bindataFileInfo{name: "src/LANGUAGE/supply/cli/_main.go", size: 2554, mode: os.FileMode(436), modTime: time.Unix(1792098762, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
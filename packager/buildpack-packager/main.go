@@ -1,14 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"compress/flate"
 	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/libbuildpack"
 	"github.com/cloudfoundry/libbuildpack/packager"
@@ -38,18 +43,35 @@ func (s *summaryCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{
 }
 
 type buildCmd struct {
-	cached   bool
-	anyStack bool
-	version  string
-	cacheDir string
-	stack    string
+	cached        bool
+	anyStack      bool
+	selfCheck     bool
+	resume        bool
+	strictHTTPS   bool
+	checkBinaries bool
+	licenses      bool
+	lock          bool
+	watch         bool
+	compression   int
+	format        string
+	publish       string
+	publishKey    string
+	uriTmpl       string
+	httpAllow     string
+	version       string
+	cacheDir      string
+	stack         string
 }
 
 func (*buildCmd) Name() string     { return "build" }
 func (*buildCmd) Synopsis() string { return "Create a buildpack zipfile from the current directory" }
 func (*buildCmd) Usage() string {
-	return `build -stack <stack>|-any-stack [-cached] [-version <version>] [-cachedir <path to cachedir>]:
+	return `build -stack <stack>|-any-stack [-cached] [-version <version>] [-cachedir <path to cachedir>] [-resume] [-self-check] [-uri-template <template>] [-strict-https] [-http-allow <hosts>] [-check-binaries] [-licenses] [-lock] [-watch] [-compression-level <-1..9>] [-format zip|tar.zst] [-publish <destination>] [-publish-key <template>]:
   When run in a directory that is structured as a buildpack, creates a zip file.
+  With -publish, uploads it with checksum and metadata files to s3://, gs://
+  or azblob:// object storage, using credentials from the environment.
+  With -lock, records the dependencies in manifest.lock.
+  With -watch, builds the uncached buildpack again whenever a file changes.
 
 `
 }
@@ -60,8 +82,21 @@ func (b *buildCmd) SetFlags(f *flag.FlagSet) {
 
 	f.StringVar(&b.stack, "stack", "", "stack to package buildpack for")
 	f.BoolVar(&b.anyStack, "any-stack", false, "package buildpack for any stack")
+	f.BoolVar(&b.selfCheck, "self-check", false, "unpack the zipfile and check it is a usable buildpack")
+	f.BoolVar(&b.resume, "resume", false, "skip dependencies already downloaded and verified by an interrupted run")
+	f.StringVar(&b.uriTmpl, "uri-template", "", "rewrite dependency uris of uncached buildpacks, e.g. https://cdn.example.com/{{.Name}}/{{.Filename}}")
+	f.BoolVar(&b.strictHTTPS, "strict-https", false, "fail instead of warning when a dependency is hosted over plain http")
+	f.StringVar(&b.httpAllow, "http-allow", "", "comma separated hosts, or uri prefixes ending at a path segment, allowed to use plain http")
+	f.BoolVar(&b.checkBinaries, "check-binaries", false, "with -cached, fail if dependency binaries need libraries their stacks do not provide")
+	f.BoolVar(&b.licenses, "licenses", false, "with -cached, copy dependency license and notice files into licenses/")
+	f.BoolVar(&b.lock, "lock", false, "write manifest.lock with the uri, sha256, size and lock date of every dependency")
+	f.BoolVar(&b.watch, "watch", false, "rebuild the uncached buildpack whenever a file in the current directory changes, until interrupted")
+	f.IntVar(&b.compression, "compression-level", flate.DefaultCompression, "compression level from 1 (fastest) to 9 (smallest), -1 for the default")
+	f.StringVar(&b.format, "format", packager.FormatZip, "artifact format, zip or tar.zst (needs zstd installed)")
+	f.StringVar(&b.publish, "publish", "", "upload the artifact to s3://bucket/prefix, gs://bucket/prefix or azblob://account/container/prefix")
+	f.StringVar(&b.publishKey, "publish-key", packager.DefaultPublishKeyTemplate, "object key template for -publish, e.g. {{.Language}}/{{.Version}}/{{.Filename}}")
 }
-func (b *buildCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+func (b *buildCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	if b.stack == "" && !b.anyStack {
 		log.Printf("error: must either specify a stack or pass -any-stack")
 		return subcommands.ExitFailure
@@ -79,12 +114,61 @@ func (b *buildCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 		b.version = strings.TrimSpace(string(v))
 	}
 
-	zipFile, err := packager.Package(".", b.cacheDir, b.version, b.stack, b.cached)
+	if b.compression < flate.DefaultCompression || b.compression > flate.BestCompression {
+		log.Printf("error: compression level must be between -1 and 9")
+		return subcommands.ExitUsageError
+	}
+	if b.format != packager.FormatZip && b.format != packager.FormatTarZst {
+		log.Printf("error: format must be %s or %s", packager.FormatZip, packager.FormatTarZst)
+		return subcommands.ExitUsageError
+	}
+	packager.CompressionLevel = b.compression
+	packager.ArtifactFormat = b.format
+
+	packager.StrictHTTPS = b.strictHTTPS
+	packager.CheckBinaryCompatibility = b.checkBinaries
+	packager.CollectLicenses = b.licenses
+	packager.WriteManifestLock = b.lock
+	if b.httpAllow != "" {
+		packager.HTTPAllowlist = strings.Split(b.httpAllow, ",")
+	}
+	if b.watch {
+		if b.cached || b.selfCheck || b.publish != "" || b.uriTmpl != "" {
+			log.Printf("error: -watch cannot be combined with -cached, -self-check, -publish or -uri-template")
+			return subcommands.ExitUsageError
+		}
+		err := packager.Watch(ctx, ".", b.cacheDir, b.version, b.stack, packager.DefaultWatchInterval, func(build packager.WatchBuild) {
+			if len(build.Changed) > 0 {
+				fmt.Printf("changed: %s\n", strings.Join(build.Changed, ", "))
+			}
+			if build.Err != nil {
+				log.Printf("error while creating zipfile: %v", build.Err)
+				return
+			}
+			fmt.Printf("uncached buildpack rebuilt as %s in %s\n", build.File, build.Duration.Round(time.Millisecond))
+		})
+		if err != nil {
+			log.Printf("error while watching: %v", err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+
+	zipFile, err := packager.PackageWithOptions(ctx, ".", b.cacheDir, b.version, b.stack, b.cached, b.resume, packager.PackageOptions{
+		DependencyURITemplate: b.uriTmpl,
+	})
 	if err != nil {
 		log.Printf("error while creating zipfile: %v", err)
 		return subcommands.ExitFailure
 	}
 
+	if b.selfCheck {
+		if err := packager.SelfCheck(zipFile); err != nil {
+			log.Printf("error: %v", err)
+			return subcommands.ExitFailure
+		}
+	}
+
 	buildpackType := "uncached"
 	if b.cached {
 		buildpackType = "cached"
@@ -97,6 +181,232 @@ func (b *buildCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 	}
 
 	fmt.Printf("%s buildpack created and saved as %s with a size of %dMB\n", buildpackType, zipFile, stat.Size()/1024/1024)
+
+	if b.publish != "" {
+		urls, err := packager.Publish(ctx, ".", zipFile, b.version, b.stack, b.cached, packager.PublishOptions{Destination: b.publish, KeyTemplate: b.publishKey})
+		if err != nil {
+			log.Printf("error while publishing: %v", err)
+			return subcommands.ExitFailure
+		}
+		for _, url := range urls {
+			fmt.Printf("published %s\n", url)
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+type bundleCmd struct {
+	cached   bool
+	anyStack bool
+	version  string
+	cacheDir string
+	stack    string
+	report   string
+}
+
+func (*bundleCmd) Name() string { return "bundle" }
+func (*bundleCmd) Synopsis() string {
+	return "Create zipfiles for several buildpacks as one release set"
+}
+func (*bundleCmd) Usage() string {
+	return `bundle -version <version> -stack <stack>|-any-stack [-cached] [-cachedir <path to cachedir>] [-report <path>] <buildpack dir>...:
+  Packages each buildpack directory with the same version and download cache,
+  after checking that shared dependencies agree across the buildpacks.
+
+`
+}
+func (b *bundleCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&b.version, "version", "", "version to build every buildpack as. Required.")
+	f.BoolVar(&b.cached, "cached", false, "include dependencies")
+	f.StringVar(&b.cacheDir, "cachedir", packager.CacheDir, "cache dir")
+	f.StringVar(&b.report, "report", "", "file to write the combined report to. Defaults to stdout.")
+
+	f.StringVar(&b.stack, "stack", "", "stack to package buildpacks for")
+	f.BoolVar(&b.anyStack, "any-stack", false, "package buildpacks for any stack")
+}
+func (b *bundleCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if b.stack == "" && !b.anyStack {
+		log.Printf("error: must either specify a stack or pass -any-stack")
+		return subcommands.ExitFailure
+	}
+	if b.stack != "" && b.anyStack {
+		log.Printf("error: cannot specify a stack AND pass -any-stack")
+		return subcommands.ExitFailure
+	}
+	if b.version == "" {
+		log.Printf("error: must specify a version for the release set")
+		return subcommands.ExitUsageError
+	}
+	if f.NArg() == 0 {
+		log.Printf("error: no buildpack directories given")
+		return subcommands.ExitUsageError
+	}
+
+	result, err := packager.Bundle(f.Args(), b.cacheDir, b.version, b.stack, b.cached)
+	if err != nil {
+		log.Printf("error while bundling buildpacks: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	if b.report != "" {
+		if err := ioutil.WriteFile(b.report, []byte(result.Report), 0644); err != nil {
+			log.Printf("error while writing report: %v", err)
+			return subcommands.ExitFailure
+		}
+	} else {
+		fmt.Println(result.Report)
+	}
+
+	for _, file := range result.Files {
+		fmt.Printf("buildpack created and saved as %s\n", file)
+	}
+	return subcommands.ExitSuccess
+}
+
+type lockCmd struct {
+	output  string
+	keyFile string
+}
+
+func (*lockCmd) Name() string { return "lock" }
+func (*lockCmd) Synopsis() string {
+	return "Export the dependency checksums of a buildpack zipfile to a lockfile"
+}
+func (*lockCmd) Usage() string {
+	return `lock [-output <path>] [-key-file <path>] <buildpack zipfile>:
+  Records the name, version, uri and sha256 of every dependency in the
+  zipfile, signing the lockfile when a key file is given.
+
+`
+}
+func (l *lockCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&l.output, "output", "buildpack.lock", "file to write the lockfile to")
+	f.StringVar(&l.keyFile, "key-file", "", "file containing the key to sign the lockfile with")
+}
+func (l *lockCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		log.Printf("error: expected one buildpack zipfile")
+		return subcommands.ExitUsageError
+	}
+	key, err := readKeyFile(l.keyFile)
+	if err != nil {
+		log.Printf("error while reading key: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	lockfile, err := packager.ExportLockfile(f.Arg(0), key)
+	if err != nil {
+		log.Printf("error while exporting lockfile: %v", err)
+		return subcommands.ExitFailure
+	}
+	if err := lockfile.Write(l.output); err != nil {
+		log.Printf("error while writing lockfile: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("lockfile saved as %s\n", l.output)
+	return subcommands.ExitSuccess
+}
+
+type verifyLockCmd struct {
+	lockfile string
+	keyFile  string
+}
+
+func (*verifyLockCmd) Name() string { return "verify-lock" }
+func (*verifyLockCmd) Synopsis() string {
+	return "Verify a buildpack zipfile against a lockfile"
+}
+func (*verifyLockCmd) Usage() string {
+	return `verify-lock [-lockfile <path>] [-key-file <path>] <buildpack zipfile>:
+  Fails unless the dependencies in the zipfile match the lockfile exactly,
+  checking its signature when a key file is given.
+
+`
+}
+func (v *verifyLockCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&v.lockfile, "lockfile", "buildpack.lock", "lockfile to verify against")
+	f.StringVar(&v.keyFile, "key-file", "", "file containing the key the lockfile was signed with")
+}
+func (v *verifyLockCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		log.Printf("error: expected one buildpack zipfile")
+		return subcommands.ExitUsageError
+	}
+	key, err := readKeyFile(v.keyFile)
+	if err != nil {
+		log.Printf("error while reading key: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	if err := packager.VerifyLockfile(f.Arg(0), v.lockfile, key); err != nil {
+		log.Printf("error: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("%s matches %s\n", f.Arg(0), v.lockfile)
+	return subcommands.ExitSuccess
+}
+
+type verifyManifestLockCmd struct{}
+
+func (*verifyManifestLockCmd) Name() string { return "verify-manifest-lock" }
+func (*verifyManifestLockCmd) Synopsis() string {
+	return "Verify manifest.yml against manifest.lock"
+}
+func (*verifyManifestLockCmd) Usage() string {
+	return `verify-manifest-lock:
+  When run in a directory that is structured as a buildpack, fails unless
+  manifest.lock, as written by build -lock, locks exactly the dependencies
+  in manifest.yml with the same uris and sha256s.
+
+`
+}
+func (*verifyManifestLockCmd) SetFlags(f *flag.FlagSet) {}
+func (*verifyManifestLockCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := packager.VerifyManifestLock("."); err != nil {
+		log.Printf("error: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("manifest.yml matches %s\n", packager.ManifestLockFile)
+	return subcommands.ExitSuccess
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	key, err := ioutil.ReadFile(path)
+	return bytes.TrimSpace(key), err
+}
+
+type diffCmd struct{}
+
+func (*diffCmd) Name() string { return "diff" }
+func (*diffCmd) Synopsis() string {
+	return "Compare the dependencies and files of two buildpack zipfiles"
+}
+func (*diffCmd) Usage() string {
+	return `diff <old zipfile> <new zipfile>:
+  Reports added, removed and changed dependencies and files, and the change in size.
+
+`
+}
+func (*diffCmd) SetFlags(f *flag.FlagSet) {}
+func (*diffCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		log.Printf("error: expected an old and a new buildpack zipfile")
+		return subcommands.ExitUsageError
+	}
+
+	diff, err := packager.Diff(f.Arg(0), f.Arg(1))
+	if err != nil {
+		log.Printf("error while comparing buildpacks: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Print(diff)
 	return subcommands.ExitSuccess
 }
 
@@ -110,15 +420,23 @@ func (*initCmd) Synopsis() string {
 	return "Creates a folder with the basic structure of a new buildpack"
 }
 func (i *initCmd) SetFlags(f *flag.FlagSet) {
-	f.StringVar(&i.name, "name", "", "Name of the buildpack. Required.")
+	f.StringVar(&i.name, "name", "", "Name of the buildpack. Required unless given as the language argument.")
 	f.StringVar(&i.dir, "path", "", "Path to folder to create. Defaults to the name + '-buildpack' in the current directory.")
 }
 func (*initCmd) Usage() string {
-	return `init:
-	Create a new directory that is structured as a buildpack.
+	return `init [-path <dir>] <language>:
+	Create a new directory that is structured as a buildpack: bin scripts
+	wired to libbuildpack supply and finalize mains, manifest.yml, VERSION
+	and an integration test harness using cutlass.
 `
 }
 func (i *initCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() > 1 || (f.NArg() == 1 && i.name != "" && i.name != f.Arg(0)) {
+		log.Printf("error: expected a single language for the new buildpack")
+		return subcommands.ExitUsageError
+	} else if f.NArg() == 1 {
+		i.name = f.Arg(0)
+	}
 	fmt.Println("Init", i.name, i.dir)
 
 	if i.name == "" {
@@ -194,16 +512,71 @@ func (u *upgradeCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{
 
 	return subcommands.ExitSuccess
 }
+
+type normalizeCmd struct {
+	dir   string
+	check bool
+}
+
+func (*normalizeCmd) Name() string { return "normalize" }
+func (*normalizeCmd) Synopsis() string {
+	return "Sorts, deduplicates and canonicalizes the dependencies in manifest.yml"
+}
+func (n *normalizeCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&n.dir, "path", ".", "Path to the buildpack. Defaults to the current directory.")
+	f.BoolVar(&n.check, "check", false, "Fail if manifest.yml is not normalized instead of rewriting it")
+}
+func (*normalizeCmd) Usage() string {
+	return `normalize [-path <buildpack>] [-check]:
+	Rewrite manifest.yml with dependencies sorted by name and version, exact duplicates removed,
+	stacks merged for identical name, version and sha256, and fields in a canonical order.
+`
+}
+func (n *normalizeCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	report, err := packager.Normalize(n.dir, !n.check)
+	if err != nil {
+		log.Printf("error normalizing manifest: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	if !report.Changed {
+		fmt.Println("manifest.yml is already normalized")
+		return subcommands.ExitSuccess
+	}
+	if n.check {
+		log.Printf("error: manifest.yml is not normalized, run buildpack-packager normalize")
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("Normalized manifest.yml: removed %d duplicates, merged %d entries\n", report.Duplicates, report.Merged)
+	return subcommands.ExitSuccess
+}
+
 func main() {
 	subcommands.Register(subcommands.HelpCommand(), "")
 	subcommands.Register(subcommands.FlagsCommand(), "")
 	subcommands.Register(subcommands.CommandsCommand(), "")
 	subcommands.Register(&summaryCmd{}, "Custom")
 	subcommands.Register(&buildCmd{}, "Custom")
+	subcommands.Register(&bundleCmd{}, "Custom")
+	subcommands.Register(&lockCmd{}, "Custom")
+	subcommands.Register(&verifyLockCmd{}, "Custom")
+	subcommands.Register(&verifyManifestLockCmd{}, "Custom")
+	subcommands.Register(&diffCmd{}, "Custom")
 	subcommands.Register(&initCmd{}, "Custom")
 	subcommands.Register(&upgradeCmd{}, "Custom")
+	subcommands.Register(&normalizeCmd{}, "Custom")
 
 	flag.Parse()
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Printf("interrupted, stopping after cleaning up partial downloads")
+		cancel()
+	}()
+
 	os.Exit(int(subcommands.Execute(ctx)))
 }
@@ -0,0 +1,129 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type BundleResult struct {
+	Files  []string
+	Report string
+}
+
+type bundleEntry struct {
+	bpDir string
+	dep   Dependency
+}
+
+// Bundle packages every buildpack in bpDirs with the same version, stack and
+// download cache. Before anything is packaged it checks that buildpacks
+// sharing a dependency agree on where it comes from, what it contains and
+// which version is the newest. If packaging any of them fails, the artifacts
+// already packaged are removed, so that a partial release set is never left
+// behind.
+func Bundle(bpDirs []string, cacheDir, version, stack string, cached bool) (result BundleResult, err error) {
+	if len(bpDirs) == 0 {
+		return BundleResult{}, fmt.Errorf("no buildpacks given to bundle")
+	}
+
+	if err := CheckBundleConsistency(bpDirs); err != nil {
+		return BundleResult{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			for _, file := range result.Files {
+				os.Remove(file)
+			}
+			result = BundleResult{}
+		}
+	}()
+
+	for _, bpDir := range bpDirs {
+		manifest, err := readManifest(bpDir)
+		if err != nil {
+			return result, fmt.Errorf("Failed to read manifest for %s: %v", bpDir, err)
+		}
+
+		zipFile, err := Package(bpDir, cacheDir, version, stack, cached)
+		if err != nil {
+			return result, fmt.Errorf("Failed to package %s: %v", bpDir, err)
+		}
+		result.Files = append(result.Files, zipFile)
+
+		summary, err := Summary(bpDir)
+		if err != nil {
+			return result, fmt.Errorf("Failed to summarize %s: %v", bpDir, err)
+		}
+		result.Report += fmt.Sprintf("## %s buildpack (%s)\n\nArtifact: %s\n%s\n", manifest.Language, version, filepath.Base(zipFile), summary)
+	}
+
+	return result, nil
+}
+
+// CheckBundleConsistency returns an error describing every dependency that is
+// declared with the same name, version and stack by more than one buildpack
+// but with a different uri or sha256, and every dependency whose newest
+// version for a stack differs between the buildpacks that ship it.
+func CheckBundleConsistency(bpDirs []string) error {
+	entries := map[string][]bundleEntry{}
+	newest := map[string][]bundleEntry{}
+
+	for _, bpDir := range bpDirs {
+		manifest, err := readManifest(bpDir)
+		if err != nil {
+			return fmt.Errorf("Failed to read manifest for %s: %v", bpDir, err)
+		}
+
+		latest := map[string]Dependency{}
+		for _, d := range manifest.Dependencies {
+			for _, s := range d.Stacks {
+				key := fmt.Sprintf("%s %s (%s)", d.Name, d.Version, s)
+				entries[key] = append(entries[key], bundleEntry{bpDir: bpDir, dep: d})
+
+				line := fmt.Sprintf("%s (%s)", d.Name, s)
+				if current, found := latest[line]; !found || (Dependencies{current, d}).Less(0, 1) {
+					latest[line] = d
+				}
+			}
+		}
+		for line, d := range latest {
+			newest[line] = append(newest[line], bundleEntry{bpDir: bpDir, dep: d})
+		}
+	}
+
+	var conflicts []string
+	for _, key := range bundleKeys(entries) {
+		first := entries[key][0]
+		for _, other := range entries[key][1:] {
+			if other.dep.SHA256 != first.dep.SHA256 || other.dep.URI != first.dep.URI {
+				conflicts = append(conflicts, fmt.Sprintf("%s: %s has %s (%s), %s has %s (%s)", key, first.bpDir, first.dep.URI, first.dep.SHA256, other.bpDir, other.dep.URI, other.dep.SHA256))
+			}
+		}
+	}
+	for _, line := range bundleKeys(newest) {
+		first := newest[line][0]
+		for _, other := range newest[line][1:] {
+			if other.dep.Version != first.dep.Version {
+				conflicts = append(conflicts, fmt.Sprintf("%s: the newest in %s is %s, in %s it is %s", line, first.bpDir, first.dep.Version, other.bpDir, other.dep.Version))
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("buildpacks disagree on dependencies:\n%s", strings.Join(conflicts, "\n"))
+	}
+	return nil
+}
+
+func bundleKeys(m map[string][]bundleEntry) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+package packager_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bundle", func() {
+	var (
+		cacheDir string
+		version  string
+		err      error
+	)
+
+	BeforeEach(func() {
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+		version = fmt.Sprintf("1.23.45.%s", time.Now().Format("20060102150405"))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cacheDir)
+	})
+
+	writeBuildpack := func(language, rubyVersion string) string {
+		dir, err := ioutil.TempDir("", "packager-bundle")
+		Expect(err).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.0.0"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.yml"), []byte(fmt.Sprintf(`---
+language: %s
+dependencies:
+- name: ruby
+  version: %s
+  sha256: b11329c3fd6dbe9dddcb8dd90f18a4bf441858a6b5bfaccae5f91e5c7d2b3596
+  uri: https://www.ietf.org/rfc/rfc2324.txt
+  cf_stacks:
+  - cflinuxfs2
+include_files:
+- manifest.yml
+- VERSION
+`, language, rubyVersion)), 0644)).To(Succeed())
+		return dir
+	}
+
+	Context("buildpacks agree on their dependencies", func() {
+		It("packages each buildpack and reports on all of them", func() {
+			result, err := packager.Bundle([]string{"./fixtures/good"}, cacheDir, version, "cflinuxfs2", false)
+			Expect(err).To(BeNil())
+			defer os.Remove(result.Files[0])
+
+			Expect(result.Files).To(HaveLen(1))
+			Expect(ZipContents(result.Files[0], "VERSION")).To(Equal(version))
+			Expect(result.Report).To(ContainSubstring("## ruby buildpack (" + version + ")"))
+			Expect(result.Report).To(ContainSubstring("| ruby | 1.2.3 | cflinuxfs2 |"))
+		})
+	})
+
+	Context("buildpacks disagree on a dependency", func() {
+		It("returns an error without packaging anything", func() {
+			_, err := packager.Bundle([]string{"./fixtures/good", "./fixtures/bad"}, cacheDir, version, "cflinuxfs2", false)
+			Expect(err).To(MatchError(ContainSubstring("ruby 1.2.3 (cflinuxfs2): ./fixtures/good has")))
+			Expect(err).To(MatchError(ContainSubstring("./fixtures/bad has https://www.ietf.org/rfc/rfc2324.txt (fffffff)")))
+		})
+	})
+
+	Context("buildpacks ship different newest versions of a dependency", func() {
+		It("returns an error", func() {
+			older, newer := writeBuildpack("older", "1.2.3"), writeBuildpack("newer", "1.2.4")
+			defer os.RemoveAll(older)
+			defer os.RemoveAll(newer)
+
+			_, err := packager.Bundle([]string{older, newer}, cacheDir, version, "cflinuxfs2", false)
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("ruby (cflinuxfs2): the newest in %s is 1.2.3, in %s it is 1.2.4", older, newer))))
+		})
+	})
+
+	Context("packaging a buildpack fails", func() {
+		It("removes the artifacts already packaged", func() {
+			broken := writeBuildpack("broken", "1.2.3")
+			defer os.RemoveAll(broken)
+			manifest, err := os.OpenFile(filepath.Join(broken, "manifest.yml"), os.O_APPEND|os.O_WRONLY, 0644)
+			Expect(err).To(BeNil())
+			_, err = manifest.WriteString("- missing.txt\n")
+			Expect(err).To(BeNil())
+			Expect(manifest.Close()).To(Succeed())
+
+			result, err := packager.Bundle([]string{"./fixtures/good", broken}, cacheDir, version, "cflinuxfs2", false)
+			Expect(err).To(MatchError(ContainSubstring("Failed to package " + broken)))
+			Expect(result.Files).To(BeEmpty())
+			Expect(filepath.Glob("./fixtures/good/*" + version + "*.zip")).To(BeEmpty())
+		})
+	})
+
+	Context("no buildpacks are given", func() {
+		It("returns an error", func() {
+			_, err := packager.Bundle([]string{}, cacheDir, version, "cflinuxfs2", false)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package packager_test
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression", func() {
+	var (
+		buildpackDir string
+		cacheDir     string
+		artifact     string
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildpackDir, err = ioutil.TempDir("", "packager-buildpack")
+		Expect(err).To(BeNil())
+		manifest := "---\nlanguage: binary\ndependencies: []\ninclude_files:\n- manifest.yml\n- VERSION\n"
+		Expect(ioutil.WriteFile(filepath.Join(buildpackDir, "manifest.yml"), []byte(manifest), 0644)).To(Succeed())
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		packager.CompressionLevel = flate.DefaultCompression
+		packager.ArtifactFormat = packager.FormatZip
+		os.RemoveAll(buildpackDir)
+		os.RemoveAll(cacheDir)
+		os.Remove(artifact)
+	})
+
+	It("writes a valid zip at the configured compression level", func() {
+		packager.CompressionLevel = flate.BestCompression
+
+		var err error
+		artifact, err = packager.Package(buildpackDir, cacheDir, "1.2.3", "cflinuxfs2", false)
+		Expect(err).To(BeNil())
+		Expect(artifact).To(HaveSuffix(".zip"))
+
+		r, err := zip.OpenReader(artifact)
+		Expect(err).To(BeNil())
+		defer r.Close()
+		var names []string
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+		Expect(names).To(ContainElement("manifest.yml"))
+		Expect(names).To(ContainElement("VERSION"))
+	})
+
+	It("writes a zstd compressed tarball when asked", func() {
+		if _, err := exec.LookPath("zstd"); err != nil {
+			Skip("needs zstd")
+		}
+		packager.ArtifactFormat = packager.FormatTarZst
+
+		var err error
+		artifact, err = packager.Package(buildpackDir, cacheDir, "1.2.3", "cflinuxfs2", false)
+		Expect(err).To(BeNil())
+		Expect(artifact).To(HaveSuffix(".tar.zst"))
+
+		out, err := exec.Command("sh", "-c", "zstd -dc "+artifact+" | tar t").CombinedOutput()
+		Expect(err).To(BeNil(), string(out))
+		Expect(strings.Fields(string(out))).To(ContainElement("manifest.yml"))
+		Expect(strings.Fields(string(out))).To(ContainElement("VERSION"))
+	})
+})
@@ -0,0 +1,189 @@
+package packager
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type DependencyChange struct {
+	Old, New LockedDependency
+}
+
+type FileChange struct {
+	Name             string
+	OldSize, NewSize int64
+}
+
+// ArtifactDiff describes what changed between two packaged buildpacks.
+// Dependencies are matched by name, version and stacks, so a version bump is
+// reported as one removal and one addition.
+type ArtifactDiff struct {
+	OldVersion, NewVersion string
+	OldSize, NewSize       int64
+
+	AddedDependencies   []LockedDependency
+	RemovedDependencies []LockedDependency
+	ChangedDependencies []DependencyChange
+
+	AddedFiles   []FileChange
+	RemovedFiles []FileChange
+	ChangedFiles []FileChange
+}
+
+// Diff compares two packaged buildpacks, each a zip or a tar.zst.
+func Diff(oldZip, newZip string) (ArtifactDiff, error) {
+	var diff ArtifactDiff
+
+	oldLock, err := ExportLockfile(oldZip, nil)
+	if err != nil {
+		return diff, err
+	}
+	newLock, err := ExportLockfile(newZip, nil)
+	if err != nil {
+		return diff, err
+	}
+	diff.OldVersion, diff.NewVersion = oldLock.Version, newLock.Version
+
+	oldDeps := map[string]LockedDependency{}
+	for _, d := range oldLock.Dependencies {
+		oldDeps[d.key()] = d
+	}
+	for _, d := range newLock.Dependencies {
+		old, found := oldDeps[d.key()]
+		if !found {
+			diff.AddedDependencies = append(diff.AddedDependencies, d)
+			continue
+		}
+		delete(oldDeps, d.key())
+		if old.SHA256 != d.SHA256 || old.URI != d.URI {
+			diff.ChangedDependencies = append(diff.ChangedDependencies, DependencyChange{Old: old, New: d})
+		}
+	}
+	for _, d := range oldLock.Dependencies {
+		if _, removed := oldDeps[d.key()]; removed {
+			diff.RemovedDependencies = append(diff.RemovedDependencies, d)
+		}
+	}
+
+	if diff.OldSize, err = fileSize(oldZip); err != nil {
+		return diff, err
+	}
+	if diff.NewSize, err = fileSize(newZip); err != nil {
+		return diff, err
+	}
+
+	oldFiles, err := artifactEntries(oldZip)
+	if err != nil {
+		return diff, err
+	}
+	newFiles, err := artifactEntries(newZip)
+	if err != nil {
+		return diff, err
+	}
+
+	for _, name := range sortedKeys(newFiles) {
+		f := newFiles[name]
+		old, found := oldFiles[name]
+		if !found {
+			diff.AddedFiles = append(diff.AddedFiles, FileChange{Name: name, NewSize: f.size})
+		} else if old.crc32 != f.crc32 || old.size != f.size {
+			diff.ChangedFiles = append(diff.ChangedFiles, FileChange{Name: name, OldSize: old.size, NewSize: f.size})
+		}
+	}
+	for _, name := range sortedKeys(oldFiles) {
+		if _, found := newFiles[name]; !found {
+			diff.RemovedFiles = append(diff.RemovedFiles, FileChange{Name: name, OldSize: oldFiles[name].size})
+		}
+	}
+
+	return diff, nil
+}
+
+// String renders the diff as a report for release review.
+func (d ArtifactDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "version: %s -> %s\n", d.OldVersion, d.NewVersion)
+	fmt.Fprintf(&b, "size: %d -> %d bytes (%+d)\n", d.OldSize, d.NewSize, d.NewSize-d.OldSize)
+
+	fmt.Fprintf(&b, "\ndependencies:\n")
+	if len(d.AddedDependencies)+len(d.RemovedDependencies)+len(d.ChangedDependencies) == 0 {
+		fmt.Fprintf(&b, "  no changes\n")
+	}
+	for _, dep := range d.AddedDependencies {
+		fmt.Fprintf(&b, "  + %s\n", describeDependency(dep))
+	}
+	for _, dep := range d.RemovedDependencies {
+		fmt.Fprintf(&b, "  - %s\n", describeDependency(dep))
+	}
+	for _, c := range d.ChangedDependencies {
+		fmt.Fprintf(&b, "  ~ %s\n", describeDependency(c.Old))
+		if c.Old.URI != c.New.URI {
+			fmt.Fprintf(&b, "      uri: %s -> %s\n", c.Old.URI, c.New.URI)
+		}
+		if c.Old.SHA256 != c.New.SHA256 {
+			fmt.Fprintf(&b, "      sha256: %s -> %s\n", c.Old.SHA256, c.New.SHA256)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nfiles:\n")
+	if len(d.AddedFiles)+len(d.RemovedFiles)+len(d.ChangedFiles) == 0 {
+		fmt.Fprintf(&b, "  no changes\n")
+	}
+	for _, f := range d.AddedFiles {
+		fmt.Fprintf(&b, "  + %s (%d bytes)\n", f.Name, f.NewSize)
+	}
+	for _, f := range d.RemovedFiles {
+		fmt.Fprintf(&b, "  - %s (%d bytes)\n", f.Name, f.OldSize)
+	}
+	for _, f := range d.ChangedFiles {
+		fmt.Fprintf(&b, "  ~ %s (%d -> %d bytes)\n", f.Name, f.OldSize, f.NewSize)
+	}
+
+	return b.String()
+}
+
+func describeDependency(d LockedDependency) string {
+	if len(d.Stacks) == 0 {
+		return fmt.Sprintf("%s %s", d.Name, d.Version)
+	}
+	return fmt.Sprintf("%s %s (%s)", d.Name, d.Version, strings.Join(d.Stacks, ", "))
+}
+
+type artifactEntry struct {
+	size  int64
+	crc32 uint32
+}
+
+func artifactEntries(artifact string) (map[string]artifactEntry, error) {
+	entries := map[string]artifactEntry{}
+	err := walkArtifact(artifact, func(name string, size int64, r io.Reader) error {
+		h := crc32.NewIEEE()
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		entries[name] = artifactEntry{size: size, crc32: h.Sum32()}
+		return nil
+	})
+	return entries, err
+}
+
+func sortedKeys(m map[string]artifactEntry) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,68 @@
+package packager_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+	var (
+		cacheDir       string
+		oldZip, newZip string
+		err            error
+	)
+
+	BeforeEach(func() {
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+
+		oldZip, err = packager.Package("./fixtures/good", cacheDir, "1.2.3", "cflinuxfs2", false)
+		Expect(err).To(BeNil())
+		newZip, err = packager.Package("./fixtures/good", cacheDir, "1.2.4", "cflinuxfs3", false)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cacheDir)
+		os.Remove(oldZip)
+		os.Remove(newZip)
+	})
+
+	It("reports changed dependencies and files", func() {
+		diff, err := packager.Diff(oldZip, newZip)
+		Expect(err).To(BeNil())
+
+		Expect(diff.OldVersion).To(Equal("1.2.3"))
+		Expect(diff.NewVersion).To(Equal("1.2.4"))
+		Expect(diff.AddedDependencies).To(BeEmpty())
+		Expect(diff.RemovedDependencies).To(BeEmpty())
+		Expect(diff.ChangedDependencies).To(HaveLen(1))
+		Expect(diff.ChangedDependencies[0].Old.URI).To(Equal("https://www.ietf.org/rfc/rfc2324.txt"))
+		Expect(diff.ChangedDependencies[0].New.URI).To(Equal("https://www.ietf.org/rfc/rfc2549.txt"))
+
+		var changed []string
+		for _, f := range diff.ChangedFiles {
+			changed = append(changed, f.Name)
+		}
+		Expect(changed).To(ConsistOf("VERSION", "manifest.yml"))
+		Expect(diff.AddedFiles).To(BeEmpty())
+		Expect(diff.RemovedFiles).To(BeEmpty())
+
+		report := diff.String()
+		Expect(report).To(ContainSubstring("version: 1.2.3 -> 1.2.4"))
+		Expect(report).To(ContainSubstring("~ ruby 1.2.3\n      uri: https://www.ietf.org/rfc/rfc2324.txt -> https://www.ietf.org/rfc/rfc2549.txt"))
+		Expect(report).To(ContainSubstring("~ VERSION (5 -> 5 bytes)"))
+	})
+
+	It("reports no changes between identical artifacts", func() {
+		diff, err := packager.Diff(oldZip, oldZip)
+		Expect(err).To(BeNil())
+
+		Expect(diff.String()).To(ContainSubstring("dependencies:\n  no changes"))
+		Expect(diff.String()).To(ContainSubstring("files:\n  no changes"))
+	})
+})
@@ -0,0 +1,71 @@
+package packager
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// StrictHTTPS makes packaging fail, rather than warn, when a dependency uri
+// or source uri in the manifest uses plain http.
+var StrictHTTPS bool
+
+// HTTPAllowlist lists hosts, or uri prefixes, that may use plain http
+// without a warning, e.g. an internal mirror. A host must match exactly, and
+// a uri prefix matches uris with the same scheme and host whose path starts
+// with its whole path segments, so http://mirror/deps allows
+// http://mirror/deps/a.tgz but not http://mirror/deps-old/a.tgz.
+var HTTPAllowlist []string
+
+// InsecureURIs lists every dependency uri and source uri in manifest that
+// uses plain http and is not allowed by HTTPAllowlist.
+func InsecureURIs(manifest Manifest) []string {
+	var insecure []string
+	for _, d := range manifest.Dependencies {
+		for _, u := range append([]string{d.URI, d.Source}, d.Mirrors...) {
+			if isInsecureURI(u) {
+				insecure = append(insecure, fmt.Sprintf("%s %s: %s", d.Name, d.Version, u))
+			}
+		}
+	}
+	return insecure
+}
+
+func checkHTTPSPolicy(manifest Manifest) error {
+	insecure := InsecureURIs(manifest)
+	if len(insecure) == 0 {
+		return nil
+	}
+
+	msg := "dependencies are hosted over plain http:\n  " + strings.Join(insecure, "\n  ")
+	if StrictHTTPS {
+		return fmt.Errorf("%s", msg)
+	}
+	fmt.Fprintf(Stderr, "warning: %s\n", msg)
+	return nil
+}
+
+func isInsecureURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	for _, allowed := range HTTPAllowlist {
+		if httpAllowed(u, allowed) {
+			return false
+		}
+	}
+	return true
+}
+
+func httpAllowed(u *url.URL, allowed string) bool {
+	if !strings.Contains(allowed, "://") {
+		return strings.EqualFold(allowed, u.Hostname())
+	}
+	prefix, err := url.Parse(allowed)
+	if err != nil || !strings.EqualFold(prefix.Scheme, u.Scheme) || !strings.EqualFold(prefix.Host, u.Host) {
+		return false
+	}
+	dir := strings.TrimSuffix(prefix.Path, "/")
+	return dir == "" || u.Path == dir || strings.HasPrefix(u.Path, dir+"/")
+}
@@ -0,0 +1,103 @@
+package packager_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPS policy", func() {
+	var (
+		bpDir    string
+		cacheDir string
+		stderr   *bytes.Buffer
+		err      error
+	)
+
+	BeforeEach(func() {
+		bpDir, err = ioutil.TempDir("", "packager-bpdir")
+		Expect(err).To(BeNil())
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+
+		Expect(ioutil.WriteFile(filepath.Join(bpDir, "VERSION"), []byte("1.0.0"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte(`---
+language: insecure
+dependencies:
+- name: plain
+  version: 1.0.0
+  uri: http://downloads.example.com/plain/plain-1.0.0.tgz
+  source: https://example.com/plain-1.0.0-src.tgz
+  sha256: aaaa
+  cf_stacks:
+  - cflinuxfs3
+- name: secure
+  version: 2.0.0
+  uri: https://downloads.example.com/secure-2.0.0.tgz
+  source: http://internal.example.com/secure-2.0.0-src.tgz
+  sha256: bbbb
+  cf_stacks:
+  - cflinuxfs3
+include_files:
+- manifest.yml
+- VERSION
+`), 0644)).To(Succeed())
+
+		stderr = new(bytes.Buffer)
+		packager.Stderr = stderr
+	})
+
+	AfterEach(func() {
+		packager.Stderr = os.Stderr
+		packager.StrictHTTPS = false
+		packager.HTTPAllowlist = nil
+		os.RemoveAll(bpDir)
+		os.RemoveAll(cacheDir)
+	})
+
+	It("warns about every plain http uri by default", func() {
+		zipFile, err := packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", false)
+		Expect(err).To(BeNil())
+		Expect(zipFile).To(BeAnExistingFile())
+
+		Expect(stderr.String()).To(ContainSubstring("warning: dependencies are hosted over plain http"))
+		Expect(stderr.String()).To(ContainSubstring("plain 1.0.0: http://downloads.example.com/plain/plain-1.0.0.tgz"))
+		Expect(stderr.String()).To(ContainSubstring("secure 2.0.0: http://internal.example.com/secure-2.0.0-src.tgz"))
+	})
+
+	It("fails when strict", func() {
+		packager.StrictHTTPS = true
+
+		_, err := packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", false)
+		Expect(err).To(MatchError(ContainSubstring("plain 1.0.0: http://downloads.example.com/plain/plain-1.0.0.tgz")))
+	})
+
+	It("allows hosts and prefixes on the allowlist", func() {
+		packager.StrictHTTPS = true
+		packager.HTTPAllowlist = []string{"internal.example.com", "http://downloads.example.com/plain"}
+
+		_, err := packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", false)
+		Expect(err).To(BeNil())
+		Expect(stderr.String()).To(BeEmpty())
+	})
+
+	It("matches allowed hosts exactly and prefixes on whole path segments", func() {
+		packager.HTTPAllowlist = []string{"internal.example.com", "http://downloads.example.com/plain"}
+
+		Expect(packager.InsecureURIs(packager.Manifest{Dependencies: packager.Dependencies{
+			{Name: "lookalike", Version: "1.0.0", URI: "http://internal.example.com.evil.com/a.tgz"},
+			{Name: "sibling", Version: "1.0.0", URI: "http://downloads.example.com/plainly/a.tgz"},
+			{Name: "other-host", Version: "1.0.0", URI: "http://downloads.example.com.evil.com/plain/a.tgz"},
+			{Name: "allowed", Version: "1.0.0", URI: "http://downloads.example.com/plain/a.tgz"},
+		}})).To(Equal([]string{
+			"lookalike 1.0.0: http://internal.example.com.evil.com/a.tgz",
+			"sibling 1.0.0: http://downloads.example.com/plainly/a.tgz",
+			"other-host 1.0.0: http://downloads.example.com.evil.com/plain/a.tgz",
+		}))
+	})
+})
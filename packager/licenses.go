@@ -0,0 +1,109 @@
+package packager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// CollectLicenses makes cached packaging copy the license and notice files of
+// every downloaded dependency into licenses/<name>-<version>/ in the
+// artifact. A dependency's license_files globs, relative to the root of its
+// archive, select the files; without them any file whose name starts with
+// LICENSE, LICENCE, NOTICE or COPYING is taken.
+var CollectLicenses bool
+
+var defaultLicensePrefixes = []string{"LICENSE", "LICENCE", "NOTICE", "COPYING"}
+
+// LicenseFiles unpacks a dependency archive into dir and returns the paths,
+// relative to dir, of the license and notice files it contains. An archive
+// without any is an error; a dependency that is a single file, not an
+// archive, has none to find.
+func LicenseFiles(archive, dir string, patterns []string) ([]string, error) {
+	isArchive, err := unpackDependency(archive, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if isLicenseFile(filepath.ToSlash(rel), patterns) {
+			found = append(found, rel)
+		}
+		return nil
+	})
+	if err == nil && isArchive && len(found) == 0 {
+		return nil, fmt.Errorf("no license files found in %s, list them in the dependency's license_files", filepath.Base(archive))
+	}
+	return found, err
+}
+
+func isLicenseFile(rel string, patterns []string) bool {
+	if len(patterns) > 0 {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, rel); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	name := strings.ToUpper(filepath.Base(rel))
+	for _, prefix := range defaultLicensePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func collectLicenses(dependency Dependency, file File, bpDir string) ([]File, error) {
+	if !CollectLicenses {
+		return nil, nil
+	}
+	// the same version is often listed once per stack
+	licenseDir := filepath.Join("licenses", dependency.Name+"-"+dependency.Version)
+	if _, err := os.Stat(filepath.Join(bpDir, licenseDir)); err == nil {
+		return nil, nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "licenses")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	found, err := LicenseFiles(file.Path, tmpDir, dependency.LicenseFiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v", dependency.Name, dependency.Version, err)
+	}
+	if len(found) == 0 {
+		fmt.Fprintf(Stderr, "warning: %s %s is not an archive, so it has no license files to collect\n", dependency.Name, dependency.Version)
+		return nil, nil
+	}
+
+	var files []File
+	for _, rel := range found {
+		name := filepath.ToSlash(filepath.Join(licenseDir, rel))
+		dest := filepath.Join(bpDir, name)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := libbuildpack.CopyFile(filepath.Join(tmpDir, rel), dest); err != nil {
+			return nil, err
+		}
+		files = append(files, File{name, dest})
+	}
+	return files, nil
+}
@@ -0,0 +1,150 @@
+package packager_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Licenses", func() {
+	var (
+		tmpDir  string
+		archive string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "packager-licenses")
+		Expect(err).To(BeNil())
+
+		archive = filepath.Join(tmpDir, "thing-1.0.0.tgz")
+		f, err := os.Create(archive)
+		Expect(err).To(BeNil())
+		gz := gzip.NewWriter(f)
+		tw := tar.NewWriter(gz)
+		for name, body := range map[string]string{
+			"LICENSE.txt":      "MIT",
+			"vendor/NOTICE":    "notice",
+			"docs/THIRD_PARTY": "third party",
+			"bin/thing":        "#!/bin/sh",
+		} {
+			Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body))})).To(Succeed())
+			_, err := tw.Write([]byte(body))
+			Expect(err).To(BeNil())
+		}
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		packager.CollectLicenses = false
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("LicenseFiles", func() {
+		It("finds license and notice files by name", func() {
+			found, err := packager.LicenseFiles(archive, filepath.Join(tmpDir, "default"), nil)
+			Expect(err).To(BeNil())
+			Expect(found).To(ConsistOf("LICENSE.txt", filepath.Join("vendor", "NOTICE")))
+		})
+
+		It("uses the given patterns instead", func() {
+			found, err := packager.LicenseFiles(archive, filepath.Join(tmpDir, "patterns"), []string{"docs/*", "LICENSE*"})
+			Expect(err).To(BeNil())
+			Expect(found).To(ConsistOf("LICENSE.txt", filepath.Join("docs", "THIRD_PARTY")))
+		})
+
+		It("tells archives apart by their contents", func() {
+			renamed := filepath.Join(tmpDir, "thing-1.0.0.bin")
+			Expect(os.Rename(archive, renamed)).To(Succeed())
+
+			found, err := packager.LicenseFiles(renamed, filepath.Join(tmpDir, "renamed"), nil)
+			Expect(err).To(BeNil())
+			Expect(found).To(ConsistOf("LICENSE.txt", filepath.Join("vendor", "NOTICE")))
+		})
+
+		It("fails when an archive has no license files", func() {
+			_, err := packager.LicenseFiles(archive, filepath.Join(tmpDir, "none"), []string{"COPYRIGHT"})
+			Expect(err).To(MatchError("no license files found in thing-1.0.0.tgz, list them in the dependency's license_files"))
+		})
+
+		It("finds nothing in a dependency that is not an archive", func() {
+			script := filepath.Join(tmpDir, "install.sh")
+			Expect(ioutil.WriteFile(script, []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+
+			found, err := packager.LicenseFiles(script, filepath.Join(tmpDir, "script"), nil)
+			Expect(err).To(BeNil())
+			Expect(found).To(BeEmpty())
+		})
+	})
+
+	Context("packaging a cached buildpack", func() {
+		var (
+			buildpackDir string
+			artifact     string
+		)
+
+		BeforeEach(func() {
+			buildpackDir = filepath.Join(tmpDir, "buildpack")
+			Expect(os.MkdirAll(buildpackDir, 0755)).To(Succeed())
+
+			contents, err := ioutil.ReadFile(archive)
+			Expect(err).To(BeNil())
+			manifest := fmt.Sprintf(`---
+language: thing
+include_files:
+- manifest.yml
+- VERSION
+dependencies:
+- name: thing
+  version: 1.0.0
+  uri: file://%s
+  sha256: %x
+  cf_stacks: [cflinuxfs2, cflinuxfs3]
+`, archive, sha256.Sum256(contents))
+			Expect(ioutil.WriteFile(filepath.Join(buildpackDir, "manifest.yml"), []byte(manifest), 0644)).To(Succeed())
+		})
+
+		It("adds dependency licenses to the artifact", func() {
+			packager.CollectLicenses = true
+
+			var err error
+			artifact, err = packager.Package(buildpackDir, filepath.Join(tmpDir, "cache"), "1.2.3", "", true)
+			Expect(err).To(BeNil())
+
+			r, err := zip.OpenReader(artifact)
+			Expect(err).To(BeNil())
+			defer r.Close()
+			var names []string
+			for _, f := range r.File {
+				names = append(names, f.Name)
+			}
+			Expect(names).To(ContainElement("licenses/thing-1.0.0/LICENSE.txt"))
+			Expect(names).To(ContainElement("licenses/thing-1.0.0/vendor/NOTICE"))
+			Expect(names).ToNot(ContainElement("licenses/thing-1.0.0/bin/thing"))
+		})
+
+		It("leaves licenses out unless asked", func() {
+			var err error
+			artifact, err = packager.Package(buildpackDir, filepath.Join(tmpDir, "cache"), "1.2.3", "", true)
+			Expect(err).To(BeNil())
+
+			r, err := zip.OpenReader(artifact)
+			Expect(err).To(BeNil())
+			defer r.Close()
+			for _, f := range r.File {
+				Expect(f.Name).ToNot(HavePrefix("licenses/"))
+			}
+		})
+	})
+})
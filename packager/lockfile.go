@@ -0,0 +1,240 @@
+package packager
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type LockedDependency struct {
+	Name    string   `yaml:"name"`
+	Version string   `yaml:"version"`
+	URI     string   `yaml:"uri"`
+	SHA256  string   `yaml:"sha256"`
+	Stacks  []string `yaml:"cf_stacks,omitempty"`
+	Size    int64    `yaml:"size,omitempty"`
+	Date    string   `yaml:"date,omitempty"`
+}
+
+// Lockfile records the dependency set of a packaged buildpack so a later
+// build can be proven to contain exactly the approved dependencies. It is
+// signed with an HMAC-SHA256 of its contents when a key is given.
+type Lockfile struct {
+	Language     string             `yaml:"language"`
+	Version      string             `yaml:"version"`
+	Dependencies []LockedDependency `yaml:"dependencies"`
+	Signature    string             `yaml:"signature,omitempty"`
+}
+
+// ExportLockfile builds a lockfile from the manifest inside zipFile, a zip or
+// a tar.zst. For a cached buildpack the sha256 recorded is that of the
+// dependency file packaged in zipFile, so that a swapped file is caught even
+// if the manifest was left alone.
+func ExportLockfile(zipFile string, key []byte) (Lockfile, error) {
+	files, err := readArtifactFiles(zipFile, "manifest.yml", "VERSION")
+	if err != nil {
+		return Lockfile{}, err
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(files["manifest.yml"], &manifest); err != nil {
+		return Lockfile{}, fmt.Errorf("%s has an unreadable manifest.yml: %v", zipFile, err)
+	}
+
+	var packaged []string
+	for _, d := range manifest.Dependencies {
+		if d.File != "" {
+			packaged = append(packaged, d.File)
+		}
+	}
+	sums, err := hashArtifactFiles(zipFile, packaged...)
+	if err != nil {
+		return Lockfile{}, err
+	}
+
+	lockfile := Lockfile{Language: manifest.Language, Version: strings.TrimSpace(string(files["VERSION"]))}
+	for _, d := range manifest.Dependencies {
+		sum := d.SHA256
+		if d.File != "" {
+			sum = sums[d.File]
+		}
+		lockfile.Dependencies = append(lockfile.Dependencies, LockedDependency{
+			Name:    d.Name,
+			Version: d.Version,
+			URI:     d.URI,
+			SHA256:  sum,
+			Stacks:  d.Stacks,
+		})
+	}
+	sort.Slice(lockfile.Dependencies, func(i, j int) bool {
+		return lockfile.Dependencies[i].key() < lockfile.Dependencies[j].key()
+	})
+
+	if len(key) > 0 {
+		if lockfile.Signature, err = lockfile.sign(key); err != nil {
+			return Lockfile{}, err
+		}
+	}
+	return lockfile, nil
+}
+
+func ReadLockfile(path string) (Lockfile, error) {
+	var lockfile Lockfile
+	err := libbuildpack.NewYAML().Load(path, &lockfile)
+	return lockfile, err
+}
+
+func (l Lockfile) Write(path string) error {
+	return libbuildpack.NewYAML().Write(path, l)
+}
+
+// VerifyLockfile checks that the dependencies packaged in zipFile are exactly
+// those recorded in the lockfile at lockfilePath, and, when key is given,
+// that the lockfile's signature is valid. A signed lockfile needs the key.
+// The error lists every difference.
+func VerifyLockfile(zipFile, lockfilePath string, key []byte) error {
+	locked, err := ReadLockfile(lockfilePath)
+	if err != nil {
+		return err
+	}
+
+	if len(key) == 0 && locked.Signature != "" {
+		return fmt.Errorf("%s is signed, but no key was given to verify it with", lockfilePath)
+	}
+	if len(key) > 0 {
+		expected, err := locked.sign(key)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(expected), []byte(locked.Signature)) {
+			return fmt.Errorf("%s has an invalid signature", lockfilePath)
+		}
+	}
+
+	actual, err := ExportLockfile(zipFile, nil)
+	if err != nil {
+		return err
+	}
+
+	problems := lockProblems(actual.Dependencies, locked.Dependencies, "is not in the lockfile", "is locked but not packaged")
+	if len(problems) > 0 {
+		return fmt.Errorf("%s does not match %s:\n  %s", zipFile, lockfilePath, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// lockProblems lists every difference between the actual dependencies and
+// the locked ones, describing those with no locked counterpart as unlocked
+// and locked ones that are missing as extra.
+func lockProblems(actual, locked []LockedDependency, unlocked, extra string) []string {
+	lockedDeps := map[string]LockedDependency{}
+	for _, d := range locked {
+		lockedDeps[d.key()] = d
+	}
+
+	var problems []string
+	for _, d := range actual {
+		l, found := lockedDeps[d.key()]
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s %s %s", d.Name, d.Version, unlocked))
+			continue
+		}
+		delete(lockedDeps, d.key())
+		if l.SHA256 != d.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s %s has sha256 %s, locked %s", d.Name, d.Version, d.SHA256, l.SHA256))
+		}
+		if l.URI != d.URI {
+			problems = append(problems, fmt.Sprintf("%s %s has uri %s, locked %s", d.Name, d.Version, d.URI, l.URI))
+		}
+	}
+	for _, l := range locked {
+		if _, missing := lockedDeps[l.key()]; missing {
+			problems = append(problems, fmt.Sprintf("%s %s %s", l.Name, l.Version, extra))
+		}
+	}
+	return problems
+}
+
+func (d LockedDependency) key() string {
+	return fmt.Sprintf("%s %s %s", d.Name, d.Version, strings.Join(d.Stacks, ","))
+}
+
+func (l Lockfile) sign(key []byte) (string, error) {
+	l.Signature = ""
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func readArtifactFiles(zipFile string, names ...string) (map[string][]byte, error) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	files := map[string][]byte{}
+	err := walkArtifact(zipFile, func(name string, _ int64, r io.Reader) error {
+		if !wanted[name] {
+			return nil
+		}
+		data, err := ioutil.ReadAll(r)
+		files[name] = data
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if _, found := files[name]; !found {
+			return nil, fmt.Errorf("%s is missing %s", zipFile, name)
+		}
+	}
+	return files, nil
+}
+
+// hashArtifactFiles returns the hex encoded sha256 of each of the named files in
+// zipFile.
+func hashArtifactFiles(zipFile string, names ...string) (map[string]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	sums := map[string]string{}
+	err := walkArtifact(zipFile, func(name string, _ int64, r io.Reader) error {
+		if !wanted[name] {
+			return nil
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if _, found := sums[name]; !found {
+			return nil, fmt.Errorf("%s is missing %s", zipFile, name)
+		}
+	}
+	return sums, nil
+}
@@ -0,0 +1,140 @@
+package packager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lockfile", func() {
+	var (
+		cacheDir     string
+		lockfileDir  string
+		lockfilePath string
+		zipFile      string
+		err          error
+	)
+
+	BeforeEach(func() {
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+		lockfileDir, err = ioutil.TempDir("", "packager-lockfile")
+		Expect(err).To(BeNil())
+		lockfilePath = filepath.Join(lockfileDir, "buildpack.lock")
+
+		zipFile, err = packager.Package("./fixtures/good", cacheDir, "1.2.3", "cflinuxfs2", false)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cacheDir)
+		os.RemoveAll(lockfileDir)
+		os.Remove(zipFile)
+	})
+
+	It("exports the packaged dependencies", func() {
+		lockfile, err := packager.ExportLockfile(zipFile, nil)
+		Expect(err).To(BeNil())
+
+		Expect(lockfile.Language).To(Equal("ruby"))
+		Expect(lockfile.Version).To(Equal("1.2.3"))
+		Expect(lockfile.Signature).To(BeEmpty())
+		Expect(lockfile.Dependencies).To(Equal([]packager.LockedDependency{{
+			Name:    "ruby",
+			Version: "1.2.3",
+			URI:     "https://www.ietf.org/rfc/rfc2324.txt",
+			SHA256:  "b11329c3fd6dbe9dddcb8dd90f18a4bf441858a6b5bfaccae5f91e5c7d2b3596",
+		}}))
+	})
+
+	It("verifies an unchanged build", func() {
+		lockfile, err := packager.ExportLockfile(zipFile, nil)
+		Expect(err).To(BeNil())
+		Expect(lockfile.Write(lockfilePath)).To(Succeed())
+
+		Expect(packager.VerifyLockfile(zipFile, lockfilePath, nil)).To(Succeed())
+	})
+
+	It("reports dependencies that changed", func() {
+		lockfile, err := packager.ExportLockfile(zipFile, nil)
+		Expect(err).To(BeNil())
+		lockfile.Dependencies[0].SHA256 = "approved-sha"
+		lockfile.Dependencies = append(lockfile.Dependencies, packager.LockedDependency{Name: "node", Version: "10.0.0"})
+		Expect(lockfile.Write(lockfilePath)).To(Succeed())
+
+		err = packager.VerifyLockfile(zipFile, lockfilePath, nil)
+		Expect(err).To(MatchError(ContainSubstring("ruby 1.2.3 has sha256 b11329c3fd6dbe9dddcb8dd90f18a4bf441858a6b5bfaccae5f91e5c7d2b3596, locked approved-sha")))
+		Expect(err).To(MatchError(ContainSubstring("node 10.0.0 is locked but not packaged")))
+	})
+
+	Context("signed with a key", func() {
+		key := []byte("compliance")
+
+		BeforeEach(func() {
+			lockfile, err := packager.ExportLockfile(zipFile, key)
+			Expect(err).To(BeNil())
+			Expect(lockfile.Signature).ToNot(BeEmpty())
+			Expect(lockfile.Write(lockfilePath)).To(Succeed())
+		})
+
+		It("verifies with the same key", func() {
+			Expect(packager.VerifyLockfile(zipFile, lockfilePath, key)).To(Succeed())
+		})
+
+		It("rejects a different key", func() {
+			Expect(packager.VerifyLockfile(zipFile, lockfilePath, []byte("other"))).To(MatchError(ContainSubstring("invalid signature")))
+		})
+
+		It("needs the key to verify", func() {
+			Expect(packager.VerifyLockfile(zipFile, lockfilePath, nil)).To(MatchError(ContainSubstring("is signed, but no key was given")))
+		})
+
+		It("rejects a lockfile edited after signing", func() {
+			lockfile, err := packager.ReadLockfile(lockfilePath)
+			Expect(err).To(BeNil())
+			lockfile.Dependencies[0].URI = "https://example.com/ruby.tgz"
+			Expect(lockfile.Write(lockfilePath)).To(Succeed())
+
+			Expect(packager.VerifyLockfile(zipFile, lockfilePath, key)).To(MatchError(ContainSubstring("invalid signature")))
+		})
+	})
+
+	Context("a cached buildpack", func() {
+		var cachedZip, swappedZip string
+
+		writeZip := func(path, contents string) {
+			dir, err := ioutil.TempDir(lockfileDir, "buildpack")
+			Expect(err).To(BeNil())
+			manifest := "language: ruby\ndependencies:\n- name: ruby\n  version: 1.2.3\n  uri: https://example.com/ruby.tgz\n  sha256: b11329c3fd6dbe9dddcb8dd90f18a4bf441858a6b5bfaccae5f91e5c7d2b3596\n  file: dependencies/abc/ruby.tgz\n"
+			Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.yml"), []byte(manifest), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.2.3"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ruby.tgz"), []byte(contents), 0644)).To(Succeed())
+			Expect(packager.ZipFiles(path, []packager.File{
+				{Name: "manifest.yml", Path: filepath.Join(dir, "manifest.yml")},
+				{Name: "VERSION", Path: filepath.Join(dir, "VERSION")},
+				{Name: "dependencies/abc/ruby.tgz", Path: filepath.Join(dir, "ruby.tgz")},
+			})).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			cachedZip = filepath.Join(lockfileDir, "cached.zip")
+			writeZip(cachedZip, "ruby")
+			swappedZip = filepath.Join(lockfileDir, "swapped.zip")
+			writeZip(swappedZip, "not ruby")
+		})
+
+		It("locks the hashes of the packaged files", func() {
+			lockfile, err := packager.ExportLockfile(cachedZip, nil)
+			Expect(err).To(BeNil())
+			Expect(lockfile.Dependencies[0].SHA256).To(Equal("b9138194ffe9e7c8bb6d79d1ed56259553d18d9cb60b66e3ba5aa2e5b078055a"))
+			Expect(lockfile.Write(lockfilePath)).To(Succeed())
+
+			Expect(packager.VerifyLockfile(cachedZip, lockfilePath, nil)).To(Succeed())
+			Expect(packager.VerifyLockfile(swappedZip, lockfilePath, nil)).To(MatchError(ContainSubstring("ruby 1.2.3 has sha256")))
+		})
+	})
+})
@@ -0,0 +1,93 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// ManifestLockFile is the lockfile packaging keeps next to manifest.yml.
+const ManifestLockFile = "manifest.lock"
+
+// WriteManifestLock makes packaging write manifest.lock into the buildpack
+// dir, recording the uri and sha256 of every dependency in manifest.yml, the
+// size of those downloaded for a cached buildpack, and the date each was
+// locked. Entries whose uri and sha256 did not change keep their date.
+var WriteManifestLock bool
+
+type ManifestLock struct {
+	Dependencies []LockedDependency `yaml:"dependencies"`
+}
+
+// writeManifestLock locks the dependencies of manifest into bpDir, taking
+// sizes, by dependency key, from this packaging run or else the previous
+// lock.
+func writeManifestLock(bpDir string, manifest Manifest, sizes map[string]int64, now time.Time) error {
+	path := filepath.Join(bpDir, ManifestLockFile)
+
+	previous := map[string]LockedDependency{}
+	if _, err := os.Stat(path); err == nil {
+		var old ManifestLock
+		if err := libbuildpack.NewYAML().Load(path, &old); err != nil {
+			return err
+		}
+		for _, d := range old.Dependencies {
+			previous[d.key()] = d
+		}
+	}
+
+	var lock ManifestLock
+	for _, d := range manifest.Dependencies {
+		locked := lockedDependency(d)
+		locked.Date = now.UTC().Format("2006-01-02")
+		if old, found := previous[locked.key()]; found && old.URI == locked.URI && old.SHA256 == locked.SHA256 {
+			locked.Size = old.Size
+			locked.Date = old.Date
+		}
+		if size, found := sizes[locked.key()]; found {
+			locked.Size = size
+		}
+		lock.Dependencies = append(lock.Dependencies, locked)
+	}
+	sort.Slice(lock.Dependencies, func(i, j int) bool {
+		return lock.Dependencies[i].key() < lock.Dependencies[j].key()
+	})
+
+	return libbuildpack.NewYAML().Write(path, lock)
+}
+
+func lockedDependency(d Dependency) LockedDependency {
+	return LockedDependency{Name: d.Name, Version: d.Version, URI: d.URI, SHA256: d.SHA256, Stacks: d.Stacks}
+}
+
+// VerifyManifestLock checks that manifest.lock in bpDir locks exactly the
+// dependencies in its manifest.yml, with the same uris and sha256s, so that
+// a manifest edit without a regenerated lock is caught. The error lists
+// every difference.
+func VerifyManifestLock(bpDir string) error {
+	manifest, err := readManifest(bpDir)
+	if err != nil {
+		return err
+	}
+
+	var lock ManifestLock
+	if err := libbuildpack.NewYAML().Load(filepath.Join(bpDir, ManifestLockFile), &lock); err != nil {
+		return err
+	}
+
+	var actual []LockedDependency
+	for _, d := range manifest.Dependencies {
+		actual = append(actual, lockedDependency(d))
+	}
+
+	problems := lockProblems(actual, lock.Dependencies, "is not in "+ManifestLockFile, "is locked but not in manifest.yml")
+	if len(problems) > 0 {
+		return fmt.Errorf("manifest.yml does not match %s:\n  %s", ManifestLockFile, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
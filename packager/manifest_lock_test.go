@@ -0,0 +1,154 @@
+package packager_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var _ = Describe("ManifestLock", func() {
+	var (
+		bpDir    string
+		cacheDir string
+		depFile  string
+		sha256a  string
+		err      error
+	)
+
+	writeManifest := func(sha string) {
+		Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte(fmt.Sprintf(`---
+language: locked
+dependencies:
+- name: dep
+  version: 1.0.0
+  uri: file://%s
+  sha256: %s
+  cf_stacks:
+  - cflinuxfs3
+- name: other
+  version: 2.0.0
+  uri: file://%s
+  sha256: %s
+  cf_stacks:
+  - cflinuxfs2
+include_files:
+- manifest.yml
+- VERSION
+`, depFile, sha, depFile, sha)), 0644)).To(Succeed())
+	}
+
+	readLock := func() packager.ManifestLock {
+		data, err := ioutil.ReadFile(filepath.Join(bpDir, packager.ManifestLockFile))
+		Expect(err).To(BeNil())
+		var lock packager.ManifestLock
+		Expect(yaml.Unmarshal(data, &lock)).To(Succeed())
+		return lock
+	}
+
+	BeforeEach(func() {
+		bpDir, err = ioutil.TempDir("", "packager-bpdir")
+		Expect(err).To(BeNil())
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+
+		depFile = filepath.Join(bpDir, "dep.txt")
+		Expect(ioutil.WriteFile(depFile, []byte("dependency"), 0644)).To(Succeed())
+		sum := sha256.Sum256([]byte("dependency"))
+		sha256a = hex.EncodeToString(sum[:])
+
+		Expect(ioutil.WriteFile(filepath.Join(bpDir, "VERSION"), []byte("1.0.0"), 0644)).To(Succeed())
+		writeManifest(sha256a)
+
+		packager.WriteManifestLock = true
+	})
+
+	AfterEach(func() {
+		packager.WriteManifestLock = false
+		os.RemoveAll(bpDir)
+		os.RemoveAll(cacheDir)
+	})
+
+	Context("when packaging", func() {
+		It("locks every dependency in the manifest", func() {
+			_, err = packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", true)
+			Expect(err).To(BeNil())
+
+			today := time.Now().UTC().Format("2006-01-02")
+			Expect(readLock().Dependencies).To(Equal([]packager.LockedDependency{
+				{Name: "dep", Version: "1.0.0", URI: "file://" + depFile, SHA256: sha256a, Stacks: []string{"cflinuxfs3"}, Size: 10, Date: today},
+				{Name: "other", Version: "2.0.0", URI: "file://" + depFile, SHA256: sha256a, Stacks: []string{"cflinuxfs2"}, Date: today},
+			}))
+			Expect(packager.VerifyManifestLock(bpDir)).To(Succeed())
+		})
+
+		It("keeps the date and size of unchanged dependencies", func() {
+			_, err = packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", true)
+			Expect(err).To(BeNil())
+			lock := readLock()
+			lock.Dependencies[0].Date = "2019-01-01"
+			data, err := yaml.Marshal(lock)
+			Expect(err).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(bpDir, packager.ManifestLockFile), data, 0644)).To(Succeed())
+
+			_, err = packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", false)
+			Expect(err).To(BeNil())
+
+			Expect(readLock().Dependencies[0].Date).To(Equal("2019-01-01"))
+			Expect(readLock().Dependencies[0].Size).To(Equal(int64(10)))
+		})
+
+		It("does not lock unless asked to", func() {
+			packager.WriteManifestLock = false
+
+			_, err = packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", false)
+			Expect(err).To(BeNil())
+			Expect(filepath.Join(bpDir, packager.ManifestLockFile)).ToNot(BeAnExistingFile())
+		})
+	})
+
+	Describe("VerifyManifestLock", func() {
+		BeforeEach(func() {
+			_, err = packager.Package(bpDir, cacheDir, "1.0.0", "cflinuxfs3", false)
+			Expect(err).To(BeNil())
+		})
+
+		It("fails when the manifest was edited without locking", func() {
+			writeManifest("0000")
+
+			Expect(packager.VerifyManifestLock(bpDir)).To(MatchError(fmt.Sprintf(
+				"manifest.yml does not match manifest.lock:\n  dep 1.0.0 has sha256 0000, locked %[1]s\n  other 2.0.0 has sha256 0000, locked %[1]s", sha256a)))
+		})
+
+		It("fails when dependencies were added or removed", func() {
+			Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte(fmt.Sprintf(`---
+language: locked
+dependencies:
+- name: dep
+  version: 1.0.1
+  uri: file://%s
+  sha256: %s
+  cf_stacks:
+  - cflinuxfs3
+`, depFile, sha256a)), 0644)).To(Succeed())
+
+			err = packager.VerifyManifestLock(bpDir)
+			Expect(err).To(MatchError(ContainSubstring("dep 1.0.1 is not in manifest.lock")))
+			Expect(err).To(MatchError(ContainSubstring("dep 1.0.0 is locked but not in manifest.yml")))
+			Expect(err).To(MatchError(ContainSubstring("other 2.0.0 is locked but not in manifest.yml")))
+		})
+
+		It("fails without a lock", func() {
+			Expect(os.Remove(filepath.Join(bpDir, packager.ManifestLockFile))).To(Succeed())
+			Expect(packager.VerifyManifestLock(bpDir)).ToNot(Succeed())
+		})
+	})
+})
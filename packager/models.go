@@ -1,15 +1,51 @@
 package packager
 
-import "github.com/Masterminds/semver"
+import (
+	"sort"
+
+	"github.com/Masterminds/semver"
+	"github.com/cloudfoundry/libbuildpack"
+)
 
 type Dependency struct {
-	URI     string   `yaml:"uri"`
-	File    string   `yaml:"file"`
-	SHA256  string   `yaml:"sha256"`
-	Name    string   `yaml:"name"`
-	Version string   `yaml:"version"`
-	Stacks  []string `yaml:"cf_stacks"`
-	Modules []string `yaml:"modules"`
+	URI          string            `yaml:"uri"`
+	Mirrors      []string          `yaml:"mirrors"`
+	Source       string            `yaml:"source"`
+	File         string            `yaml:"file"`
+	SHA256       string            `yaml:"sha256"`
+	SHA512       string            `yaml:"sha512"`
+	Checksums    map[string]string `yaml:"checksums"`
+	Name         string            `yaml:"name"`
+	Version      string            `yaml:"version"`
+	Stacks       []string          `yaml:"cf_stacks"`
+	Modules      []string          `yaml:"modules"`
+	LicenseFiles []string          `yaml:"license_files"`
+}
+
+// verifyChecksums checks file against every checksum the dependency
+// declares.
+func (d Dependency) verifyChecksums(file string) error {
+	checksums, err := libbuildpack.ParseChecksums(d.SHA256, d.SHA512, d.Checksums)
+	if err != nil {
+		return err
+	}
+	return libbuildpack.VerifyChecksums(file, checksums)
+}
+
+// checksumKey identifies the expected contents of the dependency, for
+// recording that a download has been verified.
+func (d Dependency) checksumKey() string {
+	var algorithms []string
+	for algorithm := range d.Checksums {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+
+	key := d.SHA256 + "\n" + d.SHA512
+	for _, algorithm := range algorithms {
+		key += "\n" + algorithm + ":" + d.Checksums[algorithm]
+	}
+	return key
 }
 
 type Dependencies []Dependency
@@ -17,6 +53,7 @@ type Dependencies []Dependency
 type Manifest struct {
 	Language     string       `yaml:"language"`
 	Stack        string       `yaml:"stack"`
+	MinVersion   string       `yaml:"min_packager_version"`
 	IncludeFiles []string     `yaml:"include_files"`
 	PrePackage   string       `yaml:"pre_package"`
 	Dependencies Dependencies `yaml:"dependencies"`
@@ -0,0 +1,274 @@
+package packager
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NormalizeReport says what Normalize changed in a manifest.
+type NormalizeReport struct {
+	Changed    bool
+	Duplicates int
+	Merged     int
+}
+
+// dependencyFieldOrder is the canonical order of dependency fields; any
+// others follow alphabetically.
+var dependencyFieldOrder = []string{"name", "version", "uri", "sha256", "cf_stacks", "source", "source_sha256"}
+
+// Normalize rewrites the manifest.yml in bpDir so that dependencies are
+// sorted by name and version, exact duplicates are removed, entries for the
+// same name, version and sha256 that differ only in cf_stacks are merged, and
+// dependency fields are in a canonical order. With write false the manifest
+// is left alone and the report says whether it would change. Comments in the
+// manifest are not preserved. Scalars keep their text, so a version written
+// 1.10 stays 1.10, quoted where YAML would otherwise read it as another value.
+func Normalize(bpDir string, write bool) (NormalizeReport, error) {
+	path := filepath.Join(bpDir, "manifest.yml")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+
+	normalized, report, err := NormalizeManifest(data)
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+	if write && report.Changed {
+		if err := ioutil.WriteFile(path, normalized, 0644); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// NormalizeManifest is Normalize for the contents of a manifest.yml.
+func NormalizeManifest(data []byte) ([]byte, NormalizeReport, error) {
+	var report NormalizeReport
+
+	var raw rawYAML
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, report, err
+	}
+	manifest, ok := raw.value.(yaml.MapSlice)
+	if !ok {
+		return nil, report, fmt.Errorf("could not read manifest as a map")
+	}
+
+	for i, item := range manifest {
+		if item.Key != "dependencies" || item.Value == nil {
+			continue
+		}
+		deps, ok := item.Value.([]interface{})
+		if !ok {
+			return nil, report, fmt.Errorf("could not read dependencies as a list")
+		}
+
+		var normalized []yaml.MapSlice
+		for _, d := range deps {
+			dep, ok := d.(yaml.MapSlice)
+			if !ok {
+				return nil, report, fmt.Errorf("could not read dependency %v", d)
+			}
+			dep = canonicalDependency(dep)
+
+			merged := false
+			for j, existing := range normalized {
+				if reflect.DeepEqual(existing, dep) {
+					report.Duplicates++
+					merged = true
+				} else if sameExceptStacks(existing, dep) {
+					normalized[j] = setField(existing, "cf_stacks", mergeStacks(field(existing, "cf_stacks"), field(dep, "cf_stacks")))
+					report.Merged++
+					merged = true
+				}
+				if merged {
+					break
+				}
+			}
+			if !merged {
+				normalized = append(normalized, dep)
+			}
+		}
+
+		sort.SliceStable(normalized, func(a, b int) bool {
+			return dependencyLess(normalized[a], normalized[b])
+		})
+		manifest[i].Value = normalized
+	}
+
+	out, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, report, err
+	}
+	if !bytes.HasPrefix(out, []byte("---")) && bytes.HasPrefix(data, []byte("---")) {
+		out = append([]byte("---\n"), out...)
+	}
+	report.Changed = !bytes.Equal(out, data)
+	return out, report, nil
+}
+
+// rawYAML decodes a YAML document like yaml.MapSlice does, except that
+// scalars are kept as rawScalars.
+type rawYAML struct {
+	value interface{}
+}
+
+func (r *rawYAML) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch v.(type) {
+	case nil:
+		r.value = nil
+	case map[interface{}]interface{}:
+		var keys yaml.MapSlice
+		if err := unmarshal(&keys); err != nil {
+			return err
+		}
+		var values map[interface{}]rawYAML
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+		mapping := yaml.MapSlice{}
+		for _, item := range keys {
+			mapping = append(mapping, yaml.MapItem{Key: item.Key, Value: values[item.Key].value})
+		}
+		r.value = mapping
+	case []interface{}:
+		var items []rawYAML
+		if err := unmarshal(&items); err != nil {
+			return err
+		}
+		list := []interface{}{}
+		for _, item := range items {
+			list = append(list, item.value)
+		}
+		r.value = list
+	default:
+		var text string
+		if err := unmarshal(&text); err != nil {
+			return err
+		}
+		r.value = rawScalar{text: text, value: v}
+	}
+	return nil
+}
+
+// rawScalar is a scalar along with the text it was written as, which YAML
+// may read as a different value, like 1.10 for the float 1.1.
+type rawScalar struct {
+	text  string
+	value interface{}
+}
+
+func (s rawScalar) String() string {
+	return s.text
+}
+
+func (s rawScalar) MarshalYAML() (interface{}, error) {
+	out, err := yaml.Marshal(s.value)
+	if err == nil && strings.TrimSuffix(string(out), "\n") == s.text {
+		return s.value, nil
+	}
+	return s.text, nil
+}
+
+func canonicalDependency(dep yaml.MapSlice) yaml.MapSlice {
+	rank := func(key interface{}) int {
+		for i, k := range dependencyFieldOrder {
+			if key == k {
+				return i
+			}
+		}
+		return len(dependencyFieldOrder)
+	}
+
+	sorted := append(yaml.MapSlice{}, dep...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		ra, rb := rank(sorted[a].Key), rank(sorted[b].Key)
+		if ra != rb {
+			return ra < rb
+		}
+		return fmt.Sprint(sorted[a].Key) < fmt.Sprint(sorted[b].Key)
+	})
+
+	if stacks := field(sorted, "cf_stacks"); stacks != nil {
+		sorted = setField(sorted, "cf_stacks", mergeStacks(stacks, nil))
+	}
+	return sorted
+}
+
+func field(dep yaml.MapSlice, key string) interface{} {
+	for _, item := range dep {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setField(dep yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	result := append(yaml.MapSlice{}, dep...)
+	for i, item := range result {
+		if item.Key == key {
+			result[i].Value = value
+		}
+	}
+	return result
+}
+
+// sameExceptStacks reports whether a and b are the same name, version and
+// sha256 and differ in nothing but cf_stacks.
+func sameExceptStacks(a, b yaml.MapSlice) bool {
+	if field(a, "sha256") == nil || field(a, "cf_stacks") == nil || field(b, "cf_stacks") == nil {
+		return false
+	}
+	return reflect.DeepEqual(setField(a, "cf_stacks", nil), setField(b, "cf_stacks", nil))
+}
+
+func mergeStacks(a, b interface{}) []interface{} {
+	seen := map[string]bool{}
+	var stacks []string
+	for _, list := range []interface{}{a, b} {
+		items, _ := list.([]interface{})
+		for _, s := range items {
+			if name := fmt.Sprint(s); !seen[name] {
+				seen[name] = true
+				stacks = append(stacks, name)
+			}
+		}
+	}
+	sort.Strings(stacks)
+
+	result := []interface{}{}
+	for _, s := range stacks {
+		result = append(result, s)
+	}
+	return result
+}
+
+func dependencyLess(a, b yaml.MapSlice) bool {
+	nameA, nameB := fmt.Sprint(field(a, "name")), fmt.Sprint(field(b, "name"))
+	if nameA != nameB {
+		return nameA < nameB
+	}
+
+	versionA, versionB := fmt.Sprint(field(a, "version")), fmt.Sprint(field(b, "version"))
+	va, errA := semver.NewVersion(versionA)
+	vb, errB := semver.NewVersion(versionB)
+	if errA == nil && errB == nil {
+		return va.LessThan(vb)
+	}
+	return versionA < versionB
+}
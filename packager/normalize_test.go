@@ -0,0 +1,200 @@
+package packager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Normalize", func() {
+	const messy = `---
+language: ruby
+dependencies:
+- version: 2.10.0
+  name: ruby
+  sha256: bbb
+  uri: https://example.com/ruby-2.10.0-cflinuxfs3.tgz
+  cf_stacks:
+  - cflinuxfs3
+- name: bundler
+  version: 1.17.3
+  uri: https://example.com/bundler-1.17.3.tgz
+  sha256: aaa
+  cf_stacks:
+  - cflinuxfs3
+- name: ruby
+  version: 2.9.1
+  uri: https://example.com/ruby-2.9.1.tgz
+  sha256: ccc
+  cf_stacks:
+  - cflinuxfs3
+  - cflinuxfs2
+- name: bundler
+  version: 1.17.3
+  uri: https://example.com/bundler-1.17.3.tgz
+  sha256: aaa
+  cf_stacks:
+  - cflinuxfs3
+- name: ruby
+  version: 2.10.0
+  uri: https://example.com/ruby-2.10.0-cflinuxfs3.tgz
+  sha256: bbb
+  cf_stacks:
+  - cflinuxfs4
+  source_sha256: ddd
+`
+
+	const clean = `---
+language: ruby
+dependencies:
+- name: bundler
+  version: 1.17.3
+  uri: https://example.com/bundler-1.17.3.tgz
+  sha256: aaa
+  cf_stacks:
+  - cflinuxfs3
+- name: ruby
+  version: 2.9.1
+  uri: https://example.com/ruby-2.9.1.tgz
+  sha256: ccc
+  cf_stacks:
+  - cflinuxfs2
+  - cflinuxfs3
+- name: ruby
+  version: 2.10.0
+  uri: https://example.com/ruby-2.10.0-cflinuxfs3.tgz
+  sha256: bbb
+  cf_stacks:
+  - cflinuxfs3
+- name: ruby
+  version: 2.10.0
+  uri: https://example.com/ruby-2.10.0-cflinuxfs3.tgz
+  sha256: bbb
+  cf_stacks:
+  - cflinuxfs4
+  source_sha256: ddd
+`
+
+	It("sorts, deduplicates and orders fields", func() {
+		out, report, err := packager.NormalizeManifest([]byte(messy))
+		Expect(err).To(BeNil())
+		Expect(string(out)).To(Equal(clean))
+		Expect(report).To(Equal(packager.NormalizeReport{Changed: true, Duplicates: 1}))
+	})
+
+	It("merges the stacks of otherwise identical entries", func() {
+		manifest := `---
+dependencies:
+- name: node
+  version: 12.0.0
+  uri: https://example.com/node.tgz
+  sha256: aaa
+  cf_stacks:
+  - cflinuxfs3
+- name: node
+  version: 12.0.0
+  uri: https://example.com/node.tgz
+  sha256: aaa
+  cf_stacks:
+  - cflinuxfs2
+`
+		out, report, err := packager.NormalizeManifest([]byte(manifest))
+		Expect(err).To(BeNil())
+		Expect(report.Merged).To(Equal(1))
+		Expect(string(out)).To(Equal(`---
+dependencies:
+- name: node
+  version: 12.0.0
+  uri: https://example.com/node.tgz
+  sha256: aaa
+  cf_stacks:
+  - cflinuxfs2
+  - cflinuxfs3
+`))
+	})
+
+	It("keeps versions that YAML would read as numbers", func() {
+		manifest := `---
+language: go
+default_versions:
+- name: go
+  version: 1.10
+dependencies:
+- name: go
+  version: 1.10
+  uri: https://example.com/go-1.10.tgz
+  sha256: bbb
+- name: go
+  version: 1.9
+  uri: https://example.com/go-1.9.tgz
+  sha256: aaa
+- name: dep
+  version: "0.50"
+  uri: https://example.com/dep.tgz
+  sha256: ccc
+  ttl: 30
+`
+		out, _, err := packager.NormalizeManifest([]byte(manifest))
+		Expect(err).To(BeNil())
+		Expect(string(out)).To(Equal(`---
+language: go
+default_versions:
+- name: go
+  version: "1.10"
+dependencies:
+- name: dep
+  version: "0.50"
+  uri: https://example.com/dep.tgz
+  sha256: ccc
+  ttl: 30
+- name: go
+  version: 1.9
+  uri: https://example.com/go-1.9.tgz
+  sha256: aaa
+- name: go
+  version: "1.10"
+  uri: https://example.com/go-1.10.tgz
+  sha256: bbb
+`))
+
+		var parsed packager.Manifest
+		Expect(yaml.Unmarshal(out, &parsed)).To(Succeed())
+		Expect(parsed.Dependencies[2].Version).To(Equal("1.10"))
+	})
+
+	It("leaves a normalized manifest unchanged", func() {
+		out, report, err := packager.NormalizeManifest([]byte(clean))
+		Expect(err).To(BeNil())
+		Expect(string(out)).To(Equal(clean))
+		Expect(report.Changed).To(BeFalse())
+	})
+
+	Context("on a buildpack directory", func() {
+		var bpDir string
+
+		BeforeEach(func() {
+			var err error
+			bpDir, err = ioutil.TempDir("", "normalize")
+			Expect(err).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte(messy), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() { os.RemoveAll(bpDir) })
+
+		It("only checks unless asked to write", func() {
+			report, err := packager.Normalize(bpDir, false)
+			Expect(err).To(BeNil())
+			Expect(report.Changed).To(BeTrue())
+			Expect(ioutil.ReadFile(filepath.Join(bpDir, "manifest.yml"))).To(Equal([]byte(messy)))
+
+			_, err = packager.Normalize(bpDir, true)
+			Expect(err).To(BeNil())
+			Expect(ioutil.ReadFile(filepath.Join(bpDir, "manifest.yml"))).To(Equal([]byte(clean)))
+		})
+	})
+})
@@ -4,26 +4,58 @@ package packager
 
 import (
 	"archive/zip"
+	"compress/flate"
+	"context"
 	"crypto/md5"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/cloudfoundry/libbuildpack"
+	"github.com/cloudfoundry/libbuildpack/cachedownloader"
 )
 
 var CacheDir = filepath.Join(os.Getenv("HOME"), ".buildpack-packager", "cache")
 var Stdout, Stderr io.Writer = os.Stdout, os.Stderr
 
+// CompressionLevel is the deflate level, from flate.BestSpeed to
+// flate.BestCompression, used for zip artifacts and passed on to zstd.
+var CompressionLevel = flate.DefaultCompression
+
+const (
+	FormatZip    = "zip"
+	FormatTarZst = "tar.zst"
+)
+
+// ArtifactFormat selects the packaged artifact type. FormatTarZst produces a
+// zstd-compressed tarball, much smaller for cached buildpacks, for platforms
+// that accept it; it needs the zstd command on the PATH.
+var ArtifactFormat = FormatZip
+
+// PackageOptions are the settings of PackageWithOptions that most callers
+// leave unset.
+//
+// DependencyURITemplate, when set, replaces the uri of every dependency in
+// the manifest of an uncached buildpack, e.g. to point at a mirror. It is a
+// text/template executed with a URITemplateData.
+type PackageOptions struct {
+	DependencyURITemplate string
+}
+
+// URITemplateData is passed to PackageOptions.DependencyURITemplate. Stack is the stack
+// being packaged for and is empty when packaging for any stack.
+type URITemplateData struct {
+	Name, Version, Stack, Filename, URI string
+}
+
 func CompileExtensionPackage(bpDir, version string, cached bool, stack string) (string, error) {
 	bpDir, err := filepath.Abs(bpDir)
 	if err != nil {
@@ -115,26 +147,113 @@ func updateDependencyMap(dependencyMap interface{}, file File) error {
 	return nil
 }
 
-func downloadDependency(dependency Dependency, cacheDir string) (File, error) {
+func rewriteDependencyURI(uriTemplate *template.Template, dependencyMap interface{}, dependency Dependency, stack string) error {
+	dep, ok := dependencyMap.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("Could not cast deps[idx] to map[interface{}]interface{}")
+	}
+
+	var uri strings.Builder
+	data := URITemplateData{
+		Name:     dependency.Name,
+		Version:  dependency.Version,
+		Stack:    stack,
+		Filename: filepath.Base(dependency.URI),
+		URI:      dependency.URI,
+	}
+	if err := uriTemplate.Execute(&uri, data); err != nil {
+		return fmt.Errorf("could not rewrite uri of %s %s: %v", dependency.Name, dependency.Version, err)
+	}
+	dep["uri"] = uri.String()
+	return nil
+}
+
+func downloadDependency(ctx context.Context, dependency Dependency, cacheDir string, resume bool) (File, error) {
 	file := filepath.Join("dependencies", fmt.Sprintf("%x", md5.Sum([]byte(dependency.URI))), filepath.Base(dependency.URI))
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		log.Fatalf("error: %v", err)
 	}
 
-	if _, err := os.Stat(filepath.Join(cacheDir, file)); err != nil {
-		if err := DownloadFromURI(dependency.URI, filepath.Join(cacheDir, file)); err != nil {
+	cachedFile := filepath.Join(cacheDir, file)
+	verifiedMarker := cachedFile + ".verified"
+	if resume {
+		if sha, err := ioutil.ReadFile(verifiedMarker); err == nil && string(sha) == dependency.checksumKey() {
+			if _, err := os.Stat(cachedFile); err == nil {
+				return File{file, cachedFile}, nil
+			}
+		}
+	}
+
+	if _, err := os.Stat(cachedFile); err != nil {
+		if err := downloadFromMirrors(ctx, dependency, cachedFile); err != nil {
+			return File{}, err
+		}
+	} else if err := dependency.verifyChecksums(cachedFile); err != nil {
+		// the dependency may have been republished at the same uri, which
+		// revalidating the cached file picks up
+		if err := downloadFromMirrors(ctx, dependency, cachedFile); err != nil {
 			return File{}, err
 		}
 	}
 
-	if err := checkSha256(filepath.Join(cacheDir, file), dependency.SHA256); err != nil {
+	if err := ioutil.WriteFile(verifiedMarker, []byte(dependency.checksumKey()), 0644); err != nil {
 		return File{}, err
 	}
 
-	return File{file, filepath.Join(cacheDir, file)}, nil
+	return File{file, cachedFile}, nil
+}
+
+func checkBinaryCompatibility(dependency Dependency, file File, stack string) error {
+	if !CheckBinaryCompatibility {
+		return nil
+	}
+
+	stacks := dependency.Stacks
+	if stack != "" {
+		stacks = []string{stack}
+	}
+	problems, err := BinaryCompatibilityProblems(file.Path, stacks)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s %s will not run on its stacks:\n  %s", dependency.Name, dependency.Version, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// downloadFromMirrors tries the dependency's uri and then each of its mirrors
+// in order, keeping the first download that matches the checksums.
+func downloadFromMirrors(ctx context.Context, dependency Dependency, cachedFile string) error {
+	var err error
+	for _, uri := range append([]string{dependency.URI}, dependency.Mirrors...) {
+		if err = downloadFromURI(ctx, uri, cachedFile); err == nil {
+			if err = dependency.verifyChecksums(cachedFile); err == nil {
+				return nil
+			}
+			os.Remove(cachedFile)
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
 }
 
 func Package(bpDir, cacheDir, version, stack string, cached bool) (string, error) {
+	return PackageContext(context.Background(), bpDir, cacheDir, version, stack, cached, false)
+}
+
+// PackageContext is Package, stopping between and during dependency downloads
+// once ctx is cancelled. Downloads land in the cache dir only when complete, so
+// a cancelled run can be resumed; with resume set, dependencies already
+// verified by an earlier run are not checksummed again.
+func PackageContext(ctx context.Context, bpDir, cacheDir, version, stack string, cached, resume bool) (string, error) {
+	return PackageWithOptions(ctx, bpDir, cacheDir, version, stack, cached, resume, PackageOptions{})
+}
+
+// PackageWithOptions is PackageContext with the settings in opts.
+func PackageWithOptions(ctx context.Context, bpDir, cacheDir, version, stack string, cached, resume bool, opts PackageOptions) (string, error) {
 	bpDir, err := filepath.Abs(bpDir)
 	if err != nil {
 		return "", err
@@ -159,6 +278,14 @@ func Package(bpDir, cacheDir, version, stack string, cached bool) (string, error
 		return "", err
 	}
 
+	if err := libbuildpack.CheckMinVersion(manifest.MinVersion); err != nil {
+		return "", err
+	}
+
+	if err := checkHTTPSPolicy(manifest); err != nil {
+		return "", err
+	}
+
 	if manifest.PrePackage != "" {
 		cmd := exec.Command(manifest.PrePackage)
 		cmd.Dir = dir
@@ -173,6 +300,11 @@ func Package(bpDir, cacheDir, version, stack string, cached bool) (string, error
 	for _, name := range manifest.IncludeFiles {
 		files = append(files, File{name, filepath.Join(dir, name)})
 	}
+	extensions, err := extensionFiles(dir, manifest.IncludeFiles)
+	if err != nil {
+		return "", err
+	}
+	files = append(files, extensions...)
 
 	var m map[string]interface{}
 	if err := libbuildpack.NewYAML().Load(filepath.Join(dir, "manifest.yml"), &m); err != nil {
@@ -187,17 +319,46 @@ func Package(bpDir, cacheDir, version, stack string, cached bool) (string, error
 	if !ok {
 		return "", fmt.Errorf("Could not cast dependencies to []interface{}")
 	}
+	var uriTemplate *template.Template
+	if !cached && opts.DependencyURITemplate != "" {
+		uriTemplate, err = template.New("uri").Parse(opts.DependencyURITemplate)
+		if err != nil {
+			return "", fmt.Errorf("invalid dependency uri template: %v", err)
+		}
+	}
+
 	dependenciesForStack := []interface{}{}
+	sizes := map[string]int64{}
 	for idx, d := range manifest.Dependencies {
 		for _, s := range d.Stacks {
 			if stack == "" || s == stack {
 				dependencyMap := deps[idx]
 				if cached {
-					if file, err := downloadDependency(d, cacheDir); err != nil {
+					if err := ctx.Err(); err != nil {
+						return "", fmt.Errorf("packaging cancelled: %v", err)
+					}
+					if file, err := downloadDependency(ctx, d, cacheDir, resume); err != nil {
 						return "", err
 					} else {
+						if err := checkBinaryCompatibility(d, file, stack); err != nil {
+							return "", err
+						}
 						updateDependencyMap(dependencyMap, file)
 						files = append(files, file)
+						if info, err := os.Stat(file.Path); err == nil {
+							sizes[lockedDependency(d).key()] = info.Size()
+						}
+
+						licenses, err := collectLicenses(d, file, dir)
+						if err != nil {
+							return "", err
+						}
+						files = append(files, licenses...)
+					}
+				}
+				if uriTemplate != nil {
+					if err := rewriteDependencyURI(uriTemplate, dependencyMap, d, stack); err != nil {
+						return "", err
 					}
 				}
 				if stack != "" {
@@ -214,6 +375,12 @@ func Package(bpDir, cacheDir, version, stack string, cached bool) (string, error
 		return "", err
 	}
 
+	if WriteManifestLock {
+		if err := writeManifestLock(bpDir, manifest, sizes, time.Now()); err != nil {
+			return "", fmt.Errorf("could not write %s: %v", ManifestLockFile, err)
+		}
+	}
+
 	stackPart := ""
 	if stack != "" {
 		stackPart = "-" + stack
@@ -224,6 +391,11 @@ func Package(bpDir, cacheDir, version, stack string, cached bool) (string, error
 		cachedPart = "-cached"
 	}
 
+	if ArtifactFormat == FormatTarZst {
+		tarFile := filepath.Join(bpDir, fmt.Sprintf("%s_buildpack%s%s-v%s.tar.zst", manifest.Language, cachedPart, stackPart, version))
+		return tarFile, TarZstFiles(tarFile, files)
+	}
+
 	fileName := fmt.Sprintf("%s_buildpack%s%s-v%s.zip", manifest.Language, cachedPart, stackPart, version)
 	zipFile := filepath.Join(bpDir, fileName)
 
@@ -234,63 +406,81 @@ func Package(bpDir, cacheDir, version, stack string, cached bool) (string, error
 	return zipFile, err
 }
 
-func DownloadFromURI(uri, fileName string) error {
-	err := os.MkdirAll(filepath.Dir(fileName), 0755)
-	if err != nil {
-		return err
+// extensionFiles lists the files in the buildpack's extensions dir that
+// include_files does not, so that operators' extensions are always packaged
+// for libbuildpack to apply at staging.
+func extensionFiles(dir string, included []string) ([]File, error) {
+	listed := map[string]bool{}
+	for _, name := range included {
+		listed[filepath.ToSlash(filepath.Clean(name))] = true
 	}
 
-	output, err := os.Create(fileName)
-	if err != nil {
-		return err
+	root := filepath.Join(dir, libbuildpack.ExtensionsDir)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
 	}
-	defer output.Close()
 
-	u, err := url.Parse(uri)
-	if err != nil {
-		return err
-	}
-
-	var source io.ReadCloser
-
-	if u.Scheme == "file" {
-		source, err = os.Open(u.Path)
-		if err != nil {
+	var files []File
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
 			return err
 		}
-		defer source.Close()
-	} else {
-		response, err := http.Get(uri)
+		name, err := filepath.Rel(dir, path)
 		if err != nil {
 			return err
 		}
-		defer response.Body.Close()
-		source = response.Body
-
-		if response.StatusCode < 200 || response.StatusCode > 299 {
-			return fmt.Errorf("could not download: %d", response.StatusCode)
+		if name = filepath.ToSlash(name); !listed[name] {
+			files = append(files, File{name, path})
 		}
-	}
-
-	_, err = io.Copy(output, source)
+		return nil
+	})
+	return files, err
+}
 
-	return err
+func DownloadFromURI(uri, fileName string) error {
+	return downloadFromURI(context.Background(), uri, fileName)
 }
 
-func checkSha256(filePath, expectedSha256 string) error {
-	content, err := ioutil.ReadFile(filePath)
+// downloadFromURI downloads uri to fileName with the libbuildpack.Fetcher
+// for its scheme. An http(s) download already at fileName is revalidated
+// with the server instead of downloaded again.
+func downloadFromURI(ctx context.Context, uri, fileName string) error {
+	fetcher, err := libbuildpack.FetcherFor(uri)
 	if err != nil {
 		return err
 	}
+	if _, ok := fetcher.(*libbuildpack.HTTPFetcher); ok {
+		_, err := cachedownloader.New(http.DefaultClient).Fetch(ctx, uri, fileName)
+		return err
+	}
 
-	sum := sha256.Sum256(content)
+	err = os.MkdirAll(filepath.Dir(fileName), 0755)
+	if err != nil {
+		return err
+	}
 
-	actualSha256 := hex.EncodeToString(sum[:])
+	partialFile := fileName + ".partial"
+	output, err := os.Create(partialFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partialFile)
+	defer output.Close()
 
-	if actualSha256 != expectedSha256 {
-		return fmt.Errorf("dependency sha256 mismatch: expected sha256 %s, actual sha256 %s", expectedSha256, actualSha256)
+	source, _, err := fetcher.Get(ctx, uri)
+	if err != nil {
+		return err
 	}
-	return nil
+	defer source.Close()
+
+	if _, err = io.Copy(output, source); err != nil {
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partialFile, fileName)
 }
 
 func ZipFiles(filename string, files []File) error {
@@ -302,6 +492,9 @@ func ZipFiles(filename string, files []File) error {
 
 	zipWriter := zip.NewWriter(newfile)
 	defer zipWriter.Close()
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, CompressionLevel)
+	})
 
 	// Add files to zip
 	for _, file := range files {
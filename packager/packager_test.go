@@ -1,6 +1,8 @@
 package packager_test
 
 import (
+	"archive/zip"
+	"context"
 	"crypto/md5"
 	"fmt"
 	"io/ioutil"
@@ -251,6 +253,22 @@ var _ = Describe("Packager", func() {
 			})
 		})
 
+		Context("manifest.yml needs a newer packager", func() {
+			var bpDir string
+
+			BeforeEach(func() {
+				bpDir, err = ioutil.TempDir("", "packager-bpdir")
+				Expect(err).To(BeNil())
+				Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte("---\nlanguage: future\nmin_packager_version: 99.0.0\ndependencies: []\n"), 0644)).To(Succeed())
+			})
+			AfterEach(func() { os.RemoveAll(bpDir) })
+
+			It("refuses to package it", func() {
+				zipFile, err = packager.Package(bpDir, cacheDir, version, "", cached)
+				Expect(err).To(MatchError(ContainSubstring("manifest requires libbuildpack 99.0.0 or newer")))
+			})
+		})
+
 		Context("manifest.yml has no dependencies", func() {
 			BeforeEach(func() { stack = "cflinuxfs2" })
 
@@ -357,6 +375,70 @@ var _ = Describe("Packager", func() {
 			})
 		})
 
+		Context("uncached with a dependency uri template", func() {
+			var opts packager.PackageOptions
+
+			BeforeEach(func() {
+				cached = false
+				opts = packager.PackageOptions{DependencyURITemplate: "https://cdn.example.com/{{.Stack}}/{{.Name}}-{{.Version}}/{{.Filename}}"}
+			})
+
+			It("rewrites the dependency uris", func() {
+				zipFile, err = packager.PackageWithOptions(context.Background(), buildpackDir, cacheDir, version, stack, cached, false, opts)
+				Expect(err).To(BeNil())
+
+				manifestYml, err := ZipContents(zipFile, "manifest.yml")
+				Expect(err).To(BeNil())
+				var m packager.Manifest
+				Expect(yaml.Unmarshal([]byte(manifestYml), &m)).To(Succeed())
+				Expect(m.Dependencies).To(HaveLen(1))
+				Expect(m.Dependencies[0].URI).To(Equal("https://cdn.example.com/cflinuxfs2/ruby-1.2.3/rfc2324.txt"))
+			})
+
+			Context("the template is invalid", func() {
+				BeforeEach(func() { opts.DependencyURITemplate = "{{.Name" })
+
+				It("returns an error", func() {
+					zipFile, err = packager.PackageWithOptions(context.Background(), buildpackDir, cacheDir, version, stack, cached, false, opts)
+					Expect(err).To(MatchError(ContainSubstring("invalid dependency uri template")))
+				})
+			})
+		})
+
+		Context("buildpack has an extensions dir", func() {
+			BeforeEach(func() {
+				buildpackDir, err = ioutil.TempDir("", "packager-extensions")
+				Expect(err).To(BeNil())
+				manifest := "---\nlanguage: binary\ndependencies: []\ninclude_files:\n- manifest.yml\n- VERSION\n- extensions/profile.d/proxy.sh\n"
+				Expect(ioutil.WriteFile(filepath.Join(buildpackDir, "manifest.yml"), []byte(manifest), 0644)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(buildpackDir, "extensions", "profile.d"), 0755)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(buildpackDir, "extensions", "hooks"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(buildpackDir, "extensions", "profile.d", "proxy.sh"), []byte("export HTTP_PROXY=proxy"), 0644)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(buildpackDir, "extensions", "hooks", "after_compile"), []byte("#!/bin/sh"), 0755)).To(Succeed())
+			})
+			AfterEach(func() { os.RemoveAll(buildpackDir) })
+
+			It("packages the extensions even if include_files does not list them", func() {
+				zipFile, err = packager.Package(buildpackDir, cacheDir, version, stack, cached)
+				Expect(err).To(BeNil())
+
+				Expect(ZipContents(zipFile, "extensions/profile.d/proxy.sh")).To(Equal("export HTTP_PROXY=proxy"))
+				Expect(ZipContents(zipFile, "extensions/hooks/after_compile")).To(Equal("#!/bin/sh"))
+
+				r, err := zip.OpenReader(zipFile)
+				Expect(err).To(BeNil())
+				defer r.Close()
+				var names []string
+				for _, f := range r.File {
+					names = append(names, f.Name)
+					if f.Name == "extensions/hooks/after_compile" {
+						Expect(f.Mode() & 0111).NotTo(BeZero())
+					}
+				}
+				Expect(names).To(ConsistOf("manifest.yml", "VERSION", "extensions/profile.d/proxy.sh", "extensions/hooks/after_compile"))
+			})
+		})
+
 		Context("packaging with missing included_files", func() {
 			It("returns an error", func() {
 				zipFile, err = packager.Package("./fixtures/missing_included_files", cacheDir, version, stack, cached)
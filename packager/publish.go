@@ -0,0 +1,303 @@
+package packager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// DefaultPublishKeyTemplate names published artifacts after their file.
+const DefaultPublishKeyTemplate = "{{.Filename}}"
+
+// PublishOptions configures Publish.
+//
+// Destination is s3://<bucket>/<prefix>, gs://<bucket>/<prefix> or
+// azblob://<account>/<container>/<prefix>. Credentials come from the
+// environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN
+// and AWS_REGION for S3, GOOGLE_OAUTH_ACCESS_TOKEN for GCS and
+// AZURE_STORAGE_SAS_TOKEN for Azure. AWS_ENDPOINT_URL,
+// STORAGE_EMULATOR_HOST and AZURE_STORAGE_BLOB_ENDPOINT point at
+// compatible services instead of the public ones.
+//
+// KeyTemplate is a text/template, executed with a PublishTemplateData, for
+// the artifact's key below the destination prefix. It defaults to
+// DefaultPublishKeyTemplate.
+type PublishOptions struct {
+	Destination string
+	KeyTemplate string
+}
+
+type PublishTemplateData struct {
+	Language, Version, Stack, Filename string
+	Cached                             bool
+}
+
+// PublishMetadata is uploaded next to the artifact as <key>.json.
+type PublishMetadata struct {
+	Language     string                `json:"language"`
+	Version      string                `json:"version"`
+	Stack        string                `json:"stack,omitempty"`
+	Cached       bool                  `json:"cached"`
+	Filename     string                `json:"filename"`
+	SHA256       string                `json:"sha256"`
+	Size         int64                 `json:"size"`
+	Dependencies []PublishedDependency `json:"dependencies"`
+}
+
+type PublishedDependency struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	SHA256  string   `json:"sha256,omitempty"`
+	Stacks  []string `json:"cf_stacks,omitempty"`
+}
+
+type objectStore interface {
+	put(ctx context.Context, key string, body io.ReadSeeker, size int64, sha256sum, contentType string) error
+	url(key string) string
+}
+
+// Publish uploads artifact, as packaged from bpDir, to object storage along
+// with <key>.SHA256SUM.txt and <key>.json metadata files. It returns the
+// URLs of the uploaded objects.
+func Publish(ctx context.Context, bpDir, artifact, version, stack string, cached bool, opts PublishOptions) ([]string, error) {
+	store, prefix, err := newObjectStore(opts.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := readManifest(bpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	keyTemplate := opts.KeyTemplate
+	if keyTemplate == "" {
+		keyTemplate = DefaultPublishKeyTemplate
+	}
+	tmpl, err := template.New("key").Parse(keyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publish key template: %v", err)
+	}
+	var key strings.Builder
+	data := PublishTemplateData{Language: manifest.Language, Version: version, Stack: stack, Filename: filepath.Base(artifact), Cached: cached}
+	if err := tmpl.Execute(&key, data); err != nil {
+		return nil, fmt.Errorf("invalid publish key template: %v", err)
+	}
+	artifactKey := path.Join(prefix, key.String())
+
+	fh, err := os.Open(artifact)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, fh)
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	metadata := PublishMetadata{
+		Language: manifest.Language,
+		Version:  version,
+		Stack:    stack,
+		Cached:   cached,
+		Filename: filepath.Base(artifact),
+		SHA256:   sum,
+		Size:     size,
+	}
+	for _, d := range manifest.Dependencies {
+		metadata.Dependencies = append(metadata.Dependencies, PublishedDependency{Name: d.Name, Version: d.Version, SHA256: d.SHA256, Stacks: d.Stacks})
+	}
+	metadataJSON, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fh.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := store.put(ctx, artifactKey, fh, size, sum, artifactContentType(artifact)); err != nil {
+		return nil, fmt.Errorf("could not publish %s: %v", artifactKey, err)
+	}
+	urls := []string{store.url(artifactKey)}
+
+	extras := []struct {
+		key, contentType string
+		body             []byte
+	}{
+		{artifactKey + ".SHA256SUM.txt", "text/plain", []byte(fmt.Sprintf("%s  %s\n", sum, filepath.Base(artifact)))},
+		{artifactKey + ".json", "application/json", metadataJSON},
+	}
+	for _, extra := range extras {
+		extraSum := sha256.Sum256(extra.body)
+		body := strings.NewReader(string(extra.body))
+		if err := store.put(ctx, extra.key, body, int64(len(extra.body)), hex.EncodeToString(extraSum[:]), extra.contentType); err != nil {
+			return nil, fmt.Errorf("could not publish %s: %v", extra.key, err)
+		}
+		urls = append(urls, store.url(extra.key))
+	}
+
+	return urls, nil
+}
+
+func artifactContentType(artifact string) string {
+	if strings.HasSuffix(artifact, ".zip") {
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
+
+func newObjectStore(destination string) (objectStore, string, error) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Host == "" {
+		return nil, "", fmt.Errorf("invalid publish destination %q", destination)
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	switch u.Scheme {
+	case "s3":
+		store := &s3Store{
+			bucket:      u.Host,
+			endpoint:    strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"),
+			credentials: libbuildpack.S3CredentialsFromEnv(),
+		}
+		if store.credentials.AccessKey == "" || store.credentials.SecretKey == "" {
+			return nil, "", fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to publish to S3")
+		}
+		return store, prefix, nil
+	case "gs":
+		endpoint := "https://storage.googleapis.com"
+		if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+			endpoint = strings.TrimSuffix(host, "/")
+			if !strings.Contains(endpoint, "://") {
+				endpoint = "http://" + endpoint
+			}
+		}
+		token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		if token == "" {
+			return nil, "", fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN must be set to publish to GCS")
+		}
+		return &bearerStore{base: endpoint + "/" + u.Host, token: token}, prefix, nil
+	case "azblob":
+		parts := strings.SplitN(prefix, "/", 2)
+		if parts[0] == "" {
+			return nil, "", fmt.Errorf("invalid publish destination %q: missing container", destination)
+		}
+		endpoint := strings.TrimSuffix(os.Getenv("AZURE_STORAGE_BLOB_ENDPOINT"), "/")
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", u.Host)
+		}
+		sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+		if sas == "" {
+			return nil, "", fmt.Errorf("AZURE_STORAGE_SAS_TOKEN must be set to publish to Azure")
+		}
+		prefix = ""
+		if len(parts) == 2 {
+			prefix = parts[1]
+		}
+		return &azureStore{base: endpoint + "/" + parts[0], sas: sas}, prefix, nil
+	}
+	return nil, "", fmt.Errorf("unsupported publish destination %q, must be s3://, gs:// or azblob://", destination)
+}
+
+func putObject(req *http.Request, body io.ReadSeeker, size int64) error {
+	req.Body = ioutil.NopCloser(body)
+	req.ContentLength = size
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// the url may carry credentials, like an Azure SAS token
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = libbuildpack.RedactURIs(urlErr.URL)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload failed: %d %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = libbuildpack.AWSURIEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+type s3Store struct {
+	bucket, endpoint string
+	credentials      libbuildpack.S3Credentials
+}
+
+func (s *s3Store) url(key string) string {
+	if s.endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, escapeKey(key))
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.credentials.Region, escapeKey(key))
+}
+
+func (s *s3Store) put(ctx context.Context, key string, body io.ReadSeeker, size int64, sha256sum, contentType string) error {
+	req, err := http.NewRequest("PUT", s.url(key), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	s.credentials.Sign(req, sha256sum, time.Now().UTC())
+	return putObject(req, body, size)
+}
+
+type bearerStore struct {
+	base, token string
+}
+
+func (s *bearerStore) url(key string) string {
+	return s.base + "/" + escapeKey(key)
+}
+
+func (s *bearerStore) put(ctx context.Context, key string, body io.ReadSeeker, size int64, _, contentType string) error {
+	req, err := http.NewRequest("PUT", s.url(key), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Content-Type", contentType)
+	return putObject(req.WithContext(ctx), body, size)
+}
+
+type azureStore struct {
+	base, sas string
+}
+
+func (s *azureStore) url(key string) string {
+	return s.base + "/" + escapeKey(key)
+}
+
+func (s *azureStore) put(ctx context.Context, key string, body io.ReadSeeker, size int64, _, contentType string) error {
+	req, err := http.NewRequest("PUT", s.url(key)+"?"+s.sas, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2020-10-02")
+	req.Header.Set("Content-Type", contentType)
+	return putObject(req.WithContext(ctx), body, size)
+}
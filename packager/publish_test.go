@@ -0,0 +1,195 @@
+package packager_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Publish", func() {
+	type upload struct {
+		header http.Header
+		query  string
+		body   []byte
+	}
+
+	var (
+		server   *httptest.Server
+		mu       sync.Mutex
+		uploads  map[string]upload
+		tmpDir   string
+		artifact string
+		env      map[string]string
+		err      error
+	)
+
+	BeforeEach(func() {
+		uploads = map[string]upload{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			if r.Method == "PUT" {
+				uploads[r.URL.Path] = upload{header: r.Header, query: r.URL.RawQuery, body: body}
+			}
+		}))
+
+		tmpDir, err = ioutil.TempDir("", "publish")
+		Expect(err).To(BeNil())
+		artifact = filepath.Join(tmpDir, "ruby_buildpack-cflinuxfs3-v1.2.3.zip")
+		Expect(ioutil.WriteFile(artifact, []byte("buildpack"), 0644)).To(Succeed())
+
+		env = map[string]string{}
+	})
+
+	setenv := func(name, value string) {
+		env[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+
+	AfterEach(func() {
+		server.Close()
+		for name, value := range env {
+			os.Setenv(name, value)
+		}
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	publish := func(opts packager.PublishOptions) ([]string, error) {
+		return packager.Publish(context.Background(), "./fixtures/good", artifact, "1.2.3", "cflinuxfs3", false, opts)
+	}
+
+	Context("to S3", func() {
+		BeforeEach(func() {
+			setenv("AWS_ENDPOINT_URL", server.URL)
+			setenv("AWS_ACCESS_KEY_ID", "AKID")
+			setenv("AWS_SECRET_ACCESS_KEY", "secret")
+			setenv("AWS_REGION", "eu-west-1")
+		})
+
+		It("uploads the artifact, its checksum and metadata", func() {
+			urls, err := publish(packager.PublishOptions{Destination: "s3://bucket/releases"})
+			Expect(err).To(BeNil())
+			Expect(urls).To(Equal([]string{
+				server.URL + "/bucket/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip",
+				server.URL + "/bucket/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip.SHA256SUM.txt",
+				server.URL + "/bucket/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip.json",
+			}))
+
+			zip := uploads["/bucket/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip"]
+			Expect(string(zip.body)).To(Equal("buildpack"))
+			Expect(zip.header.Get("Content-Type")).To(Equal("application/zip"))
+			Expect(zip.header.Get("X-Amz-Content-Sha256")).To(Equal("d7e91d8b2fe4e850416f69aa49d8550fcb01bf48cf7e8ac4a5900cfc871f9e3c"))
+			Expect(zip.header.Get("Authorization")).To(MatchRegexp(`^AWS4-HMAC-SHA256 Credential=AKID/\d{8}/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`))
+
+			sum := uploads["/bucket/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip.SHA256SUM.txt"]
+			Expect(string(sum.body)).To(Equal("d7e91d8b2fe4e850416f69aa49d8550fcb01bf48cf7e8ac4a5900cfc871f9e3c  ruby_buildpack-cflinuxfs3-v1.2.3.zip\n"))
+
+			var metadata packager.PublishMetadata
+			Expect(json.Unmarshal(uploads["/bucket/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip.json"].body, &metadata)).To(Succeed())
+			Expect(metadata.Language).To(Equal("ruby"))
+			Expect(metadata.Version).To(Equal("1.2.3"))
+			Expect(metadata.Stack).To(Equal("cflinuxfs3"))
+			Expect(metadata.Size).To(Equal(int64(9)))
+			Expect(metadata.Dependencies).To(ContainElement(packager.PublishedDependency{
+				Name:    "ruby",
+				Version: "1.2.3",
+				SHA256:  "646b43b5d718913d6211e2c18b2b3b667cf6eaa76a2493e55b1de5ca04c2578e",
+				Stacks:  []string{"cflinuxfs3"},
+			}))
+		})
+
+		It("signs the session token", func() {
+			setenv("AWS_SESSION_TOKEN", "token")
+
+			_, err := publish(packager.PublishOptions{Destination: "s3://bucket"})
+			Expect(err).To(BeNil())
+
+			zip := uploads["/bucket/ruby_buildpack-cflinuxfs3-v1.2.3.zip"]
+			Expect(zip.header.Get("X-Amz-Security-Token")).To(Equal("token"))
+			Expect(zip.header.Get("Authorization")).To(ContainSubstring("SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,"))
+		})
+
+		It("names the artifact with the key template", func() {
+			_, err := publish(packager.PublishOptions{Destination: "s3://bucket/releases", KeyTemplate: "{{.Language}}/v{{.Version}}/{{.Stack}}.zip"})
+			Expect(err).To(BeNil())
+
+			Expect(uploads).To(HaveKey("/bucket/releases/ruby/v1.2.3/cflinuxfs3.zip"))
+			Expect(uploads).To(HaveKey("/bucket/releases/ruby/v1.2.3/cflinuxfs3.zip.json"))
+		})
+
+		It("needs credentials", func() {
+			setenv("AWS_SECRET_ACCESS_KEY", "")
+
+			_, err := publish(packager.PublishOptions{Destination: "s3://bucket"})
+			Expect(err).To(MatchError("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to publish to S3"))
+		})
+
+		It("reports failed uploads", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "AccessDenied", http.StatusForbidden)
+			})
+
+			_, err := publish(packager.PublishOptions{Destination: "s3://bucket"})
+			Expect(err).To(MatchError("could not publish ruby_buildpack-cflinuxfs3-v1.2.3.zip: upload failed: 403 AccessDenied"))
+		})
+	})
+
+	Context("to GCS", func() {
+		BeforeEach(func() {
+			setenv("STORAGE_EMULATOR_HOST", server.URL)
+			setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "gcs-token")
+		})
+
+		It("uploads with the access token", func() {
+			_, err := publish(packager.PublishOptions{Destination: "gs://bucket/releases"})
+			Expect(err).To(BeNil())
+
+			zip := uploads["/bucket/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip"]
+			Expect(string(zip.body)).To(Equal("buildpack"))
+			Expect(zip.header.Get("Authorization")).To(Equal("Bearer gcs-token"))
+			Expect(uploads).To(HaveLen(3))
+		})
+	})
+
+	Context("to Azure", func() {
+		BeforeEach(func() {
+			setenv("AZURE_STORAGE_BLOB_ENDPOINT", server.URL)
+			setenv("AZURE_STORAGE_SAS_TOKEN", "?sv=2020&sig=abc")
+		})
+
+		It("uploads block blobs with the SAS token", func() {
+			_, err := publish(packager.PublishOptions{Destination: "azblob://account/container/releases"})
+			Expect(err).To(BeNil())
+
+			zip := uploads["/container/releases/ruby_buildpack-cflinuxfs3-v1.2.3.zip"]
+			Expect(string(zip.body)).To(Equal("buildpack"))
+			Expect(zip.query).To(Equal("sv=2020&sig=abc"))
+			Expect(zip.header.Get("x-ms-blob-type")).To(Equal("BlockBlob"))
+			Expect(uploads).To(HaveLen(3))
+		})
+
+		It("keeps the SAS token out of transport errors", func() {
+			server.Close()
+
+			_, err := publish(packager.PublishOptions{Destination: "azblob://account/container/releases"})
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("sig=-redacted-"))
+			Expect(err.Error()).NotTo(ContainSubstring("abc"))
+		})
+	})
+
+	It("rejects unsupported destinations", func() {
+		_, err := publish(packager.PublishOptions{Destination: "ftp://example.com/releases"})
+		Expect(err).To(MatchError(`unsupported publish destination "ftp://example.com/releases", must be s3://, gs:// or azblob://`))
+	})
+})
@@ -0,0 +1,179 @@
+package packager_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PackageContext", func() {
+	var (
+		bpDir    string
+		cacheDir string
+		depFile  string
+		err      error
+	)
+
+	BeforeEach(func() {
+		bpDir, err = ioutil.TempDir("", "packager-bpdir")
+		Expect(err).To(BeNil())
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+
+		depFile = filepath.Join(bpDir, "dep.txt")
+		Expect(ioutil.WriteFile(depFile, []byte("dependency"), 0644)).To(Succeed())
+		sum := sha256.Sum256([]byte("dependency"))
+
+		Expect(ioutil.WriteFile(filepath.Join(bpDir, "VERSION"), []byte("1.0.0"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte(fmt.Sprintf(`---
+language: resumable
+dependencies:
+- name: dep
+  version: 1.0.0
+  uri: file://%s
+  sha256: %s
+  cf_stacks:
+  - cflinuxfs3
+include_files:
+- manifest.yml
+- VERSION
+`, depFile, hex.EncodeToString(sum[:]))), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(bpDir)
+		os.RemoveAll(cacheDir)
+	})
+
+	cachedFiles := func() []string {
+		var files []string
+		filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				files = append(files, filepath.Base(path))
+			}
+			return nil
+		})
+		return files
+	}
+
+	It("stops without creating a zipfile when cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := packager.PackageContext(ctx, bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+		Expect(err).To(MatchError(ContainSubstring("packaging cancelled")))
+		Expect(filepath.Join(bpDir, "resumable_buildpack-cached-cflinuxfs3-v1.0.0.zip")).ToNot(BeAnExistingFile())
+		Expect(cachedFiles()).To(BeEmpty())
+	})
+
+	It("leaves only complete, verified downloads in the cache", func() {
+		_, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+		Expect(err).To(BeNil())
+		Expect(cachedFiles()).To(ConsistOf("dep.txt", "dep.txt.verified"))
+	})
+
+	Context("resuming", func() {
+		BeforeEach(func() {
+			_, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+			Expect(err).To(BeNil())
+
+			Expect(os.Remove(depFile)).To(Succeed())
+			// only a run that neither downloads nor checksums it again packages
+			// this file as it is
+			cached, err := filepath.Glob(filepath.Join(cacheDir, "dependencies", "*", "dep.txt"))
+			Expect(err).To(BeNil())
+			Expect(cached).To(HaveLen(1))
+			Expect(ioutil.WriteFile(cached[0], []byte("tampered"), 0644)).To(Succeed())
+		})
+
+		It("does not download or checksum verified dependencies again", func() {
+			zipFile, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, true)
+			Expect(err).To(BeNil())
+			manifest, err := ZipContents(zipFile, "manifest.yml")
+			Expect(err).To(BeNil())
+			Expect(manifest).To(ContainSubstring("file: dependencies/"))
+
+			file := manifest[strings.Index(manifest, "file: dependencies/")+len("file: "):]
+			file = file[:strings.Index(file, "\n")]
+			Expect(ZipContents(zipFile, file)).To(Equal("tampered"))
+		})
+
+		It("checks the cached dependencies again when not resuming", func() {
+			_, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+			Expect(err).ToNot(BeNil())
+		})
+	})
+	Context("with a sha512 checksum", func() {
+		var manifest string
+
+		BeforeEach(func() {
+			contents, err := ioutil.ReadFile(filepath.Join(bpDir, "manifest.yml"))
+			Expect(err).To(BeNil())
+			manifest = string(contents)
+		})
+
+		writeSHA512 := func(sum string) {
+			sha256Line := manifest[strings.Index(manifest, "  sha256:"):]
+			sha256Line = sha256Line[:strings.Index(sha256Line, "\n")+1]
+			Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), []byte(strings.Replace(manifest, sha256Line, "  sha512: "+sum+"\n", 1)), 0644)).To(Succeed())
+		}
+
+		It("verifies downloads against it", func() {
+			sum := sha512.Sum512([]byte("dependency"))
+			writeSHA512(hex.EncodeToString(sum[:]))
+
+			_, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+			Expect(err).To(BeNil())
+			Expect(cachedFiles()).To(ConsistOf("dep.txt", "dep.txt.verified"))
+		})
+
+		It("rejects downloads that do not match", func() {
+			sum := sha512.Sum512([]byte("something else"))
+			writeSHA512(hex.EncodeToString(sum[:]))
+
+			_, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+			Expect(err).To(MatchError(ContainSubstring("dependency sha512 mismatch")))
+			Expect(cachedFiles()).To(BeEmpty())
+		})
+	})
+
+	Context("with mirrors", func() {
+		BeforeEach(func() {
+			badFile := filepath.Join(bpDir, "bad.txt")
+			Expect(ioutil.WriteFile(badFile, []byte("tampered"), 0644)).To(Succeed())
+
+			manifest, err := ioutil.ReadFile(filepath.Join(bpDir, "manifest.yml"))
+			Expect(err).To(BeNil())
+			manifest = []byte(strings.Replace(string(manifest), "uri: file://"+depFile, fmt.Sprintf(`uri: file://%s
+  mirrors:
+  - file://%s
+  - file://%s`, filepath.Join(bpDir, "missing.txt"), badFile, depFile), 1))
+			Expect(ioutil.WriteFile(filepath.Join(bpDir, "manifest.yml"), manifest, 0644)).To(Succeed())
+		})
+
+		It("falls back through the mirrors to the first matching download", func() {
+			zipFile, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+			Expect(err).To(BeNil())
+			Expect(ZipContents(zipFile, "manifest.yml")).To(ContainSubstring("file: dependencies/"))
+			Expect(cachedFiles()).To(ConsistOf("missing.txt", "missing.txt.verified"))
+		})
+
+		It("fails when no mirror matches the sha256", func() {
+			Expect(os.Remove(depFile)).To(Succeed())
+
+			_, err := packager.PackageContext(context.Background(), bpDir, cacheDir, "1.0.0", "cflinuxfs3", true, false)
+			Expect(err).ToNot(BeNil())
+			Expect(cachedFiles()).To(BeEmpty())
+		})
+	})
+})
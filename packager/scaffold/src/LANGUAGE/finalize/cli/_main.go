@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"{{LANGUAGE}}/finalize"
 	_ "{{LANGUAGE}}/hooks"
 	"os"
@@ -12,28 +13,50 @@ import (
 func main() {
 	logger := libbuildpack.NewLogger(os.Stdout)
 
+	phase := libbuildpack.NewTracer().StartPhase("finalize", nil)
+	metrics := libbuildpack.NewMetrics()
+	phaseDone := metrics.Phase("finalize")
+	code := runFinalize(logger)
+	phaseDone()
+	metrics.Close()
+
+	var err error
+	if code != 0 {
+		err = fmt.Errorf("finalize exited with status %d", code)
+	}
+	phase.End(err)
+	os.Exit(code)
+}
+
+func runFinalize(logger *libbuildpack.Logger) int {
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
 		logger.Error("Unable to determine buildpack directory: %s", err)
-		os.Exit(9)
+		return 9
 	}
 
 	manifest, err := libbuildpack.NewManifest(buildpackDir, logger, time.Now())
 	if err != nil {
 		logger.Error("Unable to load buildpack manifest: %s", err)
-		os.Exit(10)
+		return 10
 	}
 
 	stager := libbuildpack.NewStager(os.Args[1:], logger, manifest)
+	unlockDepDir, err := stager.LockDepDir()
+	if err != nil {
+		logger.Error("Unable to claim deps dir: %s", err)
+		return 15
+	}
+	defer unlockDepDir()
 
 	if err = manifest.ApplyOverride(stager.DepsDir()); err != nil {
 		logger.Error("Unable to apply override.yml files: %s", err)
-		os.Exit(17)
+		return 17
 	}
 
 	if err := stager.SetStagingEnvironment(); err != nil {
 		logger.Error("Unable to setup environment variables: %s", err)
-		os.Exit(11)
+		return 11
 	}
 
 	f := finalize.Finalizer{
@@ -44,18 +67,24 @@ func main() {
 	}
 
 	if err := f.Run(); err != nil {
-		os.Exit(12)
+		return 12
 	}
 
 	if err := libbuildpack.RunAfterCompile(stager); err != nil {
 		logger.Error("After Compile: %s", err)
-		os.Exit(13)
+		return 13
 	}
 
 	if err := stager.SetLaunchEnvironment(); err != nil {
 		logger.Error("Unable to setup launch environment: %s", err)
-		os.Exit(14)
+		return 14
+	}
+
+	if err := stager.ScrubSensitiveFiles(); err != nil {
+		logger.Error("Unable to remove sensitive files: %s", err)
+		return 16
 	}
 
 	stager.StagingComplete()
+	return 0
 }
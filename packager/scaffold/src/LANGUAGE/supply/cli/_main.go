@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	_ "{{LANGUAGE}}/hooks"
 	"{{LANGUAGE}}/supply"
 	"os"
@@ -13,22 +14,44 @@ import (
 func main() {
 	logger := libbuildpack.NewLogger(os.Stdout)
 
+	phase := libbuildpack.NewTracer().StartPhase("supply", nil)
+	metrics := libbuildpack.NewMetrics()
+	phaseDone := metrics.Phase("supply")
+	code := runSupply(logger)
+	phaseDone()
+	metrics.Close()
+
+	var err error
+	if code != 0 {
+		err = fmt.Errorf("supply exited with status %d", code)
+	}
+	phase.End(err)
+	os.Exit(code)
+}
+
+func runSupply(logger *libbuildpack.Logger) int {
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
 		logger.Error("Unable to determine buildpack directory: %s", err)
-		os.Exit(9)
+		return 9
 	}
 
 	manifest, err := libbuildpack.NewManifest(buildpackDir, logger, time.Now())
 	if err != nil {
 		logger.Error("Unable to load buildpack manifest: %s", err)
-		os.Exit(10)
+		return 10
 	}
 	installer := libbuildpack.NewInstaller(manifest)
 
 	stager := libbuildpack.NewStager(os.Args[1:], logger, manifest)
+	unlockDepDir, err := stager.LockDepDir()
+	if err != nil {
+		logger.Error("Unable to claim deps dir: %s", err)
+		return 20
+	}
+	defer unlockDepDir()
 	if err := stager.CheckBuildpackValid(); err != nil {
-		os.Exit(11)
+		return 11
 	}
 
 	os.MkdirAll(filepath.Join(stager.DepDir(), "bin"), 0755)
@@ -36,28 +59,28 @@ func main() {
 
 	if err = installer.SetAppCacheDir(stager.CacheDir()); err != nil {
 		logger.Error("Unable to setup app cache dir: %s", err)
-		os.Exit(18)
+		return 18
 	}
 	if err = manifest.ApplyOverride(stager.DepsDir()); err != nil {
 		logger.Error("Unable to apply override.yml files: %s", err)
-		os.Exit(17)
+		return 17
 	}
 
 	err = libbuildpack.RunBeforeCompile(stager)
 	if err != nil {
 		logger.Error("Before Compile: %s", err)
-		os.Exit(12)
+		return 12
 	}
 
 	if err := os.MkdirAll(filepath.Join(stager.DepDir(), "bin"), 0755); err != nil {
 		logger.Error("Unable to create bin directory: %s", err)
-		os.Exit(13)
+		return 13
 	}
 
 	err = stager.SetStagingEnvironment()
 	if err != nil {
 		logger.Error("Unable to setup environment variables: %s", err)
-		os.Exit(14)
+		return 14
 	}
 
 	s := supply.Supplier{
@@ -71,15 +94,17 @@ func main() {
 	err = s.Run()
 	if err != nil {
 		logger.Error("Error: %s", err)
-		os.Exit(15)
+		return 15
 	}
 
 	if err := stager.WriteConfigYml(nil); err != nil {
 		logger.Error("Error writing config.yml: %s", err)
-		os.Exit(16)
+		return 16
 	}
 	if err = installer.CleanupAppCache(); err != nil {
 		logger.Error("Unable clean up app cache: %s", err)
-		os.Exit(19)
+		return 19
 	}
+	logger.StepSummary()
+	return 0
 }
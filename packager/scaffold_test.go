@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"text/template"
 	"time"
 
 	httpmock "github.com/jarcoal/httpmock"
@@ -101,6 +102,47 @@ var _ = Describe("Scaffold", func() {
 		})
 	})
 
+	Describe("embedded assets", func() {
+		var baseDir string
+
+		BeforeEach(func() {
+			baseDir, err = ioutil.TempDir("", "scaffold-assets")
+			Expect(err).To(BeNil())
+
+			funcMap := template.FuncMap{"LANGUAGE": func() string { return "mylanguage" }}
+			Expect(packager.OurRestoreAssets(baseDir, "", funcMap, map[string]string{}, false)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(baseDir)
+		})
+
+		It("are regenerated from the scaffold sources", func() {
+			for _, name := range []string{"bin/release", "src/LANGUAGE/supply/cli/_main.go", "src/LANGUAGE/finalize/cli/_main.go"} {
+				embedded, err := packager.Asset(name)
+				Expect(err).To(BeNil())
+				Expect(ioutil.ReadFile(filepath.Join("scaffold", name))).To(Equal(embedded), name)
+			}
+		})
+
+		It("wire the staging helpers into the generated mains", func() {
+			supply, err := ioutil.ReadFile(filepath.Join(baseDir, "src", "mylanguage", "supply", "cli", "main.go"))
+			Expect(err).To(BeNil())
+			Expect(string(supply)).To(ContainSubstring("stager.LockDepDir()"))
+			Expect(string(supply)).To(ContainSubstring(`StartPhase("supply", nil)`))
+			Expect(string(supply)).To(ContainSubstring("logger.StepSummary()"))
+			Expect(string(supply)).To(ContainSubstring("metrics.Close()"))
+
+			finalize, err := ioutil.ReadFile(filepath.Join(baseDir, "src", "mylanguage", "finalize", "cli", "main.go"))
+			Expect(err).To(BeNil())
+			Expect(string(finalize)).To(ContainSubstring("stager.LockDepDir()"))
+			Expect(string(finalize)).To(ContainSubstring(`StartPhase("finalize", nil)`))
+			Expect(string(finalize)).To(ContainSubstring("stager.ScrubSensitiveFiles()"))
+			Expect(string(finalize)).To(ContainSubstring("stager.StagingComplete()"))
+			Expect(string(finalize)).To(ContainSubstring("metrics.Close()"))
+		})
+	})
+
 	Describe("Upgrade", func() {
 		var baseDir string
 
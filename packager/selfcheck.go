@@ -0,0 +1,108 @@
+package packager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+var lifecycleScripts = []string{"detect", "supply", "finalize", "compile", "release"}
+
+// SelfCheck unpacks a packaged buildpack, a zip or a tar.zst, and checks that it is usable: the
+// manifest and VERSION are present, every included and cached file exists,
+// cached dependencies match their sha256, lifecycle scripts are executable and
+// bin/detect can be run against an empty app.
+func SelfCheck(zipFile string) error {
+	dir, err := ioutil.TempDir("", "buildpack-self-check")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := libbuildpack.ExtractArchive(zipFile, zipFile, dir); err != nil {
+		return fmt.Errorf("could not unpack %s: %v", zipFile, err)
+	}
+
+	if exists, err := libbuildpack.FileExists(filepath.Join(dir, "VERSION")); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("%s is missing VERSION", zipFile)
+	}
+
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return fmt.Errorf("%s has an unreadable manifest.yml: %v", zipFile, err)
+	}
+
+	var problems []string
+	for _, name := range manifest.IncludeFiles {
+		if exists, err := libbuildpack.FileExists(filepath.Join(dir, name)); err != nil {
+			return err
+		} else if !exists {
+			problems = append(problems, fmt.Sprintf("included file %s is missing", name))
+		}
+	}
+
+	for _, d := range manifest.Dependencies {
+		if d.File == "" {
+			continue
+		}
+		if err := d.verifyChecksums(filepath.Join(dir, d.File)); err != nil {
+			problems = append(problems, fmt.Sprintf("cached dependency %s %s: %v", d.Name, d.Version, err))
+		}
+	}
+
+	for _, script := range lifecycleScripts {
+		info, err := os.Stat(filepath.Join(dir, "bin", script))
+		if os.IsNotExist(err) {
+			if script == "detect" {
+				problems = append(problems, "bin/detect is missing")
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		if info.Mode().Perm()&0111 == 0 {
+			problems = append(problems, fmt.Sprintf("bin/%s is not executable", script))
+		}
+	}
+
+	if len(problems) == 0 {
+		if err := dryRunDetect(dir); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s failed self-check:\n%s", zipFile, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func dryRunDetect(bpDir string) error {
+	appDir, err := ioutil.TempDir("", "buildpack-self-check-app")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(appDir)
+
+	cmd := exec.Command(filepath.Join(bpDir, "bin", "detect"), appDir)
+	cmd.Dir = bpDir
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// exiting 1 only means the buildpack does not apply to an empty app,
+		// anything else is detect itself failing
+		if exitErr.ExitCode() == 1 {
+			return nil
+		}
+		return fmt.Errorf("bin/detect failed with exit status %d, not 0 or 1:\n%s", exitErr.ExitCode(), out)
+	} else if err != nil {
+		return fmt.Errorf("could not run bin/detect: %v\n%s", err, out)
+	}
+	return nil
+}
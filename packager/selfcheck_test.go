@@ -0,0 +1,145 @@
+package packager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelfCheck", func() {
+	var (
+		cacheDir string
+		zipFile  string
+		err      error
+	)
+
+	BeforeEach(func() {
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cacheDir)
+		os.Remove(zipFile)
+	})
+
+	Context("the artifact is a usable buildpack", func() {
+		It("succeeds", func() {
+			zipFile, err = packager.Package("./fixtures/self_check", cacheDir, "1.2.3", "", false)
+			Expect(err).To(BeNil())
+
+			Expect(packager.SelfCheck(zipFile)).To(Succeed())
+		})
+	})
+
+	Context("the artifact is a tar.zst", func() {
+		BeforeEach(func() {
+			if _, err := exec.LookPath("zstd"); err != nil {
+				Skip("needs zstd")
+			}
+			packager.ArtifactFormat = packager.FormatTarZst
+		})
+
+		AfterEach(func() {
+			packager.ArtifactFormat = packager.FormatZip
+		})
+
+		It("unpacks and checks it", func() {
+			zipFile, err = packager.Package("./fixtures/self_check", cacheDir, "1.2.3", "", false)
+			Expect(err).To(BeNil())
+			Expect(zipFile).To(HaveSuffix(".tar.zst"))
+
+			Expect(packager.SelfCheck(zipFile)).To(Succeed())
+		})
+
+		It("can be locked and diffed", func() {
+			zipFile, err = packager.Package("./fixtures/self_check", cacheDir, "1.2.3", "", false)
+			Expect(err).To(BeNil())
+
+			lockfile, err := packager.ExportLockfile(zipFile, nil)
+			Expect(err).To(BeNil())
+			Expect(lockfile.Version).To(Equal("1.2.3"))
+
+			diff, err := packager.Diff(zipFile, zipFile)
+			Expect(err).To(BeNil())
+			Expect(diff.AddedFiles).To(BeEmpty())
+			Expect(diff.ChangedFiles).To(BeEmpty())
+			Expect(diff.RemovedFiles).To(BeEmpty())
+		})
+	})
+
+	Context("the artifact has no bin/detect", func() {
+		It("reports the problem", func() {
+			zipFile, err = packager.Package("./fixtures/good", cacheDir, "1.2.3", "cflinuxfs2", false)
+			Expect(err).To(BeNil())
+
+			Expect(packager.SelfCheck(zipFile)).To(MatchError(ContainSubstring("bin/detect is missing")))
+		})
+	})
+
+	Context("the artifact is missing included files and scripts are not executable", func() {
+		It("reports every problem", func() {
+			dir, err := ioutil.TempDir("", "self-check-broken")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+
+			Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.yml"), []byte("language: broken\ninclude_files:\n- manifest.yml\n- VERSION\n- bin/detect\n- lib/missing.rb\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.2.3"), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(dir, "bin"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "bin", "detect"), []byte("#!/bin/sh\n"), 0644)).To(Succeed())
+
+			zipFile = filepath.Join(dir, "broken.zip")
+			Expect(packager.ZipFiles(zipFile, []packager.File{
+				{Name: "manifest.yml", Path: filepath.Join(dir, "manifest.yml")},
+				{Name: "VERSION", Path: filepath.Join(dir, "VERSION")},
+				{Name: "bin/detect", Path: filepath.Join(dir, "bin", "detect")},
+			})).To(Succeed())
+
+			err = packager.SelfCheck(zipFile)
+			Expect(err).To(MatchError(ContainSubstring("included file lib/missing.rb is missing")))
+			Expect(err).To(MatchError(ContainSubstring("bin/detect is not executable")))
+		})
+	})
+
+	Context("bin/detect fails", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, err = ioutil.TempDir("", "self-check-detect")
+			Expect(err).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.yml"), []byte("language: detect\ninclude_files:\n- manifest.yml\n- VERSION\n- bin/detect\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.2.3"), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(dir, "bin"), 0755)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		pack := func(detect string) string {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "bin", "detect"), []byte(detect), 0755)).To(Succeed())
+			zipFile = filepath.Join(dir, "detect.zip")
+			Expect(packager.ZipFiles(zipFile, []packager.File{
+				{Name: "manifest.yml", Path: filepath.Join(dir, "manifest.yml")},
+				{Name: "VERSION", Path: filepath.Join(dir, "VERSION")},
+				{Name: "bin/detect", Path: filepath.Join(dir, "bin", "detect")},
+			})).To(Succeed())
+			return zipFile
+		}
+
+		It("passes when it exits 1, as it does for an app it does not apply to", func() {
+			Expect(packager.SelfCheck(pack("#!/bin/sh\nexit 1\n"))).To(Succeed())
+		})
+
+		It("reports any other exit status with the output", func() {
+			err := packager.SelfCheck(pack("#!/bin/sh\necho 'lib/detect.rb: cannot load such file' >&2\nexit 127\n"))
+			Expect(err).To(MatchError(ContainSubstring("bin/detect failed with exit status 127, not 0 or 1")))
+			Expect(err).To(MatchError(ContainSubstring("lib/detect.rb: cannot load such file")))
+		})
+	})
+})
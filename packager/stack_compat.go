@@ -0,0 +1,169 @@
+package packager
+
+import (
+	"debug/elf"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/libbuildpack"
+)
+
+// StackProfile describes the runtime libraries a stack provides. An empty
+// Libraries list means only the glibc version is checked.
+type StackProfile struct {
+	GlibcVersion string   `yaml:"glibc"`
+	Libraries    []string `yaml:"libraries"`
+}
+
+// StackProfiles are the bundled profiles of the cflinuxfs stacks, keyed by
+// stack name. Entries can be added or replaced for other stacks.
+var StackProfiles = map[string]StackProfile{
+	"cflinuxfs2": {GlibcVersion: "2.19"},
+	"cflinuxfs3": {GlibcVersion: "2.27"},
+	"cflinuxfs4": {GlibcVersion: "2.35"},
+}
+
+// CheckBinaryCompatibility makes cached packaging inspect the ELF binaries in
+// every downloaded dependency and fail if any need a newer glibc, or a
+// library, that a stack the dependency is declared for does not provide.
+var CheckBinaryCompatibility bool
+
+// BinaryCompatibilityProblems unpacks a dependency archive and lists every
+// ELF binary in it that will not run on one of stacks. Libraries the archive
+// ships itself are not needed from the stack. Stacks without a profile are
+// skipped.
+func BinaryCompatibilityProblems(archive string, stacks []string) ([]string, error) {
+	dir, err := ioutil.TempDir("", "stack-compat")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := unpackDependency(archive, dir); err != nil {
+		return nil, err
+	}
+
+	var binaries []string
+	bundled := map[string]bool{}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		f, err := elf.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		binaries = append(binaries, path)
+		// the loader finds a library by its soname, or by its file name
+		// through an rpath
+		bundled[info.Name()] = true
+		if sonames, err := f.DynString(elf.DT_SONAME); err == nil {
+			for _, soname := range sonames {
+				bundled[soname] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, path := range binaries {
+		f, err := elf.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		for _, stack := range stacks {
+			profile, found := StackProfiles[stack]
+			if !found {
+				continue
+			}
+			for _, p := range elfProblems(f, profile, bundled) {
+				problems = append(problems, fmt.Sprintf("%s on %s: %s", rel, stack, p))
+			}
+		}
+		f.Close()
+	}
+	return problems, nil
+}
+
+// unpackDependency extracts an archive into dir, telling its format from its
+// contents, or copies any other file there as is. It reports whether the file
+// was an archive.
+func unpackDependency(archive, dir string) (bool, error) {
+	format, err := libbuildpack.ArchiveFormat(archive)
+	if err == nil {
+		if format == "" {
+			err = libbuildpack.CopyFile(archive, filepath.Join(dir, filepath.Base(archive)))
+		} else {
+			err = libbuildpack.ExtractArchive(archive, archive, dir)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not unpack %s: %v", archive, err)
+	}
+	return format != "", nil
+}
+
+func elfProblems(f *elf.File, profile StackProfile, bundled map[string]bool) []string {
+	var problems []string
+
+	symbols, _ := f.ImportedSymbols()
+	required := ""
+	for _, s := range symbols {
+		if v := strings.TrimPrefix(s.Version, "GLIBC_"); v != s.Version && compareVersions(v, required) > 0 {
+			required = v
+		}
+	}
+	if required != "" && profile.GlibcVersion != "" && compareVersions(required, profile.GlibcVersion) > 0 {
+		problems = append(problems, fmt.Sprintf("requires glibc %s, stack has %s", required, profile.GlibcVersion))
+	}
+
+	if len(profile.Libraries) > 0 {
+		libs, _ := f.ImportedLibraries()
+		for _, lib := range libs {
+			if !bundled[lib] && !containsString(profile.Libraries, lib) {
+				problems = append(problems, fmt.Sprintf("requires %s, which the stack does not provide", lib))
+			}
+		}
+	}
+	return problems
+}
+
+// compareVersions compares dotted numeric versions such as 2.27 and 2.3.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
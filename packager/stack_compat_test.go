@@ -0,0 +1,98 @@
+package packager_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"debug/elf"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack/packager"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BinaryCompatibilityProblems", func() {
+	const binary = "/bin/ls"
+
+	BeforeEach(func() {
+		f, err := elf.Open(binary)
+		if err != nil {
+			Skip("needs a dynamically linked " + binary)
+		}
+		defer f.Close()
+		if libs, _ := f.ImportedLibraries(); len(libs) == 0 {
+			Skip("needs a dynamically linked " + binary)
+		}
+	})
+
+	AfterEach(func() {
+		delete(packager.StackProfiles, "old-stack")
+		delete(packager.StackProfiles, "new-stack")
+	})
+
+	It("flags binaries needing a newer glibc than the stack has", func() {
+		packager.StackProfiles["old-stack"] = packager.StackProfile{GlibcVersion: "2.0"}
+
+		problems, err := packager.BinaryCompatibilityProblems(binary, []string{"old-stack"})
+		Expect(err).To(BeNil())
+		Expect(problems).To(ContainElement(MatchRegexp(`^ls on old-stack: requires glibc 2\.\d+, stack has 2\.0$`)))
+	})
+
+	It("flags libraries the stack does not provide", func() {
+		packager.StackProfiles["new-stack"] = packager.StackProfile{GlibcVersion: "99.0", Libraries: []string{"libnothing.so.1"}}
+
+		problems, err := packager.BinaryCompatibilityProblems(binary, []string{"new-stack"})
+		Expect(err).To(BeNil())
+		Expect(problems).To(ContainElement("ls on new-stack: requires libc.so.6, which the stack does not provide"))
+	})
+
+	It("accepts binaries the stack can run and skips unknown stacks", func() {
+		packager.StackProfiles["new-stack"] = packager.StackProfile{GlibcVersion: "99.0"}
+
+		problems, err := packager.BinaryCompatibilityProblems(binary, []string{"new-stack", "unknown-stack"})
+		Expect(err).To(BeNil())
+		Expect(problems).To(BeEmpty())
+	})
+
+	Context("with an archive", func() {
+		var archive string
+
+		BeforeEach(func() {
+			contents, err := ioutil.ReadFile(binary)
+			Expect(err).To(BeNil())
+
+			dir, err := ioutil.TempDir("", "stack-compat-test")
+			Expect(err).To(BeNil())
+			// nothing in the name says this is a tar.gz
+			archive = filepath.Join(dir, "dependency.bin")
+			fh, err := os.Create(archive)
+			Expect(err).To(BeNil())
+			defer fh.Close()
+			gz := gzip.NewWriter(fh)
+			tw := tar.NewWriter(gz)
+			// a binary shipping its own build of a library the stack lacks
+			for _, name := range []string{"bin/ls", "lib/libc.so.6"} {
+				Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(contents)), Typeflag: tar.TypeReg})).To(Succeed())
+				_, err = tw.Write(contents)
+				Expect(err).To(BeNil())
+			}
+			Expect(tw.Close()).To(Succeed())
+			Expect(gz.Close()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(filepath.Dir(archive))
+		})
+
+		It("unpacks it by its contents and does not flag the libraries it ships", func() {
+			packager.StackProfiles["old-stack"] = packager.StackProfile{GlibcVersion: "2.0", Libraries: []string{"libnothing.so.1"}}
+
+			problems, err := packager.BinaryCompatibilityProblems(archive, []string{"old-stack"})
+			Expect(err).To(BeNil())
+			Expect(problems).To(ContainElement(MatchRegexp(`^bin/ls on old-stack: requires glibc 2\.\d+, stack has 2\.0$`)))
+			Expect(problems).ToNot(ContainElement(ContainSubstring("requires libc.so.6")))
+		})
+	})
+})
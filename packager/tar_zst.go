@@ -0,0 +1,78 @@
+package packager
+
+import (
+	"archive/tar"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// TarZstFiles writes files to a zstd-compressed tarball at filename, piping
+// the tar stream through the zstd command at a level matching
+// CompressionLevel.
+func TarZstFiles(filename string, files []File) error {
+	level := 3
+	switch {
+	case CompressionLevel == flate.BestSpeed || CompressionLevel == flate.HuffmanOnly:
+		level = 1
+	case CompressionLevel > flate.BestSpeed:
+		// deflate levels 1-9 onto zstd's 1-19
+		level = CompressionLevel*2 + 1
+	}
+
+	cmd := exec.Command("zstd", "-q", "-f", fmt.Sprintf("-%d", level), "-o", filename)
+	cmd.Stderr = Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not run zstd, is it installed? %v", err)
+	}
+
+	writeErr := writeTar(stdin, files)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		os.Remove(filename)
+		return fmt.Errorf("zstd failed: %v", err)
+	}
+	if writeErr != nil {
+		os.Remove(filename)
+	}
+	return writeErr
+}
+
+func writeTar(w io.Writer, files []File) error {
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			return fmt.Errorf("failed to open included_file: %s, %v", file.Path, err)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = file.Name
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		fh, err := os.Open(file.Path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, fh)
+		fh.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
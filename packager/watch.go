@@ -0,0 +1,125 @@
+package packager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch looks for changed files.
+var DefaultWatchInterval = time.Second
+
+// WatchBuild reports a build made by Watch: the artifact, or the error that
+// failed the build, the files whose change started it (none for the first
+// build) and how long it took.
+type WatchBuild struct {
+	File     string
+	Err      error
+	Changed  []string
+	Duration time.Duration
+}
+
+// Watch packages the uncached buildpack in bpDir, as PackageContext does, and
+// packages it again whenever a file in bpDir is added, removed or modified,
+// until ctx is done. Each build is passed to report, so failed builds do not
+// stop the watch. Downloads in cacheDir are reused across builds. Artifacts,
+// manifest.lock, .git and cacheDir are not watched.
+func Watch(ctx context.Context, bpDir, cacheDir, version, stack string, interval time.Duration, report func(WatchBuild)) error {
+	bpDir, err := filepath.Abs(bpDir)
+	if err != nil {
+		return err
+	}
+	if cacheDir, err = filepath.Abs(cacheDir); err != nil {
+		return err
+	}
+
+	var files map[string]watchedFile
+	var changed []string
+	for {
+		start := time.Now()
+		file, err := PackageContext(ctx, bpDir, cacheDir, version, stack, false, true)
+		if ctx.Err() != nil {
+			return nil
+		}
+		report(WatchBuild{File: file, Err: err, Changed: changed, Duration: time.Since(start)})
+
+		// packaging writes the artifact, so compare against the files
+		// as they are after the build
+		if files, err = watchedFiles(bpDir, cacheDir); err != nil {
+			return err
+		}
+		for changed = nil; len(changed) == 0; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+
+			current, err := watchedFiles(bpDir, cacheDir)
+			if err != nil {
+				return err
+			}
+			changed = changedFiles(files, current)
+		}
+	}
+}
+
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchedFiles maps the files Watch looks at, relative to bpDir, to their
+// size and modification time.
+func watchedFiles(bpDir, cacheDir string) (map[string]watchedFile, error) {
+	files := map[string]watchedFile{}
+	err := filepath.Walk(bpDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" || path == cacheDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(bpDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == ManifestLockFile || isArtifact(rel) {
+			return nil
+		}
+		files[rel] = watchedFile{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// isArtifact reports whether rel names a buildpack artifact packaging wrote.
+func isArtifact(rel string) bool {
+	if filepath.Dir(rel) != "." || !strings.Contains(rel, "_buildpack") {
+		return false
+	}
+	return strings.HasSuffix(rel, ".zip") || strings.HasSuffix(rel, ".tar.zst")
+}
+
+func changedFiles(before, after map[string]watchedFile) []string {
+	var changed []string
+	for name, file := range after {
+		if old, found := before[name]; !found || old.size != file.size || !old.modTime.Equal(file.modTime) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, found := after[name]; !found {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
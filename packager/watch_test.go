@@ -0,0 +1,85 @@
+package packager_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	"github.com/cloudfoundry/libbuildpack/packager"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watch", func() {
+	var (
+		buildpackDir string
+		cacheDir     string
+		builds       chan packager.WatchBuild
+		done         chan error
+		cancel       context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildpackDir, err = ioutil.TempDir("", "watch")
+		Expect(err).To(BeNil())
+		Expect(libbuildpack.CopyDirectory("./fixtures/good", buildpackDir)).To(Succeed())
+		cacheDir, err = ioutil.TempDir("", "packager-cachedir")
+		Expect(err).To(BeNil())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		builds = make(chan packager.WatchBuild, 10)
+		done = make(chan error, 1)
+		go func() {
+			done <- packager.Watch(ctx, buildpackDir, cacheDir, "1.2.3", "cflinuxfs2", 10*time.Millisecond, func(build packager.WatchBuild) {
+				builds <- build
+			})
+		}()
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done, 10*time.Second).Should(Receive(BeNil()))
+		Expect(os.RemoveAll(buildpackDir)).To(Succeed())
+		Expect(os.RemoveAll(cacheDir)).To(Succeed())
+	})
+
+	It("builds the buildpack and builds it again when a file changes", func() {
+		var build packager.WatchBuild
+		Eventually(builds, 10*time.Second).Should(Receive(&build))
+		Expect(build.Err).To(BeNil())
+		Expect(build.Changed).To(BeEmpty())
+		Expect(build.File).To(Equal(filepath.Join(buildpackDir, "ruby_buildpack-cflinuxfs2-v1.2.3.zip")))
+		Expect(build.File).To(BeAnExistingFile())
+
+		Expect(ioutil.WriteFile(filepath.Join(buildpackDir, "manifest.lock"), []byte("dependencies: []\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(buildpackDir, "otherfile"), []byte("changed\n"), 0644)).To(Succeed())
+
+		Eventually(builds, 10*time.Second).Should(Receive(&build))
+		Expect(build.Err).To(BeNil())
+		Expect(build.Changed).To(Equal([]string{"otherfile"}))
+		Consistently(builds, 100*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("keeps watching after a failed build", func() {
+		Eventually(builds, 10*time.Second).Should(Receive())
+
+		manifest := filepath.Join(buildpackDir, "manifest.yml")
+		contents, err := ioutil.ReadFile(manifest)
+		Expect(err).To(BeNil())
+		Expect(ioutil.WriteFile(manifest, []byte("{"), 0644)).To(Succeed())
+
+		var build packager.WatchBuild
+		Eventually(builds, 10*time.Second).Should(Receive(&build))
+		Expect(build.Err).ToNot(BeNil())
+
+		Expect(ioutil.WriteFile(manifest, contents, 0644)).To(Succeed())
+		Eventually(builds, 10*time.Second).Should(Receive(&build))
+		Expect(build.Err).To(BeNil())
+	})
+})
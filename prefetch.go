@@ -0,0 +1,171 @@
+package libbuildpack
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultPrefetchWorkers bounds how many dependencies PrefetchDependencies
+// downloads at once.
+var DefaultPrefetchWorkers = 4
+
+type prefetchCache struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]string
+}
+
+// prefetchKey identifies entry by everything that decides which file it
+// downloads, so that entries for other stacks with the same name and version
+// are not served each other's files.
+func prefetchKey(entry *ManifestEntry) string {
+	return strings.Join([]string{entry.Dependency.Name, entry.Dependency.Version, entry.URI, entry.SHA256, strings.Join(entry.CFStacks, ",")}, "\x00")
+}
+
+// PrefetchDependencies downloads and checksums entries concurrently, with the
+// installer's download options, download cache and progress, so that later
+// InstallDependency and FetchDependency calls for them are served from disk
+// instead of waiting on the network one after another. Entries cached in the
+// buildpack itself, in the app cache or in the install cache are skipped, as
+// installing them downloads nothing. Every entry is attempted; the error lists
+// those that failed, and they are downloaded again when installed. Files that
+// are never installed are removed by RemovePrefetched or CleanupAppCache.
+//
+// It is on Installer rather than Manifest because the installer's options and
+// caches decide what is downloaded, and it is the installer that serves the
+// prefetched files.
+func (i *Installer) PrefetchDependencies(entries []ManifestEntry) error {
+	return i.PrefetchDependenciesCtx(context.Background(), entries)
+}
+
+// PrefetchDependenciesCtx is PrefetchDependencies, giving up on the
+// downloads when ctx is done.
+func (i *Installer) PrefetchDependenciesCtx(ctx context.Context, entries []ManifestEntry) error {
+	if i.prefetch == nil {
+		dir, err := ioutil.TempDir("", "prefetch")
+		if err != nil {
+			return err
+		}
+		i.prefetch = &prefetchCache{dir: dir, files: map[string]string{}}
+	}
+
+	workers := DefaultPrefetchWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan ManifestEntry)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []string
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				if err := i.prefetchEntry(ctx, entry); err != nil {
+					mu.Lock()
+					failures = append(failures, fmt.Sprintf("%s %s: %v", entry.Dependency.Name, entry.Dependency.Version, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		if entry.File != "" {
+			continue
+		}
+		jobs <- entry
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(failures) > 0 {
+		return fmt.Errorf("could not prefetch %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// RemovePrefetched deletes the prefetched files that were not installed.
+func (i *Installer) RemovePrefetched() error {
+	if i.prefetch == nil {
+		return nil
+	}
+	dir := i.prefetch.dir
+	i.prefetch = nil
+	return os.RemoveAll(dir)
+}
+
+func (i *Installer) prefetchEntry(ctx context.Context, entry ManifestEntry) error {
+	if _, found := i.prefetched(&entry); found || i.cachedLocally(&entry) {
+		return nil
+	}
+
+	dir, err := ioutil.TempDir(i.prefetch.dir, entry.Dependency.Name)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, filepath.Base(entry.URI))
+	if _, err := i.fetch(ctx, &entry, file); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	i.prefetch.mu.Lock()
+	defer i.prefetch.mu.Unlock()
+	i.prefetch.files[prefetchKey(&entry)] = file
+	return nil
+}
+
+// cachedLocally reports whether the app cache or the install cache already
+// has entry, so installing it would not download it.
+func (i *Installer) cachedLocally(entry *ManifestEntry) bool {
+	if i.appCacheDir == "" {
+		return false
+	}
+	for _, path := range []string{i.installCachePath(entry), i.appCacheFile(entry)} {
+		if path == "" {
+			continue
+		}
+		if exists, err := FileExists(path); err == nil && exists {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Installer) prefetched(entry *ManifestEntry) (string, bool) {
+	if i.prefetch == nil {
+		return "", false
+	}
+	i.prefetch.mu.Lock()
+	defer i.prefetch.mu.Unlock()
+	file, found := i.prefetch.files[prefetchKey(entry)]
+	return file, found
+}
+
+// usePrefetched hands over the prefetched file for entry, if there is one.
+func (i *Installer) usePrefetched(entry *ManifestEntry, outputFile string) (bool, error) {
+	file, found := i.prefetched(entry)
+	if !found {
+		return false, nil
+	}
+	i.prefetch.mu.Lock()
+	delete(i.prefetch.files, prefetchKey(entry))
+	i.prefetch.mu.Unlock()
+
+	i.manifest.log.Info("Using prefetched %s %s", entry.Dependency.Name, entry.Dependency.Version)
+	defer os.RemoveAll(filepath.Dir(file))
+	if err := CopyFile(file, outputFile); err != nil {
+		return true, err
+	}
+	return true, deleteBadFile(entry, outputFile)
+}
@@ -0,0 +1,170 @@
+package libbuildpack_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	"github.com/cloudfoundry/libbuildpack/ansicleaner"
+	httpmock "github.com/jarcoal/httpmock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PrefetchDependencies", func() {
+	var (
+		oldCfStack  string
+		oldTmpDir   string
+		tmpDir      string
+		manifestDir string
+		outputDir   string
+		buffer      *bytes.Buffer
+		manifest    *libbuildpack.Manifest
+		installer   *libbuildpack.Installer
+	)
+
+	entry := func(name, content string) libbuildpack.ManifestEntry {
+		sum := sha256.Sum256([]byte(content))
+		return libbuildpack.ManifestEntry{
+			Dependency: libbuildpack.Dependency{Name: name, Version: "1.0.0"},
+			URI:        "https://example.com/dependencies/" + name + "-1.0.0.tgz",
+			SHA256:     hex.EncodeToString(sum[:]),
+			CFStacks:   []string{"cflinuxfs2"},
+		}
+	}
+
+	BeforeEach(func() {
+		oldCfStack = os.Getenv("CF_STACK")
+		os.Setenv("CF_STACK", "cflinuxfs2")
+		httpmock.Reset()
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "tmp")
+		Expect(err).To(BeNil())
+		oldTmpDir = os.Getenv("TMPDIR")
+		os.Setenv("TMPDIR", tmpDir)
+
+		manifestDir, err = ioutil.TempDir("", "buildpack")
+		Expect(err).To(BeNil())
+		outputDir, err = ioutil.TempDir("", "downloads")
+		Expect(err).To(BeNil())
+
+		entries := []libbuildpack.ManifestEntry{entry("node", "node data"), entry("yarn", "yarn data"), entry("npm", "npm data")}
+		for _, e := range entries {
+			httpmock.RegisterResponder("GET", e.URI, httpmock.NewStringResponder(200, e.Dependency.Name+" data"))
+		}
+		Expect(libbuildpack.NewYAML().Write(filepath.Join(manifestDir, "manifest.yml"), libbuildpack.Manifest{
+			LanguageString:  "sample",
+			ManifestEntries: entries,
+		})).To(Succeed())
+
+		buffer = new(bytes.Buffer)
+		manifest, err = libbuildpack.NewManifest(manifestDir, libbuildpack.NewLogger(ansicleaner.New(buffer)), time.Now())
+		Expect(err).To(BeNil())
+		installer = libbuildpack.NewInstaller(manifest)
+	})
+
+	AfterEach(func() {
+		os.Setenv("CF_STACK", oldCfStack)
+		os.Setenv("TMPDIR", oldTmpDir)
+		os.RemoveAll(tmpDir)
+		os.RemoveAll(manifestDir)
+		os.RemoveAll(outputDir)
+	})
+
+	It("downloads every entry once and serves installs from the prefetched files", func() {
+		Expect(installer.PrefetchDependencies(manifest.ManifestEntries)).To(Succeed())
+		Expect(httpmock.GetTotalCallCount()).To(Equal(3))
+
+		for _, name := range []string{"node", "yarn", "npm"} {
+			outputFile := filepath.Join(outputDir, name+".tgz")
+			Expect(installer.FetchDependency(libbuildpack.Dependency{Name: name, Version: "1.0.0"}, outputFile)).To(Succeed())
+			Expect(ioutil.ReadFile(outputFile)).To(Equal([]byte(name + " data")))
+		}
+		Expect(httpmock.GetTotalCallCount()).To(Equal(3))
+		Expect(buffer.String()).To(ContainSubstring("Using prefetched yarn 1.0.0"))
+	})
+
+	It("reports failed entries and downloads them again when installed", func() {
+		yarn := manifest.ManifestEntries[1]
+		httpmock.RegisterResponder("GET", yarn.URI, httpmock.NewStringResponder(404, ""))
+
+		err := installer.PrefetchDependencies(manifest.ManifestEntries)
+		Expect(err).To(MatchError(ContainSubstring("could not prefetch yarn 1.0.0")))
+
+		httpmock.RegisterResponder("GET", yarn.URI, httpmock.NewStringResponder(200, "yarn data"))
+		outputFile := filepath.Join(outputDir, "yarn.tgz")
+		Expect(installer.FetchDependency(yarn.Dependency, outputFile)).To(Succeed())
+		Expect(ioutil.ReadFile(outputFile)).To(Equal([]byte("yarn data")))
+		Expect(httpmock.GetTotalCallCount()).To(Equal(4))
+	})
+
+	It("downloads with the installer's options and download cache", func() {
+		cacheDir := filepath.Join(outputDir, "cache")
+		Expect(installer.SetDownloadCache(cacheDir, 0)).To(Succeed())
+		installer.SetDownloadOptions(libbuildpack.DownloadOptions{Attempts: 1})
+		yarn := manifest.ManifestEntries[1]
+		httpmock.RegisterResponder("GET", yarn.URI, httpmock.NewStringResponder(500, ""))
+
+		Expect(installer.PrefetchDependencies(manifest.ManifestEntries)).To(MatchError(ContainSubstring("could not prefetch yarn 1.0.0")))
+		Expect(httpmock.GetTotalCallCount()).To(Equal(3))
+
+		files, err := ioutil.ReadDir(cacheDir)
+		Expect(err).To(BeNil())
+		var cached int
+		for _, file := range files {
+			if filepath.Ext(file.Name()) == "" {
+				cached++
+			}
+		}
+		Expect(cached).To(Equal(2))
+	})
+
+	It("skips entries the app cache already has", func() {
+		Expect(installer.SetAppCacheDir(filepath.Join(outputDir, "app-cache"))).To(Succeed())
+		outputFile := filepath.Join(outputDir, "node.tgz")
+		Expect(installer.FetchDependency(libbuildpack.Dependency{Name: "node", Version: "1.0.0"}, outputFile)).To(Succeed())
+		Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+
+		Expect(installer.PrefetchDependencies(manifest.ManifestEntries)).To(Succeed())
+		Expect(httpmock.GetTotalCallCount()).To(Equal(3))
+
+		Expect(installer.FetchDependency(libbuildpack.Dependency{Name: "node", Version: "1.0.0"}, outputFile)).To(Succeed())
+		Expect(ioutil.ReadFile(outputFile)).To(Equal([]byte("node data")))
+		Expect(httpmock.GetTotalCallCount()).To(Equal(3))
+		Expect(buffer.String()).ToNot(ContainSubstring("Using prefetched node"))
+	})
+
+	It("does not serve an entry for another stack with the same name and version", func() {
+		other := entry("node", "other node data")
+		other.URI = "https://example.com/dependencies/node-1.0.0-cflinuxfs3.tgz"
+		other.CFStacks = []string{"cflinuxfs3"}
+		httpmock.RegisterResponder("GET", other.URI, httpmock.NewStringResponder(200, "other node data"))
+
+		Expect(installer.PrefetchDependencies([]libbuildpack.ManifestEntry{other})).To(Succeed())
+		Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+
+		outputFile := filepath.Join(outputDir, "node.tgz")
+		Expect(installer.FetchDependency(libbuildpack.Dependency{Name: "node", Version: "1.0.0"}, outputFile)).To(Succeed())
+		Expect(ioutil.ReadFile(outputFile)).To(Equal([]byte("node data")))
+		Expect(httpmock.GetTotalCallCount()).To(Equal(2))
+	})
+
+	It("removes the files that were not installed", func() {
+		Expect(installer.PrefetchDependencies(manifest.ManifestEntries)).To(Succeed())
+		Expect(filepath.Glob(filepath.Join(tmpDir, "prefetch*"))).To(HaveLen(1))
+
+		Expect(installer.RemovePrefetched()).To(Succeed())
+		Expect(filepath.Glob(filepath.Join(tmpDir, "prefetch*"))).To(BeEmpty())
+
+		outputFile := filepath.Join(outputDir, "node.tgz")
+		Expect(installer.FetchDependency(libbuildpack.Dependency{Name: "node", Version: "1.0.0"}, outputFile)).To(Succeed())
+		Expect(httpmock.GetTotalCallCount()).To(Equal(4))
+	})
+})
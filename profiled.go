@@ -0,0 +1,111 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// ProfileD builds a profile.d script from environment exports and shell
+// snippets, e.g.
+//
+//	name, err := stager.ProfileD("node.sh").
+//		Export("NODE_ENV", "production").
+//		PrependPath("PATH", `"$DEPS_DIR"/0/node/bin`).
+//		Snippet("ulimit -n 4096").
+//		Write()
+//
+// Variables are exported in the order they were first added, each once, and
+// before the snippets, which follow in the order they were added. The script
+// is for a unix shell, so it cannot be written on windows.
+type ProfileD struct {
+	stager   *Stager
+	name     string
+	order    []string
+	vars     map[string]*profileDVar
+	snippets []string
+	err      error
+}
+
+type profileDVar struct {
+	value   string
+	prepend []string
+}
+
+// ProfileD starts a profile.d script that Write adds to the dep dir as name,
+// numbered to run after the scripts already there.
+func (s *Stager) ProfileD(name string) *ProfileD {
+	return &ProfileD{stager: s, name: name, vars: map[string]*profileDVar{}}
+}
+
+// Export sets name to value, which is quoted so the shell takes it
+// literally. Exporting name again replaces the value.
+func (p *ProfileD) Export(name, value string) *ProfileD {
+	return p.ExportExpr(name, shellQuote(value))
+}
+
+// ExportExpr sets name to expr, a shell word that is expanded at launch such
+// as `"$DEPS_DIR"/0/node` or `"${PORT:-8080}"`.
+func (p *ProfileD) ExportExpr(name, expr string) *ProfileD {
+	if v := p.variable(name); v != nil {
+		v.value = expr
+	}
+	return p
+}
+
+// PrependPath puts expr, a shell word like those of ExportExpr, in front of
+// the colon separated list in name, after the entries already prepended.
+func (p *ProfileD) PrependPath(name, expr string) *ProfileD {
+	if v := p.variable(name); v != nil {
+		v.prepend = append(v.prepend, expr)
+	}
+	return p
+}
+
+// Snippet adds shell commands to run after the exports.
+func (p *ProfileD) Snippet(script string) *ProfileD {
+	p.snippets = append(p.snippets, strings.TrimRight(script, "\n"))
+	return p
+}
+
+func (p *ProfileD) variable(name string) *profileDVar {
+	if !envVarName.MatchString(name) {
+		if p.err == nil {
+			p.err = fmt.Errorf("invalid environment variable name %q in profile.d script %s", name, p.name)
+		}
+		return nil
+	}
+	if _, found := p.vars[name]; !found {
+		p.vars[name] = &profileDVar{}
+		p.order = append(p.order, name)
+	}
+	return p.vars[name]
+}
+
+// String is the script.
+func (p *ProfileD) String() string {
+	var script strings.Builder
+	for _, name := range p.order {
+		v := p.vars[name]
+		value := strings.Join(v.prepend, ":")
+		switch {
+		case v.value != "" && value != "":
+			value += ":" + v.value
+		case v.value != "":
+			value = v.value
+		default:
+			value += fmt.Sprintf("${%[1]s:+:$%[1]s}", name)
+		}
+		fmt.Fprintf(&script, "export %s=%s\n", name, value)
+	}
+	for _, snippet := range p.snippets {
+		script.WriteString(snippet + "\n")
+	}
+	return script.String()
+}
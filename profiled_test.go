@@ -0,0 +1,93 @@
+package libbuildpack_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProfileD", func() {
+	var (
+		depsDir string
+		stager  *libbuildpack.Stager
+		err     error
+	)
+
+	BeforeEach(func() {
+		depsDir, err = ioutil.TempDir("", "profiled")
+		Expect(err).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(depsDir, "0"), 0755)).To(Succeed())
+
+		logger := libbuildpack.NewLogger(ioutil.Discard)
+		manifest, err := libbuildpack.NewManifest(filepath.Join("fixtures", "manifest", "standard"), logger, time.Now())
+		Expect(err).To(BeNil())
+		stager = libbuildpack.NewStager([]string{depsDir, depsDir, depsDir, "0", depsDir}, logger, manifest)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	It("exports each variable once, in order, before the snippets", func() {
+		script := stager.ProfileD("app.sh").
+			Export("NODE_ENV", "development").
+			PrependPath("PATH", `"$DEPS_DIR"/0/node/bin`).
+			Snippet("ulimit -n 4096\n").
+			Export("NODE_ENV", "production").
+			ExportExpr("NODE_HOME", `"$DEPS_DIR"/0/node`).
+			PrependPath("PATH", `"$HOME"/bin`).
+			String()
+
+		Expect(script).To(Equal(`export NODE_ENV='production'
+export PATH="$DEPS_DIR"/0/node/bin:"$HOME"/bin${PATH:+:$PATH}
+export NODE_HOME="$DEPS_DIR"/0/node
+ulimit -n 4096
+`))
+	})
+
+	It("writes numbered scripts that the shell runs with values intact", func() {
+		if runtime.GOOS == "windows" {
+			Skip("uses sh")
+		}
+
+		first, err := stager.ProfileD("first.sh").Export("GREETING", `it's $HOME "quoted"`).Write()
+		Expect(err).To(BeNil())
+		second, err := stager.ProfileD("second.sh").PrependPath("LIST", "'b'").PrependPath("LIST", "'c'").Write()
+		Expect(err).To(BeNil())
+		Expect([]string{first, second}).To(Equal([]string{"00_first.sh", "01_second.sh"}))
+
+		profileDir := filepath.Join(depsDir, "0", "profile.d")
+		cmd := exec.Command("sh", "-c", `. ./00_first.sh; . ./01_second.sh; printf '%s|%s' "$GREETING" "$LIST"`)
+		cmd.Dir = profileDir
+		cmd.Env = append(os.Environ(), "LIST=a")
+		output, err := cmd.Output()
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal(`it's $HOME "quoted"|b:c:a`))
+	})
+
+	It("refuses invalid variable names", func() {
+		if runtime.GOOS == "windows" {
+			Skip("profile.d scripts are not written on windows")
+		}
+
+		_, err := stager.ProfileD("bad.sh").Export("NOT-VALID", "x").Write()
+		Expect(err).To(MatchError(`invalid environment variable name "NOT-VALID" in profile.d script bad.sh`))
+		Expect(filepath.Join(depsDir, "0", "profile.d")).ToNot(BeADirectory())
+	})
+
+	It("does not write unix scripts on windows", func() {
+		if runtime.GOOS != "windows" {
+			Skip("only on windows")
+		}
+
+		_, err := stager.ProfileD("app.sh").Export("NODE_ENV", "production").Write()
+		Expect(err).To(MatchError("profile.d scripts built with ProfileD are not supported on windows"))
+	})
+})
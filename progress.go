@@ -0,0 +1,124 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Progress is told how far along dependency installs are.
+type Progress interface {
+	// Downloading reports that downloaded of the total bytes of dep have
+	// arrived; total is -1 when the server does not say.
+	Downloading(dep Dependency, downloaded, total int64)
+	// Extracting reports that dep has been downloaded and is being extracted.
+	Extracting(dep Dependency)
+}
+
+// DefaultProgressInterval is how often download progress is logged when the
+// log is not a terminal.
+var DefaultProgressInterval = 15 * time.Second
+
+// terminalProgressInterval is how often the progress line is redrawn on a
+// terminal.
+const terminalProgressInterval = 200 * time.Millisecond
+
+// ProgressReporter returns a Progress that renders download progress as
+// "Downloaded 45% (230.4MiB of 512.0MiB)" lines. On a terminal the line is
+// redrawn in place; otherwise, as in staging and CI logs, a line is only
+// logged once a download has gone on for interval since the last one, so
+// quick downloads add nothing to the log.
+func (l *Logger) ProgressReporter(interval time.Duration) Progress {
+	return &logProgress{log: l, interval: interval, terminal: isTerminal(l.w), started: map[Dependency]time.Time{}, reported: map[Dependency]bool{}}
+}
+
+type logProgress struct {
+	log      *Logger
+	interval time.Duration
+	terminal bool
+
+	mu        sync.Mutex
+	started   map[Dependency]time.Time
+	lastPrint time.Time
+	drawing   bool
+	reported  map[Dependency]bool
+}
+
+func (p *logProgress) Downloading(dep Dependency, downloaded, total int64) {
+	if !p.log.Enabled(LogLevelInfo) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if _, found := p.started[dep]; !found {
+		p.started[dep] = now
+		p.lastPrint = now
+		if !p.terminal {
+			return
+		}
+	}
+
+	done := total >= 0 && downloaded >= total
+	if p.terminal {
+		if !done && now.Sub(p.lastPrint) < terminalProgressInterval {
+			return
+		}
+		p.lastPrint = now
+		p.log.mu.Lock()
+		fmt.Fprintf(p.log.w, "\r%s %s", msgPrefix, progressMessage(downloaded, total))
+		p.drawing = !done
+		if done {
+			fmt.Fprintln(p.log.w)
+		}
+		p.log.lastWrite = now
+		p.log.mu.Unlock()
+		return
+	}
+
+	if done || now.Sub(p.lastPrint) < p.interval {
+		return
+	}
+	p.lastPrint = now
+	p.reported[dep] = true
+	p.log.Info("%s", progressMessage(downloaded, total))
+}
+
+func (p *logProgress) Extracting(dep Dependency) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawing {
+		p.log.mu.Lock()
+		fmt.Fprintln(p.log.w)
+		p.log.mu.Unlock()
+		p.drawing = false
+	}
+	// only slow downloads had their progress logged, and those are the
+	// large dependencies whose extraction takes a while too
+	if p.reported[dep] {
+		p.log.Info("Extracting %s %s", dep.Name, dep.Version)
+	}
+	delete(p.started, dep)
+	delete(p.reported, dep)
+}
+
+func progressMessage(downloaded, total int64) string {
+	if total <= 0 {
+		return fmt.Sprintf("Downloaded %s", formatBytes(downloaded))
+	}
+	return fmt.Sprintf("Downloaded %d%% (%s of %s)", downloaded*100/total, formatBytes(downloaded), formatBytes(total))
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,132 @@
+package libbuildpack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	"github.com/cloudfoundry/libbuildpack/ansicleaner"
+	httpmock "github.com/jarcoal/httpmock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type recordingProgress struct {
+	downloaded []int64
+	totals     []int64
+	extracting []libbuildpack.Dependency
+}
+
+func (r *recordingProgress) Downloading(dep libbuildpack.Dependency, downloaded, total int64) {
+	r.downloaded = append(r.downloaded, downloaded)
+	r.totals = append(r.totals, total)
+}
+
+func (r *recordingProgress) Extracting(dep libbuildpack.Dependency) {
+	r.extracting = append(r.extracting, dep)
+}
+
+var _ = Describe("Progress", func() {
+	var (
+		buffer *bytes.Buffer
+		logger *libbuildpack.Logger
+		dep    libbuildpack.Dependency
+	)
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		logger = libbuildpack.NewLogger(ansicleaner.New(buffer))
+		dep = libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}
+	})
+
+	Describe("Installer", func() {
+		var (
+			installer  *libbuildpack.Installer
+			progress   *recordingProgress
+			outputDir  string
+			contents   []byte
+			oldCfStack string
+			err        error
+		)
+
+		BeforeEach(func() {
+			oldCfStack = os.Getenv("CF_STACK")
+			os.Setenv("CF_STACK", "cflinuxfs2")
+			httpmock.Reset()
+			contents, err = ioutil.ReadFile("fixtures/thing.tgz")
+			Expect(err).To(BeNil())
+			httpmock.RegisterResponder("GET", "https://example.com/dependencies/real_tar_file-3-linux-x64.tgz", func(*http.Request) (*http.Response, error) {
+				resp := httpmock.NewBytesResponse(200, contents)
+				resp.ContentLength = int64(len(contents))
+				resp.Header.Set("Content-Length", strconv.Itoa(len(contents)))
+				return resp, nil
+			})
+
+			outputDir, err = ioutil.TempDir("", "downloads")
+			Expect(err).To(BeNil())
+
+			manifest, err := libbuildpack.NewManifest("fixtures/manifest/fetch", logger, time.Now())
+			Expect(err).To(BeNil())
+			installer = libbuildpack.NewInstaller(manifest)
+			progress = &recordingProgress{}
+			installer.SetProgress(progress)
+		})
+
+		AfterEach(func() {
+			os.Setenv("CF_STACK", oldCfStack)
+			Expect(os.RemoveAll(outputDir)).To(Succeed())
+		})
+
+		It("reports download progress and extraction", func() {
+			Expect(installer.InstallDependency(dep, outputDir)).To(Succeed())
+
+			Expect(progress.downloaded).ToNot(BeEmpty())
+			Expect(progress.downloaded[len(progress.downloaded)-1]).To(Equal(int64(len(contents))))
+			for _, total := range progress.totals {
+				Expect(total).To(Equal(int64(len(contents))))
+			}
+			Expect(progress.extracting).To(Equal([]libbuildpack.Dependency{dep}))
+		})
+
+		It("reports nothing once disabled", func() {
+			installer.SetProgress(nil)
+			Expect(installer.InstallDependency(dep, outputDir)).To(Succeed())
+			Expect(buffer.String()).ToNot(ContainSubstring("Downloaded"))
+		})
+	})
+
+	Describe("ProgressReporter", func() {
+		It("logs progress of slow downloads", func() {
+			reporter := logger.ProgressReporter(0)
+			reporter.Downloading(dep, 0, 2048)
+			reporter.Downloading(dep, 1024, 2048)
+			reporter.Downloading(dep, 2048, 2048)
+			reporter.Extracting(dep)
+
+			Expect(buffer.String()).To(Equal("       Downloaded 50% (1.0KiB of 2.0KiB)\n       Extracting real_tar_file 3\n"))
+		})
+
+		It("logs the bytes downloaded when the total is unknown", func() {
+			reporter := logger.ProgressReporter(0)
+			reporter.Downloading(dep, 512, -1)
+			reporter.Downloading(dep, 1536, -1)
+
+			Expect(buffer.String()).To(Equal("       Downloaded 1.5KiB\n"))
+		})
+
+		It("logs nothing for quick downloads", func() {
+			reporter := logger.ProgressReporter(time.Minute)
+			reporter.Downloading(dep, 0, 2048)
+			reporter.Downloading(dep, 1024, 2048)
+			reporter.Downloading(dep, 2048, 2048)
+			reporter.Extracting(dep)
+
+			Expect(buffer.String()).To(BeEmpty())
+		})
+	})
+})
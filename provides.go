@@ -0,0 +1,138 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+const providesFile = "provides.yml"
+
+type ProvidedDependency struct {
+	Dependency Dependency `yaml:",inline"`
+	DepsIdx    string     `yaml:"-"`
+}
+
+type providesYml struct {
+	Provides []Dependency `yaml:"provides"`
+}
+
+// WriteProvides records in <depDir>/provides.yml that this buildpack supplies
+// the given dependencies, so that later buildpacks can find them with Provided.
+// An existing entry with the same name is replaced.
+func (s *Stager) WriteProvides(deps ...Dependency) error {
+	file := filepath.Join(s.DepDir(), providesFile)
+
+	var current providesYml
+	if exists, err := FileExists(file); err != nil {
+		return err
+	} else if exists {
+		if err := NewYAML().Load(file, &current); err != nil {
+			return err
+		}
+	}
+
+	for _, dep := range deps {
+		replaced := false
+		for idx, existing := range current.Provides {
+			if existing.Name == dep.Name {
+				current.Provides[idx] = dep
+				replaced = true
+			}
+		}
+		if !replaced {
+			current.Provides = append(current.Provides, dep)
+		}
+	}
+
+	return NewYAML().Write(file, current)
+}
+
+// Provided returns every dependency named depName declared by any buildpack
+// in the deps dir, ordered by deps index.
+func (s *Stager) Provided(depName string) ([]ProvidedDependency, error) {
+	files, err := ioutil.ReadDir(s.depsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var provided []ProvidedDependency
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		file := filepath.Join(s.depsDir, f.Name(), providesFile)
+		if exists, err := FileExists(file); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		var p providesYml
+		if err := NewYAML().Load(file, &p); err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", file, err)
+		}
+		for _, dep := range p.Provides {
+			if dep.Name == depName {
+				provided = append(provided, ProvidedDependency{Dependency: dep, DepsIdx: f.Name()})
+			}
+		}
+	}
+
+	sort.SliceStable(provided, func(i, j int) bool {
+		return depsIdxLess(provided[i].DepsIdx, provided[j].DepsIdx)
+	})
+
+	return provided, nil
+}
+
+// RequireProvided returns the highest version of depName matching constraint
+// that a buildpack with a lower deps index declared, or an error naming what
+// was found.
+func (s *Stager) RequireProvided(depName, constraint string) (ProvidedDependency, error) {
+	all, err := s.Provided(depName)
+	if err != nil {
+		return ProvidedDependency{}, err
+	}
+
+	var provided []ProvidedDependency
+	for _, p := range all {
+		if depsIdxLess(p.DepsIdx, s.DepsIdx()) {
+			provided = append(provided, p)
+		}
+	}
+
+	if len(provided) == 0 {
+		return ProvidedDependency{}, fmt.Errorf("%s is required but no buildpack provides it", depName)
+	}
+
+	var versions []string
+	for _, p := range provided {
+		versions = append(versions, p.Dependency.Version)
+	}
+
+	version, err := FindMatchingVersion(constraint, versions)
+	if err != nil {
+		return ProvidedDependency{}, fmt.Errorf("%s %s is required but buildpacks only provide %v", depName, constraint, versions)
+	}
+
+	for _, p := range provided {
+		if p.Dependency.Version == version {
+			return p, nil
+		}
+	}
+	return ProvidedDependency{}, fmt.Errorf("%s %s is required but buildpacks only provide %v", depName, constraint, versions)
+}
+
+func depsIdxLess(a, b string) bool {
+	var ia, ib int
+	if _, err := fmt.Sscanf(a, "%d", &ia); err != nil {
+		return a < b
+	}
+	if _, err := fmt.Sscanf(b, "%d", &ib); err != nil {
+		return a < b
+	}
+	return ia < ib
+}
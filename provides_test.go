@@ -0,0 +1,109 @@
+package libbuildpack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provides", func() {
+	var (
+		depsDir string
+		err     error
+		stagers map[string]*libbuildpack.Stager
+	)
+
+	BeforeEach(func() {
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+
+		logger := libbuildpack.NewLogger(&bytes.Buffer{})
+		stagers = map[string]*libbuildpack.Stager{}
+		for _, idx := range []string{"0", "1", "10"} {
+			Expect(os.MkdirAll(filepath.Join(depsDir, idx), 0755)).To(Succeed())
+			stagers[idx] = libbuildpack.NewStager([]string{"buildDir", "cacheDir", depsDir, idx}, logger, &libbuildpack.Manifest{})
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	Describe("WriteProvides", func() {
+		It("replaces entries with the same name", func() {
+			Expect(stagers["0"].WriteProvides(libbuildpack.Dependency{Name: "node", Version: "10.1.0"}, libbuildpack.Dependency{Name: "yarn", Version: "1.0.0"})).To(Succeed())
+			Expect(stagers["0"].WriteProvides(libbuildpack.Dependency{Name: "node", Version: "10.2.0"})).To(Succeed())
+
+			provided, err := stagers["0"].Provided("node")
+			Expect(err).To(BeNil())
+			Expect(provided).To(Equal([]libbuildpack.ProvidedDependency{
+				{Dependency: libbuildpack.Dependency{Name: "node", Version: "10.2.0"}, DepsIdx: "0"},
+			}))
+
+			provided, err = stagers["0"].Provided("yarn")
+			Expect(err).To(BeNil())
+			Expect(provided).To(HaveLen(1))
+		})
+	})
+
+	Describe("Provided", func() {
+		It("returns declarations from every buildpack in deps index order", func() {
+			Expect(stagers["10"].WriteProvides(libbuildpack.Dependency{Name: "node", Version: "12.0.0"})).To(Succeed())
+			Expect(stagers["1"].WriteProvides(libbuildpack.Dependency{Name: "node", Version: "10.2.0"})).To(Succeed())
+
+			provided, err := stagers["0"].Provided("node")
+			Expect(err).To(BeNil())
+			Expect(provided).To(Equal([]libbuildpack.ProvidedDependency{
+				{Dependency: libbuildpack.Dependency{Name: "node", Version: "10.2.0"}, DepsIdx: "1"},
+				{Dependency: libbuildpack.Dependency{Name: "node", Version: "12.0.0"}, DepsIdx: "10"},
+			}))
+		})
+
+		It("returns nothing when no buildpack provides the dependency", func() {
+			provided, err := stagers["0"].Provided("node")
+			Expect(err).To(BeNil())
+			Expect(provided).To(BeEmpty())
+		})
+	})
+
+	Describe("RequireProvided", func() {
+		BeforeEach(func() {
+			Expect(stagers["0"].WriteProvides(libbuildpack.Dependency{Name: "node", Version: "10.2.0"})).To(Succeed())
+			Expect(stagers["1"].WriteProvides(libbuildpack.Dependency{Name: "node", Version: "12.0.0"})).To(Succeed())
+		})
+
+		It("returns the highest matching version", func() {
+			provided, err := stagers["10"].RequireProvided("node", "10.x")
+			Expect(err).To(BeNil())
+			Expect(provided.Dependency.Version).To(Equal("10.2.0"))
+			Expect(provided.DepsIdx).To(Equal("0"))
+		})
+
+		It("errors when no provided version matches", func() {
+			_, err := stagers["10"].RequireProvided("node", "8.x")
+			Expect(err).To(MatchError("node 8.x is required but buildpacks only provide [10.2.0 12.0.0]"))
+		})
+
+		It("ignores what this and later buildpacks provide", func() {
+			Expect(stagers["10"].WriteProvides(libbuildpack.Dependency{Name: "node", Version: "12.1.0"})).To(Succeed())
+
+			provided, err := stagers["1"].RequireProvided("node", "10.x")
+			Expect(err).To(BeNil())
+			Expect(provided.DepsIdx).To(Equal("0"))
+
+			_, err = stagers["1"].RequireProvided("node", "12.x")
+			Expect(err).To(MatchError("node 12.x is required but buildpacks only provide [10.2.0]"))
+		})
+
+		It("errors when nothing is provided", func() {
+			_, err := stagers["10"].RequireProvided("python", "3.x")
+			Expect(err).To(MatchError("python is required but no buildpack provides it"))
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package libbuildpack
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// credentialParam matches query parameters that carry credentials, as
+	// in presigned S3 and GCS urls, Azure SAS urls and token authenticated
+	// mirrors.
+	credentialParam = regexp.MustCompile(`(?i)^(x-amz-(signature|credential|security-token)|x-goog-(signature|credential)|sig|signature|token|access_token|auth|api_?key|key|password|secret)$`)
+
+	// uriInText matches urls within log messages and command output.
+	uriInText = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+)
+
+// redactQuery replaces the values of credential parameters in a raw query,
+// leaving the rest of it as it was.
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		parts := strings.SplitN(param, "=", 2)
+		if len(parts) == 2 && parts[1] != "" && credentialParam.MatchString(parts[0]) {
+			params[i] = parts[0] + "=-redacted-"
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// RedactURIs redacts the credentials of every url in text, such as the
+// signature of a presigned url or an Azure SAS token.
+func RedactURIs(text string) string {
+	if !strings.Contains(text, "://") {
+		return text
+	}
+	return uriInText.ReplaceAllStringFunc(text, func(uri string) string {
+		if filtered, err := filterURI(uri); err == nil {
+			return filtered
+		}
+		return uri
+	})
+}
+
+// Redact makes the logger replace every match of the patterns with
+// [REDACTED] in what it logs, including command output streamed through an
+// OutputTee. Credentials in urls are always redacted.
+func (l *Logger) Redact(patterns ...*regexp.Regexp) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactions = append(l.redactions, patterns...)
+}
+
+// redact is s with credentials in urls and matches of the logger's patterns
+// redacted. The caller holds l.mu.
+func (l *Logger) redact(s string) string {
+	s = RedactURIs(s)
+	for _, pattern := range l.redactions {
+		s = pattern.ReplaceAllString(s, "[REDACTED]")
+	}
+	return s
+}
+
+// redacted is redact for callers that do not hold l.mu.
+func (l *Logger) redacted(s string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.redact(s)
+}
@@ -0,0 +1,76 @@
+package libbuildpack
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Masterminds/semver"
+)
+
+// DependencyResolutionError is returned when the manifest has no entry for a
+// dependency's name and version on the current stack. It carries what is
+// available instead so the user can be pointed somewhere useful.
+type DependencyResolutionError struct {
+	Dependency Dependency
+	Stack      string
+	// NearestVersions are the versions closest to the requested one that are
+	// available on Stack, nearest first.
+	NearestVersions []string
+	// OtherStacks are the stacks the requested version is available on.
+	OtherStacks []string
+}
+
+func (e *DependencyResolutionError) Error() string {
+	return fmt.Sprintf("dependency %s %s not found", e.Dependency.Name, e.Dependency.Version)
+}
+
+// Suggestions describes what the user could use instead, one per line.
+func (e *DependencyResolutionError) Suggestions() []string {
+	var suggestions []string
+	for _, v := range e.NearestVersions {
+		suggestions = append(suggestions, fmt.Sprintf("use %s %s instead", e.Dependency.Name, v))
+	}
+	for _, s := range e.OtherStacks {
+		suggestions = append(suggestions, fmt.Sprintf("push with stack %s, which provides %s %s", s, e.Dependency.Name, e.Dependency.Version))
+	}
+	return suggestions
+}
+
+// nearestVersions orders versions by how far they are from target, comparing
+// major, then minor, then patch distance, and returns at most max of them.
+// Versions that are not semver sort after those that are.
+func nearestVersions(target string, versions []string, max int) []string {
+	t, terr := semver.NewVersion(target)
+
+	distance := func(v string) [3]int64 {
+		sv, err := semver.NewVersion(v)
+		if terr != nil || err != nil {
+			return [3]int64{math.MaxInt64}
+		}
+		return [3]int64{abs(sv.Major() - t.Major()), abs(sv.Minor() - t.Minor()), abs(sv.Patch() - t.Patch())}
+	}
+
+	sorted := append([]string{}, versions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		di, dj := distance(sorted[i]), distance(sorted[j])
+		for k := range di {
+			if di[k] != dj[k] {
+				return di[k] < dj[k]
+			}
+		}
+		return false
+	})
+
+	if len(sorted) > max {
+		sorted = sorted[:max]
+	}
+	return sorted
+}
+
+func abs(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,124 @@
+package libbuildpack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DependencySignature is a detached signature of a dependency's archive.
+// Type is "gpg" or "cosign". The signature is downloaded from URI, or read
+// from File relative to the manifest for cached buildpacks.
+type DependencySignature struct {
+	Type string `yaml:"type"`
+	URI  string `yaml:"uri"`
+	File string `yaml:"file"`
+}
+
+// SignatureVerifier checks signatureFile, a detached signature of the given
+// type, against file.
+type SignatureVerifier interface {
+	Verify(ctx context.Context, sigType, file, signatureFile string) error
+}
+
+// SignatureOptions configures signature verification of dependencies.
+// GPGKeyring and CosignKey hold the trusted public keys used by the default
+// verifier, which runs the gpg and cosign binaries. With Required,
+// dependencies without a signature are refused.
+type SignatureOptions struct {
+	GPGKeyring string
+	CosignKey  string
+	Required   bool
+	Verifier   SignatureVerifier
+}
+
+// SetSignatureVerification makes InstallDependency verify the signature of
+// each dependency after downloading it and before extracting it.
+func (i *Installer) SetSignatureVerification(opts SignatureOptions) {
+	if opts.Verifier == nil {
+		opts.Verifier = &commandSignatureVerifier{gpgKeyring: opts.GPGKeyring, cosignKey: opts.CosignKey}
+	}
+	i.signatureOptions = &opts
+}
+
+func (i *Installer) verifySignature(ctx context.Context, entry *ManifestEntry, file string) error {
+	opts := i.signatureOptions
+	if opts == nil {
+		return nil
+	}
+
+	dep := entry.Dependency
+	if entry.Signature == nil {
+		if opts.Required {
+			return fmt.Errorf("dependency %s %s has no signature", dep.Name, dep.Version)
+		}
+		i.manifest.log.Warning("Dependency %s %s has no signature, skipping verification", dep.Name, dep.Version)
+		return nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "signature")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	signatureFile := filepath.Join(tmpDir, "signature")
+	if entry.Signature.File != "" {
+		err = CopyFile(filepath.Join(i.manifest.manifestRootDir, entry.Signature.File), signatureFile)
+	} else if entry.Signature.URI != "" {
+		err = downloadFile(ctx, entry.Signature.URI, signatureFile, i.downloadOptions, i.manifest.log)
+	} else {
+		err = fmt.Errorf("no uri or file")
+	}
+	if err != nil {
+		return fmt.Errorf("could not get signature of %s %s: %v", dep.Name, dep.Version, err)
+	}
+
+	i.manifest.log.Debug("Verifying %s signature of %s %s", entry.Signature.Type, dep.Name, dep.Version)
+	if err := opts.Verifier.Verify(ctx, entry.Signature.Type, file, signatureFile); err != nil {
+		i.manifest.log.Error("Signature verification failed for %s %s", dep.Name, dep.Version)
+		return fmt.Errorf("signature verification failed for %s %s: %v", dep.Name, dep.Version, err)
+	}
+	i.manifest.log.Info("Verified %s signature of %s %s", entry.Signature.Type, dep.Name, dep.Version)
+	return nil
+}
+
+type commandSignatureVerifier struct {
+	gpgKeyring string
+	cosignKey  string
+}
+
+func (v *commandSignatureVerifier) Verify(ctx context.Context, sigType, file, signatureFile string) error {
+	var program string
+	var args []string
+	switch strings.ToLower(sigType) {
+	case "gpg":
+		if v.gpgKeyring == "" {
+			return fmt.Errorf("no gpg keyring configured")
+		}
+		program = "gpg"
+		args = []string{"--batch", "--no-default-keyring", "--keyring", v.gpgKeyring, "--verify", signatureFile, file}
+	case "cosign":
+		if v.cosignKey == "" {
+			return fmt.Errorf("no cosign key configured")
+		}
+		program = "cosign"
+		args = []string{"verify-blob", "--key", v.cosignKey, "--signature", signatureFile, file}
+	default:
+		return fmt.Errorf("unsupported signature type %q", sigType)
+	}
+
+	output := new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, program, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", program, err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
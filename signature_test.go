@@ -0,0 +1,128 @@
+package libbuildpack_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry/libbuildpack"
+	"github.com/cloudfoundry/libbuildpack/ansicleaner"
+	httpmock "github.com/jarcoal/httpmock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeSignatureVerifier struct {
+	sigType   string
+	signature []byte
+	err       error
+}
+
+func (f *fakeSignatureVerifier) Verify(ctx context.Context, sigType, file, signatureFile string) error {
+	f.sigType = sigType
+	f.signature, _ = ioutil.ReadFile(signatureFile)
+	return f.err
+}
+
+var _ = Describe("Signature verification", func() {
+	var (
+		installer *libbuildpack.Installer
+		verifier  *fakeSignatureVerifier
+		outputDir string
+		buffer    *bytes.Buffer
+		oldStack  string
+		err       error
+	)
+
+	BeforeEach(func() {
+		oldStack = os.Getenv("CF_STACK")
+		os.Setenv("CF_STACK", "cflinuxfs2")
+		httpmock.Reset()
+
+		buffer = new(bytes.Buffer)
+		manifest, err := libbuildpack.NewManifest("fixtures/manifest/signed", libbuildpack.NewLogger(ansicleaner.New(buffer)), time.Now())
+		Expect(err).To(BeNil())
+		installer = libbuildpack.NewInstaller(manifest)
+		verifier = &fakeSignatureVerifier{}
+
+		outputDir, err = ioutil.TempDir("", "signed")
+		Expect(err).To(BeNil())
+
+		tgzContents, err := ioutil.ReadFile("fixtures/thing.tgz")
+		Expect(err).To(BeNil())
+		httpmock.RegisterResponder("GET", "https://example.com/dependencies/real_tar_file-3-linux-x64.tgz",
+			httpmock.NewBytesResponder(200, tgzContents))
+		httpmock.RegisterResponder("GET", "https://example.com/dependencies/real_tar_file-3-linux-x64.tgz.asc",
+			httpmock.NewStringResponder(200, "signature"))
+		zipContents, err := ioutil.ReadFile("fixtures/thing.zip")
+		Expect(err).To(BeNil())
+		httpmock.RegisterResponder("GET", "https://example.com/dependencies/real_zip_file-3-linux-x64.zip",
+			httpmock.NewBytesResponder(200, zipContents))
+	})
+
+	AfterEach(func() {
+		os.Setenv("CF_STACK", oldStack)
+		Expect(os.RemoveAll(outputDir)).To(Succeed())
+	})
+
+	It("does not fetch signatures unless enabled", func() {
+		err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
+		Expect(err).To(BeNil())
+		Expect(httpmock.GetTotalCallCount()).To(Equal(1))
+	})
+
+	Context("when enabled", func() {
+		BeforeEach(func() {
+			installer.SetSignatureVerification(libbuildpack.SignatureOptions{Verifier: verifier})
+		})
+
+		It("verifies the downloaded signature before extracting", func() {
+			err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
+			Expect(err).To(BeNil())
+
+			Expect(verifier.sigType).To(Equal("gpg"))
+			Expect(string(verifier.signature)).To(Equal("signature"))
+			Expect(filepath.Join(outputDir, "root.txt")).To(BeAnExistingFile())
+			Expect(buffer.String()).To(ContainSubstring("Verified gpg signature of real_tar_file 3"))
+		})
+
+		It("fails without extracting when the signature does not verify", func() {
+			verifier.err = errors.New("BAD signature")
+
+			err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
+			Expect(err).To(MatchError(ContainSubstring("signature verification failed for real_tar_file 3: BAD signature")))
+			Expect(filepath.Join(outputDir, "root.txt")).ToNot(BeAnExistingFile())
+		})
+
+		It("warns about dependencies without a signature", func() {
+			err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_zip_file", Version: "3"}, outputDir)
+			Expect(err).To(BeNil())
+			Expect(buffer.String()).To(ContainSubstring("Dependency real_zip_file 3 has no signature"))
+		})
+	})
+
+	Context("when signatures are required", func() {
+		BeforeEach(func() {
+			installer.SetSignatureVerification(libbuildpack.SignatureOptions{Verifier: verifier, Required: true})
+		})
+
+		It("refuses dependencies without a signature", func() {
+			err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_zip_file", Version: "3"}, outputDir)
+			Expect(err).To(MatchError("dependency real_zip_file 3 has no signature"))
+		})
+	})
+
+	Context("with the default verifier", func() {
+		It("needs a keyring for gpg signatures", func() {
+			installer.SetSignatureVerification(libbuildpack.SignatureOptions{CosignKey: "cosign.pub"})
+
+			err = installer.InstallDependency(libbuildpack.Dependency{Name: "real_tar_file", Version: "3"}, outputDir)
+			Expect(err).To(MatchError(ContainSubstring("no gpg keyring configured")))
+		})
+	})
+})
@@ -32,7 +32,7 @@ func Dir(dir string, logger Logger) *DirSnapshot {
 		command:      libbuildpack.Command{},
 	}
 
-	if os.Getenv("BP_DEBUG") != "" {
+	if libbuildpack.LogLevelFromEnv() == libbuildpack.LogLevelDebug {
 		if paths, checksum, err := dirSnapshot.calcChecksum(); err == nil {
 			logger.Debug("Initial dir checksum %s", checksum)
 			dirSnapshot.initialPaths = paths
@@ -6,7 +6,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const SENTINEL = "sentinel"
@@ -148,8 +151,122 @@ func (s *Stager) CheckBuildpackValid() error {
 	return nil
 }
 
+// StagingComplete records the buildpack in the cache and logs how long each
+// step of staging took.
 func (s *Stager) StagingComplete() {
 	s.manifest.StoreBuildpackMetadata(s.cacheDir)
+	s.log.StepSummary()
+}
+
+// DepDirInUseError reports that another process holds the dep dir a stager
+// wanted to write.
+type DepDirInUseError struct {
+	DepDir string
+	Owner  string
+}
+
+func (e *DepDirInUseError) Error() string {
+	owner := e.Owner
+	if owner == "" {
+		owner = "an unknown process"
+	}
+	return fmt.Sprintf("%s is already being written by %s; two buildpacks share deps index %s, check the buildpack order of the app and the platform's multi-buildpack setup",
+		e.DepDir, owner, filepath.Base(e.DepDir))
+}
+
+// LockDepDir claims the dep dir for this process, so that a second process
+// writing the same deps index fails fast with a *DepDirInUseError naming the
+// owner, instead of the two corrupting each other's files. The lock and a
+// file describing its owner are kept next to the dep dir, where the
+// buildpacks of other indexes and ClearDepDir leave them alone. The operating
+// system drops the lock when its holder dies, on Windows as well as Unix, so
+// the files left behind by a crashed staging are taken over rather than
+// blocking the next one. Calling the returned function releases the claim.
+func (s *Stager) LockDepDir() (func(), error) {
+	lockPath := filepath.Join(s.depsDir, "."+s.depsIdx+".lock")
+	ownerPath := filepath.Join(s.depsDir, "."+s.depsIdx+".owner")
+
+	unlock, locked, err := tryLockFile(lockPath)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		owner, _ := ioutil.ReadFile(ownerPath)
+		return nil, &DepDirInUseError{DepDir: s.DepDir(), Owner: strings.TrimSpace(string(owner))}
+	}
+
+	if previous, err := ioutil.ReadFile(ownerPath); err == nil {
+		s.log.Debug("Taking over %s from %s, which did not release it", s.DepDir(), strings.TrimSpace(string(previous)))
+	}
+	if err := ioutil.WriteFile(ownerPath, []byte(s.depDirOwner()+"\n"), 0644); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return func() {
+		os.Remove(ownerPath)
+		unlock()
+	}, nil
+}
+
+// depDirOwner describes this process for a DepDirInUseError in another one.
+func (s *Stager) depDirOwner() string {
+	hostname, _ := os.Hostname()
+	version, _ := s.manifest.Version()
+	return fmt.Sprintf("pid %d on %s (%s buildpack %s) since %s",
+		os.Getpid(), hostname, s.manifest.Language(), version, time.Now().UTC().Format(time.RFC3339))
+}
+
+// RegisterSensitiveFile adds path, a file or directory holding secrets such
+// as a .netrc or an auth token written for the build, to the files
+// ScrubSensitiveFiles removes at the end of finalize so they never reach the
+// droplet. A relative path is taken to be in the build dir. The list is kept
+// next to the dep dir, so paths registered during supply are scrubbed by the
+// finalize of the last buildpack.
+func (s *Stager) RegisterSensitiveFile(path string) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.buildDir, path)
+	}
+
+	fh, err := os.OpenFile(filepath.Join(s.depsDir, "."+s.depsIdx+".scrub"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	_, err = fmt.Fprintln(fh, filepath.Clean(path))
+	return err
+}
+
+// ScrubSensitiveFiles removes every file registered with
+// RegisterSensitiveFile by any buildpack of the app, along with the lists of
+// them. It is called once finalize is done.
+func (s *Stager) ScrubSensitiveFiles() error {
+	lists, err := filepath.Glob(filepath.Join(s.depsDir, ".*.scrub"))
+	if err != nil {
+		return err
+	}
+
+	for _, list := range lists {
+		data, err := ioutil.ReadFile(list)
+		if err != nil {
+			return err
+		}
+		for _, path := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if path == "" {
+				continue
+			}
+			s.log.Debug("Removing sensitive file %s", path)
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("could not remove sensitive file %s: %v", path, err)
+			}
+		}
+		if err := os.Remove(list); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *Stager) ClearCache() error {
@@ -188,6 +305,12 @@ func (s *Stager) ClearDepDir() error {
 	return nil
 }
 
+// WriteProfileD writes scriptName to the dep dir's profile.d. At launch the
+// scripts of every buildpack are sourced in one sequence: first the
+// multi-supply script that sets PATH and friends, then each buildpack's
+// scripts in deps index order, and within a buildpack in lexical order of
+// their names. Use AppendProfileD to have a script run after the ones this
+// buildpack has already written.
 func (s *Stager) WriteProfileD(scriptName, scriptContents string) error {
 	profileDir := filepath.Join(s.DepDir(), "profile.d")
 
@@ -199,6 +322,67 @@ func (s *Stager) WriteProfileD(scriptName, scriptContents string) error {
 	return writeToFile(strings.NewReader(scriptContents), filepath.Join(profileDir, scriptName), 0755)
 }
 
+// AppendProfileD writes scriptName to the dep dir's profile.d prefixed with
+// the next free sequence number, e.g. 03_scriptName, so that it runs after
+// every numbered script already there. It returns the name it assigned.
+func (s *Stager) AppendProfileD(scriptName, scriptContents string) (string, error) {
+	next := 0
+	files, err := ioutil.ReadDir(filepath.Join(s.DepDir(), "profile.d"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, file := range files {
+		if parts := strings.SplitN(file.Name(), "_", 2); len(parts) == 2 {
+			if n, err := strconv.Atoi(parts[0]); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+	}
+
+	name := fmt.Sprintf("%02d_%s", next, scriptName)
+	return name, s.WriteProfileD(name, scriptContents)
+}
+
+// ReleaseStepFile is where WriteProcessTypes leaves the release metadata for
+// bin/release, which runs after finalize with only the build dir, to print.
+func (s *Stager) ReleaseStepFile() string {
+	return filepath.Join(s.buildDir, "tmp", s.manifest.Language()+"-buildpack-release-step.yml")
+}
+
+// WriteProcessTypes writes the default_process_types that bin/release
+// reports, mapping process types such as "web" to their start commands, to
+// ReleaseStepFile. A bin/release of
+//
+//	cat "$1/tmp/LANGUAGE-buildpack-release-step.yml"
+//
+// prints them.
+func (s *Stager) WriteProcessTypes(processTypes map[string]string) error {
+	release := map[string]map[string]string{"default_process_types": processTypes}
+	return NewYAML().Write(s.ReleaseStepFile(), release)
+}
+
+// WriteProcfile writes processTypes to a Procfile in the build dir, in order
+// of process type, unless the app has its own Procfile. It reports whether
+// it wrote one.
+func (s *Stager) WriteProcfile(processTypes map[string]string) (bool, error) {
+	procfile := filepath.Join(s.buildDir, "Procfile")
+	if exists, err := FileExists(procfile); err != nil || exists {
+		return false, err
+	}
+
+	var names []string
+	for name := range processTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var contents strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&contents, "%s: %s\n", name, processTypes[name])
+	}
+	return true, writeToFile(strings.NewReader(contents.String()), procfile, 0644)
+}
+
 func (s *Stager) BuildDir() string {
 	return s.buildDir
 }
@@ -285,13 +469,20 @@ func (s *Stager) SetLaunchEnvironment() error {
 		return err
 	}
 
+	width := 2
+	for _, dir := range profileDirs {
+		if n := len(filepath.Base(filepath.Dir(dir))); n > width {
+			width = n
+		}
+	}
+
 	for _, dir := range profileDirs {
 		sections := strings.Split(dir, string(filepath.Separator))
 		if len(sections) < 2 {
 			return errors.New("invalid dep dir")
 		}
 
-		depsIdx := sections[len(sections)-2]
+		depsIdx := padDepsIdx(sections[len(sections)-2], width)
 
 		files, err := ioutil.ReadDir(dir)
 		if err != nil {
@@ -321,6 +512,16 @@ func (s *Stager) BuildpackVersion() (string, error) {
 	return s.manifest.Version()
 }
 
+// padDepsIdx zero pads a numeric deps index to width so that the profile.d
+// scripts of buildpack 10 sort after those of buildpack 2.
+func padDepsIdx(depsIdx string, width int) string {
+	n, err := strconv.Atoi(depsIdx)
+	if err != nil {
+		return depsIdx
+	}
+	return fmt.Sprintf("%0*d", width, n)
+}
+
 func existingDepsDirs(depsDir, subDir, prefix string) ([]string, error) {
 	files, err := ioutil.ReadDir(depsDir)
 	if err != nil {
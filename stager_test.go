@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"time"
@@ -246,6 +247,127 @@ var _ = Describe("Stager", func() {
 		})
 	})
 
+	Describe("LockDepDir", func() {
+		It("records the owner next to the dep dir until released", func() {
+			unlock, err := s.LockDepDir()
+			Expect(err).To(BeNil())
+
+			owner, err := ioutil.ReadFile(filepath.Join(depsDir, ".0.owner"))
+			Expect(err).To(BeNil())
+			Expect(string(owner)).To(HavePrefix(fmt.Sprintf("pid %d on ", os.Getpid())))
+			Expect(string(owner)).To(ContainSubstring("(dotnet-core buildpack "))
+
+			Expect(s.ClearDepDir()).To(Succeed())
+			Expect(filepath.Join(depsDir, ".0.owner")).To(BeAnExistingFile())
+
+			unlock()
+			Expect(filepath.Join(depsDir, ".0.owner")).ToNot(BeAnExistingFile())
+		})
+
+		It("fails fast when another stager holds the same deps index", func() {
+			unlock, err := s.LockDepDir()
+			Expect(err).To(BeNil())
+			defer unlock()
+
+			other := libbuildpack.NewStager([]string{buildDir, cacheDir, depsDir, depsIdx, profileDir}, logger, manifest)
+			_, err = other.LockDepDir()
+			Expect(err).To(BeAssignableToTypeOf(&libbuildpack.DepDirInUseError{}))
+			Expect(err.(*libbuildpack.DepDirInUseError).Owner).To(HavePrefix(fmt.Sprintf("pid %d on ", os.Getpid())))
+			Expect(err.Error()).To(ContainSubstring(filepath.Join(depsDir, "0") + " is already being written by pid "))
+		})
+
+		It("takes over the files left behind by a stager that died holding the dep dir", func() {
+			Expect(ioutil.WriteFile(filepath.Join(depsDir, ".0.lock"), nil, 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(depsDir, ".0.owner"), []byte("pid 1 on crashed\n"), 0644)).To(Succeed())
+
+			unlock, err := s.LockDepDir()
+			Expect(err).To(BeNil())
+			defer unlock()
+
+			owner, err := ioutil.ReadFile(filepath.Join(depsDir, ".0.owner"))
+			Expect(err).To(BeNil())
+			Expect(string(owner)).To(HavePrefix(fmt.Sprintf("pid %d on ", os.Getpid())))
+		})
+
+		It("lets other deps indexes and later stagers claim their dirs", func() {
+			unlock, err := s.LockDepDir()
+			Expect(err).To(BeNil())
+
+			other := libbuildpack.NewStager([]string{buildDir, cacheDir, depsDir, "1", profileDir}, logger, manifest)
+			unlockOther, err := other.LockDepDir()
+			Expect(err).To(BeNil())
+			unlockOther()
+
+			unlock()
+			again := libbuildpack.NewStager([]string{buildDir, cacheDir, depsDir, depsIdx, profileDir}, logger, manifest)
+			unlock, err = again.LockDepDir()
+			Expect(err).To(BeNil())
+			unlock()
+		})
+	})
+
+	Describe("ScrubSensitiveFiles", func() {
+		It("removes the files every buildpack registered", func() {
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, ".netrc"), []byte("machine example.com"), 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(depsDir, "1", "auth"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(depsDir, "1", "auth", "token"), []byte("s3cr3t"), 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "keep.txt"), []byte("keep"), 0644)).To(Succeed())
+
+			Expect(s.RegisterSensitiveFile(".netrc")).To(Succeed())
+			supplier := libbuildpack.NewStager([]string{buildDir, cacheDir, depsDir, "1", profileDir}, logger, manifest)
+			Expect(supplier.RegisterSensitiveFile(filepath.Join(depsDir, "1", "auth"))).To(Succeed())
+			Expect(supplier.RegisterSensitiveFile(filepath.Join(buildDir, "never-written"))).To(Succeed())
+
+			Expect(s.ScrubSensitiveFiles()).To(Succeed())
+			Expect(filepath.Join(buildDir, ".netrc")).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(depsDir, "1", "auth")).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(buildDir, "keep.txt")).To(BeAnExistingFile())
+			Expect(filepath.Join(depsDir, ".0.scrub")).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(depsDir, ".1.scrub")).ToNot(BeAnExistingFile())
+		})
+
+		It("succeeds when nothing was registered", func() {
+			Expect(s.ScrubSensitiveFiles()).To(Succeed())
+		})
+	})
+
+	Describe("WriteProcessTypes", func() {
+		It("writes default_process_types for bin/release", func() {
+			Expect(s.ReleaseStepFile()).To(Equal(filepath.Join(buildDir, "tmp", "dotnet-core-buildpack-release-step.yml")))
+			Expect(s.WriteProcessTypes(map[string]string{"web": "dotnet app.dll --port $PORT", "worker": "dotnet worker.dll"})).To(Succeed())
+
+			var release map[string]map[string]string
+			Expect(libbuildpack.NewYAML().Load(s.ReleaseStepFile(), &release)).To(Succeed())
+			Expect(release).To(Equal(map[string]map[string]string{
+				"default_process_types": {"web": "dotnet app.dll --port $PORT", "worker": "dotnet worker.dll"},
+			}))
+		})
+	})
+
+	Describe("WriteProcfile", func() {
+		It("writes the process types in order", func() {
+			wrote, err := s.WriteProcfile(map[string]string{"worker": "dotnet worker.dll", "web": "dotnet app.dll"})
+			Expect(err).To(BeNil())
+			Expect(wrote).To(BeTrue())
+
+			contents, err := ioutil.ReadFile(filepath.Join(buildDir, "Procfile"))
+			Expect(err).To(BeNil())
+			Expect(string(contents)).To(Equal("web: dotnet app.dll\nworker: dotnet worker.dll\n"))
+		})
+
+		It("leaves the app's own Procfile alone", func() {
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "Procfile"), []byte("web: ./custom\n"), 0644)).To(Succeed())
+
+			wrote, err := s.WriteProcfile(map[string]string{"web": "dotnet app.dll"})
+			Expect(err).To(BeNil())
+			Expect(wrote).To(BeFalse())
+
+			contents, err := ioutil.ReadFile(filepath.Join(buildDir, "Procfile"))
+			Expect(err).To(BeNil())
+			Expect(string(contents)).To(Equal("web: ./custom\n"))
+		})
+	})
+
 	Describe("WriteEnvFile", func() {
 		It("creates a file in the <depDir>/env directory", func() {
 			err := s.WriteEnvFile("ENVVAR", "value")
@@ -347,6 +469,25 @@ var _ = Describe("Stager", func() {
 		})
 	})
 
+	Describe("AppendProfileD", func() {
+		It("numbers scripts so they run in the order they were appended", func() {
+			name, err := s.AppendProfileD("first.sh", "first")
+			Expect(err).To(BeNil())
+			Expect(name).To(Equal("00_first.sh"))
+
+			Expect(s.WriteProfileD("04_manual.sh", "manual")).To(Succeed())
+			Expect(s.WriteProfileD("unnumbered.sh", "unnumbered")).To(Succeed())
+
+			name, err = s.AppendProfileD("second.sh", "second")
+			Expect(err).To(BeNil())
+			Expect(name).To(Equal("05_second.sh"))
+
+			contents, err := ioutil.ReadFile(filepath.Join(s.DepDir(), "profile.d", "05_second.sh"))
+			Expect(err).To(BeNil())
+			Expect(string(contents)).To(Equal("second"))
+		})
+	})
+
 	Describe("WriteProfileD", func() {
 		var (
 			info           os.FileInfo
@@ -416,6 +557,39 @@ var _ = Describe("Stager", func() {
 		})
 	})
 
+	Describe("WriteLaunchConfigTemplate", func() {
+		var destDir string
+
+		BeforeEach(func() {
+			if runtime.GOOS == "windows" {
+				Skip("launch config templates are rendered by bash")
+			}
+
+			destDir, err = ioutil.TempDir("", "launch config")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(destDir)).To(Succeed())
+		})
+
+		It("writes a profile.d script that renders the template from the runtime environment", func() {
+			dest := filepath.Join(destDir, "it's", "config.json")
+			Expect(s.WriteLaunchConfigTemplate("config.sh", dest, `{"port": {{PORT}}, "services": {{VCAP_SERVICES}}, "keep": "{{UNLISTED}}"}`, []string{"PORT", "VCAP_SERVICES"})).To(Succeed())
+
+			cmd := exec.Command("bash", "-c", "source "+filepath.Join(s.DepDir(), "profile.d", "config.sh"))
+			cmd.Env = append(os.Environ(), "DEPS_DIR="+depsDir, "PORT=8080", `VCAP_SERVICES={"p": "$HOME & \"quoted\" 'single' \\ $(whoami) `+"`id`"+`"}`)
+			output, err := cmd.CombinedOutput()
+			Expect(err).To(BeNil(), string(output))
+
+			Expect(ioutil.ReadFile(dest)).To(Equal([]byte(`{"port": 8080, "services": {"p": "$HOME & \"quoted\" 'single' \\ $(whoami) ` + "`id`" + `"}, "keep": "{{UNLISTED}}"}` + "\n")))
+		})
+
+		It("rejects invalid variable names", func() {
+			Expect(s.WriteLaunchConfigTemplate("config.sh", "config.json", "{{PORT}}", []string{"PORT; rm -rf /"})).To(MatchError(ContainSubstring("invalid environment variable name")))
+		})
+	})
+
 	Describe("Supply Environment", func() {
 		BeforeEach(func() {
 			err = os.MkdirAll(filepath.Join(depsDir, "00", "bin"), 0755)
@@ -656,6 +830,28 @@ var _ = Describe("Stager", func() {
 
 				Expect(string(contents)).To(Equal("second"))
 			})
+
+			It("names the copied scripts so they sort in deps index order", func() {
+				for _, idx := range []string{"2", "10"} {
+					Expect(os.MkdirAll(filepath.Join(depsDir, idx, "profile.d"), 0755)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(depsDir, idx, "profile.d", "supplied-script.sh"), []byte(idx), 0644)).To(Succeed())
+				}
+
+				err = s.SetLaunchEnvironment()
+				Expect(err).To(BeNil())
+
+				files, err := ioutil.ReadDir(profileDir)
+				Expect(err).To(BeNil())
+				var names []string
+				for _, file := range files {
+					names = append(names, file.Name())
+				}
+				multiSupply := "000_multi-supply.sh"
+				if runtime.GOOS == "windows" {
+					multiSupply = "000_multi-supply.bat"
+				}
+				Expect(names).To(Equal([]string{multiSupply, "00_supplied-script.sh", "01_supplied-script.sh", "02_supplied-script.sh", "10_supplied-script.sh"}))
+			})
 		})
 	})
 
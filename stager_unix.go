@@ -3,6 +3,8 @@
 package libbuildpack
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 )
@@ -42,3 +44,39 @@ func (s *Stager) AddBinDependencyLink(destPath, sourceName string) error {
 
 	return os.Symlink(relPath, filepath.Join(binDir, sourceName))
 }
+
+// WriteLaunchConfigTemplate writes a profile.d script that renders template to
+// destPath each time the app starts. Every {{NAME}} placeholder for a NAME in
+// vars is replaced with the value of that environment variable at launch, so
+// values such as $PORT or $VCAP_SERVICES are never interpreted by the shell.
+func (s *Stager) WriteLaunchConfigTemplate(scriptName, destPath, template string, vars []string) error {
+	templateDir := filepath.Join(s.DepDir(), "launch_templates")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		return err
+	}
+
+	templateName := scriptName + ".tmpl"
+	if err := ioutil.WriteFile(filepath.Join(templateDir, templateName), []byte(template), 0644); err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf("__bp_tmpl=$(cat \"$DEPS_DIR\"/%s)\n", shellQuote(filepath.Join(s.DepsIdx(), "launch_templates", templateName)))
+	for _, v := range vars {
+		if !envVarName.MatchString(v) {
+			return fmt.Errorf("invalid environment variable name %q in launch template %s", v, scriptName)
+		}
+		script += fmt.Sprintf("__bp_tmpl=${__bp_tmpl//%s/\"${%s:-}\"}\n", shellQuote("{{"+v+"}}"), v)
+	}
+	script += fmt.Sprintf("mkdir -p \"$(dirname %[1]s)\"\nprintf '%%s\\n' \"$__bp_tmpl\" > %[1]s\nunset __bp_tmpl\n", shellQuote(destPath))
+
+	return s.WriteProfileD(scriptName, script)
+}
+
+// Write adds the script to the dep dir's profile.d with AppendProfileD and
+// returns the name it was given.
+func (p *ProfileD) Write() (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.stager.AppendProfileD(p.name, p.String())
+}
@@ -3,6 +3,7 @@
 package libbuildpack
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 )
@@ -31,3 +32,11 @@ func (s *Stager) AddBinDependencyLink(destPath, sourceName string) error {
 
 	return os.Link(destPath, filepath.Join(binDir, sourceName))
 }
+
+func (s *Stager) WriteLaunchConfigTemplate(scriptName, destPath, template string, vars []string) error {
+	return errors.New("launch config templates are not supported on windows")
+}
+
+func (p *ProfileD) Write() (string, error) {
+	return "", errors.New("profile.d scripts built with ProfileD are not supported on windows")
+}
@@ -0,0 +1,62 @@
+package libbuildpack
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TOML reads and writes TOML files such as buildpack.toml, order.toml and
+// layer metadata.
+type TOML struct {
+	// InterpolateEnv expands ${VAR} references when loading, see InterpolateEnv.
+	// Values are escaped so they can be used inside TOML strings.
+	InterpolateEnv bool
+	// Strict makes Load fail on keys that obj has no field for, to catch
+	// misspelt keys.
+	Strict bool
+}
+
+func NewTOML() *TOML {
+	return &TOML{}
+}
+
+func (t *TOML) Load(file string, obj interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	if t.InterpolateEnv {
+		if data, err = interpolateEnv(data, escapeJSONString); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	metadata, err := toml.Decode(string(removeBOM(data)), obj)
+	if err != nil {
+		return err
+	}
+
+	if undecoded := metadata.Undecoded(); t.Strict && len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("%s: unknown keys: %s", file, strings.Join(keys, ", "))
+	}
+
+	return nil
+}
+
+func (t *TOML) Write(dest string, obj interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(obj); err != nil {
+		return err
+	}
+
+	return writeToFile(buf, dest, 0666)
+}
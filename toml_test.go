@@ -0,0 +1,93 @@
+package libbuildpack_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TOML", func() {
+	type buildpack struct {
+		ID      string `toml:"id"`
+		Version string `toml:"version"`
+	}
+	type buildpackTOML struct {
+		API       string    `toml:"api"`
+		Buildpack buildpack `toml:"buildpack"`
+	}
+
+	var (
+		t      *libbuildpack.TOML
+		tmpDir string
+		err    error
+	)
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "toml")
+		Expect(err).To(BeNil())
+
+		t = libbuildpack.NewTOML()
+	})
+
+	AfterEach(func() {
+		err = os.RemoveAll(tmpDir)
+		Expect(err).To(BeNil())
+	})
+
+	Describe("Load", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "buildpack.toml"), []byte(`api = "0.2"
+
+[buildpack]
+  id = "org.cloudfoundry.node"
+  version = "${BP_TEST_VERSION}"
+  name = "Node Engine"
+`), 0666)).To(Succeed())
+		})
+
+		It("ignores unknown keys", func() {
+			var obj buildpackTOML
+			Expect(t.Load(filepath.Join(tmpDir, "buildpack.toml"), &obj)).To(Succeed())
+			Expect(obj).To(Equal(buildpackTOML{API: "0.2", Buildpack: buildpack{ID: "org.cloudfoundry.node", Version: "${BP_TEST_VERSION}"}}))
+		})
+
+		It("fails on unknown keys in strict mode", func() {
+			t.Strict = true
+			var obj buildpackTOML
+			err := t.Load(filepath.Join(tmpDir, "buildpack.toml"), &obj)
+			Expect(err).To(MatchError(filepath.Join(tmpDir, "buildpack.toml") + ": unknown keys: buildpack.name"))
+		})
+
+		It("expands environment variables", func() {
+			os.Setenv("BP_TEST_VERSION", `1.2.3"`)
+			defer os.Unsetenv("BP_TEST_VERSION")
+			t.InterpolateEnv = true
+
+			var obj buildpackTOML
+			Expect(t.Load(filepath.Join(tmpDir, "buildpack.toml"), &obj)).To(Succeed())
+			Expect(obj.Buildpack.Version).To(Equal(`1.2.3"`))
+		})
+
+		It("returns an error for invalid toml", func() {
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "invalid.toml"), []byte("api = "), 0666)).To(Succeed())
+			var obj buildpackTOML
+			Expect(t.Load(filepath.Join(tmpDir, "invalid.toml"), &obj)).ToNot(Succeed())
+		})
+	})
+
+	Describe("Write", func() {
+		It("writes toml that loads back", func() {
+			written := buildpackTOML{API: "0.2", Buildpack: buildpack{ID: "org.cloudfoundry.node", Version: "1.2.3"}}
+			Expect(t.Write(filepath.Join(tmpDir, "subdir", "buildpack.toml"), written)).To(Succeed())
+
+			var loaded buildpackTOML
+			t.Strict = true
+			Expect(t.Load(filepath.Join(tmpDir, "subdir", "buildpack.toml"), &loaded)).To(Succeed())
+			Expect(loaded).To(Equal(written))
+		})
+	})
+})
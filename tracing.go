@@ -0,0 +1,313 @@
+package libbuildpack
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer emits OpenTelemetry spans over OTLP/HTTP (JSON encoding) so slow
+// stagings show up as distributed traces. The collector is taken from
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or OTEL_EXPORTER_OTLP_ENDPOINT with
+// /v1/traces appended, and OTEL_EXPORTER_OTLP_HEADERS ("key=value,...") is
+// sent with every export. A W3C TRACEPARENT in the environment becomes the
+// parent of spans started without one, which joins supply and finalize into
+// the platform's trace. StartPhase opens the root span of a staging phase:
+// spans started without a parent while it is open, by any Tracer, become its
+// children and are exported in one batch when it ends. With no endpoint set
+// every call is a no-op. Like Metrics, failures to export are ignored.
+type Tracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	parent   spanContext
+	client   *http.Client
+}
+
+type spanContext struct {
+	traceID string
+	spanID  string
+}
+
+type Span struct {
+	tracer     *Tracer
+	name       string
+	ctx        spanContext
+	parentID   string
+	start      time.Time
+	attributes map[string]string
+}
+
+type spanKey struct{}
+
+var (
+	phaseMu      sync.Mutex
+	currentPhase *Span
+	pendingSpans []pendingSpan
+)
+
+type pendingSpan struct {
+	tracer *Tracer
+	span   otlpSpan
+}
+
+func NewTracer() *Tracer {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		endpoint = strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/") + "/v1/traces"
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "libbuildpack"
+	}
+
+	headers := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+
+	return &Tracer{
+		endpoint: endpoint,
+		headers:  headers,
+		service:  service,
+		parent:   parseTraceparent(os.Getenv("TRACEPARENT")),
+		client:   &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func (t *Tracer) Enabled() bool {
+	return t != nil && t.endpoint != ""
+}
+
+// Start begins a span named name as a child of the span in ctx, e.g.
+//
+//	ctx, span := tracer.Start(ctx, "supply", nil)
+//	defer func() { span.End(err) }()
+//
+// The returned context carries the new span for nested calls. Start on a
+// disabled Tracer returns ctx unchanged and a nil *Span, which is safe to use.
+func (t *Tracer) Start(ctx context.Context, name string, attributes map[string]string) (context.Context, *Span) {
+	if !t.Enabled() {
+		return ctx, nil
+	}
+
+	parent := t.parent
+	if s, ok := ctx.Value(spanKey{}).(*Span); ok && s != nil {
+		parent = s.ctx
+	} else if phase := openPhase(); phase != nil {
+		parent = phase.ctx
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		ctx:        spanContext{traceID: parent.traceID, spanID: randomHex(8)},
+		parentID:   parent.spanID,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+	if span.ctx.traceID == "" {
+		span.ctx.traceID = randomHex(16)
+	}
+	for k, v := range attributes {
+		span.attributes[k] = v
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartPhase begins the root span of the staging phase name, such as
+// "supply" or "finalize", e.g.
+//
+//	phase := tracer.StartPhase("supply", nil)
+//	defer func() { phase.End(err) }()
+//
+// Ending it exports it along with the spans started during the phase.
+func (t *Tracer) StartPhase(name string, attributes map[string]string) *Span {
+	_, span := t.Start(context.Background(), name, attributes)
+	if span != nil {
+		phaseMu.Lock()
+		currentPhase = span
+		phaseMu.Unlock()
+	}
+	return span
+}
+
+func openPhase() *Span {
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+	return currentPhase
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s != nil {
+		s.attributes[key] = value
+	}
+}
+
+// Traceparent returns the W3C traceparent header value for s, to hand to
+// child processes in the TRACEPARENT environment variable.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.ctx.traceID + "-" + s.ctx.spanID + "-01"
+}
+
+// End finishes the span, marking it failed when err is not nil. It is
+// exported with its phase, or straight away when no phase is open.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.tracer.export(s, time.Now(), err)
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+func (t *Tracer) export(s *Span, end time.Time, err error) {
+	span := t.otlpSpan(s, end, err)
+
+	phaseMu.Lock()
+	if currentPhase != nil && currentPhase != s {
+		pendingSpans = append(pendingSpans, pendingSpan{t, span})
+		phaseMu.Unlock()
+		return
+	}
+	batch := append(pendingSpans, pendingSpan{t, span})
+	if currentPhase == s {
+		currentPhase = nil
+	}
+	pendingSpans = nil
+	phaseMu.Unlock()
+
+	// one request per collector
+	var tracers []*Tracer
+	spans := map[string][]otlpSpan{}
+	for _, pending := range batch {
+		key := pending.tracer.endpoint + "\n" + pending.tracer.service
+		if _, found := spans[key]; !found {
+			tracers = append(tracers, pending.tracer)
+		}
+		spans[key] = append(spans[key], pending.span)
+	}
+	for _, tracer := range tracers {
+		tracer.send(spans[tracer.endpoint+"\n"+tracer.service])
+	}
+}
+
+func (t *Tracer) otlpSpan(s *Span, end time.Time, err error) otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.ctx.traceID,
+		SpanID:            s.ctx.spanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              1, // internal
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attributes),
+	}
+	span.Status.Code = 1 // ok
+	if err != nil {
+		span.Status.Code = 2 // error
+		span.Status.Message = RedactURIs(err.Error())
+	}
+	return span
+}
+
+func (t *Tracer) send(spans []otlpSpan) {
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]string{"service.name": t.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "libbuildpack"},
+				"spans": spans,
+			}},
+		}},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	var keys []string
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var result []otlpAttribute
+	for _, k := range keys {
+		a := otlpAttribute{Key: k}
+		a.Value.StringValue = attributes[k]
+		result = append(result, a)
+	}
+	return result
+}
+
+// parseTraceparent reads a W3C "00-<trace id>-<parent id>-<flags>" header,
+// returning an empty spanContext when it is missing or malformed.
+func parseTraceparent(header string) spanContext {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return spanContext{}
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return spanContext{}
+	}
+	return spanContext{traceID: parts[1], spanID: parts[2]}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
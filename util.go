@@ -3,20 +3,20 @@ package libbuildpack
 import (
 	"archive/tar"
 	"archive/zip"
+	"bytes"
 	"compress/gzip"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
-	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -25,9 +25,18 @@ func init() {
 }
 
 func MoveDirectory(srcDir, destDir string) error {
+	return MoveDirectoryWithProgress(srcDir, destDir, nil)
+}
+
+// MoveDirectoryWithProgress behaves like MoveDirectory. When a rename fails
+// because srcDir and destDir are on different filesystems, entries are copied
+// with their modes and modification times, verified against the contents of
+// srcDir and then removed from it. progress, if not nil, is called with each
+// file copied that way.
+func MoveDirectoryWithProgress(srcDir, destDir string, progress func(path string, size int64)) error {
 	destExists, _ := FileExists(destDir)
 	if !destExists {
-		return os.Rename(srcDir, destDir)
+		return renameOrCopy(srcDir, destDir, progress)
 	}
 
 	files, err := ioutil.ReadDir(srcDir)
@@ -46,12 +55,12 @@ func MoveDirectory(srcDir, destDir string) error {
 					return err
 				}
 			}
-			if err = os.Rename(src, dest); err != nil {
+			if err = renameOrCopy(src, dest, progress); err != nil {
 				return err
 			}
 		} else {
 			if f.IsDir() {
-				if err = MoveDirectory(src, dest); err != nil {
+				if err = MoveDirectoryWithProgress(src, dest, progress); err != nil {
 					return err
 				}
 			}
@@ -60,6 +69,157 @@ func MoveDirectory(srcDir, destDir string) error {
 	return nil
 }
 
+func renameOrCopy(src, dest string, progress func(path string, size int64)) error {
+	err := os.Rename(src, dest)
+	if err == nil || !isCrossDeviceError(err) {
+		return err
+	}
+
+	if _, err := os.Lstat(dest); err == nil {
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
+	}
+
+	if err := copyPreservingAttributes(src, dest, progress); err != nil {
+		os.RemoveAll(dest)
+		return fmt.Errorf("could not copy %s to %s across filesystems: %v", src, dest, err)
+	}
+
+	if err := verifyCopy(src, dest); err != nil {
+		os.RemoveAll(dest)
+		return fmt.Errorf("could not verify copy of %s to %s: %v", src, dest, err)
+	}
+
+	return removeAllWritable(src)
+}
+
+// removeAllWritable is os.RemoveAll, first making read-only directories
+// under path writable so that their children can be removed.
+func removeAllWritable(path string) error {
+	if err := os.RemoveAll(path); err == nil {
+		return nil
+	}
+	filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(path, info.Mode().Perm()|0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+func isCrossDeviceError(err error) bool {
+	if linkErr, ok := err.(*os.LinkError); ok {
+		return linkErr.Err == syscall.EXDEV
+	}
+	return false
+}
+
+func copyPreservingAttributes(src, dest string, progress func(path string, size int64)) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return moveSymlinks(src, dest)
+	case info.IsDir():
+		// writable until its children are copied, as the source may not be
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return err
+		}
+
+		files, err := ioutil.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := copyPreservingAttributes(filepath.Join(src, f.Name()), filepath.Join(dest, f.Name()), progress); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Chmod(dest, info.Mode().Perm()); err != nil {
+			return err
+		}
+	default:
+		fh, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		err = writeToFile(fh, dest, info.Mode())
+		fh.Close()
+		if err != nil {
+			return err
+		}
+
+		if err := os.Chmod(dest, info.Mode()); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(dest, info.Size())
+		}
+	}
+
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// verifyCopy checks that dest has every file of src, with the same type and
+// contents, and the same target for symlinks.
+func verifyCopy(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		destInfo, err := os.Lstat(filepath.Join(dest, relPath))
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeType != destInfo.Mode()&os.ModeType {
+			return fmt.Errorf("%s has type %s, expected %s", filepath.Join(dest, relPath), destInfo.Mode()&os.ModeType, info.Mode()&os.ModeType)
+		}
+		switch {
+		case info.Mode().IsRegular():
+			if info.Size() != destInfo.Size() {
+				return fmt.Errorf("%s has size %d, expected %d", filepath.Join(dest, relPath), destInfo.Size(), info.Size())
+			}
+			expected, err := fileChecksum(path, "sha256")
+			if err != nil {
+				return err
+			}
+			actual, err := fileChecksum(filepath.Join(dest, relPath), "sha256")
+			if err != nil {
+				return err
+			}
+			if actual != expected {
+				return fmt.Errorf("%s has sha256 %s, expected %s", filepath.Join(dest, relPath), actual, expected)
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			expected, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			actual, err := os.Readlink(filepath.Join(dest, relPath))
+			if err != nil {
+				return err
+			}
+			if actual != expected {
+				return fmt.Errorf("%s links to %s, expected %s", filepath.Join(dest, relPath), actual, expected)
+			}
+		}
+		return nil
+	})
+}
+
 // CopyDirectory copies srcDir to destDir
 func CopyDirectory(srcDir, destDir string) error {
 	destExists, _ := FileExists(destDir)
@@ -119,6 +279,10 @@ func moveSymlinks(src, dest string) error {
 
 // ExtractZip extracts zipfile to destDir
 func ExtractZip(zipfile, destDir string) error {
+	return extractZip(zipfile, destDir, ExtractOptions{})
+}
+
+func extractZip(zipfile, destDir string, opts ExtractOptions) error {
 	r, err := zip.OpenReader(zipfile)
 	if err != nil {
 		return err
@@ -126,7 +290,26 @@ func ExtractZip(zipfile, destDir string) error {
 	defer r.Close()
 
 	for _, f := range r.File {
-		path := filepath.Join(destDir, filepath.Clean(f.Name))
+		name, ok, err := opts.target(f.Name)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		path := filepath.Join(destDir, filepath.Clean(name))
+		if !opts.AllowUnsafePaths {
+			if err := confined(destDir, path, f.Name); err != nil {
+				return err
+			}
+		}
+
+		mode := f.Mode()
+		if !mode.IsDir() && !mode.IsRegular() && mode&os.ModeSymlink == 0 {
+			if err := opts.irregular(f.Name); err != nil {
+				return err
+			}
+			continue
+		}
 
 		rc, err := f.Open()
 		if err != nil {
@@ -134,12 +317,17 @@ func ExtractZip(zipfile, destDir string) error {
 		}
 
 		if f.FileInfo().IsDir() {
-			err = os.MkdirAll(path, f.Mode())
+			err = os.MkdirAll(path, opts.dirMode(f.Mode()))
+		} else if f.Mode()&os.ModeSymlink != 0 {
+			err = extractZipSymlink(rc, destDir, path, opts)
 		} else {
-			err = writeToFile(rc, path, f.Mode())
+			err = writeToFile(rc, path, opts.fileMode(f.Mode()))
 		}
 
 		rc.Close()
+		if err == nil {
+			err = opts.apply(path, f.FileInfo().IsDir())
+		}
 		if err != nil {
 			return err
 		}
@@ -148,6 +336,100 @@ func ExtractZip(zipfile, destDir string) error {
 	return nil
 }
 
+func extractZipSymlink(rc io.Reader, destDir, path string, opts ExtractOptions) error {
+	linkname, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return symlink(destDir, path, string(linkname), opts)
+}
+
+// symlink creates a symlink at path to linkname, refusing links that lead
+// outside destDir unless opts allows unsafe paths.
+func symlink(destDir, path, linkname string, opts ExtractOptions) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if !opts.AllowUnsafePaths {
+		if filepath.IsAbs(linkname) {
+			return fmt.Errorf("cannot link to an absolute path when extracting archives")
+		}
+
+		fullLink, err := filepath.Abs(filepath.Join(filepath.Dir(path), linkname))
+		if err != nil {
+			return err
+		}
+		fullDest, err := filepath.Abs(destDir)
+		if err != nil {
+			return err
+		}
+		// check that the relative link does not escape the destination dir,
+		// also once the links already extracted along it are followed
+		if !withinDir(fullDest, fullLink) || confined(destDir, filepath.Dir(path)+string(filepath.Separator)+linkname, linkname) != nil {
+			return fmt.Errorf("cannot link outside of the destination diretory when extracting archives")
+		}
+	}
+
+	return os.Symlink(linkname, path)
+}
+
+// confined refuses to extract the archive entry called name to path if the
+// links already extracted lead path outside destDir. Paths through links
+// that lead nowhere yet are refused too, as where they lead can still
+// change.
+func confined(destDir, path, name string) error {
+	dest, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	if dest, err = filepath.EvalSymlinks(dest); os.IsNotExist(err) {
+		// nothing has been extracted yet
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(path) {
+		// not filepath.Abs, which would clean away the ".." elements that
+		// climb out of links
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		path = wd + string(filepath.Separator) + path
+	}
+
+	existing, rest := path, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if withinDir(dest, filepath.Join(resolved, rest)) {
+				return nil
+			}
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if _, err := os.Lstat(existing); err == nil {
+			// a link that leads nowhere
+			break
+		}
+
+		i := strings.LastIndex(existing, string(filepath.Separator))
+		if i <= 0 {
+			break
+		}
+		rest = filepath.Join(existing[i+1:], rest)
+		existing = existing[:i]
+	}
+	return fmt.Errorf("cannot extract %s outside of the destination directory", name)
+}
+
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 func ExtractTarXz(tarfile, destDir string) error {
 	file, err := os.Open(tarfile)
 	if err != nil {
@@ -156,13 +438,131 @@ func ExtractTarXz(tarfile, destDir string) error {
 	defer file.Close()
 	xz := xzReader(file)
 	defer xz.Close()
-	return extractTar(xz, destDir)
+	return extractTar(xz, destDir, ExtractOptions{})
+}
+
+// ExtractTarZst extracts tar.zst to destDir. It needs the zstd command.
+func ExtractTarZst(tarfile, destDir string) error {
+	file, err := os.Open(tarfile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	zst := zstdReader(file)
+	defer zst.Close()
+	return extractTar(zst, destDir, ExtractOptions{})
+}
+
+// ExtractArchive extracts a zip, tar, tar.gz, tar.xz or tar.zst file to
+// destDir, telling them apart by their magic bytes. Files without
+// recognisable magic bytes are told apart by the extension of name, and
+// otherwise treated as tar.gz.
+func ExtractArchive(file, name, destDir string) error {
+	return ExtractArchiveWithOptions(file, name, destDir, ExtractOptions{})
+}
+
+// ExtractArchiveWithOptions is ExtractArchive, adjusting the extracted paths,
+// modes and owners as opts asks.
+func ExtractArchiveWithOptions(file, name, destDir string, opts ExtractOptions) error {
+	format, err := archiveFormat(file, name)
+	if err != nil {
+		return err
+	}
+	if format == "zip" {
+		return extractZip(file, destDir, opts)
+	}
+
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var src io.Reader = fh
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		src = gz
+	case "tar.xz":
+		xz := xzReader(fh)
+		defer xz.Close()
+		src = xz
+	case "tar.zst":
+		zst := zstdReader(fh)
+		defer zst.Close()
+		src = zst
+	}
+	return extractTar(src, destDir, opts)
+}
+
+// ArchiveFormat tells the format of an archive from its magic bytes: "zip",
+// "tar", "tar.gz", "tar.xz" or "tar.zst", or "" for any other file.
+func ArchiveFormat(file string) (string, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	header := make([]byte, 262)
+	n, err := io.ReadFull(fh, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return "tar.gz", nil
+	case bytes.HasPrefix(header, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return "tar.xz", nil
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return "tar.zst", nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return "zip", nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return "tar", nil
+	}
+	return "", nil
+}
+
+func archiveFormat(file, name string) (string, error) {
+	if format, err := ArchiveFormat(file); err != nil || format != "" {
+		return format, err
+	}
+
+	for _, ext := range [][2]string{
+		{".zip", "zip"},
+		{".tar.xz", "tar.xz"},
+		{".txz", "tar.xz"},
+		{".tar.zst", "tar.zst"},
+		{".tzst", "tar.zst"},
+		{".tar", "tar"},
+	} {
+		if strings.HasSuffix(name, ext[0]) {
+			return ext[1], nil
+		}
+	}
+	return "tar.gz", nil
 }
 
 func xzReader(r io.Reader) io.ReadCloser {
+	return commandReader(r, "xz", "--decompress", "--stdout")
+}
+
+func zstdReader(r io.Reader) io.ReadCloser {
+	return commandReader(r, "zstd", "--decompress", "--stdout")
+}
+
+// commandReader streams r through a decompression program.
+func commandReader(r io.Reader, program string, args ...string) io.ReadCloser {
 	rpipe, wpipe := io.Pipe()
 
-	cmd := exec.Command("xz", "--decompress", "--stdout")
+	cmd := exec.Command(program, args...)
 	cmd.Stdin = r
 	cmd.Stdout = wpipe
 
@@ -203,7 +603,7 @@ func ExtractTarGz(tarfile, destDir string) error {
 		return err
 	}
 	defer gz.Close()
-	return extractTar(gz, destDir)
+	return extractTar(gz, destDir, ExtractOptions{})
 }
 
 // CopyFile copies source file to destFile, creating all intermediate directories in destFile
@@ -245,68 +645,87 @@ func RandString(n int) string {
 	return string(b)
 }
 
-func extractTar(src io.Reader, destDir string) error {
+func extractTar(src io.Reader, destDir string, opts ExtractOptions) error {
 	tr := tar.NewReader(src)
 
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
 			break
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			// metadata for the whole archive, like the commit of a git
+			// archive, rather than an entry to extract
+			continue
+		}
+		name, ok, err := opts.target(hdr.Name)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		path := filepath.Join(destDir, cleanPath(name))
+		if !opts.AllowUnsafePaths {
+			if err := confined(destDir, path, hdr.Name); err != nil {
+				return err
+			}
 		}
-		path := filepath.Join(destDir, cleanPath(hdr.Name))
 
 		fi := hdr.FileInfo()
 		if fi.IsDir() {
-			if err := os.MkdirAll(path, hdr.FileInfo().Mode()); err != nil {
+			if err := os.MkdirAll(path, opts.dirMode(hdr.FileInfo().Mode())); err != nil {
 				return err
 			}
 		} else if hdr.Typeflag == tar.TypeSymlink {
-			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			if err := symlink(destDir, path, hdr.Linkname, opts); err != nil {
 				return err
 			}
-
-			if filepath.IsAbs(hdr.Linkname) {
-				return fmt.Errorf("cannot link to an absolute path when extracting archives")
-			}
-
-			fullLink, err := filepath.Abs(filepath.Join(filepath.Dir(path), hdr.Linkname))
+		} else if hdr.Typeflag == tar.TypeLink {
+			linkname, ok, err := opts.target(hdr.Linkname)
 			if err != nil {
 				return err
+			} else if !ok {
+				return fmt.Errorf("cannot link to %s, which is not extracted", hdr.Linkname)
 			}
-
-			fullDest, err := filepath.Abs(destDir)
+			originalPath := filepath.Join(destDir, cleanPath(linkname))
+			if !opts.AllowUnsafePaths {
+				if err := confined(destDir, originalPath, hdr.Linkname); err != nil {
+					return err
+				}
+			}
+			file, err := os.Open(originalPath)
 			if err != nil {
 				return err
 			}
 
-			// check that the relative link does not escape the destination dir
-			if !strings.HasPrefix(fullLink, fullDest) {
-				return fmt.Errorf("cannot link outside of the destination diretory when extracting archives")
-			}
-
-			if err = os.Symlink(hdr.Linkname, path); err != nil {
-				return err
-			}
-		} else if hdr.Typeflag == tar.TypeLink {
-			originalPath := filepath.Join(destDir, cleanPath(hdr.Linkname))
-			file, err := os.Open(originalPath)
+			err = writeToFile(file, path, opts.fileMode(hdr.FileInfo().Mode()))
+			file.Close()
 			if err != nil {
 				return err
 			}
 
-			if err := writeToFile(file, path, hdr.FileInfo().Mode()); err != nil {
+		} else if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			if err := opts.irregular(hdr.Name); err != nil {
 				return err
 			}
-
+			continue
 		} else {
-			if err := writeToFile(tr, path, hdr.FileInfo().Mode()); err != nil {
+			if err := writeToFile(tr, path, opts.fileMode(hdr.FileInfo().Mode())); err != nil {
 				return err
 			}
 		}
+
+		if err := opts.apply(path, fi.IsDir()); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// filterURI redacts the user info and credential query parameters, such as
+// the signature of a presigned url, of rawURL.
 func filterURI(rawURL string) (string, error) {
 	unsafeURL, err := url.Parse(rawURL)
 
@@ -316,47 +735,108 @@ func filterURI(rawURL string) (string, error) {
 
 	var safeURL string
 
-	if unsafeURL.User == nil {
+	redactedQuery := redactQuery(unsafeURL.RawQuery)
+	if unsafeURL.User == nil && redactedQuery == unsafeURL.RawQuery {
 		safeURL = rawURL
 		return safeURL, nil
 	}
 
-	redactedUserInfo := url.UserPassword("-redacted-", "-redacted-")
-
-	unsafeURL.User = redactedUserInfo
+	if unsafeURL.User != nil {
+		unsafeURL.User = url.UserPassword("-redacted-", "-redacted-")
+	}
+	unsafeURL.RawQuery = redactedQuery
 	safeURL = unsafeURL.String()
 
 	return safeURL, nil
 }
 
 func CheckSha256(filePath, expectedSha256 string) error {
-	content, err := ioutil.ReadFile(filePath)
+	actualSha256, err := fileChecksum(filePath, "sha256")
 	if err != nil {
 		return err
 	}
 
-	sum := sha256.Sum256(content)
-
-	actualSha256 := hex.EncodeToString(sum[:])
-
 	if actualSha256 != expectedSha256 {
 		return fmt.Errorf("dependency sha256 mismatch: expected sha256 %s, actual sha256 %s", expectedSha256, actualSha256)
 	}
 	return nil
 }
 
-func downloadFile(url, destFile string) error {
-	resp, err := http.Get(url)
+// VerifyFileChecksum checks that the file at path has the expected hex
+// encoded checksum. algorithm is one of ChecksumAlgorithms.
+func VerifyFileChecksum(path, algorithm, expected string) error {
+	actual, err := fileChecksum(path, algorithm)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("could not download: %d", resp.StatusCode)
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%s %s mismatch: expected %s, actual %s", path, algorithm, expected, actual)
+	}
+	return nil
+}
+
+// HashDirectory returns a hex encoded sha256 over the relative path, mode and
+// contents (or symlink target) of everything under dir. Two directories with
+// the same layout and contents hash the same regardless of where they live.
+func HashDirectory(dir string) (string, error) {
+	h, err := newHash("sha256")
+	if err != nil {
+		return "", err
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", filepath.ToSlash(relPath), info.Mode())
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(h, target)
+		case info.Mode().IsRegular():
+			fh, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer fh.Close()
+			if _, err := io.Copy(h, fh); err != nil {
+				return err
+			}
+		}
+		h.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileChecksum(path, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", err
 	}
+	defer fh.Close()
 
-	return writeToFile(resp.Body, destFile, 0666)
+	if _, err := io.Copy(h, fh); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func writeToFile(source io.Reader, destFile string, mode os.FileMode) error {
@@ -1,13 +1,18 @@
 package libbuildpack_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/cloudfoundry/libbuildpack"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -85,6 +90,49 @@ var _ = Describe("Util", func() {
 				Expect(filepath.Join(tmpdir, "passwdLink")).To(BeADirectory())
 			})
 		})
+
+		Context("with unsafe entries", func() {
+			var archive string
+			BeforeEach(func() {
+				archive = filepath.Join(tmpdir, "unsafe.zip")
+			})
+
+			writeZip := func(name string, mode os.FileMode, content string) {
+				fh, err := os.Create(archive)
+				Expect(err).To(BeNil())
+				defer fh.Close()
+				zw := zip.NewWriter(fh)
+				hdr := &zip.FileHeader{Name: name}
+				hdr.SetMode(mode)
+				w, err := zw.CreateHeader(hdr)
+				Expect(err).To(BeNil())
+				_, err = w.Write([]byte(content))
+				Expect(err).To(BeNil())
+				Expect(zw.Close()).To(Succeed())
+			}
+
+			It("refuses entries outside the destination", func() {
+				writeZip("../evil.txt", 0644, "evil")
+				err := libbuildpack.ExtractZip(archive, filepath.Join(tmpdir, "dest"))
+				Expect(err).To(MatchError("cannot extract ../evil.txt outside of the destination directory"))
+				Expect(filepath.Join(tmpdir, "evil.txt")).ToNot(BeAnExistingFile())
+			})
+
+			It("refuses symlinks outside the destination", func() {
+				writeZip("link", os.ModeSymlink|0777, "../../etc/passwd")
+				err := libbuildpack.ExtractZip(archive, filepath.Join(tmpdir, "dest"))
+				Expect(err).To(MatchError("cannot link outside of the destination diretory when extracting archives"))
+			})
+
+			It("extracts symlinks inside the destination", func() {
+				if runtime.GOOS == "windows" {
+					Skip("Creating symlinks requires privileges on Windows")
+				}
+				writeZip("bin/link", os.ModeSymlink|0777, "../file.txt")
+				Expect(libbuildpack.ExtractZip(archive, filepath.Join(tmpdir, "dest"))).To(Succeed())
+				Expect(os.Readlink(filepath.Join(tmpdir, "dest", "bin", "link"))).To(Equal("../file.txt"))
+			})
+		})
 	})
 
 	Describe("GetBuildpackDir", func() {
@@ -183,6 +231,95 @@ var _ = Describe("Util", func() {
 			})
 		})
 
+		Context("a tar.zst file", func() {
+			It("extracts the whole file", func() {
+				Expect(libbuildpack.ExtractTarZst("fixtures/thing.tar.zst", tmpdir)).To(Succeed())
+
+				Expect(ioutil.ReadFile(filepath.Join(tmpdir, "root.txt"))).To(Equal([]byte("root\n")))
+				Expect(ioutil.ReadFile(filepath.Join(tmpdir, "thing", "bin", "file2.exe"))).To(Equal([]byte("progam2\n")))
+			})
+		})
+
+		Context("any archive", func() {
+			DescribeTable("detects the format from the magic bytes",
+				func(fixture string) {
+					archive := filepath.Join(tmpdir, "archive.tgz")
+					Expect(libbuildpack.CopyFile(fixture, archive)).To(Succeed())
+					destDir := filepath.Join(tmpdir, "dest")
+
+					Expect(libbuildpack.ExtractArchive(archive, "https://example.com/archive.tgz", destDir)).To(Succeed())
+					Expect(ioutil.ReadFile(filepath.Join(destDir, "thing", "bin", "file2.exe"))).To(Equal([]byte("progam2\n")))
+				},
+				Entry("tar.gz", "fixtures/thing.tgz"),
+				Entry("zip", "fixtures/thing.zip"),
+				Entry("tar.zst", "fixtures/thing.tar.zst"),
+			)
+
+			Describe("with options", func() {
+				var destDir string
+				BeforeEach(func() { destDir = filepath.Join(tmpdir, "dest") })
+
+				for _, fixture := range []string{"fixtures/thing.tgz", "fixtures/thing.zip"} {
+					fixture := fixture
+
+					It("strips leading path components of "+fixture, func() {
+						Expect(libbuildpack.ExtractArchiveWithOptions(fixture, fixture, destDir, libbuildpack.ExtractOptions{StripComponents: 1})).To(Succeed())
+
+						Expect(filepath.Join(destDir, "bin", "file2.exe")).To(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "file1.txt")).To(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "root.txt")).ToNot(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "thing")).ToNot(BeADirectory())
+					})
+
+					It("extracts only included paths of "+fixture, func() {
+						Expect(libbuildpack.ExtractArchiveWithOptions(fixture, fixture, destDir, libbuildpack.ExtractOptions{Include: []string{"thing/bin"}})).To(Succeed())
+
+						Expect(filepath.Join(destDir, "thing", "bin", "file2.exe")).To(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "thing", "file1.txt")).ToNot(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "root.txt")).ToNot(BeAnExistingFile())
+					})
+
+					It("skips excluded paths of "+fixture, func() {
+						Expect(libbuildpack.ExtractArchiveWithOptions(fixture, fixture, destDir, libbuildpack.ExtractOptions{Exclude: []string{"*.txt", "thing/*.txt"}})).To(Succeed())
+
+						Expect(filepath.Join(destDir, "thing", "bin", "file2.exe")).To(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "thing", "file1.txt")).ToNot(BeAnExistingFile())
+						Expect(filepath.Join(destDir, "root.txt")).ToNot(BeAnExistingFile())
+					})
+				}
+
+				It("sets the file and directory modes", func() {
+					if runtime.GOOS == "windows" {
+						Skip(windowsFileModeWarning)
+					}
+					Expect(libbuildpack.ExtractArchiveWithOptions("fixtures/thing.tgz", "thing.tgz", destDir, libbuildpack.ExtractOptions{FileMode: 0600, DirMode: 0700})).To(Succeed())
+
+					info, err := os.Stat(filepath.Join(destDir, "thing", "bin", "file2.exe"))
+					Expect(err).To(BeNil())
+					Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+					info, err = os.Stat(filepath.Join(destDir, "thing", "bin"))
+					Expect(err).To(BeNil())
+					Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+				})
+			})
+
+			It("extracts uncompressed tar files", func() {
+				archive := filepath.Join(tmpdir, "thing.tar")
+				fh, err := os.Open("fixtures/thing.tgz")
+				Expect(err).To(BeNil())
+				defer fh.Close()
+				gz, err := gzip.NewReader(fh)
+				Expect(err).To(BeNil())
+				tarContents, err := ioutil.ReadAll(gz)
+				Expect(err).To(BeNil())
+				Expect(ioutil.WriteFile(archive, tarContents, 0644)).To(Succeed())
+				destDir := filepath.Join(tmpdir, "dest")
+
+				Expect(libbuildpack.ExtractArchive(archive, archive, destDir)).To(Succeed())
+				Expect(ioutil.ReadFile(filepath.Join(destDir, "root.txt"))).To(Equal([]byte("root\n")))
+			})
+		})
+
 		Context("with a valid tar file", func() {
 			It("extracts a file at the root", func() {
 				err = libbuildpack.ExtractTarGz("fixtures/thing.tgz", tmpdir)
@@ -226,6 +363,15 @@ var _ = Describe("Util", func() {
 				Expect(err).To(BeNil())
 				Expect(fi.Mode() & os.ModeSymlink).ToNot(Equal(0))
 			})
+			It("skips the global header of a git archive", func() {
+				err = libbuildpack.ExtractTarGz("fixtures/git_archive.tgz", tmpdir)
+				Expect(err).To(BeNil())
+
+				Expect(ioutil.ReadFile(filepath.Join(tmpdir, "thing-1.0.0", "root.txt"))).To(Equal([]byte("root\n")))
+				Expect(ioutil.ReadFile(filepath.Join(tmpdir, "thing-1.0.0", "thing", "bin", "file2.exe"))).To(Equal([]byte("progam2\n")))
+				Expect(filepath.Join(tmpdir, "pax_global_header")).NotTo(BeAnExistingFile())
+			})
+
 			It("handles malicious global symlinks", func() {
 				err = libbuildpack.ExtractTarGz("fixtures/maliciousGlobalSymlink.tar.gz", tmpdir)
 				Expect(err).ToNot(BeNil())
@@ -252,6 +398,139 @@ var _ = Describe("Util", func() {
 				Expect(err).ToNot(BeNil())
 			})
 		})
+
+		Context("with unsafe entries", func() {
+			var (
+				archive string
+				destDir string
+			)
+			BeforeEach(func() {
+				archive = filepath.Join(tmpdir, "unsafe.tgz")
+				destDir = filepath.Join(tmpdir, "dest")
+			})
+
+			writeTarGz := func(headers ...*tar.Header) {
+				fh, err := os.Create(archive)
+				Expect(err).To(BeNil())
+				defer fh.Close()
+				gw := gzip.NewWriter(fh)
+				tw := tar.NewWriter(gw)
+				for _, hdr := range headers {
+					if hdr.Typeflag == tar.TypeReg {
+						hdr.Size = int64(len(hdr.Name))
+					}
+					Expect(tw.WriteHeader(hdr)).To(Succeed())
+					if hdr.Typeflag == tar.TypeReg {
+						_, err := tw.Write([]byte(hdr.Name))
+						Expect(err).To(BeNil())
+					}
+				}
+				Expect(tw.Close()).To(Succeed())
+				Expect(gw.Close()).To(Succeed())
+			}
+
+			It("refuses entries outside the destination", func() {
+				writeTarGz(&tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644})
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).To(MatchError("cannot extract ../evil.txt outside of the destination directory"))
+				Expect(filepath.Join(tmpdir, "evil.txt")).ToNot(BeAnExistingFile())
+			})
+
+			It("refuses absolute entries", func() {
+				writeTarGz(&tar.Header{Name: "/etc/evil.txt", Typeflag: tar.TypeReg, Mode: 0644})
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).To(MatchError("cannot extract /etc/evil.txt outside of the destination directory"))
+			})
+
+			It("refuses symlinks to siblings sharing the destination's prefix", func() {
+				writeTarGz(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../dest-evil"})
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).ToNot(Succeed())
+				Expect(filepath.Join(destDir, "link")).ToNot(BeAnExistingFile())
+			})
+
+			It("refuses entries reached through chained symlinks", func() {
+				if runtime.GOOS == "windows" {
+					Skip("Creating symlinks requires privileges on Windows")
+				}
+				writeTarGz(
+					&tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+					&tar.Header{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+					&tar.Header{Name: "a/b/c", Typeflag: tar.TypeSymlink, Linkname: ".."},
+					&tar.Header{Name: "a/b/c/pwned.txt", Typeflag: tar.TypeReg, Mode: 0644},
+				)
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).ToNot(Succeed())
+				Expect(filepath.Join(tmpdir, "pwned.txt")).ToNot(BeAnExistingFile())
+				Expect(filepath.Join(destDir, "c")).ToNot(BeAnExistingFile())
+			})
+
+			It("refuses entries written through a symlink already extracted", func() {
+				if runtime.GOOS == "windows" {
+					Skip("Creating symlinks requires privileges on Windows")
+				}
+				writeTarGz(
+					&tar.Header{Name: "d", Typeflag: tar.TypeSymlink, Linkname: "."},
+					&tar.Header{Name: "l", Typeflag: tar.TypeSymlink, Linkname: "d/.."},
+					&tar.Header{Name: "l/pwned.txt", Typeflag: tar.TypeReg, Mode: 0644},
+				)
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).ToNot(Succeed())
+				Expect(filepath.Join(tmpdir, "pwned.txt")).ToNot(BeAnExistingFile())
+			})
+
+			It("follows symlinks that stay inside the destination", func() {
+				if runtime.GOOS == "windows" {
+					Skip("Creating symlinks requires privileges on Windows")
+				}
+				writeTarGz(
+					&tar.Header{Name: "lib/", Typeflag: tar.TypeDir, Mode: 0755},
+					&tar.Header{Name: "current", Typeflag: tar.TypeSymlink, Linkname: "lib"},
+					&tar.Header{Name: "current/file.txt", Typeflag: tar.TypeReg, Mode: 0644},
+				)
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).To(Succeed())
+				Expect(filepath.Join(destDir, "lib", "file.txt")).To(BeAnExistingFile())
+			})
+
+			It("refuses hard links outside the destination", func() {
+				writeTarGz(&tar.Header{Name: "passwd", Typeflag: tar.TypeLink, Linkname: "../../etc/passwd"})
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).To(MatchError("cannot extract ../../etc/passwd outside of the destination directory"))
+			})
+
+			It("extracts hard links inside the destination", func() {
+				writeTarGz(
+					&tar.Header{Name: "bin/file.txt", Typeflag: tar.TypeReg, Mode: 0644},
+					&tar.Header{Name: "bin/link.txt", Typeflag: tar.TypeLink, Linkname: "bin/file.txt"},
+				)
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).To(Succeed())
+				Expect(ioutil.ReadFile(filepath.Join(destDir, "bin", "link.txt"))).To(Equal([]byte("bin/file.txt")))
+			})
+
+			It("refuses device nodes", func() {
+				writeTarGz(&tar.Header{Name: "null", Typeflag: tar.TypeChar, Mode: 0666, Devmajor: 1, Devminor: 3})
+				Expect(libbuildpack.ExtractTarGz(archive, destDir)).To(MatchError("cannot extract null, which is not a regular file"))
+			})
+
+			Context("when unsafe paths are allowed", func() {
+				opts := libbuildpack.ExtractOptions{AllowUnsafePaths: true}
+
+				It("confines entries to the destination", func() {
+					writeTarGz(&tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644})
+					Expect(libbuildpack.ExtractArchiveWithOptions(archive, archive, destDir, opts)).To(Succeed())
+					Expect(filepath.Join(destDir, "evil.txt")).To(BeAnExistingFile())
+				})
+
+				It("keeps symlinks leading outside the destination", func() {
+					if runtime.GOOS == "windows" {
+						Skip("Creating symlinks requires privileges on Windows")
+					}
+					writeTarGz(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../dest-evil"})
+					Expect(libbuildpack.ExtractArchiveWithOptions(archive, archive, destDir, opts)).To(Succeed())
+					Expect(os.Readlink(filepath.Join(destDir, "link"))).To(Equal("../dest-evil"))
+				})
+
+				It("skips device nodes", func() {
+					writeTarGz(&tar.Header{Name: "null", Typeflag: tar.TypeChar, Mode: 0666, Devmajor: 1, Devminor: 3})
+					Expect(libbuildpack.ExtractArchiveWithOptions(archive, archive, destDir, opts)).To(Succeed())
+					Expect(filepath.Join(destDir, "null")).ToNot(BeAnExistingFile())
+				})
+			})
+		})
 	})
 
 	Describe("CopyFile", func() {
@@ -425,6 +704,139 @@ var _ = Describe("Util", func() {
 				})
 			})
 		})
+
+		Context("source directory is on a different filesystem", func() {
+			var shmDir string
+
+			BeforeEach(func() {
+				if exists, _ := libbuildpack.FileExists("/dev/shm"); !exists {
+					Skip("no tmpfs mounted at /dev/shm")
+				}
+				shmDir, err = ioutil.TempDir("/dev/shm", "dir1")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(shmDir)
+			})
+
+			It("copies contents with their attributes and removes the source", func() {
+				modTime := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+				Expect(os.MkdirAll(filepath.Join(shmDir, "src", "inner_dir"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(shmDir, "src", "inner_dir", "test_file"), []byte("contents"), 0750)).To(Succeed())
+				Expect(os.Chtimes(filepath.Join(shmDir, "src", "inner_dir", "test_file"), modTime, modTime)).To(Succeed())
+
+				var progress []string
+				dest := filepath.Join(destDir, "moved")
+				Expect(libbuildpack.MoveDirectoryWithProgress(filepath.Join(shmDir, "src"), dest, func(path string, size int64) {
+					progress = append(progress, path)
+				})).To(Succeed())
+
+				destFile := filepath.Join(dest, "inner_dir", "test_file")
+				Expect(ioutil.ReadFile(destFile)).To(Equal([]byte("contents")))
+				fileInfo, err := os.Stat(destFile)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fileInfo.Mode()).To(Equal(os.FileMode(0750)))
+				Expect(fileInfo.ModTime().Equal(modTime)).To(BeTrue())
+				Expect(progress).To(Equal([]string{destFile}))
+				Expect(filepath.Join(shmDir, "src")).ToNot(BeADirectory())
+			})
+
+			It("copies read-only directories", func() {
+				Expect(os.MkdirAll(filepath.Join(shmDir, "src", "readonly"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(shmDir, "src", "readonly", "test_file"), []byte("contents"), 0644)).To(Succeed())
+				Expect(os.Chmod(filepath.Join(shmDir, "src", "readonly"), 0555)).To(Succeed())
+
+				dest := filepath.Join(destDir, "moved")
+				Expect(libbuildpack.MoveDirectoryWithProgress(filepath.Join(shmDir, "src"), dest, nil)).To(Succeed())
+				defer os.Chmod(filepath.Join(dest, "readonly"), 0755)
+
+				Expect(ioutil.ReadFile(filepath.Join(dest, "readonly", "test_file"))).To(Equal([]byte("contents")))
+				dirInfo, err := os.Stat(filepath.Join(dest, "readonly"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(dirInfo.Mode().Perm()).To(Equal(os.FileMode(0555)))
+				Expect(filepath.Join(shmDir, "src")).ToNot(BeADirectory())
+			})
+		})
+	})
+
+	Describe("VerifyFileChecksum", func() {
+		var file string
+
+		BeforeEach(func() {
+			tmpFile, err := ioutil.TempFile("", "checksum")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = tmpFile.WriteString("some content")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tmpFile.Close()).To(Succeed())
+			file = tmpFile.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(file)
+		})
+
+		It("accepts matching checksums for each algorithm", func() {
+			Expect(libbuildpack.VerifyFileChecksum(file, "md5", "9893532233caff98cd083a116b013c0b")).To(Succeed())
+			Expect(libbuildpack.VerifyFileChecksum(file, "sha1", "94e66df8cd09d410c62d9e0dc59d3a884e458e05")).To(Succeed())
+			Expect(libbuildpack.VerifyFileChecksum(file, "sha256", "290F493C44F5D63D06B374D0A5ABD292FAE38B92CAB2FAE5EFEFE1B0E9347F56")).To(Succeed())
+			Expect(libbuildpack.VerifyFileChecksum(file, "SHA512", "65c256c639bd6dd483be341831c19a3996954901bb2a07f79593f3e3af5692559bdb124d099c2b92ced7e59b7ed02d3b7f42d50740d999bebd91983db2842762")).To(Succeed())
+		})
+
+		It("reports mismatches", func() {
+			Expect(libbuildpack.VerifyFileChecksum(file, "sha256", "abc")).To(MatchError(file + " sha256 mismatch: expected abc, actual 290f493c44f5d63d06b374d0a5abd292fae38b92cab2fae5efefe1b0e9347f56"))
+		})
+
+		It("rejects unknown algorithms", func() {
+			Expect(libbuildpack.VerifyFileChecksum(file, "crc32", "abc")).To(MatchError(`unsupported checksum algorithm "crc32"`))
+		})
+	})
+
+	Describe("HashDirectory", func() {
+		var dir1, dir2 string
+
+		BeforeEach(func() {
+			var err error
+			dir1, err = ioutil.TempDir("", "hash1")
+			Expect(err).ToNot(HaveOccurred())
+			dir2, err = ioutil.TempDir("", "hash2")
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, dir := range []string{dir1, dir2} {
+				Expect(os.MkdirAll(filepath.Join(dir, "vendor"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(dir, "vendor", "module.go"), []byte("package module"), 0644)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir1)
+			os.RemoveAll(dir2)
+		})
+
+		hashes := func() (string, string) {
+			hash1, err := libbuildpack.HashDirectory(dir1)
+			Expect(err).ToNot(HaveOccurred())
+			hash2, err := libbuildpack.HashDirectory(dir2)
+			Expect(err).ToNot(HaveOccurred())
+			return hash1, hash2
+		}
+
+		It("hashes identical trees the same", func() {
+			hash1, hash2 := hashes()
+			Expect(hash1).To(Equal(hash2))
+		})
+
+		It("hashes trees with different contents differently", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir2, "vendor", "module.go"), []byte("package other"), 0644)).To(Succeed())
+			hash1, hash2 := hashes()
+			Expect(hash1).ToNot(Equal(hash2))
+		})
+
+		It("hashes trees with different layouts differently", func() {
+			Expect(os.Rename(filepath.Join(dir2, "vendor", "module.go"), filepath.Join(dir2, "vendor", "renamed.go"))).To(Succeed())
+			hash1, hash2 := hashes()
+			Expect(hash1).ToNot(Equal(hash2))
+		})
 	})
 
 	Describe("FileExists", func() {
@@ -0,0 +1,30 @@
+package libbuildpack
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+)
+
+// Version is the version of libbuildpack and of the buildpack-packager built
+// from it. Manifests using features newer than some release set
+// min_packager_version so that older tooling refuses them instead of
+// silently ignoring what it does not understand.
+const Version = "1.3.0"
+
+// CheckMinVersion fails if minVersion, a manifest's min_packager_version, is
+// newer than Version.
+func CheckMinVersion(minVersion string) error {
+	if minVersion == "" {
+		return nil
+	}
+
+	min, err := semver.NewVersion(minVersion)
+	if err != nil {
+		return fmt.Errorf("invalid min_packager_version %q: %v", minVersion, err)
+	}
+	if min.GreaterThan(semver.MustParse(Version)) {
+		return fmt.Errorf("manifest requires libbuildpack %s or newer (min_packager_version), but this is %s; upgrade the buildpack-packager or libbuildpack", minVersion, Version)
+	}
+	return nil
+}
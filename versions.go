@@ -3,6 +3,7 @@ package libbuildpack
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	semver2 "github.com/Masterminds/semver"
 	semver1 "github.com/blang/semver"
@@ -98,3 +99,44 @@ func matchSemver2(constraint string, versions []string) ([]string, error) {
 
 	return []string{}, fmt.Errorf("no match found for %s in %v", constraint, versions)
 }
+
+// normalizeConstraint turns space separated ranges such as ">=14.0.0 <16"
+// into the comma separated form Masterminds/semver expects, leaving
+// operators followed by a space and hyphen ranges intact. Partial versions
+// after < are padded, as Masterminds/semver would otherwise read <16 as
+// anything below 17.
+func normalizeConstraint(constraint string) string {
+	var groups []string
+	for _, group := range strings.Split(constraint, "||") {
+		var parts []string
+		tokens := strings.Fields(strings.Replace(group, ",", " ", -1))
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			if strings.Trim(token, "=<>!~^") == "" && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+			if i+2 < len(tokens) && tokens[i+1] == "-" {
+				token += " - " + tokens[i+2]
+				i += 2
+			}
+			parts = append(parts, padLessThan(token))
+		}
+		groups = append(groups, strings.Join(parts, ", "))
+	}
+	return strings.Join(groups, " || ")
+}
+
+func padLessThan(token string) string {
+	if !strings.HasPrefix(token, "<") || strings.HasPrefix(token, "<=") {
+		return token
+	}
+	version := strings.TrimSpace(token[1:])
+	if strings.ContainsAny(version, "-+xX*") {
+		return token
+	}
+	for strings.Count(version, ".") < 2 {
+		version += ".0"
+	}
+	return "<" + version
+}
@@ -2,12 +2,20 @@ package libbuildpack
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 
 	yaml "gopkg.in/yaml.v2"
 )
 
 type YAML struct {
+	// InterpolateEnv expands ${VAR} references when loading, see InterpolateEnv.
+	// Values are quoted or escaped so they are read as the string they are,
+	// and references in comments are left alone.
+	InterpolateEnv bool
+	// Strict makes Load fail on keys that obj has no field for, and on
+	// duplicate keys, to catch misspelt keys.
+	Strict bool
 }
 
 func NewYAML() *YAML {
@@ -20,7 +28,17 @@ func (y *YAML) Load(file string, obj interface{}) error {
 		return err
 	}
 
-	err = yaml.Unmarshal(data, obj)
+	if y.InterpolateEnv {
+		if data, err = interpolateYAML(data); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	unmarshal := yaml.Unmarshal
+	if y.Strict {
+		unmarshal = yaml.UnmarshalStrict
+	}
+	err = unmarshal(data, obj)
 	if err != nil {
 		return err
 	}
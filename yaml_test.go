@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cloudfoundry/libbuildpack"
 	. "github.com/onsi/ginkgo"
@@ -61,6 +62,122 @@ var _ = Describe("YAML", func() {
 				Expect(err).ToNot(BeNil())
 			})
 		})
+
+		Context("with Strict", func() {
+			type manifest struct {
+				Language     string   `yaml:"language"`
+				Dependencies []string `yaml:"dependencies"`
+			}
+
+			It("fails on unknown keys", func() {
+				yaml.Strict = true
+				ioutil.WriteFile(filepath.Join(tmpDir, "typo.yml"), []byte("language: go\ndependecies: [go]\n"), 0666)
+
+				var obj manifest
+				err = yaml.Load(filepath.Join(tmpDir, "typo.yml"), &obj)
+				Expect(err).To(MatchError(ContainSubstring("field dependecies not found")))
+			})
+
+			It("ignores unknown keys when disabled", func() {
+				ioutil.WriteFile(filepath.Join(tmpDir, "typo.yml"), []byte("language: go\ndependecies: [go]\n"), 0666)
+
+				var obj manifest
+				Expect(yaml.Load(filepath.Join(tmpDir, "typo.yml"), &obj)).To(Succeed())
+				Expect(obj).To(Equal(manifest{Language: "go"}))
+			})
+		})
+
+		Context("with InterpolateEnv", func() {
+			BeforeEach(func() {
+				yaml.InterpolateEnv = true
+				os.Setenv("YAML_TEST_PROXY", "http://proxy:8080")
+				os.Unsetenv("YAML_TEST_MISSING")
+				os.Unsetenv("YAML_TEST_OTHER")
+			})
+			AfterEach(func() { os.Unsetenv("YAML_TEST_PROXY") })
+
+			It("expands variables, defaults and escapes", func() {
+				ioutil.WriteFile(filepath.Join(tmpDir, "env.yml"), []byte("proxy: ${YAML_TEST_PROXY}\nuser: ${YAML_TEST_MISSING:-admin}\nliteral: $${YAML_TEST_PROXY}\n"), 0666)
+
+				obj := make(map[string]string)
+				Expect(yaml.Load(filepath.Join(tmpDir, "env.yml"), &obj)).To(Succeed())
+				Expect(obj).To(Equal(map[string]string{"proxy": "http://proxy:8080", "user": "admin", "literal": "${YAML_TEST_PROXY}"}))
+			})
+
+			It("reports every unset variable", func() {
+				ioutil.WriteFile(filepath.Join(tmpDir, "env.yml"), []byte("a: ${YAML_TEST_MISSING}\nb: ${YAML_TEST_OTHER}\n"), 0666)
+
+				obj := make(map[string]string)
+				err = yaml.Load(filepath.Join(tmpDir, "env.yml"), &obj)
+				Expect(err).To(MatchError(ContainSubstring("required environment variables are not set: YAML_TEST_MISSING, YAML_TEST_OTHER")))
+			})
+
+			Context("with values YAML would read differently", func() {
+				BeforeEach(func() {
+					os.Setenv("YAML_TEST_NOTE", "abc #1")
+					os.Setenv("YAML_TEST_INJECT", "x\nadmin: true")
+					os.Setenv("YAML_TEST_QUOTE", `it's "quoted"`)
+					os.Setenv("YAML_TEST_PORT", "8080")
+				})
+				AfterEach(func() {
+					os.Unsetenv("YAML_TEST_NOTE")
+					os.Unsetenv("YAML_TEST_INJECT")
+					os.Unsetenv("YAML_TEST_QUOTE")
+					os.Unsetenv("YAML_TEST_PORT")
+				})
+
+				It("reads every value back as it is", func() {
+					ioutil.WriteFile(filepath.Join(tmpDir, "env.yml"), []byte(strings.Join([]string{
+						"note: ${YAML_TEST_NOTE}",
+						"inject: ${YAML_TEST_INJECT} # ${YAML_TEST_MISSING}",
+						`double: "say ${YAML_TEST_QUOTE}"`,
+						`single: 'say ${YAML_TEST_QUOTE}'`,
+						"url: ${YAML_TEST_PROXY}/path",
+						"block: |",
+						"  first",
+						"  ${YAML_TEST_INJECT}",
+						"# ${YAML_TEST_MISSING}",
+						"",
+					}, "\n")), 0666)
+
+					obj := make(map[string]string)
+					Expect(yaml.Load(filepath.Join(tmpDir, "env.yml"), &obj)).To(Succeed())
+					Expect(obj).To(Equal(map[string]string{
+						"note":   "abc #1",
+						"inject": "x\nadmin: true",
+						"double": `say it's "quoted"`,
+						"single": `say it's "quoted"`,
+						"url":    "http://proxy:8080/path",
+						"block":  "first\nx\nadmin: true\n",
+					}))
+				})
+
+				It("keeps numbers as numbers", func() {
+					ioutil.WriteFile(filepath.Join(tmpDir, "env.yml"), []byte("port: ${YAML_TEST_PORT}\n"), 0666)
+
+					var obj struct{ Port int }
+					Expect(yaml.Load(filepath.Join(tmpDir, "env.yml"), &obj)).To(Succeed())
+					Expect(obj.Port).To(Equal(8080))
+				})
+
+				It("refuses to write a value into an unquoted value it would end", func() {
+					ioutil.WriteFile(filepath.Join(tmpDir, "env.yml"), []byte("url: http://${YAML_TEST_INJECT}/path\n"), 0666)
+
+					obj := make(map[string]string)
+					err = yaml.Load(filepath.Join(tmpDir, "env.yml"), &obj)
+					Expect(err).To(MatchError(ContainSubstring("${YAML_TEST_INJECT} can't be written into an unquoted value")))
+				})
+			})
+
+			It("leaves variables alone when disabled", func() {
+				yaml.InterpolateEnv = false
+				ioutil.WriteFile(filepath.Join(tmpDir, "env.yml"), []byte("proxy: ${YAML_TEST_PROXY}\n"), 0666)
+
+				obj := make(map[string]string)
+				Expect(yaml.Load(filepath.Join(tmpDir, "env.yml"), &obj)).To(Succeed())
+				Expect(obj["proxy"]).To(Equal("${YAML_TEST_PROXY}"))
+			})
+		})
 	})
 
 	Describe("Write", func() {